@@ -8,7 +8,11 @@ package analyzer
 
 import (
 	"go/ast"
+	"go/constant"
 	"go/types"
+	"maps"
+	"path"
+	"regexp"
 	"slices"
 	"sort"
 	"strings"
@@ -31,11 +35,175 @@ type Config struct {
 	// Granularity determines whether capability sets are examined per-package
 	// or per-function when doing comparisons.
 	Granularity Granularity
-	// CapabilitySet is the set of capabilities to use for graph output mode.
+	// CapabilitySet is the set of capabilities to use for graph output mode,
+	// and to compare in compare mode.
 	// If CapabilitySet is nil, all capabilities are used.
 	CapabilitySet *CapabilitySet
 	// OmitPaths disables output of example call paths.
 	OmitPaths bool
+	// Quiet disables printing of the differences found by comparisons, so that
+	// only the returned error indicates whether a difference was found.
+	Quiet bool
+	// IgnoreRemovals makes comparisons report capabilities which are in the
+	// baseline but no longer found without counting them as differences, so
+	// that only new capabilities make the comparison fail.
+	IgnoreRemovals bool
+	// GraphFocus, if non-empty, is a package pattern which restricts the
+	// output of CapabilityGraph to call paths that pass through a function in a
+	// matching package.  See interesting.MatchPackagePattern for the pattern
+	// syntax.
+	GraphFocus string
+	// PerCallSite makes GetCapabilityInfo return a separate CapabilityInfo for
+	// each call site through which a function reaches a capability, rather than
+	// one per function.  It only has an effect with GranularityFunction.
+	PerCallSite bool
+	// ReportExportedEntrypoints restricts the functions reported as having
+	// capabilities to the exported functions and methods of the queried
+	// packages, which are the ones that users of those packages can call.
+	ReportExportedEntrypoints bool
+	// Roots, if non-empty, restricts the functions reported as having
+	// capabilities to the functions of the queried packages with these
+	// names, such as "example.com/cmd/tool.main" or "(*example.com/p.T).Run",
+	// so that the results describe what can be reached from those entry
+	// points rather than from every function of the queried packages.
+	// Functions are named as in capability maps.  RunCapslock returns an
+	// error if one of them names no function of the queried packages.
+	Roots []string
+	// IgnorePaths drops results whose call path matches one of these regular
+	// expressions from the output.  The path is matched as it appears in a
+	// CapabilityInfo's DepPath: the full names of the functions in the path,
+	// separated by spaces, such as "example.com/p.Foo net.Dial".  This can be
+	// used to suppress known false positives which are hard to express with
+	// ignore_edge lines in a capability map.
+	IgnorePaths []*regexp.Regexp
+	// Logf, if non-nil, is used to log details of the analysis which can help
+	// to explain its results, such as calls which could not be rewritten to
+	// make the callgraph more precise.
+	Logf func(format string, args ...any)
+	// DetectPanics makes the analysis report CAPABILITY_PANIC for functions
+	// which call panic, and the functions which call them.  This is not done
+	// by default, since nearly all code can panic.
+	DetectPanics bool
+	// DetectRecursion makes the analysis report CAPABILITY_RECURSION for
+	// functions in recursive cycles of calls, such as a function which calls
+	// itself, which include a function outside the standard library and
+	// TrustedPackages, and for the functions which call them.  Recursion
+	// whose depth is controlled by its input can exhaust the stack.  Each
+	// CapabilityInfo for CAPABILITY_RECURSION has one of the cycles in its
+	// RecursiveCycle field.
+	DetectRecursion bool
+	// CollapseSubcapabilities makes the analysis report each subcapability,
+	// such as CAPABILITY_NETWORK_CLIENT, as its parent capability, for users
+	// who only need the coarser set of capabilities.
+	CollapseSubcapabilities bool
+	// DirectOnly restricts the analysis to capabilities that functions have
+	// directly, by not following calls from one package outside the standard
+	// library into another.  GetCapabilityInfo then only returns results of
+	// type CAPABILITY_TYPE_DIRECT.
+	DirectOnly bool
+	// IgnoreStringMethods makes the analysis skip the calls to String and
+	// Error methods which packages fmt and errors make through interfaces
+	// such as fmt.Stringer and error when formatting values.  These calls can
+	// reach the String or Error method of every type whose values are
+	// formatted anywhere in the program, which is usually a false positive.
+	// Without it, paths through these calls are still reported, with
+	// CapabilityInfo.LowConfidence set.
+	IgnoreStringMethods bool
+	// Severities maps capabilities to their severities, which are used by
+	// MinSeverity and SortBySeverity.  If Severities is nil, the default
+	// severities from interesting.DefaultSeverities are used.
+	Severities map[cpb.Capability]interesting.Severity
+	// MinSeverity, if set, omits capabilities with a lower severity from the
+	// results.
+	MinSeverity interesting.Severity
+	// Sort determines the order in which the default, verbose, json and
+	// machine-functions outputs list capabilities.  SortByPackage only affects
+	// the json and machine-functions outputs, which list capabilities per
+	// package.
+	Sort SortOrder
+	// ExamplesPerKey is the maximum number of distinct example paths that
+	// GetCapabilityInfo returns for each capability and function, call site,
+	// or package, depending on the granularity.  Values less than 1 are
+	// treated as 1.  It has no effect with OmitPaths or with intermediate
+	// granularity.
+	ExamplesPerKey int
+	// CallgraphReachableOnly restricts the callgraph and callgraph-json
+	// outputs to the functions which can be reached from the queried
+	// packages, instead of every function in the callgraph.
+	CallgraphReachableOnly bool
+	// OutputDir, if set, makes the json output mode write the results for
+	// each package to a separate file in that directory, instead of writing
+	// all of them to the output writer.
+	OutputDir string
+	// CompactJSON makes the json and callgraph-json output modes write the
+	// result on a single line, instead of indenting it over many lines.
+	CompactJSON bool
+	// Shallow restricts the analysis to the code of the queried packages,
+	// which is much faster.  Function bodies are not analyzed for their
+	// dependencies, including the standard library, so a function called in
+	// another package only has the capability that Classifier assigns it,
+	// and calls it makes are not followed.
+	Shallow bool
+	// RelativePaths makes GetCapabilityInfo write the import paths of packages
+	// in the main module relative to the module's path, for example
+	// "internal/foo" instead of "example.com/mod/internal/foo", in package
+	// directories and function names.
+	RelativePaths bool
+	// MarkGenerated makes GetCapabilityInfo set the Generated field of
+	// CapabilityInfos whose paths start in a generated file.  A file is
+	// generated if it has a "// Code generated ... DO NOT EDIT." comment, or
+	// if it matches one of GeneratedFilePatterns.
+	MarkGenerated bool
+	// GeneratedFilePatterns are patterns in the syntax of filepath.Match,
+	// such as "*.pb.go" or "zz_generated_*.go", for files to treat as
+	// generated.  Each pattern is matched against the base name of a file,
+	// not its path, so a pattern containing a separator matches nothing.
+	// Invalid patterns are ignored.
+	GeneratedFilePatterns []string
+	// SeparateHeuristicFindings makes GetCapabilityInfo set the Heuristic
+	// field of CapabilityInfos whose paths end at a function that was given
+	// its capability by an analysis of its body, such as the detection of
+	// unsafe pointer conversions, reflect.Value copies and assembly, rather
+	// than by the Classifier.
+	SeparateHeuristicFindings bool
+	// ReportRules makes GetCapabilityInfo set the Rule field of each
+	// CapabilityInfo to the rule which gave the function at the end of its
+	// path the capability, if the Classifier is a RuleClassifier or the
+	// capability was found by a builtin analysis.
+	ReportRules bool
+	// TrustedPackages lists package patterns, such as "example.com/x/...",
+	// for packages to treat like the standard library where the analysis
+	// distinguishes code outside it: a path through them to a capability of
+	// the standard library is still direct, DirectOnly follows calls into
+	// them, and capabilities are not attributed to them in the matrix,
+	// osv-annotations and -forbid-module results.  See
+	// interesting.MatchPackagePattern for the pattern syntax.
+	TrustedPackages []string
+	// ExcludeBuildTags lists build tags, such as "example", for code which
+	// should not be reported.  Functions in files of the queried packages
+	// whose "//go:build" constraints require one of these tags are not
+	// reported as having capabilities, and FilterQueriedPackages removes
+	// packages made up only of such files from the queried packages.  The
+	// files are only loaded, and so only need to be excluded, if the tag is
+	// also set when loading packages.
+	ExcludeBuildTags []string
+	// QueryVendoredAndGenerated keeps packages in a vendor directory, and
+	// packages all of whose files are generated, in the queried packages.
+	// By default FilterQueriedPackages removes them from the queried
+	// packages, unless that would leave none, but they are still analyzed as
+	// dependencies of the others, so the capabilities they provide to them
+	// are still reported.
+	QueryVendoredAndGenerated bool
+	// Metadata, if non-nil, is included in the json output to record how the
+	// analysis was run.
+	Metadata *cpb.AnalysisMetadata
+	// CountUnanalyzed makes RunCapslock count the functions which have
+	// CAPABILITY_UNANALYZED, which takes another search of the callgraph.
+	CountUnanalyzed bool
+	// Cache, if non-nil, holds the callgraph of the packages being analyzed,
+	// so that the analyses run with this Config, or with copies of it, build
+	// it only once.
+	Cache *Cache
 }
 
 // Classifier is an interface for types that help map code features to
@@ -57,6 +225,26 @@ type Classifier interface {
 	IncludeCall(edge *callgraph.Edge) bool
 }
 
+// RuleClassifier can be implemented by a Classifier which can also describe
+// the rule which gave a function its category.
+type RuleClassifier interface {
+	// FunctionRule returns the category of a function, as FunctionCategory
+	// does, and a description of the rule which assigned it, such as the line
+	// of a capability map.  The description is "" if no rule applied.
+	FunctionRule(pkg string, name string) (cpb.Capability, string)
+}
+
+// InterfaceClassifier can be implemented by a Classifier which also assigns
+// capabilities to the methods of every type implementing some interfaces.
+type InterfaceClassifier interface {
+	// InterfaceCategories returns a map from interface names, such as
+	// "example.com/plugin.Runner", to capabilities.  A method of a type
+	// implementing one of the interfaces, whose name is in the interface's
+	// method set, has the corresponding capability if FunctionCategory
+	// returns CAPABILITY_UNSPECIFIED for it.
+	InterfaceCategories() map[string]cpb.Capability
+}
+
 // GetClassifier returns a classifier for mapping packages and functions to the
 // appropriate capability.
 // If excludedUnanalyzed is true, the UNANALYZED capability is never returned.
@@ -82,101 +270,321 @@ func GetClassifier(excludeUnanalyzed bool) *interesting.Classifier {
 //   - For "intermediate" granularity, one CapabilityInfo is returned for each
 //     combination of capability and package that is in a path from a function
 //     in pkgs to a function with a capability.
+//
+// If Config.PerCallSite is set and the granularity is "function", one
+// CapabilityInfo is returned for each call site in a function in pkgs that
+// leads to a capability, instead of one per function.
+//
+// If Config.ExamplesPerKey is greater than 1, up to that many CapabilityInfos
+// with distinct example paths are returned for each of the combinations
+// above.
 func GetCapabilityInfo(pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) *cpb.CapabilityInfoList {
 	if config.Granularity == GranularityUnset {
 		config.Granularity = GranularityFunction
 	}
 	if config.Granularity == GranularityIntermediate {
-		return intermediatePackages(pkgs, queriedPackages, config)
+		cil := intermediatePackages(pkgs, queriedPackages, config)
+		setModules(cil, pkgs)
+		if config.RelativePaths {
+			makePathsRelative(cil, pkgs)
+		}
+		return cil
 	}
 	type output struct {
 		*cpb.CapabilityInfo
 		*ssa.Function // used for sorting
+		node          *callgraph.Node
+		// cap and last are the capability and the function with that
+		// capability at the end of the path.
+		cap  cpb.Capability
+		last *callgraph.Node
 	}
 	var caps []output
-	forEachPath(pkgs, queriedPackages,
+	pb := newPathBuilder(config, config.Granularity == GranularityFunction)
+	// addPath adds an output for the path from v to a function with capability
+	// cap.  The path starts with the edges in prefix, and then follows the
+	// edges recorded in nodes.
+	addPath := func(cap cpb.Capability, nodes bfsStateMap, v *callgraph.Node, prefix []*callgraph.Edge) {
+		if c, last := pb.capabilityInfo(cap, nodes, v, prefix); c != nil {
+			caps = append(caps, output{c, v.Func, v, cap, last})
+		}
+	}
+	type root struct {
+		cap   cpb.Capability
+		nodes bfsStateMap
+		v     *callgraph.Node
+	}
+	var roots []root
+	perCallSite := config.PerCallSite && config.Granularity == GranularityFunction
+	examples := config.examplesPerKey()
+	rewriteFailures, heuristic := forEachPath(pkgs, queriedPackages,
 		func(cap cpb.Capability, nodes bfsStateMap, v *callgraph.Node) {
-			i := 0
-			c := cpb.CapabilityInfo{}
-			fn := v.Func
-			var n string
-			var ctype cpb.CapabilityType
-			var incomingEdge *callgraph.Edge
-			for v != nil {
-				if !config.OmitPaths || (i == 0 && config.Granularity == GranularityFunction) {
-					addFunction(&c.Path, v, incomingEdge)
-				}
-				if i == 0 {
-					n = v.Func.Package().Pkg.Path()
-					ctype = cpb.CapabilityType_CAPABILITY_TYPE_DIRECT
-					c.Capability = cap.Enum()
-					c.PackageDir = proto.String(v.Func.Package().Pkg.Path())
-					c.PackageName = proto.String(v.Func.Package().Pkg.Name())
-				}
-				i++
-				if pName := packagePath(v.Func); n != pName && !isStdLib(pName) {
-					ctype = cpb.CapabilityType_CAPABILITY_TYPE_TRANSITIVE
-				}
-				incomingEdge, v = nodes[v].edge, nodes[v].next()
+			if perCallSite || examples > 1 {
+				// The BFS for cap is still in progress, so the paths from the
+				// other callees of v may not be known yet.  Handle v later.
+				roots = append(roots, root{cap, nodes, v})
+				return
 			}
-			c.CapabilityType = &ctype
-			if !config.OmitPaths {
-				var b strings.Builder
-				for i, p := range c.Path {
-					if i != 0 {
-						b.WriteByte(' ')
-					}
-					b.WriteString(p.GetName())
-				}
-				c.DepPath = proto.String(b.String())
-			}
-			caps = append(caps, output{&c, fn})
+			addPath(cap, nodes, v, nil)
 		}, config)
-	sort.Slice(caps, func(i, j int) bool {
+	for _, r := range roots {
+		firsts := []*callgraph.Edge{nil}
+		if perCallSite {
+			if edges := callSiteEdges(r.nodes, r.v, config.edgeClassifier()); len(edges) > 0 {
+				firsts = edges
+			}
+			// Otherwise r.v has the capability itself.
+		}
+		for _, first := range firsts {
+			for _, path := range examplePaths(r.nodes, r.v, first, examples, config.edgeClassifier()) {
+				addPath(r.cap, r.nodes, r.v, path)
+			}
+		}
+	}
+	starts := make(nodeset)
+	for _, o := range caps {
+		starts[o.node] = struct{}{}
+	}
+	reachable := reachableFromExported(starts, queriedPackages, config.edgeClassifier())
+	for _, o := range caps {
+		_, ok := reachable[o.node]
+		o.CapabilityInfo.ReachableFromExported = proto.Bool(ok)
+	}
+	if config.SeparateHeuristicFindings {
+		for _, o := range caps {
+			if _, ok := heuristic[o.cap][o.last]; ok {
+				o.CapabilityInfo.Heuristic = proto.Bool(true)
+			}
+		}
+	}
+	for _, o := range caps {
+		if o.cap == cpb.Capability_CAPABILITY_RECURSION {
+			o.CapabilityInfo.RecursiveCycle = recursiveCycle(o.last, heuristic[o.cap])
+		}
+	}
+	if config.ReportRules {
+		for _, o := range caps {
+			_, h := heuristic[o.cap][o.last]
+			if rule := capabilityRule(config.Classifier, o.last.Func, o.cap, h); rule != "" {
+				o.CapabilityInfo.Rule = proto.String(rule)
+			}
+		}
+	}
+	if config.MarkGenerated {
+		generated := generatedFiles(pkgs, config.GeneratedFilePatterns)
+		for _, o := range caps {
+			if o.Function == nil {
+				continue
+			}
+			if _, ok := generated[o.Function.Prog.Fset.Position(o.Function.Pos()).Filename]; ok {
+				o.CapabilityInfo.Generated = proto.Bool(true)
+			}
+		}
+	}
+	sort.SliceStable(caps, func(i, j int) bool {
 		if x, y := caps[i].CapabilityInfo.GetCapability(), caps[j].CapabilityInfo.GetCapability(); x != y {
 			return x < y
 		}
 		return funcCompare(caps[i].Function, caps[j].Function) < 0
 	})
 	if config.Granularity == GranularityPackage {
-		// Keep only the first entry in the sorted list for each (capability, package) pair.
+		// Keep only the first entries in the sorted list for each (capability,
+		// package) pair.
 		type cp struct {
 			cpb.Capability
 			*ssa.Package
 		}
-		seen := make(map[cp]struct{})
-		// del returns true if the capability and package of o have been seen before.
+		seen := make(map[cp]int)
+		// del returns true if the capability and package of o have been seen
+		// as many times as there can be examples.
 		del := func(o output) bool {
 			var pkg *ssa.Package
 			if o.Function != nil {
 				pkg = o.Function.Package()
 			}
 			cp := cp{o.CapabilityInfo.GetCapability(), pkg}
-			if _, ok := seen[cp]; ok {
+			if seen[cp] >= examples {
 				return true
 			}
-			seen[cp] = struct{}{}
+			seen[cp]++
 			return false
 		}
 		caps = slices.DeleteFunc(caps, del)
 	}
 	cil := &cpb.CapabilityInfoList{
-		CapabilityInfo: make([]*cpb.CapabilityInfo, len(caps)),
-		ModuleInfo:     collectModuleInfo(pkgs),
-		PackageInfo:    collectPackageInfo(pkgs),
+		CapabilityInfo:     make([]*cpb.CapabilityInfo, len(caps)),
+		ModuleInfo:         collectModuleInfo(pkgs),
+		PackageInfo:        collectPackageInfo(pkgs),
+		BuildTimeExecution: collectBuildTimeExecution(pkgs),
+	}
+	if rewriteFailures > 0 {
+		cil.UnrewrittenCallCount = proto.Int64(int64(rewriteFailures))
 	}
 	for i := range caps {
 		cil.CapabilityInfo[i] = caps[i].CapabilityInfo
+		setCustomCapability(cil.CapabilityInfo[i])
+	}
+	setModules(cil, pkgs)
+	if config.RelativePaths {
+		makePathsRelative(cil, pkgs)
 	}
 	return cil
 }
 
+// pathBuilder constructs the CapabilityInfo for each path found by
+// forEachPath.
+type pathBuilder struct {
+	config *Config
+	// keepFirst is set if the first function in a path should be kept even
+	// if config.OmitPaths is set, since it is the key of the CapabilityInfo.
+	keepFirst bool
+	// recovers records the result of callsRecover for each function.
+	recovers map[*ssa.Function]bool
+}
+
+func newPathBuilder(config *Config, keepFirst bool) *pathBuilder {
+	return &pathBuilder{config, keepFirst, make(map[*ssa.Function]bool)}
+}
+
+// capabilityInfo returns a CapabilityInfo for the path from v to a function
+// with capability cap, and the node for that function.  The path starts with
+// the edges in prefix, and then follows the edges recorded in nodes.  It
+// returns nil if config.DirectOnly excludes the path.
+func (pb *pathBuilder) capabilityInfo(cap cpb.Capability, nodes bfsStateMap, v *callgraph.Node, prefix []*callgraph.Edge) (*cpb.CapabilityInfo, *callgraph.Node) {
+	config := pb.config
+	i := 0
+	c := cpb.CapabilityInfo{}
+	var n string
+	var ctype cpb.CapabilityType
+	var incomingEdge *callgraph.Edge
+	// atInit is set if the path starts with calls from an init function
+	// which are within its package.
+	atInit, inRoot := false, true
+	// viaRecover is set if one of those functions calls recover and the
+	// path continues from it.
+	viaRecover := false
+	// lowConfidence is set if the path passes through a call for which
+	// isFormattingStringMethodCall is true.
+	lowConfidence := false
+	var last *callgraph.Node
+	for v != nil {
+		last = v
+		if !config.OmitPaths || (i == 0 && pb.keepFirst) {
+			addFunction(&c.Path, v, incomingEdge)
+		}
+		if i == 0 {
+			n = v.Func.Package().Pkg.Path()
+			ctype = cpb.CapabilityType_CAPABILITY_TYPE_DIRECT
+			c.Capability = cap.Enum()
+			c.PackageDir = proto.String(v.Func.Package().Pkg.Path())
+			c.PackageName = proto.String(v.Func.Package().Pkg.Name())
+		}
+		i++
+		pName := packagePath(v.Func)
+		if n != pName && !config.isStdLib(pName) {
+			ctype = cpb.CapabilityType_CAPABILITY_TYPE_TRANSITIVE
+		}
+		if inRoot = inRoot && n == pName; inRoot && isInitFunction(v.Func) {
+			atInit = true
+		}
+		recovering := false
+		if inRoot {
+			r, ok := pb.recovers[v.Func]
+			if !ok {
+				r = callsRecover(v.Func)
+				pb.recovers[v.Func] = r
+			}
+			recovering = r
+		}
+		if i <= len(prefix) {
+			incomingEdge, v = prefix[i-1], prefix[i-1].Callee
+		} else {
+			incomingEdge, v = nodes[v].edge, nodes[v].next()
+		}
+		if recovering && v != nil {
+			viaRecover = true
+		}
+		if isFormattingStringMethodCall(incomingEdge) {
+			lowConfidence = true
+		}
+	}
+	if config.DirectOnly && ctype != cpb.CapabilityType_CAPABILITY_TYPE_DIRECT {
+		// The path passes through the standard library into another
+		// package, for example through a callback.
+		return nil, nil
+	}
+	c.CapabilityType = &ctype
+	if atInit {
+		c.AtInitTime = proto.Bool(true)
+	}
+	if viaRecover {
+		c.ViaRecover = proto.Bool(true)
+	}
+	if lowConfidence {
+		c.LowConfidence = proto.Bool(true)
+	}
+	if !config.OmitPaths {
+		var b strings.Builder
+		for i, p := range c.Path {
+			if i != 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(p.GetName())
+		}
+		c.DepPath = proto.String(b.String())
+	}
+	return &c, last
+}
+
+// StreamCapabilities analyzes the packages in pkgs like GetCapabilityInfo
+// with function granularity, but instead of returning a CapabilityInfoList,
+// it calls fn with each CapabilityInfo as soon as it is found, so that the
+// results do not need to be kept in memory.  fn is called from the
+// goroutine that called StreamCapabilities, and the analysis waits for fn
+// to return.
+//
+// The CapabilityInfos are not passed to fn in any particular order, and
+// the ReachableFromExported and Heuristic fields, which depend on all the
+// results, are not set.  Config.ExamplesPerKey and Config.PerCallSite are
+// ignored.
+func StreamCapabilities(pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config, fn func(*cpb.CapabilityInfo)) {
+	pb := newPathBuilder(config, true)
+	modules := packageModules(pkgs)
+	var relative func(*cpb.CapabilityInfo)
+	if config.RelativePaths {
+		relative = pathRelativizer(pkgs)
+	}
+	var generated map[string]struct{}
+	if config.MarkGenerated {
+		generated = generatedFiles(pkgs, config.GeneratedFilePatterns)
+	}
+	forEachPath(pkgs, queriedPackages,
+		func(cap cpb.Capability, nodes bfsStateMap, v *callgraph.Node) {
+			ci, _ := pb.capabilityInfo(cap, nodes, v, nil)
+			if ci == nil {
+				return
+			}
+			if generated != nil {
+				if _, ok := generated[v.Func.Prog.Fset.Position(v.Func.Pos()).Filename]; ok {
+					ci.Generated = proto.Bool(true)
+				}
+			}
+			setCustomCapability(ci)
+			setModule(ci, modules)
+			if relative != nil {
+				relative(ci)
+			}
+			fn(ci)
+		}, config)
+}
+
 type CapabilityCounter struct {
 	capability       cpb.Capability
 	count            int64
 	direct_count     int64
 	transitive_count int64
 	example          []*cpb.Function
+	path_lengths     []int64
 }
 
 // GetCapabilityStats analyzes the packages in pkgs.  For each function in
@@ -188,10 +596,11 @@ func GetCapabilityStats(pkgs []*packages.Package, queriedPackages map[*types.Pac
 	cm := make(map[string]*CapabilityCounter)
 	forEachPath(pkgs, queriedPackages,
 		func(cap cpb.Capability, nodes bfsStateMap, v *callgraph.Node) {
-			if _, ok := cm[cap.String()]; !ok {
-				cm[cap.String()] = &CapabilityCounter{count: 1, capability: cap}
+			name := interesting.CapabilityName(cap)
+			if _, ok := cm[name]; !ok {
+				cm[name] = &CapabilityCounter{count: 1, capability: cap}
 			} else {
-				cm[cap.String()].count += 1
+				cm[name].count += 1
 			}
 			i := 0
 			var n string
@@ -206,45 +615,59 @@ func GetCapabilityStats(pkgs []*packages.Package, queriedPackages map[*types.Pac
 					n = v.Func.Package().Pkg.Path()
 				}
 				i++
-				if pName := packagePath(v.Func); n != pName && !isStdLib(pName) {
+				if pName := packagePath(v.Func); n != pName && !config.isStdLib(pName) {
 					isDirect = false
 				}
 				incomingEdge, v = nodes[v].edge, nodes[v].next()
 			}
+			cm[name].path_lengths = append(cm[name].path_lengths, int64(i))
 			if isDirect {
-				if _, ok := cm[cap.String()]; !ok {
-					cm[cap.String()] = &CapabilityCounter{count: 1, direct_count: 1}
+				if _, ok := cm[name]; !ok {
+					cm[name] = &CapabilityCounter{count: 1, direct_count: 1}
 				} else {
-					cm[cap.String()].direct_count += 1
+					cm[name].direct_count += 1
 				}
 			} else {
-				if _, ok := cm[cap.String()]; !ok {
-					cm[cap.String()] = &CapabilityCounter{count: 1, transitive_count: 1}
+				if _, ok := cm[name]; !ok {
+					cm[name] = &CapabilityCounter{count: 1, transitive_count: 1}
 				} else {
-					cm[cap.String()].transitive_count += 1
+					cm[name].transitive_count += 1
 				}
 			}
-			if _, ok := cm[cap.String()]; !ok {
-				cm[cap.String()] = &CapabilityCounter{example: e}
+			if _, ok := cm[name]; !ok {
+				cm[name] = &CapabilityCounter{example: e}
 			} else {
-				cm[cap.String()].example = e
+				cm[name].example = e
 			}
 		}, config)
 	for _, counts := range cm {
+		lengths := counts.path_lengths
+		slices.Sort(lengths)
+		capability, custom := interesting.ToProto(counts.capability)
 		cs = append(cs, &cpb.CapabilityStats{
-			Capability:      &counts.capability,
-			Count:           &counts.count,
-			DirectCount:     &counts.direct_count,
-			TransitiveCount: &counts.transitive_count,
-			ExampleCallpath: counts.example,
+			Capability:       &capability,
+			Count:            &counts.count,
+			DirectCount:      &counts.direct_count,
+			TransitiveCount:  &counts.transitive_count,
+			ExampleCallpath:  counts.example,
+			MinPathLength:    proto.Int64(lengths[0]),
+			MedianPathLength: proto.Int64(lengths[(len(lengths)-1)/2]),
+			MaxPathLength:    proto.Int64(lengths[len(lengths)-1]),
 		})
+		if custom != "" {
+			cs[len(cs)-1].CustomCapability = proto.String(custom)
+		}
 	}
 	sort.Slice(cs, func(i, j int) bool {
-		return cs[i].GetCapability() < cs[j].GetCapability()
+		if x, y := cs[i].GetCapability(), cs[j].GetCapability(); x != y {
+			return x < y
+		}
+		return cs[i].GetCustomCapability() < cs[j].GetCustomCapability()
 	})
 	return &cpb.CapabilityStatList{
-		CapabilityStats: cs,
-		ModuleInfo:      collectModuleInfo(pkgs),
+		CapabilityStats:    cs,
+		ModuleInfo:         collectModuleInfo(pkgs),
+		BuildTimeExecution: collectBuildTimeExecution(pkgs),
 	}
 }
 
@@ -256,15 +679,12 @@ func GetCapabilityCounts(pkgs []*packages.Package, queriedPackages map[*types.Pa
 	cm := make(map[string]int64)
 	forEachPath(pkgs, queriedPackages,
 		func(cap cpb.Capability, nodes bfsStateMap, v *callgraph.Node) {
-			if _, ok := cm[cap.String()]; !ok {
-				cm[cap.String()] = 1
-			} else {
-				cm[cap.String()] += 1
-			}
+			cm[interesting.CapabilityName(cap)] += 1
 		}, config)
 	return &cpb.CapabilityCountList{
-		CapabilityCounts: cm,
-		ModuleInfo:       collectModuleInfo(pkgs),
+		CapabilityCounts:   cm,
+		ModuleInfo:         collectModuleInfo(pkgs),
+		BuildTimeExecution: collectBuildTimeExecution(pkgs),
 	}
 }
 
@@ -439,12 +859,12 @@ func CapabilityGraph(pkgs []*packages.Package,
 	outputCapability GraphOutputCapabilityFn,
 	filter func(capability cpb.Capability) bool,
 ) {
-	safe, nodesByCapability, extraNodesByCapability := getPackageNodesWithCapability(pkgs, config)
-	nodesByCapability, allNodesWithExplicitCapability := mergeCapabilities(nodesByCapability, extraNodesByCapability)
+	safe, nodesByCapability, extraNodesByCapability, _ := getPackageNodesWithCapability(pkgs, config)
+	nodesByCapability, allNodesWithExplicitCapability, _ := mergeCapabilities(nodesByCapability, extraNodesByCapability)
 	extraNodesByCapability = nil
 
 	search := func(nodesByCapability nodesetPerCapability) {
-		bfsFromCapabilities := searchBackwardsFromCapabilities(nodesByCapability, safe, allNodesWithExplicitCapability, config.Classifier)
+		bfsFromCapabilities := searchBackwardsFromCapabilities(nodesByCapability, safe, allNodesWithExplicitCapability, config.edgeClassifier())
 
 		canBeReachedFromQuery := make(nodeset)
 		for v := range bfsFromCapabilities {
@@ -456,12 +876,18 @@ func CapabilityGraph(pkgs []*packages.Package,
 			}
 		}
 
+		outputNode, outputCall, outputCapability := outputNode, outputCall, outputCapability
+		if config.GraphFocus != "" {
+			f := newFocusFilter(config.GraphFocus, canBeReachedFromQuery, allNodesWithExplicitCapability, bfsFromCapabilities, config.edgeClassifier())
+			outputNode, outputCall, outputCapability = f.wrap(outputNode, outputCall, outputCapability)
+		}
+
 		searchForwardsFromQueriedFunctions(
 			canBeReachedFromQuery,
 			nodesByCapability,
 			allNodesWithExplicitCapability,
 			bfsFromCapabilities,
-			config.Classifier,
+			config.edgeClassifier(),
 			outputNode,
 			outputCall,
 			outputCapability)
@@ -469,16 +895,146 @@ func CapabilityGraph(pkgs []*packages.Package,
 	if filter != nil {
 		// Consider each capability individually.
 		for c, ns := range nodesByCapability {
-			if filter(c) {
+			if config.hasMinSeverity(c) && filter(c) {
 				search(nodesetPerCapability{c: ns})
 			}
 		}
 	} else {
 		// Generate a single graph.
+		if config.MinSeverity != interesting.SeverityUnspecified {
+			nodesByCapability = maps.Clone(nodesByCapability)
+			maps.DeleteFunc(nodesByCapability, func(c cpb.Capability, _ nodeset) bool {
+				return !config.hasMinSeverity(c)
+			})
+		}
 		search(nodesByCapability)
 	}
 }
 
+// focusFilter restricts the output of CapabilityGraph to the nodes and edges
+// that are on some path from a queried function to a capability which passes
+// through a function in a focus package.
+type focusFilter struct {
+	// beforeFocus contains the nodes reachable from a queried function which can
+	// reach a function in a focus package.
+	beforeFocus nodeset
+	// afterFocus contains the nodes reachable from a function in a focus
+	// package which is itself reachable from a queried function.
+	afterFocus nodeset
+}
+
+// newFocusFilter computes a focusFilter for the part of the callgraph that
+// searchForwardsFromQueriedFunctions would visit, starting from the nodes in
+// queried.
+func newFocusFilter(
+	pattern string,
+	queried nodeset,
+	allNodesWithExplicitCapability nodeset,
+	bfsFromCapabilities bfsStateMap,
+	classifier Classifier,
+) *focusFilter {
+	// includeEdge returns true if searchForwardsFromQueriedFunctions would
+	// follow edge.
+	includeEdge := func(edge *callgraph.Edge) bool {
+		if _, ok := allNodesWithExplicitCapability[edge.Caller]; ok {
+			return false
+		}
+		if _, ok := bfsFromCapabilities[edge.Callee]; !ok {
+			return false
+		}
+		return classifier.IncludeCall(edge)
+	}
+	// search returns the set of nodes reachable from start, following edges
+	// forwards or backwards.
+	search := func(start nodeset, forwards bool) nodeset {
+		visited := make(nodeset)
+		var q []*callgraph.Node
+		for v := range start {
+			visited[v] = struct{}{}
+			q = append(q, v)
+		}
+		for len(q) > 0 {
+			v := q[0]
+			q = q[1:]
+			edges, next := v.Out, func(e *callgraph.Edge) *callgraph.Node { return e.Callee }
+			if !forwards {
+				edges, next = v.In, func(e *callgraph.Edge) *callgraph.Node { return e.Caller }
+			}
+			for _, edge := range edges {
+				if !includeEdge(edge) {
+					continue
+				}
+				w := next(edge)
+				if _, ok := visited[w]; ok {
+					continue
+				}
+				visited[w] = struct{}{}
+				q = append(q, w)
+			}
+		}
+		return visited
+	}
+	fromQuery := search(queried, true)
+	focus := make(nodeset)
+	for v := range fromQuery {
+		if v.Func != nil && interesting.MatchPackagePattern(pattern, packagePath(v.Func)) {
+			focus[v] = struct{}{}
+		}
+	}
+	beforeFocus := search(focus, false)
+	for v := range beforeFocus {
+		if _, ok := fromQuery[v]; !ok {
+			delete(beforeFocus, v)
+		}
+	}
+	return &focusFilter{
+		beforeFocus: beforeFocus,
+		afterFocus:  search(focus, true),
+	}
+}
+
+// wrap returns versions of the CapabilityGraph output functions which only
+// pass on nodes, edges, and capabilities that f keeps.  Nil functions are
+// returned unchanged.
+func (f *focusFilter) wrap(
+	outputNode GraphOutputNodeFn,
+	outputCall GraphOutputCallFn,
+	outputCapability GraphOutputCapabilityFn,
+) (GraphOutputNodeFn, GraphOutputCallFn, GraphOutputCapabilityFn) {
+	in := func(ns nodeset, v *callgraph.Node) bool {
+		_, ok := ns[v]
+		return ok
+	}
+	var (
+		node       GraphOutputNodeFn
+		call       GraphOutputCallFn
+		capability GraphOutputCapabilityFn
+	)
+	if outputNode != nil {
+		node = func(fromQuery bfsStateMap, v *callgraph.Node, toCapability bfsStateMap) {
+			if in(f.beforeFocus, v) || in(f.afterFocus, v) {
+				outputNode(fromQuery, v, toCapability)
+			}
+		}
+	}
+	if outputCall != nil {
+		call = func(edge *callgraph.Edge) {
+			if (in(f.beforeFocus, edge.Caller) && in(f.beforeFocus, edge.Callee)) ||
+				(in(f.afterFocus, edge.Caller) && in(f.afterFocus, edge.Callee)) {
+				outputCall(edge)
+			}
+		}
+	}
+	if outputCapability != nil {
+		capability = func(v *callgraph.Node, c cpb.Capability) {
+			if in(f.afterFocus, v) {
+				outputCapability(v, c)
+			}
+		}
+	}
+	return node, call, capability
+}
+
 // getPackageNodesWithCapability analyzes all the functions in pkgs and their
 // transitive dependencies, and returns three sets of callgraph nodes.
 //
@@ -489,21 +1045,58 @@ func CapabilityGraph(pkgs []*packages.Package,
 // to a set of nodes.
 // extraNodesByCapability contains nodes for functions that use unsafe pointers
 // or the reflect package in a way that we want to report to the user.
+//
+// If config.Cache is set, the results are shared with other calls using the
+// same classification settings, so callers must not modify them.
 func getPackageNodesWithCapability(pkgs []*packages.Package,
 	config *Config,
-) (safe nodeset, nodesByCapability, extraNodesByCapability nodesetPerCapability) {
-	graph, ssaProg, allFunctions := buildGraph(pkgs, true)
-	unsafePointerFunctions := findUnsafePointerConversions(pkgs, ssaProg, allFunctions)
-	ssaProg = nil // possibly save memory; we don't use ssaProg again
+) (safe nodeset, nodesByCapability, extraNodesByCapability nodesetPerCapability, rewriteFailures int) {
+	if n := config.Cache.lookupNodes(config); n != nil {
+		return n.safe, n.nodesByCapability, n.extraNodesByCapability, n.rewriteFailures
+	}
+	graph, ssaProg, allFunctions, failures := config.Cache.buildGraph(pkgs, config.Shallow)
+	if config.Logf != nil {
+		for _, f := range failures {
+			config.Logf("%v", f)
+		}
+	}
 	safe, nodesByCapability = getNodeCapabilities(graph, config.Classifier)
 
 	if !config.DisableBuiltin {
-		extraNodesByCapability = getExtraNodesByCapability(graph, allFunctions, unsafePointerFunctions)
+		// Unsafe pointer conversions are only reported by the builtin
+		// analyses, so there is no need to look for them otherwise.
+		unsafePointerFunctions := findUnsafePointerConversions(pkgs, ssaProg, allFunctions, config.Shallow)
+		ssaProg = nil // possibly save memory; we don't use ssaProg again
+		var analyzedPackages map[*types.Package]struct{}
+		if config.Shallow {
+			analyzedPackages = GetQueriedPackages(pkgs)
+		}
+		extraNodesByCapability = getExtraNodesByCapability(graph, allFunctions, unsafePointerFunctions, analyzedPackages)
 	}
-	return safe, nodesByCapability, extraNodesByCapability
+	if config.DetectPanics {
+		if extraNodesByCapability == nil {
+			extraNodesByCapability = make(nodesetPerCapability)
+		}
+		addPanickingFunctions(extraNodesByCapability, graph, allFunctions)
+	}
+	if config.DetectRecursion {
+		if extraNodesByCapability == nil {
+			extraNodesByCapability = make(nodesetPerCapability)
+		}
+		addRecursiveFunctions(extraNodesByCapability, graph, allFunctions, config)
+	}
+	if config.CollapseSubcapabilities {
+		nodesByCapability.collapseSubcapabilities()
+		extraNodesByCapability.collapseSubcapabilities()
+	}
+	config.Cache.storeNodes(config, &cachedNodes{safe, nodesByCapability, extraNodesByCapability, len(failures)})
+	return safe, nodesByCapability, extraNodesByCapability, len(failures)
 }
 
-func getExtraNodesByCapability(graph *callgraph.Graph, allFunctions map[*ssa.Function]bool, unsafePointerFunctions map[*ssa.Function]struct{}) nodesetPerCapability {
+// getExtraNodesByCapability returns the nodes for functions which the
+// builtin analyses find to have capabilities.  If analyzedPackages is
+// non-nil, the source code of functions in other packages was not analyzed.
+func getExtraNodesByCapability(graph *callgraph.Graph, allFunctions map[*ssa.Function]bool, unsafePointerFunctions map[*ssa.Function][]cpb.Capability, analyzedPackages map[*types.Package]struct{}) nodesetPerCapability {
 	// Find functions that copy reflect.Value objects in a way that could
 	// possibly cause a data race, and add their nodes to
 	// extraNodesByCapability[Capability_CAPABILITY_REFLECT].
@@ -557,12 +1150,18 @@ func getExtraNodesByCapability(graph *callgraph.Graph, allFunctions map[*ssa.Fun
 		}
 	}
 	// Add nodes for the functions in unsafePointerFunctions to
-	// extraNodesByCapability[Capability_CAPABILITY_UNSAFE_POINTER].
-	for f := range unsafePointerFunctions {
+	// extraNodesByCapability, under CAPABILITY_UNSAFE_POINTER or its
+	// subcapability, and CAPABILITY_REFLECT_UNEXPORTED_FIELD.
+	for f, cs := range unsafePointerFunctions {
 		if node, ok := graph.Nodes[f]; ok {
-			extraNodesByCapability.add(cpb.Capability_CAPABILITY_UNSAFE_POINTER, node)
+			for _, c := range cs {
+				extraNodesByCapability.add(c, node)
+			}
 		}
 	}
+	addShellExecFunctions(extraNodesByCapability, graph, allFunctions)
+	addInsecureTLSFunctions(extraNodesByCapability, graph, allFunctions)
+	addDeviceFileFunctions(extraNodesByCapability, graph, allFunctions)
 	// Add the arbitrary-execution capability to asm function nodes.
 	for f, node := range graph.Nodes {
 		if f.Blocks == nil {
@@ -571,26 +1170,365 @@ func getExtraNodesByCapability(graph *callgraph.Graph, allFunctions map[*ssa.Fun
 				// Exclude synthetic functions, such as those loaded from object files.
 				continue
 			}
+			if analyzedPackages != nil && !inPackages(f, analyzedPackages) {
+				// The function's package was only loaded from type information.
+				continue
+			}
 			extraNodesByCapability.add(cpb.Capability_CAPABILITY_ARBITRARY_EXECUTION, node)
 		}
 	}
 	return extraNodesByCapability
 }
 
+// addPanickingFunctions adds the nodes for the functions in allFunctions
+// which contain a panic instruction, i.e. which call the panic builtin, to
+// extraNodesByCapability under CAPABILITY_PANIC.  Run-time panics, such as
+// those caused by an out-of-range index, are not included.
+func addPanickingFunctions(extraNodesByCapability nodesetPerCapability, graph *callgraph.Graph, allFunctions map[*ssa.Function]bool) {
+	for f := range allFunctions {
+		node, ok := graph.Nodes[f]
+		if !ok {
+			continue
+		}
+	blocks:
+		for _, b := range f.Blocks {
+			for _, i := range b.Instrs {
+				if _, ok := i.(*ssa.Panic); ok {
+					extraNodesByCapability.add(cpb.Capability_CAPABILITY_PANIC, node)
+					break blocks
+				}
+			}
+		}
+	}
+}
+
+// addRecursiveFunctions adds the nodes for the functions in allFunctions
+// which are in a cycle in the callgraph to extraNodesByCapability under
+// CAPABILITY_RECURSION.  Cycles made up only of functions in the standard
+// library, or in config.TrustedPackages, are ignored.  If config.Logf is
+// non-nil, each cycle is logged.
+func addRecursiveFunctions(extraNodesByCapability nodesetPerCapability, graph *callgraph.Graph, allFunctions map[*ssa.Function]bool, config *Config) {
+	// Find the strongly connected components of the callgraph with Tarjan's
+	// algorithm.
+	var (
+		index   = make(map[*callgraph.Node]int)
+		lowlink = make(map[*callgraph.Node]int)
+		onStack = make(nodeset)
+		stack   []*callgraph.Node
+		cycles  [][]*callgraph.Node
+	)
+	var visit func(v *callgraph.Node)
+	visit = func(v *callgraph.Node) {
+		index[v] = len(index)
+		lowlink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = struct{}{}
+		selfLoop := false
+		for _, e := range v.Out {
+			w := e.Callee
+			if w == v {
+				selfLoop = true
+			}
+			if _, ok := allFunctions[w.Func]; !ok {
+				continue
+			}
+			if _, ok := index[w]; !ok {
+				visit(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if _, ok := onStack[w]; ok {
+				lowlink[v] = min(lowlink[v], index[w])
+			}
+		}
+		if lowlink[v] != index[v] {
+			return
+		}
+		i := len(stack) - 1
+		for stack[i] != v {
+			i--
+		}
+		component := slices.Clone(stack[i:])
+		stack = stack[:i]
+		for _, w := range component {
+			delete(onStack, w)
+		}
+		if len(component) > 1 || selfLoop {
+			cycles = append(cycles, component)
+		}
+	}
+	for f := range allFunctions {
+		if node, ok := graph.Nodes[f]; ok {
+			if _, ok := index[node]; !ok {
+				visit(node)
+			}
+		}
+	}
+	for _, component := range cycles {
+		if !slices.ContainsFunc(component, func(v *callgraph.Node) bool { return !config.isStdLib(packagePath(v.Func)) }) {
+			continue
+		}
+		for _, v := range component {
+			extraNodesByCapability.add(cpb.Capability_CAPABILITY_RECURSION, v)
+		}
+		if config.Logf != nil {
+			names := make([]string, len(component))
+			for i, v := range component {
+				names[i] = v.Func.String()
+			}
+			slices.Sort(names)
+			config.Logf("recursive cycle: %s", strings.Join(names, ", "))
+		}
+	}
+}
+
+// recursiveCycle returns the names of the functions in one of the shortest
+// cycles of calls from v back to itself, starting with v, which passes only
+// through the nodes in recursive, the functions which addRecursiveFunctions
+// found to be in cycles.  The callees of each function are searched in the
+// order of their names, so the same cycle is always chosen.
+func recursiveCycle(v *callgraph.Node, recursive nodeset) []string {
+	// Search forwards from v for a path back to v, recording the edge by
+	// which each node was first reached.
+	reachedBy := make(map[*callgraph.Node]*callgraph.Node)
+	q := []*callgraph.Node{v}
+	for len(q) > 0 {
+		w := q[0]
+		q = q[1:]
+		var callees []*callgraph.Node
+		for _, e := range w.Out {
+			if _, ok := recursive[e.Callee]; ok {
+				callees = append(callees, e.Callee)
+			}
+		}
+		sort.Sort(byFunction(callees))
+		for _, x := range callees {
+			if x == v {
+				var cycle []string
+				for ; w != v; w = reachedBy[w] {
+					cycle = append(cycle, w.Func.String())
+				}
+				cycle = append(cycle, v.Func.String())
+				slices.Reverse(cycle)
+				return cycle
+			}
+			if _, ok := reachedBy[x]; !ok {
+				reachedBy[x] = w
+				q = append(q, x)
+			}
+		}
+	}
+	return nil
+}
+
+// shellNames contains the names of command shells, which can interpret their
+// arguments as arbitrary commands.
+var shellNames = map[string]struct{}{
+	"ash": {}, "bash": {}, "cmd": {}, "csh": {}, "dash": {}, "fish": {},
+	"ksh": {}, "powershell": {}, "pwsh": {}, "sh": {}, "tcsh": {}, "zsh": {},
+}
+
+// execNameArgument maps functions which execute a command to the index of
+// their argument holding the command's name.
+var execNameArgument = map[string]int{
+	"os/exec.Command":        0,
+	"os/exec.CommandContext": 1,
+	"os.StartProcess":        0,
+}
+
+// isShellName returns true if the command name or path s names a command
+// shell, such as "sh", "/bin/bash", or "cmd.exe".
+func isShellName(s string) bool {
+	if i := strings.LastIndexAny(s, `/\`); i >= 0 {
+		s = s[i+1:]
+	}
+	s = strings.ToLower(s)
+	s = strings.TrimSuffix(s, ".exe")
+	_, ok := shellNames[s]
+	return ok
+}
+
+// addShellExecFunctions adds the nodes for the functions in allFunctions
+// which execute a command shell to extraNodesByCapability under
+// CAPABILITY_EXEC_SHELL.  This is a heuristic: only calls to the functions
+// in execNameArgument whose command name is a constant are recognized.
+func addShellExecFunctions(extraNodesByCapability nodesetPerCapability, graph *callgraph.Graph, allFunctions map[*ssa.Function]bool) {
+	addConstantArgumentFunctions(extraNodesByCapability, graph, allFunctions,
+		execNameArgument, isShellName, cpb.Capability_CAPABILITY_EXEC_SHELL)
+}
+
+// addConstantArgumentFunctions adds the nodes for the functions in
+// allFunctions which make a static call to one of the functions in
+// argumentIndex with a constant string argument for which match returns true
+// to extraNodesByCapability under capability.  argumentIndex maps the names
+// of the callees to the index of the argument to check.
+func addConstantArgumentFunctions(extraNodesByCapability nodesetPerCapability, graph *callgraph.Graph, allFunctions map[*ssa.Function]bool,
+	argumentIndex map[string]int, match func(string) bool, capability cpb.Capability,
+) {
+	for f := range allFunctions {
+		node, ok := graph.Nodes[f]
+		if !ok {
+			continue
+		}
+	blocks:
+		for _, b := range f.Blocks {
+			for _, i := range b.Instrs {
+				call, ok := i.(*ssa.Call)
+				if !ok {
+					continue
+				}
+				callee := call.Call.StaticCallee()
+				if callee == nil {
+					continue
+				}
+				n, ok := argumentIndex[callee.String()]
+				if !ok || n >= len(call.Call.Args) {
+					continue
+				}
+				c, ok := call.Call.Args[n].(*ssa.Const)
+				if !ok || c.Value == nil || c.Value.Kind() != constant.String {
+					continue
+				}
+				if match(constant.StringVal(c.Value)) {
+					extraNodesByCapability.add(capability, node)
+					break blocks
+				}
+			}
+		}
+	}
+}
+
+// openNameArgument maps functions which open a file to the index of their
+// argument holding the file's name.
+var openNameArgument = map[string]int{
+	"os.Create":    0,
+	"os.Open":      0,
+	"os.OpenFile":  0,
+	"os.ReadDir":   0,
+	"os.ReadFile":  0,
+	"os.WriteFile": 0,
+}
+
+// devicePathPrefixes are the directories holding device files and special
+// file systems.
+var devicePathPrefixes = []string{"/dev/", "/proc/", "/sys/"}
+
+// isDevicePath returns true if the file name s is in one of
+// devicePathPrefixes, such as "/dev/urandom" or "/proc/self/status".
+func isDevicePath(s string) bool {
+	s = path.Clean(s) + "/"
+	for _, prefix := range devicePathPrefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// addDeviceFileFunctions adds the nodes for the functions in allFunctions
+// which open a device file or a file of a special file system, such as
+// /proc/self/status, to extraNodesByCapability under
+// CAPABILITY_FILES_DEVICE.  This is a heuristic: only calls to the functions
+// in openNameArgument whose file name is a constant are recognized.
+func addDeviceFileFunctions(extraNodesByCapability nodesetPerCapability, graph *callgraph.Graph, allFunctions map[*ssa.Function]bool) {
+	addConstantArgumentFunctions(extraNodesByCapability, graph, allFunctions,
+		openNameArgument, isDevicePath, cpb.Capability_CAPABILITY_FILES_DEVICE)
+}
+
+// addInsecureTLSFunctions adds the nodes for the functions in allFunctions
+// which set the InsecureSkipVerify field of a crypto/tls.Config to true, which
+// disables the verification of the certificates of TLS servers, to
+// extraNodesByCapability under CAPABILITY_NETWORK_INSECURE_TLS.  Both
+// composite literals, such as &tls.Config{InsecureSkipVerify: true}, and
+// assignments to the field are compiled to stores to the field's address.
+// This is a heuristic: only stores of the constant true are recognized.
+func addInsecureTLSFunctions(extraNodesByCapability nodesetPerCapability, graph *callgraph.Graph, allFunctions map[*ssa.Function]bool) {
+	for f := range allFunctions {
+		node, ok := graph.Nodes[f]
+		if !ok {
+			continue
+		}
+	blocks:
+		for _, b := range f.Blocks {
+			for _, i := range b.Instrs {
+				store, ok := i.(*ssa.Store)
+				if !ok {
+					continue
+				}
+				c, ok := store.Val.(*ssa.Const)
+				if !ok || c.Value == nil || c.Value.Kind() != constant.Bool || !constant.BoolVal(c.Value) {
+					continue
+				}
+				if fa, ok := store.Addr.(*ssa.FieldAddr); ok && isInsecureSkipVerifyField(fa) {
+					extraNodesByCapability.add(cpb.Capability_CAPABILITY_NETWORK_INSECURE_TLS, node)
+					break blocks
+				}
+			}
+		}
+	}
+}
+
+// isInsecureSkipVerifyField returns true if fa is the address of the
+// InsecureSkipVerify field of a crypto/tls.Config.
+func isInsecureSkipVerifyField(fa *ssa.FieldAddr) bool {
+	ptr, ok := fa.X.Type().Underlying().(*types.Pointer)
+	if !ok {
+		return false
+	}
+	named, ok := types.Unalias(ptr.Elem()).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	if obj.Pkg() == nil || obj.Pkg().Path() != "crypto/tls" || obj.Name() != "Config" {
+		return false
+	}
+	s, ok := named.Underlying().(*types.Struct)
+	return ok && s.Field(fa.Field).Name() == "InsecureSkipVerify"
+}
+
 // findUnsafePointerConversions uses analysis of the syntax tree to find
-// functions which convert unsafe.Pointer values to another type.
-func findUnsafePointerConversions(pkgs []*packages.Package, ssaProg *ssa.Program, allFunctions map[*ssa.Function]bool) (unsafePointer map[*ssa.Function]struct{}) {
+// functions which convert unsafe.Pointer values to another type.  Functions
+// which also convert a pointer to a uintptr and a uintptr back to an
+// unsafe.Pointer are mapped to CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP,
+// and the others to CAPABILITY_UNSAFE_POINTER.
+//
+// Functions which both get a struct field with reflect, and get an unsafe
+// pointer with reflect, are also mapped to
+// CAPABILITY_REFLECT_UNEXPORTED_FIELD.
+//
+// If shallow is true, only the packages in pkgs are examined, and not their
+// dependencies.
+func findUnsafePointerConversions(pkgs []*packages.Package, ssaProg *ssa.Program, allFunctions map[*ssa.Function]bool, shallow bool) (unsafePointer map[*ssa.Function][]cpb.Capability) {
 	// AST nodes corresponding to functions which convert unsafe.Pointer values.
 	unsafeFunctionNodes := make(map[ast.Node]struct{})
+	// AST nodes corresponding to functions which convert pointers to uintptr
+	// values, and uintptr values to pointers, respectively.
+	pointerToUintptrNodes := make(map[ast.Node]struct{})
+	uintptrToPointerNodes := make(map[ast.Node]struct{})
+	// AST nodes corresponding to functions which get struct fields with
+	// reflect, and which get unsafe pointers with reflect, respectively.
+	reflectFieldNodes := make(map[ast.Node]struct{})
+	reflectUnsafeNodes := make(map[ast.Node]struct{})
 	// Packages which contain variables that are initialized using
 	// unsafe.Pointer conversions.  We will later find the function nodes
 	// corresponding to the init functions for these packages.
 	packagesWithUnsafePointerUseInInitialization := make(map[*types.Package]struct{})
-	forEachPackageIncludingDependencies(pkgs, func(pkg *packages.Package) {
+	forEachPackage := forEachPackageIncludingDependencies
+	if shallow {
+		forEachPackage = func(pkgs []*packages.Package, fn func(*packages.Package)) {
+			for _, pkg := range pkgs {
+				fn(pkg)
+			}
+		}
+	}
+	forEachPackage(pkgs, func(pkg *packages.Package) {
 		seenUnsafePointerUseInInitialization := false
 		for _, file := range pkg.Syntax {
 			vis := visitor{
 				unsafeFunctionNodes:                  unsafeFunctionNodes,
+				pointerToUintptrNodes:                pointerToUintptrNodes,
+				uintptrToPointerNodes:                uintptrToPointerNodes,
+				reflectFieldNodes:                    reflectFieldNodes,
+				reflectUnsafeNodes:                   reflectUnsafeNodes,
 				seenUnsafePointerUseInInitialization: &seenUnsafePointerUseInInitialization,
 				pkg:                                  pkg,
 			}
@@ -609,10 +1547,20 @@ func findUnsafePointerConversions(pkgs []*packages.Package, ssaProg *ssa.Program
 	})
 	// Find the *ssa.Function pointers corresponding to the syntax nodes found
 	// above.
-	unsafePointerFunctions := make(map[*ssa.Function]struct{})
+	unsafePointerFunctions := make(map[*ssa.Function][]cpb.Capability)
 	for f := range allFunctions {
-		if _, ok := unsafeFunctionNodes[f.Syntax()]; ok {
-			unsafePointerFunctions[f] = struct{}{}
+		n := f.Syntax()
+		_, toUintptr := pointerToUintptrNodes[n]
+		_, fromUintptr := uintptrToPointerNodes[n]
+		if toUintptr && fromUintptr {
+			unsafePointerFunctions[f] = append(unsafePointerFunctions[f], cpb.Capability_CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP)
+		} else if _, ok := unsafeFunctionNodes[n]; ok {
+			unsafePointerFunctions[f] = append(unsafePointerFunctions[f], cpb.Capability_CAPABILITY_UNSAFE_POINTER)
+		}
+		_, field := reflectFieldNodes[n]
+		_, unsafe := reflectUnsafeNodes[n]
+		if field && unsafe {
+			unsafePointerFunctions[f] = append(unsafePointerFunctions[f], cpb.Capability_CAPABILITY_REFLECT_UNEXPORTED_FIELD)
 		}
 	}
 	for _, pkg := range ssaProg.AllPackages() {
@@ -624,7 +1572,11 @@ func findUnsafePointerConversions(pkgs []*packages.Package, ssaProg *ssa.Program
 			// didn't exist in the source, a synthetic one will have been
 			// created.
 			if f := pkg.Func("init"); f != nil {
-				unsafePointerFunctions[f] = struct{}{}
+				cs := unsafePointerFunctions[f]
+				if !slices.Contains(cs, cpb.Capability_CAPABILITY_UNSAFE_POINTER) &&
+					!slices.Contains(cs, cpb.Capability_CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP) {
+					unsafePointerFunctions[f] = append(cs, cpb.Capability_CAPABILITY_UNSAFE_POINTER)
+				}
 			}
 		}
 	}
@@ -636,6 +1588,7 @@ func getNodeCapabilities(graph *callgraph.Graph,
 ) (safe nodeset, nodesByCapability nodesetPerCapability) {
 	safe = make(nodeset)
 	nodesByCapability = make(nodesetPerCapability)
+	interfaces := findInterfaceCategories(graph, classifier)
 	for _, v := range graph.Nodes {
 		if v.Func == nil {
 			continue
@@ -658,6 +1611,9 @@ func getNodeCapabilities(graph *callgraph.Graph,
 			name := origin.String()
 			c = classifier.FunctionCategory(pkg, name)
 		}
+		if c == cpb.Capability_CAPABILITY_UNSPECIFIED {
+			c = methodInterfaceCategory(v.Func, interfaces)
+		}
 		if c == cpb.Capability_CAPABILITY_SAFE {
 			safe[v] = struct{}{}
 		} else if c != cpb.Capability_CAPABILITY_UNSPECIFIED {
@@ -667,7 +1623,7 @@ func getNodeCapabilities(graph *callgraph.Graph,
 	return safe, nodesByCapability
 }
 
-func mergeCapabilities(nodesByCapability, extraNodesByCapability nodesetPerCapability) (nodesetPerCapability, nodeset) {
+func mergeCapabilities(nodesByCapability, extraNodesByCapability nodesetPerCapability) (nodesetPerCapability, nodeset, nodesetPerCapability) {
 	// We gather here all the nodes which were given an explicit categorization.
 	// We will not search for paths that go through these nodes to reach other
 	// capabilities; for example, we do not report that os.ReadFile also has
@@ -684,7 +1640,15 @@ func mergeCapabilities(nodesByCapability, extraNodesByCapability nodesetPerCapab
 	// extraNodesByCapability contains function capabilities that our analyzer
 	// found by examining the function's source code.  These findings are
 	// ignored when they apply to a function that already has an explicit
-	// category.
+	// category.  The nodes which are added are also returned in heuristic.
+	// The sets are copied first, so that the results of
+	// getPackageNodesWithCapability, which may be shared through a Cache, are
+	// not modified.
+	merged := make(nodesetPerCapability, len(nodesByCapability))
+	for cap, ns := range nodesByCapability {
+		merged[cap] = maps.Clone(ns)
+	}
+	heuristic := make(nodesetPerCapability)
 	for cap, ns := range extraNodesByCapability {
 		for node := range ns {
 			if _, ok := allNodesWithExplicitCapability[node]; ok {
@@ -692,10 +1656,11 @@ func mergeCapabilities(nodesByCapability, extraNodesByCapability nodesetPerCapab
 				// extra capability.
 				continue
 			}
-			nodesByCapability.add(cap, node)
+			merged.add(cap, node)
+			heuristic.add(cap, node)
 		}
 	}
-	return nodesByCapability, allNodesWithExplicitCapability
+	return merged, allNodesWithExplicitCapability, heuristic
 }
 
 // forEachPath analyzes the callgraph rooted at the packages in pkgs.
@@ -708,16 +1673,54 @@ func mergeCapabilities(nodesByCapability, extraNodesByCapability nodesetPerCapab
 // in the callgraph representing the function.  fn can use this information
 // to reconstruct the path.
 //
-// forEachPath may modify pkgs.
+// If config.ReportExportedEntrypoints is set, fn is only called for exported
+// functions, if config.Roots is non-empty, fn is only called for the functions
+// it names, and fn is not called for functions in files excluded by
+// config.ExcludeBuildTags, or for paths which match one of config.IgnorePaths.
+//
+// forEachPath may modify pkgs.  It returns the number of calls which could not
+// be rewritten to make the callgraph more precise, and the functions which
+// were given a capability by analyzing their bodies rather than by the
+// classifier.
 func forEachPath(pkgs []*packages.Package, queriedPackages map[*types.Package]struct{},
 	fn func(cpb.Capability, bfsStateMap, *callgraph.Node), config *Config,
-) {
-	safe, nodesByCapability, extraNodesByCapability := getPackageNodesWithCapability(pkgs, config)
-	nodesByCapability, allNodesWithExplicitCapability := mergeCapabilities(nodesByCapability, extraNodesByCapability)
+) (rewriteFailures int, heuristic nodesetPerCapability) {
+	safe, nodesByCapability, extraNodesByCapability, rewriteFailures := getPackageNodesWithCapability(pkgs, config)
+	nodesByCapability, allNodesWithExplicitCapability, heuristic := mergeCapabilities(nodesByCapability, extraNodesByCapability)
 	extraNodesByCapability = nil // we don't use extraNodesByCapability again.
+	if len(config.IgnorePaths) > 0 {
+		report := fn
+		fn = func(cap cpb.Capability, nodes bfsStateMap, v *callgraph.Node) {
+			if !config.ignoresPath(nodes, v) {
+				report(cap, nodes, v)
+			}
+		}
+	}
+	excludedFiles, _ := buildTagFiles(pkgs, config.ExcludeBuildTags)
+	roots := make(map[string]struct{})
+	for _, r := range config.Roots {
+		roots[r] = struct{}{}
+	}
+	// isEntrypoint returns true if fn should be called for v.
+	isEntrypoint := func(v *callgraph.Node) bool {
+		if len(excludedFiles) > 0 {
+			if _, ok := excludedFiles[v.Func.Prog.Fset.Position(v.Func.Pos()).Filename]; ok {
+				return false
+			}
+		}
+		if len(roots) > 0 {
+			if _, ok := roots[v.Func.String()]; !ok {
+				return false
+			}
+		}
+		return !config.ReportExportedEntrypoints || isExportedFunction(v.Func)
+	}
+	classifier := config.edgeClassifier()
 	var caps []cpb.Capability
 	for cap := range nodesByCapability {
-		caps = append(caps, cap)
+		if config.hasMinSeverity(cap) {
+			caps = append(caps, cap)
+		}
 	}
 	sort.Slice(caps, func(i, j int) bool { return caps[i] < caps[j] })
 	for _, cap := range caps {
@@ -740,7 +1743,7 @@ func forEachPath(pkgs []*packages.Package, queriedPackages map[*types.Package]st
 			if v.Func.Package() == nil {
 				continue
 			}
-			if _, ok := queriedPackages[v.Func.Package().Pkg]; ok {
+			if _, ok := queriedPackages[v.Func.Package().Pkg]; ok && isEntrypoint(v) {
 				// v itself is in one of the queried packages.  Call fn here because
 				// the BFS below will only call fn for functions that call v
 				// directly or transitively.
@@ -754,7 +1757,7 @@ func forEachPath(pkgs []*packages.Package, queriedPackages map[*types.Package]st
 			q = q[1:]
 			var incomingEdges []*callgraph.Edge
 			for _, edge := range v.In {
-				if config.Classifier.IncludeCall(edge) {
+				if classifier.IncludeCall(edge) {
 					incomingEdges = append(incomingEdges, edge)
 				}
 			}
@@ -779,13 +1782,187 @@ func forEachPath(pkgs []*packages.Package, queriedPackages map[*types.Package]st
 				visited[w] = bfsState{edge: edge}
 				q = append(q, w)
 				if w.Func.Package() != nil {
-					if _, ok := queriedPackages[w.Func.Package().Pkg]; ok {
+					if _, ok := queriedPackages[w.Func.Package().Pkg]; ok && isEntrypoint(w) {
 						fn(cap, visited, w)
 					}
 				}
 			}
 		}
 	}
+	return rewriteFailures, heuristic
+}
+
+// reachableFromExported returns the subset of roots which can be reached in
+// the callgraph from an exported function or method of one of the queried
+// packages, including roots which are themselves exported.
+func reachableFromExported(roots nodeset, queriedPackages map[*types.Package]struct{}, classifier Classifier) nodeset {
+	// Only ancestors of the roots are relevant, so first find them with a
+	// backwards search, and then search forwards from the exported functions
+	// among them.
+	ancestors := make(nodeset)
+	var q []*callgraph.Node
+	for v := range roots {
+		ancestors[v] = struct{}{}
+		q = append(q, v)
+	}
+	for len(q) > 0 {
+		v := q[0]
+		q = q[1:]
+		for _, edge := range v.In {
+			w := edge.Caller
+			if _, ok := ancestors[w]; ok || w.Func == nil || !classifier.IncludeCall(edge) {
+				continue
+			}
+			ancestors[w] = struct{}{}
+			q = append(q, w)
+		}
+	}
+	reachable := make(nodeset)
+	for v := range ancestors {
+		if pkg := v.Func.Package(); pkg == nil || !isExportedFunction(v.Func) {
+			continue
+		} else if _, ok := queriedPackages[pkg.Pkg]; !ok {
+			continue
+		}
+		reachable[v] = struct{}{}
+		q = append(q, v)
+	}
+	for len(q) > 0 {
+		v := q[0]
+		q = q[1:]
+		for _, edge := range v.Out {
+			w := edge.Callee
+			if _, ok := ancestors[w]; !ok || !classifier.IncludeCall(edge) {
+				continue
+			}
+			if _, ok := reachable[w]; ok {
+				continue
+			}
+			reachable[w] = struct{}{}
+			q = append(q, w)
+		}
+	}
+	for v := range reachable {
+		if _, ok := roots[v]; !ok {
+			delete(reachable, v)
+		}
+	}
+	return reachable
+}
+
+// sortEdgesBySite sorts edges by the position of their call sites, and then
+// by callee.
+func sortEdgesBySite(edges []*callgraph.Edge) {
+	sort.Slice(edges, func(i, j int) bool {
+		p, q := callsitePosition(edges[i]), callsitePosition(edges[j])
+		if positionLess(p, q) {
+			return true
+		} else if positionLess(q, p) {
+			return false
+		}
+		return nodeCompare(edges[i].Callee, edges[j].Callee) < 0
+	})
+}
+
+// callSiteEdges returns the outgoing edges of v which start a path to a
+// capability in the completed BFS state nodes, with one edge for each distinct
+// call site, sorted by call site position.  It returns nil if v has the
+// capability itself.
+func callSiteEdges(nodes bfsStateMap, v *callgraph.Node, classifier Classifier) []*callgraph.Edge {
+	if nodes[v].edge == nil {
+		return nil
+	}
+	// leadsToCapability returns true if the path recorded in nodes from w to a
+	// capability does not pass through v.
+	leadsToCapability := func(w *callgraph.Node) bool {
+		if _, ok := nodes[w]; !ok {
+			return false
+		}
+		for ; w != nil; w = nodes[w].next() {
+			if w == v {
+				return false
+			}
+		}
+		return true
+	}
+	var edges []*callgraph.Edge
+	for _, edge := range v.Out {
+		if edge.Callee.Func == nil || !classifier.IncludeCall(edge) {
+			continue
+		}
+		if leadsToCapability(edge.Callee) {
+			edges = append(edges, edge)
+		}
+	}
+	sortEdgesBySite(edges)
+	// Keep only the first edge for each call site.
+	return slices.CompactFunc(edges, func(a, b *callgraph.Edge) bool {
+		if a.Site == nil || b.Site == nil {
+			return a == b
+		}
+		return a.Site == b.Site
+	})
+}
+
+// examplePaths returns up to n distinct paths from v to a capability in the
+// completed BFS state nodes, each as the list of edges to follow.  The first
+// path starts with first, if it is non-nil, and then follows the path
+// recorded in nodes.  Each other path leaves the first path at some node
+// after first through a different call, and then follows the path recorded
+// in nodes from the callee.
+func examplePaths(nodes bfsStateMap, v *callgraph.Node, first *callgraph.Edge, n int, classifier Classifier) [][]*callgraph.Edge {
+	var primary []*callgraph.Edge
+	w := v
+	if first != nil {
+		primary = append(primary, first)
+		w = first.Callee
+	}
+	for ; nodes[w].edge != nil; w = nodes[w].next() {
+		primary = append(primary, nodes[w].edge)
+	}
+	paths := [][]*callgraph.Edge{primary}
+	start := 0
+	if first != nil {
+		start = 1
+	}
+	for i := start; i < len(primary) && len(paths) < n; i++ {
+		// Look for a path which shares primary[:i], and so reaches u, but
+		// then takes a different edge.
+		prefix := primary[:i]
+		onPrefix := nodeset{v: struct{}{}}
+		for _, edge := range prefix {
+			onPrefix[edge.Callee] = struct{}{}
+		}
+		u := primary[i].Caller
+		var edges []*callgraph.Edge
+		for _, edge := range u.Out {
+			if edge == primary[i] || edge.Callee.Func == nil || !classifier.IncludeCall(edge) {
+				continue
+			}
+			if _, ok := nodes[edge.Callee]; ok {
+				edges = append(edges, edge)
+			}
+		}
+		sortEdgesBySite(edges)
+	edges:
+		for _, edge := range edges {
+			path := append(slices.Clip(prefix), edge)
+			for w := edge.Callee; w != nil; w = nodes[w].next() {
+				if _, ok := onPrefix[w]; ok {
+					// The path would contain a cycle.
+					continue edges
+				}
+				if nodes[w].edge != nil {
+					path = append(path, nodes[w].edge)
+				}
+			}
+			paths = append(paths, path)
+			if len(paths) == n {
+				break
+			}
+		}
+	}
+	return paths
 }
 
 // intermediatePackages returns a CapabilityInfo for each unique (P, C) pair
@@ -830,6 +2007,7 @@ func intermediatePackages(pkgs []*packages.Package, queriedPackages map[*types.P
 			PackageDir:  proto.String(pkg.Path()),
 			PackageName: proto.String(pkg.Name()),
 		}
+		setCustomCapability(&ci)
 		if !config.OmitPaths {
 			// Add ci.Path entries for the part of the path leading from a function in
 			// pkgs to node, including node itself.
@@ -866,6 +2044,9 @@ func intermediatePackages(pkgs []*packages.Package, queriedPackages map[*types.P
 		if x, y := a.GetCapability(), b.GetCapability(); x != y {
 			return int(x) - int(y)
 		}
+		if x, y := a.GetCustomCapability(), b.GetCustomCapability(); x != y {
+			return strings.Compare(x, y)
+		}
 		return strings.Compare(a.GetPackageDir(), b.GetPackageDir())
 	})
 	return &cpb.CapabilityInfoList{CapabilityInfo: cis}
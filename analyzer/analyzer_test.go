@@ -7,10 +7,21 @@
 package analyzer
 
 import (
+	"bytes"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"go/types"
+	"io"
+	"maps"
 	"os"
+	"path"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/google/capslock/interesting"
@@ -19,6 +30,8 @@ import (
 	"golang.org/x/tools/go/analysis/analysistest"
 	"golang.org/x/tools/go/callgraph"
 	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/ssa/ssautil"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/testing/protocmp"
 )
@@ -35,7 +48,7 @@ func C() { println(os.IsExist(nil)) }
 `}
 
 // setup contains common code for loading test packages.
-func setup(filemap map[string]string, pkg string) (pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, cleanup func(), err error) {
+func setup(filemap map[string]string, pkg ...string) (pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, cleanup func(), err error) {
 	dir, cleanup, err := analysistest.WriteFiles(filemap)
 	if err != nil {
 		return nil, nil, cleanup, fmt.Errorf("analysistest.WriteFiles: %w", err)
@@ -46,7 +59,7 @@ func setup(filemap map[string]string, pkg string) (pkgs []*packages.Package, que
 		Dir:  dir,
 		Env:  append(os.Environ(), env...),
 	}
-	pkgs, err = packages.Load(cfg, pkg)
+	pkgs, err = packages.Load(cfg, pkg...)
 	if err != nil {
 		return nil, nil, cleanup, fmt.Errorf("packages.Load: %w", err)
 	}
@@ -81,8 +94,9 @@ func testAnalysis(t *testing.T, omitPaths bool) {
 				&cpb.Function{Name: proto.String("testlib.Bar"), Package: proto.String("testlib")},
 				&cpb.Function{Name: proto.String("os.Getpid"), Package: proto.String("os")},
 			},
-			PackageDir:     proto.String("testlib"),
-			CapabilityType: cpb.CapabilityType_CAPABILITY_TYPE_DIRECT.Enum(),
+			PackageDir:            proto.String("testlib"),
+			CapabilityType:        cpb.CapabilityType_CAPABILITY_TYPE_DIRECT.Enum(),
+			ReachableFromExported: proto.Bool(true),
 		}, {
 			PackageName: proto.String("testlib"),
 			Capability:  cpb.Capability_CAPABILITY_READ_SYSTEM_STATE.Enum(),
@@ -91,8 +105,9 @@ func testAnalysis(t *testing.T, omitPaths bool) {
 				&cpb.Function{Name: proto.String("testlib.Foo"), Package: proto.String("testlib")},
 				&cpb.Function{Name: proto.String("os.Getpid"), Package: proto.String("os")},
 			},
-			PackageDir:     proto.String("testlib"),
-			CapabilityType: cpb.CapabilityType_CAPABILITY_TYPE_DIRECT.Enum(),
+			PackageDir:            proto.String("testlib"),
+			CapabilityType:        cpb.CapabilityType_CAPABILITY_TYPE_DIRECT.Enum(),
+			ReachableFromExported: proto.Bool(true),
 		}},
 	}
 	if omitPaths {
@@ -223,8 +238,9 @@ func TestAnalysisWithClassifier(t *testing.T) {
 				&cpb.Function{Name: proto.String("testlib.C"), Package: proto.String("testlib")},
 				&cpb.Function{Name: proto.String("os.IsExist"), Package: proto.String("os")},
 			},
-			PackageDir:     proto.String("testlib"),
-			CapabilityType: cpb.CapabilityType_CAPABILITY_TYPE_DIRECT.Enum(),
+			PackageDir:            proto.String("testlib"),
+			CapabilityType:        cpb.CapabilityType_CAPABILITY_TYPE_DIRECT.Enum(),
+			ReachableFromExported: proto.Bool(true),
 		}, {
 			PackageName: proto.String("testlib"),
 			Capability:  cpb.Capability_CAPABILITY_FILES.Enum(),
@@ -233,8 +249,9 @@ func TestAnalysisWithClassifier(t *testing.T) {
 				&cpb.Function{Name: proto.String("testlib.C"), Package: proto.String("testlib")},
 				&cpb.Function{Name: proto.String("os.IsExist"), Package: proto.String("os")},
 			},
-			PackageDir:     proto.String("testlib"),
-			CapabilityType: cpb.CapabilityType_CAPABILITY_TYPE_DIRECT.Enum(),
+			PackageDir:            proto.String("testlib"),
+			CapabilityType:        cpb.CapabilityType_CAPABILITY_TYPE_DIRECT.Enum(),
+			ReachableFromExported: proto.Bool(true),
 		}, {
 			PackageName: proto.String("testlib"),
 			Capability:  cpb.Capability_CAPABILITY_FILES.Enum(),
@@ -242,8 +259,9 @@ func TestAnalysisWithClassifier(t *testing.T) {
 				&cpb.Function{Name: proto.String("testlib.C"), Package: proto.String("testlib")},
 				&cpb.Function{Name: proto.String("os.IsExist"), Package: proto.String("os")},
 			},
-			PackageDir:     proto.String("testlib"),
-			CapabilityType: cpb.CapabilityType_CAPABILITY_TYPE_DIRECT.Enum(),
+			PackageDir:            proto.String("testlib"),
+			CapabilityType:        cpb.CapabilityType_CAPABILITY_TYPE_DIRECT.Enum(),
+			ReachableFromExported: proto.Bool(true),
 		}},
 	}
 	opts := []cmp.Option{
@@ -340,8 +358,9 @@ func TestAnalysisPackageGranularity(t *testing.T) {
 				&cpb.Function{Name: proto.String("testlib.Bar"), Package: proto.String("testlib")},
 				&cpb.Function{Name: proto.String("os.Getpid"), Package: proto.String("os")},
 			},
-			PackageDir:     proto.String("testlib"),
-			CapabilityType: cpb.CapabilityType_CAPABILITY_TYPE_DIRECT.Enum(),
+			PackageDir:            proto.String("testlib"),
+			CapabilityType:        cpb.CapabilityType_CAPABILITY_TYPE_DIRECT.Enum(),
+			ReachableFromExported: proto.Bool(true),
 		}},
 	}
 	opts := []cmp.Option{
@@ -391,6 +410,14 @@ func TestNewCapabilitySet(t *testing.T) {
 			},
 			wantNegated: false,
 		},
+		{
+			list: "NETWORK/LISTEN,CAPABILITY_NETWORK/CLIENT",
+			wantCapabilities: map[cpb.Capability]struct{}{
+				cpb.Capability_CAPABILITY_NETWORK_LISTEN: struct{}{},
+				cpb.Capability_CAPABILITY_NETWORK_CLIENT: struct{}{},
+			},
+			wantNegated: false,
+		},
 		{
 			list: "NETWORK,FILES",
 			wantCapabilities: map[cpb.Capability]struct{}{
@@ -468,6 +495,29 @@ func TestNewCapabilitySet(t *testing.T) {
 	}
 }
 
+func TestCapabilitySetHasSubcapabilities(t *testing.T) {
+	for _, test := range []struct {
+		list string
+		c    cpb.Capability
+		want bool
+	}{
+		{"NETWORK", cpb.Capability_CAPABILITY_NETWORK_LISTEN, true},
+		{"NETWORK/LISTEN", cpb.Capability_CAPABILITY_NETWORK_LISTEN, true},
+		{"NETWORK/LISTEN", cpb.Capability_CAPABILITY_NETWORK_CLIENT, false},
+		{"NETWORK/LISTEN", cpb.Capability_CAPABILITY_NETWORK, false},
+		{"-NETWORK", cpb.Capability_CAPABILITY_NETWORK_CLIENT, false},
+		{"-NETWORK/LISTEN", cpb.Capability_CAPABILITY_NETWORK_CLIENT, true},
+	} {
+		cs, err := NewCapabilitySet(test.list)
+		if err != nil {
+			t.Fatalf("NewCapabilitySet(%q): %v", test.list, err)
+		}
+		if got := cs.Has(test.c); got != test.want {
+			t.Errorf("NewCapabilitySet(%q).Has(%v): got %v, want %v", test.list, test.c, got, test.want)
+		}
+	}
+}
+
 func TestIntermediatePackages(t *testing.T) {
 	filemap := map[string]string{
 		"p1/p1.go": `package p1; func Foo() { Bar() }; func Bar() { }`,
@@ -627,3 +677,1935 @@ func TestIntermediatePackages(t *testing.T) {
 		}
 	}
 }
+
+func TestGraphFocus(t *testing.T) {
+	filemap := map[string]string{
+		"p1/p1.go": `package p1; func Foo() { }`,
+		"p2/p2.go": `package p2; import "p1"; func Foo() { p1.Foo() }`,
+		"p3/p3.go": `package p3; import "p1"; func Foo() { p1.Foo() }`,
+		"p4/p4.go": `package p4; import "p2"; import "p3"; func Foo() { p2.Foo(); p3.Foo() }`,
+	}
+	classifier := testClassifier{
+		functions: map[[2]string]cpb.Capability{
+			{"p1", "p1.Foo"}: cpb.Capability_CAPABILITY_FILES,
+		},
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p4")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, focus := range []string{"p2", "p2/..."} {
+		nodes := make(map[string]struct{})
+		calls := make(map[[2]string]struct{})
+		caps := make(map[string][]cpb.Capability)
+		CapabilityGraph(pkgs, queriedPackages,
+			&Config{
+				Classifier:     &classifier,
+				DisableBuiltin: true,
+				GraphFocus:     focus,
+			},
+			func(_ bfsStateMap, node *callgraph.Node, _ bfsStateMap) {
+				nodes[node.Func.String()] = struct{}{}
+			},
+			func(edge *callgraph.Edge) {
+				calls[[2]string{edge.Caller.Func.String(), edge.Callee.Func.String()}] = struct{}{}
+			},
+			func(fn *callgraph.Node, c cpb.Capability) {
+				f := fn.Func.String()
+				caps[f] = append(caps[f], c)
+			},
+			nil)
+		expectedNodes := map[string]struct{}{
+			"p4.Foo": {},
+			"p2.Foo": {},
+			"p1.Foo": {},
+		}
+		expectedCalls := map[[2]string]struct{}{
+			{"p4.Foo", "p2.Foo"}: {},
+			{"p2.Foo", "p1.Foo"}: {},
+		}
+		expectedCaps := map[string][]cpb.Capability{
+			"p1.Foo": {cpb.Capability_CAPABILITY_FILES},
+		}
+		if !reflect.DeepEqual(nodes, expectedNodes) {
+			t.Errorf("CapabilityGraph with focus %q: got nodes %v want %v",
+				focus, nodes, expectedNodes)
+		}
+		if !reflect.DeepEqual(calls, expectedCalls) {
+			t.Errorf("CapabilityGraph with focus %q: got calls %v want %v",
+				focus, calls, expectedCalls)
+		}
+		if !reflect.DeepEqual(caps, expectedCaps) {
+			t.Errorf("CapabilityGraph with focus %q: got capabilities %v want %v",
+				focus, caps, expectedCaps)
+		}
+	}
+}
+
+func TestPerCallSite(t *testing.T) {
+	filemap := map[string]string{
+		"p1/p1.go": `package p1; func Foo() { }`,
+		"p2/p2.go": `package p2; import "p1"; func Foo() { p1.Foo() }`,
+		"p3/p3.go": `package p3
+import "p1"
+import "p2"
+func Foo() {
+	p1.Foo()
+	p2.Foo()
+	p1.Foo()
+}`,
+	}
+	classifier := testClassifier{
+		functions: map[[2]string]cpb.Capability{
+			{"p1", "p1.Foo"}: cpb.Capability_CAPABILITY_FILES,
+		},
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p3")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, test := range []struct {
+		perCallSite bool
+		paths       []string
+	}{
+		{false, []string{"p3.Foo p1.Foo"}},
+		{true, []string{"p3.Foo p1.Foo", "p3.Foo p2.Foo p1.Foo", "p3.Foo p1.Foo"}},
+	} {
+		cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier:     &classifier,
+			DisableBuiltin: true,
+			PerCallSite:    test.perCallSite,
+		})
+		var paths []string
+		var lines []int64
+		for _, c := range cil.GetCapabilityInfo() {
+			paths = append(paths, c.GetDepPath())
+			lines = append(lines, c.GetPath()[1].GetSite().GetLine())
+		}
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("GetCapabilityInfo with PerCallSite=%v: got paths %q, want %q",
+				test.perCallSite, paths, test.paths)
+		}
+		if test.perCallSite && !reflect.DeepEqual(lines, []int64{5, 6, 7}) {
+			t.Errorf("GetCapabilityInfo with PerCallSite=%v: got call site lines %v, want [5 6 7]",
+				test.perCallSite, lines)
+		}
+	}
+}
+
+func TestExamplesPerKey(t *testing.T) {
+	filemap := map[string]string{
+		"p1/p1.go": `package p1; func Foo() { }`,
+		"p2/p2.go": `package p2; import "p1"; func Foo() { p1.Foo() }`,
+		"p3/p3.go": `package p3
+import "p1"
+import "p2"
+func Foo() {
+	p1.Foo()
+	p2.Foo()
+}`,
+		"p4/p4.go": `package p4
+import "p1"
+import "p2"
+import "p3"
+func Foo() {
+	p3.Foo()
+	p2.Foo()
+}
+func Bar() {
+	p1.Foo()
+	p3.Foo()
+	p2.Foo()
+}`,
+	}
+	classifier := testClassifier{
+		functions: map[[2]string]cpb.Capability{
+			{"p1", "p1.Foo"}: cpb.Capability_CAPABILITY_FILES,
+		},
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p4")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, test := range []struct {
+		examples    int
+		granularity Granularity
+		paths       []string
+	}{
+		{0, GranularityFunction, []string{
+			"p4.Bar p1.Foo",
+			"p4.Foo p2.Foo p1.Foo",
+		}},
+		// The two examples for p4.Foo start with different calls.
+		{2, GranularityFunction, []string{
+			"p4.Bar p1.Foo",
+			"p4.Bar p3.Foo p1.Foo",
+			"p4.Foo p2.Foo p1.Foo",
+			"p4.Foo p3.Foo p1.Foo",
+		}},
+		{5, GranularityFunction, []string{
+			"p4.Bar p1.Foo",
+			"p4.Bar p3.Foo p1.Foo",
+			"p4.Bar p2.Foo p1.Foo",
+			"p4.Foo p2.Foo p1.Foo",
+			"p4.Foo p3.Foo p1.Foo",
+		}},
+		{3, GranularityPackage, []string{
+			"p4.Bar p1.Foo",
+			"p4.Bar p3.Foo p1.Foo",
+			"p4.Bar p2.Foo p1.Foo",
+		}},
+	} {
+		cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier:     &classifier,
+			DisableBuiltin: true,
+			Granularity:    test.granularity,
+			ExamplesPerKey: test.examples,
+		})
+		var paths []string
+		for _, c := range cil.GetCapabilityInfo() {
+			paths = append(paths, c.GetDepPath())
+		}
+		if !reflect.DeepEqual(paths, test.paths) {
+			t.Errorf("GetCapabilityInfo with ExamplesPerKey=%d: got paths %q, want %q",
+				test.examples, paths, test.paths)
+		}
+	}
+}
+
+func TestReportExportedEntrypoints(t *testing.T) {
+	filemap := map[string]string{
+		"p1/p1.go": `package p1; func Foo() { }`,
+		"p2/p2.go": `package p2
+import "p1"
+func Exported() { helper() }
+func helper() { p1.Foo() }
+type T struct{}
+func (T) Method() { helper() }
+type t struct{}
+func (t) Method() { helper() }
+var I interface{ Method() } = t{}
+func Closure() func() { return func() { p1.Foo() } }`,
+	}
+	classifier := testClassifier{
+		functions: map[[2]string]cpb.Capability{
+			{"p1", "p1.Foo"}: cpb.Capability_CAPABILITY_FILES,
+		},
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p2")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, test := range []struct {
+		exportedOnly bool
+		functions    []string
+	}{
+		{false, []string{"p2.Closure$1", "p2.Exported", "p2.helper", "(p2.T).Method", "(p2.t).Method"}},
+		{true, []string{"p2.Exported", "(p2.T).Method"}},
+	} {
+		cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier:                &classifier,
+			DisableBuiltin:            true,
+			ReportExportedEntrypoints: test.exportedOnly,
+		})
+		var functions []string
+		for _, c := range cil.GetCapabilityInfo() {
+			functions = append(functions, c.GetPath()[0].GetName())
+		}
+		if !reflect.DeepEqual(functions, test.functions) {
+			t.Errorf("GetCapabilityInfo with ReportExportedEntrypoints=%v: got functions %q, want %q",
+				test.exportedOnly, functions, test.functions)
+		}
+	}
+}
+
+func TestRoots(t *testing.T) {
+	filemap := map[string]string{
+		"p1/p1.go": `package p1; func Foo() { }; func Bar() { }`,
+		"p2/p2.go": `package p2
+import "p1"
+func Main() { helper() }
+func helper() { p1.Foo() }
+func Other() { p1.Bar() }
+type T struct{}
+func (*T) Run() { p1.Bar() }`,
+	}
+	classifier := testClassifier{
+		functions: map[[2]string]cpb.Capability{
+			{"p1", "p1.Foo"}: cpb.Capability_CAPABILITY_FILES,
+			{"p1", "p1.Bar"}: cpb.Capability_CAPABILITY_NETWORK,
+		},
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p2")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, test := range []struct {
+		roots     []string
+		functions []string
+	}{
+		{nil, []string{"p2.Main", "p2.helper", "p2.Other", "(*p2.T).Run"}},
+		{[]string{"p2.Main"}, []string{"p2.Main"}},
+		{[]string{"p2.Main", "(*p2.T).Run"}, []string{"p2.Main", "(*p2.T).Run"}},
+		{[]string{"p1.Foo"}, nil},
+	} {
+		cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier:     &classifier,
+			DisableBuiltin: true,
+			Roots:          test.roots,
+		})
+		var functions []string
+		for _, c := range cil.GetCapabilityInfo() {
+			functions = append(functions, c.GetPath()[0].GetName())
+		}
+		if !reflect.DeepEqual(functions, test.functions) {
+			t.Errorf("GetCapabilityInfo with Roots=%q: got functions %q, want %q",
+				test.roots, functions, test.functions)
+		}
+	}
+	roots := []string{"p2.Main", "p2.helper", "(*p2.T).Run", "p2.init", "p2.T.Run", "p1.Foo", "p2.Missing"}
+	if got, want := unmatchedRoots(roots, queriedPackages), []string{"p2.T.Run", "p1.Foo", "p2.Missing"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unmatchedRoots(%q): got %q, want %q", roots, got, want)
+	}
+	_, err = RunCapslock(io.Discard, nil, "m", pkgs, queriedPackages, &Config{
+		Classifier:     &classifier,
+		DisableBuiltin: true,
+		Roots:          []string{"p2.Main", "p2.Missing"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "p2.Missing") {
+		t.Errorf("RunCapslock with Roots naming a missing function: got error %v, want one naming it", err)
+	}
+}
+
+func TestIgnorePaths(t *testing.T) {
+	filemap := map[string]string{
+		"p1/p1.go": `package p1; func Foo() { }; func Bar() { }`,
+		"p2/p2.go": `package p2
+import "p1"
+func Main() { helper() }
+func helper() { p1.Foo() }
+func Other() { p1.Bar() }`,
+	}
+	classifier := testClassifier{
+		functions: map[[2]string]cpb.Capability{
+			{"p1", "p1.Foo"}: cpb.Capability_CAPABILITY_FILES,
+			{"p1", "p1.Bar"}: cpb.Capability_CAPABILITY_NETWORK,
+		},
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p2")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, test := range []struct {
+		patterns  []string
+		functions []string
+	}{
+		{nil, []string{"p2.Main", "p2.helper", "p2.Other"}},
+		{[]string{`p2\.helper p1\.Foo`}, []string{"p2.Other"}},
+		{[]string{`^p2\.Main `, `p1\.Bar$`}, []string{"p2.helper"}},
+		{[]string{`p3\.`}, []string{"p2.Main", "p2.helper", "p2.Other"}},
+	} {
+		var ignorePaths []*regexp.Regexp
+		for _, p := range test.patterns {
+			ignorePaths = append(ignorePaths, regexp.MustCompile(p))
+		}
+		cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier:     &classifier,
+			DisableBuiltin: true,
+			IgnorePaths:    ignorePaths,
+		})
+		var functions []string
+		for _, c := range cil.GetCapabilityInfo() {
+			functions = append(functions, c.GetPath()[0].GetName())
+		}
+		if !reflect.DeepEqual(functions, test.functions) {
+			t.Errorf("GetCapabilityInfo with IgnorePaths=%q: got functions %q, want %q",
+				test.patterns, functions, test.functions)
+		}
+	}
+}
+
+func TestReachableFromExported(t *testing.T) {
+	filemap := map[string]string{
+		"p1/p1.go": `package p1; func Foo() { }`,
+		"p2/p2.go": `package p2
+import "p1"
+func Exported() { reachable() }
+func reachable() { p1.Foo() }
+func unreachable() { p1.Foo() }
+var F = unreachable`,
+	}
+	classifier := testClassifier{
+		functions: map[[2]string]cpb.Capability{
+			{"p1", "p1.Foo"}: cpb.Capability_CAPABILITY_FILES,
+		},
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p2")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+		Classifier:     &classifier,
+		DisableBuiltin: true,
+	})
+	got := make(map[string]bool)
+	for _, c := range cil.GetCapabilityInfo() {
+		got[c.GetPath()[0].GetName()] = c.GetReachableFromExported()
+	}
+	want := map[string]bool{
+		"p2.Exported":    true,
+		"p2.reachable":   true,
+		"p2.unreachable": false,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCapabilityInfo: got ReachableFromExported %v, want %v", got, want)
+	}
+}
+
+func TestCapabilityStatsPathLengths(t *testing.T) {
+	filemap := map[string]string{
+		"p1/p1.go": `package p1; func Foo() { }`,
+		"p2/p2.go": `package p2
+import "p1"
+func A() { p1.Foo() }
+func B() { A() }
+func C() { B() }`,
+	}
+	classifier := testClassifier{
+		functions: map[[2]string]cpb.Capability{
+			{"p1", "p1.Foo"}: cpb.Capability_CAPABILITY_FILES,
+		},
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p2")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	stats := GetCapabilityStats(pkgs, queriedPackages, &Config{
+		Classifier:     &classifier,
+		DisableBuiltin: true,
+	})
+	if len(stats.GetCapabilityStats()) != 1 {
+		t.Fatalf("GetCapabilityStats: got %v, want one CapabilityStats", stats)
+	}
+	s := stats.GetCapabilityStats()[0]
+	// The paths from A, B and C have 2, 3 and 4 functions respectively.
+	if s.GetMinPathLength() != 2 || s.GetMedianPathLength() != 3 || s.GetMaxPathLength() != 4 {
+		t.Errorf("GetCapabilityStats: got path lengths %d min, %d median, %d max; want 2 min, 3 median, 4 max",
+			s.GetMinPathLength(), s.GetMedianPathLength(), s.GetMaxPathLength())
+	}
+}
+
+func TestInterfaceCategories(t *testing.T) {
+	filemap := map[string]string{
+		"p1/p1.go": `package p1
+type Runner interface { Run() }
+type Impl struct{}
+func (Impl) Run() {}
+func (Impl) Other() {}
+type PtrImpl struct{}
+func (*PtrImpl) Run() {}
+type NotImpl struct{}
+func (NotImpl) Run(x int) {}`,
+		"p2/p2.go": `package p2
+import "p1"
+func A() { p1.Impl{}.Run() }
+func B() { p1.Impl{}.Other() }
+func C() { (&p1.PtrImpl{}).Run() }
+func D() { p1.NotImpl{}.Run(1) }`,
+	}
+	classifier, err := interesting.LoadClassifier(t.Name(),
+		strings.NewReader("interface p1.Runner CAPABILITY_EXEC\n"), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier: %v", err)
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p2")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+		Classifier:     classifier,
+		DisableBuiltin: true,
+	})
+	var got []string
+	for _, c := range cil.GetCapabilityInfo() {
+		got = append(got, c.GetPath()[0].GetName()+" "+c.GetCapability().String())
+	}
+	want := []string{"p2.A CAPABILITY_EXEC", "p2.C CAPABILITY_EXEC"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCapabilityInfo: got %q, want %q", got, want)
+	}
+}
+
+func TestReportRules(t *testing.T) {
+	filemap := map[string]string{
+		"p1/p1.go": `package p1
+type Runner interface { Run() }
+type Impl struct{}
+func (Impl) Run() {}
+func Foo() {}`,
+		"p3/p3.go": `package p3; func Bar() {}`,
+		"p2/p2.go": `package p2
+import (
+	"p1"
+	"p3"
+	"unsafe"
+)
+func A() { p1.Impl{}.Run() }
+func B() { p1.Foo() }
+func C() { p3.Bar() }
+func D(x *int) *uint { return (*uint)(unsafe.Pointer(x)) }`,
+	}
+	classifier, err := interesting.LoadClassifier(t.Name(), strings.NewReader(`
+interface p1.Runner CAPABILITY_EXEC
+func p1.Foo CAPABILITY_FILES
+package p3/... CAPABILITY_NETWORK
+`), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier: %v", err)
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p2")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, reportRules := range []bool{false, true} {
+		cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier:  classifier,
+			ReportRules: reportRules,
+		})
+		got := make(map[string]string)
+		for _, c := range cil.GetCapabilityInfo() {
+			got[c.GetPath()[0].GetName()] = c.GetRule()
+		}
+		want := map[string]string{
+			"p2.A": "interface p1.Runner CAPABILITY_EXEC",
+			"p2.B": "func p1.Foo CAPABILITY_FILES",
+			"p2.C": "package p3/... CAPABILITY_NETWORK",
+			"p2.D": "builtin analysis: use of unsafe.Pointer",
+			// p2.init calls p3.init.
+			"p2.init": "package p3/... CAPABILITY_NETWORK",
+		}
+		if !reportRules {
+			for k := range want {
+				want[k] = ""
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetCapabilityInfo with ReportRules=%v: got rules %q, want %q", reportRules, got, want)
+		}
+	}
+}
+
+func TestRewriteFailures(t *testing.T) {
+	filemap := map[string]string{
+		"p/p.go": `package p
+import (
+	"os"
+	"sort"
+	"sync"
+)
+var once sync.Once
+func A(s sort.IntSlice) {
+	sort.Sort(s)
+	if sort.Sort(s); true {
+		os.Getenv("A")
+	}
+	once.Do(func() {})
+	go once.Do(func() {})
+}`,
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	var logged []string
+	cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+		Classifier:     &testClassifier1,
+		DisableBuiltin: true,
+		Logf: func(format string, args ...any) {
+			logged = append(logged, fmt.Sprintf(format, args...))
+		},
+	})
+	if got, want := cil.GetUnrewrittenCallCount(), int64(2); got != want {
+		t.Errorf("GetCapabilityInfo: got UnrewrittenCallCount %d, want %d", got, want)
+	}
+	want := []string{
+		"p.go:10:5: could not rewrite call to sort.Sort: the statement is in a position where it cannot be replaced by a block",
+		"p.go:14:5: could not rewrite call to once.Do: the call is not a statement on its own",
+	}
+	if len(logged) != len(want) {
+		t.Fatalf("got logged messages %q, want %q", logged, want)
+	}
+	for i := range want {
+		if !strings.HasSuffix(logged[i], want[i]) {
+			t.Errorf("got logged message %q, want suffix %q", logged[i], want[i])
+		}
+	}
+}
+
+func TestRewriteOnceFunc(t *testing.T) {
+	filemap := map[string]string{
+		"p/p.go": `package p
+import (
+	"os"
+	"sync"
+)
+var f = sync.OnceFunc(func() { os.Getenv("A") })
+func Foo() { f() }
+func Bar() int { return sync.OnceValue(func() int { return 1 })() }
+func Baz() string {
+	s, _ := sync.OnceValues(sync.OnceValues(func() (string, error) { return os.Getenv("B"), nil }))()
+	return s
+}
+func Qux() { sync.OnceFunc(func() {})() }`,
+	}
+	classifier := testClassifier{
+		functions: map[[2]string]cpb.Capability{
+			{"os", "os.Getenv"}: cpb.Capability_CAPABILITY_READ_SYSTEM_STATE,
+		},
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+		Classifier:     &classifier,
+		DisableBuiltin: true,
+	})
+	var got []string
+	for _, c := range cil.GetCapabilityInfo() {
+		got = append(got, c.GetDepPath())
+	}
+	// Without rewriting, calls to the functions returned by sync.OnceFunc and
+	// the others would all reach every function passed to them, so Bar and Qux
+	// would also have the capability.
+	want := []string{
+		"p.Baz p.Baz$1 os.Getenv",
+		"p.Baz$1 os.Getenv",
+		"p.Foo p.init$1 os.Getenv",
+		"p.init$1 os.Getenv",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCapabilityInfo: got paths %q, want %q", got, want)
+	}
+}
+
+func TestCollapseSubcapabilities(t *testing.T) {
+	filemap := map[string]string{
+		"p/p.go": `package p
+import "net"
+func Foo() {
+	net.Dial("tcp", "example.com:80")
+	net.Listen("tcp", ":80")
+}
+func Bar() { net.Dial("tcp", "example.com:80") }`,
+	}
+	classifier := testClassifier{
+		functions: map[[2]string]cpb.Capability{
+			{"net", "net.Dial"}:   cpb.Capability_CAPABILITY_NETWORK_CLIENT,
+			{"net", "net.Listen"}: cpb.Capability_CAPABILITY_NETWORK_LISTEN,
+		},
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, test := range []struct {
+		collapse bool
+		want     []string
+	}{
+		{false, []string{
+			"p.Bar CAPABILITY_NETWORK_CLIENT",
+			"p.Foo CAPABILITY_NETWORK_CLIENT",
+			"p.Foo CAPABILITY_NETWORK_LISTEN",
+		}},
+		{true, []string{
+			"p.Bar CAPABILITY_NETWORK",
+			"p.Foo CAPABILITY_NETWORK",
+		}},
+	} {
+		cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier:              &classifier,
+			DisableBuiltin:          true,
+			CollapseSubcapabilities: test.collapse,
+		})
+		var got []string
+		for _, c := range cil.GetCapabilityInfo() {
+			got = append(got, c.GetPath()[0].GetName()+" "+c.GetCapability().String())
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("GetCapabilityInfo with CollapseSubcapabilities=%v: got %q, want %q", test.collapse, got, test.want)
+		}
+	}
+}
+
+func TestDirectOnly(t *testing.T) {
+	filemap := map[string]string{
+		"example.com/p/p.go": `package p
+import (
+	"os"
+	"sort"
+
+	"example.com/q"
+)
+func A() string { return q.B() }
+func C() string { return os.Getenv("C") }
+func D() {
+	x := []int{2, 1}
+	sort.Slice(x, q.Less)
+}`,
+		"example.com/q/q.go": `package q
+import "os"
+func B() string { return os.Getenv("B") }
+func Less(i, j int) bool { return os.Getenv("L") == "" }`,
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "example.com/p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, test := range []struct {
+		directOnly bool
+		want       []string
+	}{
+		{false, []string{
+			"example.com/p.A CAPABILITY_TYPE_TRANSITIVE",
+			"example.com/p.C CAPABILITY_TYPE_DIRECT",
+			"example.com/p.D CAPABILITY_TYPE_TRANSITIVE",
+		}},
+		{true, []string{
+			"example.com/p.C CAPABILITY_TYPE_DIRECT",
+		}},
+	} {
+		cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier:     interesting.DefaultClassifier(),
+			DisableBuiltin: true,
+			DirectOnly:     test.directOnly,
+		})
+		var got []string
+		for _, c := range cil.GetCapabilityInfo() {
+			if c.GetCapability() != cpb.Capability_CAPABILITY_READ_SYSTEM_STATE {
+				continue
+			}
+			got = append(got, c.GetPath()[0].GetName()+" "+c.GetCapabilityType().String())
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("GetCapabilityInfo with DirectOnly=%v: got %q, want %q", test.directOnly, got, test.want)
+		}
+	}
+}
+
+func TestTrustedPackages(t *testing.T) {
+	filemap := map[string]string{
+		"example.com/trusted/trusted.go": `package trusted; func Write() {}`,
+		"example.com/p/p.go": `package p
+import "example.com/trusted"
+func A() { trusted.Write() }`,
+	}
+	classifier, err := interesting.LoadClassifier(t.Name(), strings.NewReader(`
+func example.com/trusted.Write CAPABILITY_FILES
+`), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier: %v", err)
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "example.com/p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, test := range []struct {
+		trusted    []string
+		directOnly bool
+		want       []string
+	}{
+		{nil, false, []string{"example.com/p.A CAPABILITY_TYPE_TRANSITIVE"}},
+		{nil, true, nil},
+		{[]string{"example.com/trusted/..."}, false, []string{"example.com/p.A CAPABILITY_TYPE_DIRECT"}},
+		{[]string{"example.com/trusted"}, true, []string{"example.com/p.A CAPABILITY_TYPE_DIRECT"}},
+		{[]string{"example.com/t*"}, false, []string{"example.com/p.A CAPABILITY_TYPE_DIRECT"}},
+		{[]string{"example.com/*/x"}, false, []string{"example.com/p.A CAPABILITY_TYPE_TRANSITIVE"}},
+	} {
+		cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier:      classifier,
+			TrustedPackages: test.trusted,
+			DirectOnly:      test.directOnly,
+		})
+		var got []string
+		for _, c := range cil.GetCapabilityInfo() {
+			got = append(got, c.GetPath()[0].GetName()+" "+c.GetCapabilityType().String())
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("GetCapabilityInfo with TrustedPackages=%q, DirectOnly=%v: got %q, want %q", test.trusted, test.directOnly, got, test.want)
+		}
+	}
+	for _, test := range []struct {
+		path string
+		want bool
+	}{
+		{"os", true},
+		{"vendor/golang.org/x/net/dns/dnsmessage", true},
+		{"p1", false},
+		{"example.com/trusted", false},
+		{"example.com", false},
+		{"", true},
+	} {
+		if got := isStdLib(test.path); got != test.want {
+			t.Errorf("isStdLib(%q): got %v, want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestUnsafePointerWithoutDebugMode(t *testing.T) {
+	// The SSA form is built without debug mode, so this checks that the
+	// analysis of unsafe pointer conversions, which uses the syntax of each
+	// function, still finds them.
+	filemap := map[string]string{
+		"example.com/p/p.go": `package p
+import "unsafe"
+func C(x *float64) *uint64 { return (*uint64)(unsafe.Pointer(x)) }`,
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "example.com/p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	counts := GetCapabilityCounts(pkgs, queriedPackages, &Config{
+		Classifier: interesting.DefaultClassifier(),
+	}).GetCapabilityCounts()
+	if got, want := counts["CAPABILITY_UNSAFE_POINTER"], int64(1); got != want {
+		t.Errorf("GetCapabilityCounts: got %d uses of CAPABILITY_UNSAFE_POINTER, want %d; counts: %v", got, want, counts)
+	}
+}
+
+func TestPromotedMethodPackage(t *testing.T) {
+	filemap := map[string]string{
+		"example.com/p/p.go": `package p
+import "os"
+type F struct{ *os.File }
+func Call(f F) error { return f.Chown(1, 2) }
+func Expr(f F) error { return F.Chown(f, 1, 2) }
+func Value(f F) error {
+	g := f.Chown
+	return g(1, 2)
+}`,
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "example.com/p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+		Classifier:     interesting.DefaultClassifier(),
+		DisableBuiltin: true,
+	})
+	found := make(map[string]bool)
+	for _, c := range cil.GetCapabilityInfo() {
+		if c.GetCapability() != cpb.Capability_CAPABILITY_FILES {
+			continue
+		}
+		path := c.GetPath()
+		found[path[0].GetName()] = true
+		for _, fn := range path[1:] {
+			if strings.Contains(fn.GetName(), "Chown") && fn.GetPackage() != "os" {
+				t.Errorf("function %s in path from %s: got package %q, want %q", fn.GetName(), path[0].GetName(), fn.GetPackage(), "os")
+			}
+		}
+		if got, want := c.GetCapabilityType(), cpb.CapabilityType_CAPABILITY_TYPE_DIRECT; got != want {
+			t.Errorf("%s: got capability type %v, want %v", path[0].GetName(), got, want)
+		}
+	}
+	for _, name := range []string{"example.com/p.Call", "example.com/p.Expr", "example.com/p.Value"} {
+		if !found[name] {
+			t.Errorf("GetCapabilityInfo: no CAPABILITY_FILES path from %s", name)
+		}
+	}
+}
+
+func TestNodeToPackageWrappers(t *testing.T) {
+	// The wrappers for the promoted method, the method expression and the
+	// method value all belong to package q, which declares the method.
+	filemap := map[string]string{
+		"example.com/q/q.go": `package q
+type File struct{}
+func (*File) Chown(uid, gid int) error { return nil }`,
+		"example.com/p/p.go": `package p
+import "example.com/q"
+type F struct{ *q.File }
+type I interface{ Chown(int, int) error }
+func Call(f F) error { var i I = f; return i.Chown(1, 2) }
+func Expr(f F) error { return F.Chown(f, 1, 2) }
+func Value(f F) error {
+	g := f.Chown
+	return g(1, 2)
+}`,
+	}
+	pkgs, _, cleanup, err := setup(filemap, "example.com/p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	graph, _ := BuildCallGraph(pkgs)
+	got := make(map[string]string)
+	for f, node := range graph.Nodes {
+		if f == nil || !strings.Contains(f.String(), "Chown") || f.Synthetic == "" {
+			continue
+		}
+		pkg := nodeToPackage(node)
+		if pkg == nil {
+			t.Errorf("nodeToPackage(%s): got nil, want example.com/q", f)
+			continue
+		}
+		got[f.String()] = pkg.Path()
+	}
+	for _, name := range []string{"(example.com/p.F).Chown", "(example.com/p.F).Chown$thunk", "(*example.com/q.File).Chown$bound"} {
+		if _, ok := got[name]; !ok {
+			t.Errorf("found wrappers %v, want one named %s", slices.Sorted(maps.Keys(got)), name)
+		}
+	}
+	for name, pkg := range got {
+		if pkg != "example.com/q" {
+			t.Errorf("nodeToPackage(%s): got %s, want example.com/q", name, pkg)
+		}
+	}
+}
+
+func TestAtInitTime(t *testing.T) {
+	filemap := map[string]string{
+		"p/p.go": `package p
+import "os"
+var X = os.Getpid()
+func init() { println(os.Getenv("A")) }
+func F() string { return os.Getenv("F") }`,
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+		Classifier:     interesting.DefaultClassifier(),
+		DisableBuiltin: true,
+	})
+	var got []string
+	for _, c := range cil.GetCapabilityInfo() {
+		got = append(got, fmt.Sprintf("%s %v", c.GetPath()[0].GetName(), c.GetAtInitTime()))
+	}
+	slices.Sort(got)
+	want := []string{"p.F false", "p.init true", "p.init#1 true"}
+	if !slices.Equal(got, want) {
+		t.Errorf("GetCapabilityInfo: got %q, want %q", got, want)
+	}
+}
+
+func TestStreamCapabilities(t *testing.T) {
+	filemap := map[string]string{
+		"p/p.go": `package p
+import "os"
+var X = os.Getpid()
+func F() string { return os.Getenv("F") }
+func G() { os.Exit(0) }
+func H() string { return F() }`,
+	}
+	describe := func(ci *cpb.CapabilityInfo) string {
+		return fmt.Sprintf("%s %s %v", ci.GetDepPath(), ci.GetCapability(), ci.GetAtInitTime())
+	}
+	var want, got []string
+	for _, stream := range []bool{false, true} {
+		pkgs, queriedPackages, cleanup, err := setup(filemap, "p")
+		if cleanup != nil {
+			defer cleanup()
+		}
+		if err != nil {
+			t.Fatalf("setup: %v", err)
+		}
+		config := &Config{
+			Classifier:     interesting.DefaultClassifier(),
+			DisableBuiltin: true,
+		}
+		if !stream {
+			for _, ci := range GetCapabilityInfo(pkgs, queriedPackages, config).GetCapabilityInfo() {
+				want = append(want, describe(ci))
+			}
+			continue
+		}
+		StreamCapabilities(pkgs, queriedPackages, config, func(ci *cpb.CapabilityInfo) {
+			got = append(got, describe(ci))
+		})
+	}
+	slices.Sort(want)
+	slices.Sort(got)
+	if len(want) == 0 || !slices.Equal(got, want) {
+		t.Errorf("StreamCapabilities: got %q, want %q", got, want)
+	}
+}
+
+func TestExecShell(t *testing.T) {
+	filemap := map[string]string{
+		"p/p.go": `package p
+import (
+	"context"
+	"os/exec"
+)
+func Shell(s string) error { return exec.Command("sh", "-c", s).Run() }
+func ShellPath(s string) error { return exec.Command("/bin/bash", "-c", s).Run() }
+func ShellContext(ctx context.Context, s string) error {
+	return exec.CommandContext(ctx, "cmd.exe", "/C", s).Run()
+}
+func CallShell() error { return Shell("ls") }
+func NotShell() error { return exec.Command("ls").Run() }
+func Variable(name string) error { return exec.Command(name).Run() }`,
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+		Classifier: interesting.DefaultClassifier(),
+	})
+	got := make(map[string][]string)
+	for _, c := range cil.GetCapabilityInfo() {
+		switch c.GetCapability() {
+		case cpb.Capability_CAPABILITY_EXEC, cpb.Capability_CAPABILITY_EXEC_SHELL:
+			got[c.GetCapability().String()] = append(got[c.GetCapability().String()], c.GetPath()[0].GetName())
+		}
+	}
+	want := map[string][]string{
+		"CAPABILITY_EXEC":       {"p.CallShell", "p.NotShell", "p.Shell", "p.ShellContext", "p.ShellPath", "p.Variable"},
+		"CAPABILITY_EXEC_SHELL": {"p.CallShell", "p.Shell", "p.ShellContext", "p.ShellPath"},
+	}
+	for _, s := range got {
+		slices.Sort(s)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCapabilityInfo: got functions %v, want %v", got, want)
+	}
+}
+
+func TestCapabilitiesByType(t *testing.T) {
+	filemap := map[string]string{
+		"p/p.go": `package p
+import (
+	"net"
+	"os"
+)
+type Client struct{}
+func (c *Client) Dial() { net.Dial("tcp", "localhost:80") }
+func (Client) Pid() int { return os.Getpid() }
+func (c *Client) exit() { os.Exit(1) }
+type Safe struct{}
+func (Safe) Nothing() {}
+type hidden struct{}
+func (hidden) Pid() int { return os.Getpid() }
+func Exit() { os.Exit(1) }`,
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	got := GetCapabilitiesByType(pkgs, queriedPackages, &Config{
+		Classifier: interesting.DefaultClassifier(),
+	})
+	want := []TypeCapabilities{{
+		Type: "p.Client",
+		Capabilities: []cpb.Capability{
+			cpb.Capability_CAPABILITY_READ_SYSTEM_STATE,
+			cpb.Capability_CAPABILITY_NETWORK_CLIENT,
+		},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCapabilitiesByType: got %v, want %v", got, want)
+	}
+}
+
+func TestByTypeOutputCustomCapability(t *testing.T) {
+	filemap := map[string]string{
+		"example.com/p/p.go": `package p
+import "example.com/db"
+type Store struct{}
+func (*Store) Get() { db.Query() }`,
+		"example.com/db/db.go": `package db
+func Query() {}`,
+	}
+	classifier, err := interesting.LoadClassifier(t.Name(), strings.NewReader(
+		"define_capability DATABASE\nfunc example.com/db.Query DATABASE\n"), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier: %v", err)
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "example.com/p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	var b bytes.Buffer
+	if err := byTypeOutput(&b, pkgs, queriedPackages, &Config{Classifier: classifier}); err != nil {
+		t.Fatalf("byTypeOutput: %v", err)
+	}
+	if got, want := b.String(), "example.com/p.Store\n\tDATABASE\n"; got != want {
+		t.Errorf("byTypeOutput: got %q, want %q", got, want)
+	}
+}
+
+func TestCapabilitiesByBinary(t *testing.T) {
+	filemap := map[string]string{
+		"tools/lib/lib.go": `package lib
+func Remove() {}
+func Chdir() {}
+func Pid() int { return 1 }`,
+		"tools/a/a.go": `package main
+import "tools/lib"
+var pid = lib.Pid()
+func main() { lib.Remove() }
+// unused is not reachable from main, so its capability is not reported.
+func unused() { lib.Chdir() }`,
+		"tools/b/b.go": `package main
+func main() {}`,
+	}
+	classifier, err := interesting.LoadClassifier(t.Name(), strings.NewReader(`
+func tools/lib.Remove CAPABILITY_FILES
+func tools/lib.Chdir CAPABILITY_MODIFY_SYSTEM_STATE
+func tools/lib.Pid CAPABILITY_READ_SYSTEM_STATE
+`), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier: %v", err)
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "tools/...")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	got := GetCapabilitiesByBinary(pkgs, queriedPackages, &Config{
+		Classifier: classifier,
+	})
+	want := []BinaryCapabilities{{
+		Package: "tools/a",
+		Capabilities: []cpb.Capability{
+			cpb.Capability_CAPABILITY_FILES,
+			cpb.Capability_CAPABILITY_READ_SYSTEM_STATE,
+		},
+	}, {
+		Package: "tools/b",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCapabilitiesByBinary: got %v, want %v", got, want)
+	}
+}
+
+func TestBinariesOutput(t *testing.T) {
+	filemap := map[string]string{
+		"tools/db/db.go": `package db
+func Query() {}`,
+		"tools/a/a.go": `package main
+import "tools/db"
+func main() { db.Query() }`,
+	}
+	classifier, err := interesting.LoadClassifier(t.Name(), strings.NewReader(
+		"define_capability DATABASE\nfunc tools/db.Query DATABASE\n"), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier: %v", err)
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "tools/a")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	var b bytes.Buffer
+	if err := binariesOutput(&b, pkgs, queriedPackages, &Config{Classifier: classifier}); err != nil {
+		t.Fatalf("binariesOutput: %v", err)
+	}
+	// Custom capabilities are written by name.
+	if got, want := b.String(), "tools/a\n\tDATABASE\n"; got != want {
+		t.Errorf("binariesOutput: got %q, want %q", got, want)
+	}
+	// The roots are the entrypoints of the main packages, so other roots
+	// are rejected rather than ignored.
+	err = binariesOutput(io.Discard, pkgs, queriedPackages, &Config{
+		Classifier: classifier,
+		Roots:      []string{"tools/db.Query"},
+	})
+	if err == nil {
+		t.Errorf("binariesOutput with Roots: got no error")
+	}
+}
+
+func TestWriteOSVAnnotations(t *testing.T) {
+	fn := func(name, pkg, module string) *cpb.Function {
+		f := &cpb.Function{Name: proto.String(name), Package: proto.String(pkg)}
+		if module != "" {
+			f.Module = proto.String(module)
+		}
+		return f
+	}
+	cil := &cpb.CapabilityInfoList{
+		CapabilityInfo: []*cpb.CapabilityInfo{{
+			Capability: cpb.Capability_CAPABILITY_NETWORK.Enum(),
+			Path: []*cpb.Function{
+				fn("example.com/app.Run", "example.com/app", "example.com/app"),
+				fn("example.com/lib.Dial", "example.com/lib", "example.com/lib"),
+				fn("net.Dial", "net", ""),
+			},
+		}, {
+			Capability: cpb.Capability_CAPABILITY_FILES.Enum(),
+			Path: []*cpb.Function{
+				fn("example.com/app.Run", "example.com/app", "example.com/app"),
+				fn("os.Open", "os", ""),
+			},
+		}, {
+			Capability: cpb.Capability_CAPABILITY_EXEC.Enum(),
+			Path: []*cpb.Function{
+				fn("example.com/app.Run", "example.com/app", "example.com/app"),
+				fn("example.com/lib.Run", "example.com/lib", "example.com/lib"),
+				fn("os/exec.Command", "os/exec", ""),
+			},
+		}},
+		ModuleInfo: []*cpb.ModuleInfo{{
+			Path:    proto.String("example.com/lib"),
+			Version: proto.String("v1.2.3"),
+		}},
+	}
+	var b strings.Builder
+	if err := writeOSVAnnotations(&b, cil, &Config{}); err != nil {
+		t.Fatalf("writeOSVAnnotations: %v", err)
+	}
+	want := `{
+	"modules": [
+		{
+			"package": {
+				"ecosystem": "Go",
+				"name": "example.com/app"
+			},
+			"capabilities": [
+				"CAPABILITY_FILES"
+			]
+		},
+		{
+			"package": {
+				"ecosystem": "Go",
+				"name": "example.com/lib"
+			},
+			"version": "v1.2.3",
+			"capabilities": [
+				"CAPABILITY_NETWORK",
+				"CAPABILITY_EXEC"
+			]
+		}
+	]
+}
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("writeOSVAnnotations: got diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseForbiddenFunctions(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want []string
+	}{
+		{"reflect.MakeFunc", []string{"reflect.MakeFunc"}},
+		{" os.Open , (*sync.Once).Do", []string{"os.Open", "(*sync.Once).Do"}},
+		{"(example.com/x.T).M", []string{"(example.com/x.T).M"}},
+		{"FILES", nil},
+		{"CAPABILITY_FILES", nil},
+		{"os.Open,", nil},
+		{"example.com/x", nil},
+		{"(*sync.Once.Do", nil},
+		{"os.Open()", nil},
+	} {
+		got, err := ParseForbiddenFunctions(test.in)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("ParseForbiddenFunctions(%q): got %q, want an error", test.in, got)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, test.want) {
+			t.Errorf("ParseForbiddenFunctions(%q): got %q, %v, want %q", test.in, got, err, test.want)
+		}
+	}
+}
+
+func TestCheckForbidden(t *testing.T) {
+	filemap := map[string]string{
+		"example.com/p/p.go": `package p
+import "example.com/q"
+func A() { q.F() }
+func B() { var t q.T; t.M() }`,
+		"example.com/q/q.go": `package q
+func F() { G() }
+func G() {}
+func H() {}
+type T struct{}
+func (*T) M() {}`,
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "example.com/p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	classifier, err := interesting.LoadClassifier(t.Name(), strings.NewReader(""), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier: %v", err)
+	}
+	config := &Config{Classifier: classifier, Cache: new(Cache)}
+	for _, test := range []struct {
+		forbidden []string
+		want      []string // reachable functions, or nil for an error
+	}{
+		{[]string{"example.com/q.G", "example.com/q.H", "(*example.com/q.T).M"}, []string{"example.com/q.G", "(*example.com/q.T).M"}},
+		// Functions in packages which were not loaded cannot be reached.
+		{[]string{"example.com/other.F"}, []string{}},
+		{[]string{"example.com/q.NoSuchFunction"}, nil},
+		{[]string{"(*example.com/q.T).NoSuchMethod"}, nil},
+		{[]string{"(*example.com/q.NoSuchType).M"}, nil},
+	} {
+		var b strings.Builder
+		err := CheckForbidden(&b, pkgs, queriedPackages, config, test.forbidden)
+		if test.want == nil {
+			if _, ok := err.(ForbiddenFoundError); ok || err == nil {
+				t.Errorf("CheckForbidden(%q): got %v, want an error for the invalid name", test.forbidden, err)
+			}
+			continue
+		}
+		var got []string
+		if f, ok := err.(ForbiddenFoundError); ok {
+			got = f.Functions
+		} else if err != nil {
+			t.Fatalf("CheckForbidden(%q): %v", test.forbidden, err)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("CheckForbidden(%q): got %q reachable, want %q", test.forbidden, got, test.want)
+		}
+	}
+}
+
+func TestCheckForbiddenModulesIgnoresFilters(t *testing.T) {
+	// The packages are loaded in module mode, so that functions have
+	// modules.
+	dir, cleanup, err := analysistest.WriteFiles(map[string]string{
+		"app/go.mod": "module example.com/app\n\ngo 1.21\n\n" +
+			"require example.com/lib v1.0.0\n\nreplace example.com/lib => ../lib\n",
+		"app/app.go": `package app
+import "example.com/lib"
+func Run() { lib.Dial() }
+func Other() {}`,
+		"lib/go.mod": "module example.com/lib\n\ngo 1.21\n",
+		"lib/lib.go": `package lib
+func Dial() { connect() }
+func connect() {}`,
+	})
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("WriteFiles: %v", err)
+	}
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: PackagesLoadModeNeeded,
+		Dir:  filepath.Join(dir, "src", "app"),
+		Env:  append(os.Environ(), "GO111MODULE=on", "GOPROXY=off", "GOFLAGS=-mod=mod", "GOWORK=off"),
+	}, "example.com/app")
+	if err != nil {
+		t.Fatalf("packages.Load: %v", err)
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		t.Fatal("packages.Load: packages had errors")
+	}
+	classifier, err := interesting.LoadClassifier(t.Name(), strings.NewReader(
+		"func example.com/lib.connect CAPABILITY_NETWORK\n"), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier: %v", err)
+	}
+	// Each of these settings alone would remove the path from app.Run
+	// through the forbidden module from the output.
+	config := &Config{
+		Classifier:                classifier,
+		Cache:                     new(Cache),
+		Severities:                map[cpb.Capability]interesting.Severity{cpb.Capability_CAPABILITY_NETWORK: interesting.SeverityLow},
+		MinSeverity:               interesting.SeverityHigh,
+		DirectOnly:                true,
+		Roots:                     []string{"example.com/app.Other"},
+		ReportExportedEntrypoints: true,
+		IgnorePaths:               []*regexp.Regexp{regexp.MustCompile(`example\.com/lib\.`)},
+	}
+	queriedPackages := GetQueriedPackages(pkgs)
+	if n := len(GetCapabilityInfo(pkgs, queriedPackages, config).GetCapabilityInfo()); n != 0 {
+		t.Errorf("GetCapabilityInfo: got %d results, want none", n)
+	}
+	var b strings.Builder
+	err = CheckForbiddenModules(&b, pkgs, queriedPackages, config, []string{"example.com/lib"})
+	if f, ok := err.(ForbiddenModuleFoundError); !ok || !slices.Equal(f.Modules, []string{"example.com/lib"}) {
+		t.Errorf("CheckForbiddenModules: got %v, want a ForbiddenModuleFoundError for example.com/lib", err)
+	}
+	if !strings.Contains(b.String(), "example.com/app.Run has capability CAPABILITY_NETWORK from forbidden module example.com/lib") {
+		t.Errorf("CheckForbiddenModules: got output %q, want a path from example.com/app.Run", b.String())
+	}
+}
+
+func TestWriteForbiddenModules(t *testing.T) {
+	fn := func(name, pkg, module string) *cpb.Function {
+		f := &cpb.Function{Name: proto.String(name), Package: proto.String(pkg)}
+		if module != "" {
+			f.Module = proto.String(module)
+		}
+		return f
+	}
+	cil := &cpb.CapabilityInfoList{
+		CapabilityInfo: []*cpb.CapabilityInfo{{
+			Capability: cpb.Capability_CAPABILITY_NETWORK.Enum(),
+			Path: []*cpb.Function{
+				fn("example.com/app.Run", "example.com/app", "example.com/app"),
+				fn("example.com/lib.Dial", "example.com/lib", "example.com/lib"),
+				fn("net.Dial", "net", ""),
+			},
+		}, {
+			Capability: cpb.Capability_CAPABILITY_NETWORK.Enum(),
+			Path: []*cpb.Function{
+				fn("example.com/app.Serve", "example.com/app", "example.com/app"),
+				fn("example.com/lib.Dial", "example.com/lib", "example.com/lib"),
+				fn("net.Dial", "net", ""),
+			},
+		}, {
+			Capability: cpb.Capability_CAPABILITY_FILES.Enum(),
+			Path: []*cpb.Function{
+				fn("example.com/app.Run", "example.com/app", "example.com/app"),
+				fn("os.Open", "os", ""),
+			},
+		}},
+		ModuleInfo: []*cpb.ModuleInfo{{
+			Path:    proto.String("example.com/lib"),
+			Version: proto.String("v1.2.3"),
+		}},
+	}
+	for _, test := range []struct {
+		forbidden []string
+		want      []string
+	}{
+		{[]string{"example.com/lib"}, []string{"example.com/lib"}},
+		{[]string{"example.com/lib@v1.2.3"}, []string{"example.com/lib@v1.2.3"}},
+		{[]string{"example.com/lib@v1.0.0"}, nil},
+		{[]string{"example.com/other", "example.com/app"}, []string{"example.com/app"}},
+	} {
+		var b strings.Builder
+		got := writeForbiddenModules(&b, cil, &Config{}, test.forbidden)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("writeForbiddenModules(%q): got %q, want %q", test.forbidden, got, test.want)
+		}
+		// One path is written for each forbidden module and capability.
+		if got, want := strings.Count(b.String(), "from forbidden module"), len(test.want); got != want {
+			t.Errorf("writeForbiddenModules(%q): wrote %d paths, want %d:\n%s", test.forbidden, got, want, b.String())
+		}
+	}
+}
+
+func TestShallow(t *testing.T) {
+	filemap := map[string]string{
+		"q/q.go": `package q
+import "os"
+func Env() string { return os.Getenv("A") }`,
+		"p/p.go": `package p
+import (
+	"os"
+	"q"
+)
+func Pid() int { return os.Getpid() }
+func Env() string { return q.Env() }`,
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, test := range []struct {
+		shallow bool
+		want    []string
+	}{
+		{false, []string{"p.Env CAPABILITY_READ_SYSTEM_STATE", "p.Pid CAPABILITY_READ_SYSTEM_STATE"}},
+		{true, []string{"p.Pid CAPABILITY_READ_SYSTEM_STATE"}},
+	} {
+		cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier: interesting.DefaultClassifier(),
+			Shallow:    test.shallow,
+		})
+		var got []string
+		for _, c := range cil.GetCapabilityInfo() {
+			got = append(got, c.GetPath()[0].GetName()+" "+c.GetCapability().String())
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, test.want) {
+			t.Errorf("GetCapabilityInfo with Shallow=%v: got %q, want %q", test.shallow, got, test.want)
+		}
+	}
+}
+
+func TestDetectPanics(t *testing.T) {
+	filemap := map[string]string{
+		"p/p.go": `package p
+func A(x int) int {
+	if x < 0 {
+		panic("negative")
+	}
+	return x
+}
+func B() int { return A(1) }
+func C() int { return 1 }`,
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, test := range []struct {
+		detectPanics bool
+		want         []string
+	}{
+		{false, nil},
+		{true, []string{"p.A", "p.B"}},
+	} {
+		cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier:     &testClassifier{},
+			DisableBuiltin: true,
+			DetectPanics:   test.detectPanics,
+		})
+		var got []string
+		for _, c := range cil.GetCapabilityInfo() {
+			if c.GetCapability() == cpb.Capability_CAPABILITY_PANIC {
+				got = append(got, c.GetPath()[0].GetName())
+			}
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("GetCapabilityInfo with DetectPanics=%v: got functions %q with CAPABILITY_PANIC, want %q", test.detectPanics, got, test.want)
+		}
+	}
+}
+
+func TestDetectRecursion(t *testing.T) {
+	filemap := map[string]string{
+		"example.com/p/p.go": `package p
+import "strings"
+func Depth(s string) int {
+	if s == "" {
+		return 0
+	}
+	return 1 + Depth(s[1:])
+}
+func Even(n int) bool { return n == 0 || Odd(n-1) }
+func Odd(n int) bool { return n != 0 && Even(n-1) }
+func UseDepth() int { return Depth("abc") }
+func Plain() string { return strings.Repeat("a", 2) }`,
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "example.com/p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, test := range []struct {
+		detectRecursion bool
+		trusted         []string
+		want            map[string][]string
+		wantLogged      []string
+	}{
+		{false, nil, map[string][]string{}, nil},
+		{
+			true, nil,
+			map[string][]string{
+				"example.com/p.Depth":    {"example.com/p.Depth"},
+				"example.com/p.Even":     {"example.com/p.Even", "example.com/p.Odd"},
+				"example.com/p.Odd":      {"example.com/p.Odd", "example.com/p.Even"},
+				"example.com/p.UseDepth": {"example.com/p.Depth"},
+			},
+			[]string{"recursive cycle: example.com/p.Depth", "recursive cycle: example.com/p.Even, example.com/p.Odd"},
+		},
+		// Cycles in trusted packages are ignored, like those in the
+		// standard library.
+		{true, []string{"example.com/p"}, map[string][]string{}, nil},
+	} {
+		var logged []string
+		cil := GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier:      &testClassifier{},
+			DisableBuiltin:  true,
+			DetectRecursion: test.detectRecursion,
+			TrustedPackages: test.trusted,
+			Logf: func(format string, args ...any) {
+				if s := fmt.Sprintf(format, args...); strings.HasPrefix(s, "recursive cycle") {
+					logged = append(logged, s)
+				}
+			},
+		})
+		got := make(map[string][]string)
+		for _, c := range cil.GetCapabilityInfo() {
+			if c.GetCapability() == cpb.Capability_CAPABILITY_RECURSION {
+				got[c.GetPath()[0].GetName()] = c.GetRecursiveCycle()
+			}
+		}
+		if !maps.EqualFunc(got, test.want, slices.Equal) {
+			t.Errorf("GetCapabilityInfo with DetectRecursion=%v, TrustedPackages=%q: got functions with CAPABILITY_RECURSION and their cycles %q, want %q", test.detectRecursion, test.trusted, got, test.want)
+		}
+		slices.Sort(logged)
+		if !slices.Equal(logged, test.wantLogged) {
+			t.Errorf("GetCapabilityInfo with DetectRecursion=%v, TrustedPackages=%q: got logged cycles %q, want %q", test.detectRecursion, test.trusted, logged, test.wantLogged)
+		}
+	}
+}
+
+func TestVendoredAndGeneratedPackages(t *testing.T) {
+	filemap := map[string]string{
+		"example.com/p/p.go": `package p
+import ("example.com/dep"; "example.com/gen")
+func A() { dep.Write() }
+func B() { gen.Write() }`,
+		"example.com/p/vendor/example.com/dep/dep.go": `package dep; func Write() {}`,
+		"example.com/gen/gen.go": `// Code generated by hand. DO NOT EDIT.
+
+package gen; func Write() {}`,
+	}
+	classifier, err := interesting.LoadClassifier(t.Name(), strings.NewReader(`
+func example.com/p/vendor/example.com/dep.Write CAPABILITY_FILES
+func example.com/gen.Write CAPABILITY_NETWORK
+`), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier: %v", err)
+	}
+	// Wildcards do not match vendored packages, so the vendored package is
+	// named explicitly.
+	all := []string{"example.com/...", "example.com/p/vendor/example.com/dep"}
+	for _, test := range []struct {
+		patterns []string
+		query    bool
+		want     string
+	}{
+		{all, false, "example.com/p.A\tCAPABILITY_FILES\nexample.com/p.B\tCAPABILITY_NETWORK\n"},
+		{all, true, "example.com/p.A\tCAPABILITY_FILES\n" +
+			"example.com/p/vendor/example.com/dep.Write\tCAPABILITY_FILES\n" +
+			"example.com/gen.Write\tCAPABILITY_NETWORK\n" +
+			"example.com/p.B\tCAPABILITY_NETWORK\n"},
+		// Generated packages are still reported if no other packages are
+		// queried.
+		{[]string{"example.com/gen"}, false, "example.com/gen.Write\tCAPABILITY_NETWORK\n"},
+	} {
+		pkgs, queriedPackages, cleanup, err := setup(filemap, test.patterns...)
+		if cleanup != nil {
+			defer cleanup()
+		}
+		if err != nil {
+			t.Fatalf("setup: %v", err)
+		}
+		config := &Config{
+			Classifier:                classifier,
+			QueryVendoredAndGenerated: test.query,
+		}
+		queriedPackages = FilterQueriedPackages(pkgs, queriedPackages, config)
+		var b bytes.Buffer
+		if _, err := RunCapslock(&b, nil, "machine-functions", pkgs, queriedPackages, config); err != nil {
+			t.Fatalf("RunCapslock: %v", err)
+		}
+		if got := b.String(); got != test.want {
+			t.Errorf("RunCapslock for %q with QueryVendoredAndGenerated=%v: got %q, want %q", test.patterns, test.query, got, test.want)
+		}
+	}
+}
+
+func TestPackagesAffectedByErrors(t *testing.T) {
+	filemap := map[string]string{
+		"example.com/broken/broken.go": `package broken
+func F() int { return "not an int" }`,
+		"example.com/uses/uses.go": `package uses
+import "example.com/broken"
+func G() int { return broken.F() }`,
+		"example.com/indirect/indirect.go": `package indirect
+import "example.com/uses"
+func H() int { return uses.G() }`,
+		"example.com/ok/ok.go": `package ok
+func I() int { return 1 }`,
+	}
+	pkgs, _, cleanup, err := setup(filemap, "example.com/...")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	var got []string
+	for _, p := range PackagesAffectedByErrors(pkgs) {
+		got = append(got, p.PkgPath)
+	}
+	slices.Sort(got)
+	// The packages which only depend on the broken one are affected too.
+	want := []string{"example.com/broken", "example.com/indirect", "example.com/uses"}
+	if !slices.Equal(got, want) {
+		t.Errorf("PackagesAffectedByErrors: got %q, want %q", got, want)
+	}
+}
+
+func TestRunCapslockUnanalyzed(t *testing.T) {
+	filemap := map[string]string{
+		"example.com/p/p.go": `package p
+import "example.com/q"
+func A() { q.F() }
+func B() { q.G() }`,
+		"example.com/q/q.go": `package q
+func F() {}
+func G() {}`,
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "example.com/p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	classifier, err := interesting.LoadClassifier(t.Name(), strings.NewReader(
+		"func example.com/q.F CAPABILITY_UNANALYZED\nfunc example.com/q.G CAPABILITY_FILES\n"), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier: %v", err)
+	}
+	cache := new(Cache)
+	for _, test := range []struct {
+		roots []string
+		want  int
+	}{
+		{nil, 1},
+		// The count uses the same roots as the output.
+		{[]string{"example.com/p.B"}, 0},
+	} {
+		var b bytes.Buffer
+		got, err := RunCapslock(&b, nil, "m", pkgs, queriedPackages, &Config{
+			Classifier:      classifier,
+			Roots:           test.roots,
+			CountUnanalyzed: true,
+			Cache:           cache,
+		})
+		if err != nil {
+			t.Fatalf("RunCapslock: %v", err)
+		}
+		if got != test.want {
+			t.Errorf("RunCapslock with roots %q: got %d functions with CAPABILITY_UNANALYZED, want %d; output %q", test.roots, got, test.want, b.String())
+		}
+	}
+	// Without CountUnanalyzed, nothing is counted.
+	got, err := RunCapslock(io.Discard, nil, "m", pkgs, queriedPackages, &Config{Classifier: classifier, Cache: cache})
+	if err != nil {
+		t.Fatalf("RunCapslock: %v", err)
+	}
+	if got != 0 {
+		t.Errorf("RunCapslock without CountUnanalyzed: got %d functions with CAPABILITY_UNANALYZED, want 0", got)
+	}
+}
+
+func TestIsDevicePath(t *testing.T) {
+	for _, test := range []struct {
+		path string
+		want bool
+	}{
+		{"/dev/urandom", true},
+		{"/proc/self/status", true},
+		{"/sys/class/net/eth0/address", true},
+		{"/dev", true},
+		{"//proc/../proc/cpuinfo", true},
+		{"/devices.txt", false},
+		{"dev/null", false},
+		{"/home/user/proc/x", false},
+		{"/", false},
+	} {
+		if got := isDevicePath(test.path); got != test.want {
+			t.Errorf("isDevicePath(%q): got %v, want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestAddConstantArgumentFunctions(t *testing.T) {
+	filemap := map[string]string{
+		"example.com/q/q.go": `package q
+func Run(dir, name string) {}`,
+		"example.com/p/p.go": `package p
+import "example.com/q"
+func Shell() { q.Run("/tmp", "/bin/sh") }
+func Other() { q.Run("/bin/sh", "ls") }
+func Variable(name string) { q.Run("/tmp", name) }`,
+	}
+	pkgs, _, cleanup, err := setup(filemap, "example.com/p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	graph, _, allFunctions, _ := buildGraph(pkgs, false)
+	nodes := make(nodesetPerCapability)
+	addConstantArgumentFunctions(nodes, graph, allFunctions,
+		map[string]int{"example.com/q.Run": 1}, isShellName, cpb.Capability_CAPABILITY_EXEC_SHELL)
+	var got []string
+	for v := range nodes[cpb.Capability_CAPABILITY_EXEC_SHELL] {
+		got = append(got, v.Func.String())
+	}
+	if want := []string{"example.com/p.Shell"}; !slices.Equal(got, want) {
+		t.Errorf("addConstantArgumentFunctions: got %q, want %q", got, want)
+	}
+}
+
+func TestIsFormattingStringMethodCall(t *testing.T) {
+	// The same source is built as package fmt, whose calls to String and
+	// Error methods are made when formatting values, and as another package,
+	// whose calls are followed as usual.
+	const src = `package %s
+
+type Stringer interface{ String() string }
+
+type Named interface{ Name() string }
+
+func Sprint(v any) string {
+	switch v := v.(type) {
+	case Stringer:
+		return v.String()
+	case error:
+		return v.Error()
+	case Named:
+		return v.Name()
+	}
+	return ""
+}
+`
+	for _, test := range []struct {
+		path string
+		want map[string]bool
+	}{
+		{"fmt", map[string]bool{"String": true, "Error": true, "Name": false}},
+		{"example.com/format", map[string]bool{"String": false, "Error": false, "Name": false}},
+	} {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "format.go", fmt.Sprintf(src, path.Base(test.path)), 0)
+		if err != nil {
+			t.Fatalf("parsing source of %s: %v", test.path, err)
+		}
+		pkg, _, err := ssautil.BuildPackage(&types.Config{}, fset, types.NewPackage(test.path, path.Base(test.path)), []*ast.File{f}, 0)
+		if err != nil {
+			t.Fatalf("building package %s: %v", test.path, err)
+		}
+		sprint := pkg.Func("Sprint")
+		got := make(map[string]bool)
+		for _, b := range sprint.Blocks {
+			for _, instr := range b.Instrs {
+				call, ok := instr.(*ssa.Call)
+				if !ok || !call.Call.IsInvoke() {
+					continue
+				}
+				edge := &callgraph.Edge{Caller: &callgraph.Node{Func: sprint}, Site: call}
+				got[call.Call.Method.Name()] = isFormattingStringMethodCall(edge)
+			}
+		}
+		if !maps.Equal(got, test.want) {
+			t.Errorf("isFormattingStringMethodCall for the calls of %s.Sprint: got %v, want %v", test.path, got, test.want)
+		}
+	}
+}
+
+func TestUnusedIgnoreEdgeWithDirectOnly(t *testing.T) {
+	// With DirectOnly, the call from p.A to q.Write is excluded because it
+	// crosses packages, but the "ignore_edge" entries which match it are
+	// still used, since they would exclude it without DirectOnly.
+	filemap := map[string]string{
+		"example.com/q/q.go": `package q; func Write() {}`,
+		"example.com/p/p.go": `package p
+import "example.com/q"
+func A() { q.Write() }
+func B() { q.Write() }`,
+	}
+	declared, err := interesting.LoadClassifier(t.Name(), strings.NewReader(`
+func example.com/q.Write CAPABILITY_FILES
+ignore_edge example.com/p.A example.com/q.Write
+ignore_edge example.com/p.B example.com/q.*
+ignore_edge example.com/p.C example.com/q.*
+`), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier: %v", err)
+	}
+	pkgs, queriedPackages, cleanup, err := setup(filemap, "example.com/p")
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	for _, directOnly := range []bool{false, true} {
+		classifier := declared.WithUsageTracking()
+		GetCapabilityInfo(pkgs, queriedPackages, &Config{
+			Classifier: classifier,
+			DirectOnly: directOnly,
+		})
+		want := []string{"ignore_edge example.com/p.C example.com/q.*"}
+		if got := classifier.UnusedEntries(declared); !slices.Equal(got, want) {
+			t.Errorf("UnusedEntries with DirectOnly=%v: got %q, want %q", directOnly, got, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package analyzer
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// loadTestPackages loads the packages in the testpkgs tree for benchmarks.
+func loadTestPackages(b *testing.B) []*packages.Package {
+	b.Helper()
+	pkgs, err := LoadPackages([]string{"../testpkgs/..."}, LoadConfig{})
+	if err != nil {
+		b.Fatalf("loading packages: %v", err)
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		b.Fatal("packages had errors")
+	}
+	return pkgs
+}
+
+func BenchmarkBuildCallGraph(b *testing.B) {
+	pkgs := loadTestPackages(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if graph, _ := BuildCallGraph(pkgs); len(graph.Nodes) == 0 {
+			b.Fatal("empty callgraph")
+		}
+	}
+}
+
+// BenchmarkSearch measures the searches CapabilityGraph makes for each
+// capability, excluding the construction of the callgraph.
+func BenchmarkSearch(b *testing.B) {
+	pkgs := loadTestPackages(b)
+	queriedPackages := GetQueriedPackages(pkgs)
+	config := &Config{Classifier: GetClassifier(true)}
+	safe, nodesByCapability, extraNodesByCapability, _ := getPackageNodesWithCapability(pkgs, config)
+	nodesByCapability, allNodesWithExplicitCapability, _ := mergeCapabilities(nodesByCapability, extraNodesByCapability)
+	classifier := config.edgeClassifier()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for c, ns := range nodesByCapability {
+			bfsFromCapabilities := searchBackwardsFromCapabilities(nodesetPerCapability{c: ns}, safe, allNodesWithExplicitCapability, classifier)
+			canBeReachedFromQuery := make(nodeset)
+			for v := range bfsFromCapabilities {
+				if v.Func.Package() == nil {
+					continue
+				}
+				if _, ok := queriedPackages[v.Func.Package().Pkg]; ok {
+					canBeReachedFromQuery[v] = struct{}{}
+				}
+			}
+			searchForwardsFromQueriedFunctions(
+				canBeReachedFromQuery,
+				nodesetPerCapability{c: ns},
+				allNodesWithExplicitCapability,
+				bfsFromCapabilities,
+				classifier,
+				nil, nil, nil)
+		}
+	}
+}
+
+// BenchmarkGetCapabilityInfo measures a complete analysis, as done for
+// -output=json.
+func BenchmarkGetCapabilityInfo(b *testing.B) {
+	pkgs := loadTestPackages(b)
+	queriedPackages := GetQueriedPackages(pkgs)
+	config := &Config{Classifier: GetClassifier(true)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cil := GetCapabilityInfo(pkgs, queriedPackages, config)
+		if len(cil.GetCapabilityInfo()) == 0 {
+			b.Fatal("found no capabilities")
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"go/types"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/google/capslock/interesting"
+	cpb "github.com/google/capslock/proto"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/packages"
+)
+
+// BinaryCapabilities holds the capabilities which a command can use when it
+// is run.
+type BinaryCapabilities struct {
+	// Package is the import path of the command's main package.
+	Package string
+	// Capabilities is the set of capabilities, in enum order.
+	Capabilities []cpb.Capability
+}
+
+// GetCapabilitiesByBinary returns, for each main package in the queried
+// packages, the capabilities reachable from its main function and from the
+// initialization of the package, which includes the initialization of all
+// of its dependencies.  This describes what the command can do when it is
+// run, rather than what every function of the package could do if called.
+// Main packages with no capabilities are included, with an empty set.  The
+// result is sorted by package path.  config.Roots is ignored, since the
+// entrypoints of the main packages are the roots.
+func GetCapabilitiesByBinary(pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) []BinaryCapabilities {
+	byBinary := make(map[string]map[cpb.Capability]struct{})
+	var roots []string
+	for p := range queriedPackages {
+		if p.Name() != "main" {
+			continue
+		}
+		byBinary[p.Path()] = make(map[cpb.Capability]struct{})
+		roots = append(roots, p.Path()+".main", p.Path()+".init")
+	}
+	if len(roots) == 0 {
+		return nil
+	}
+	c := *config
+	c.Roots = roots
+	c.ReportExportedEntrypoints = false
+	forEachPath(pkgs, queriedPackages,
+		func(cap cpb.Capability, _ bfsStateMap, v *callgraph.Node) {
+			if p := v.Func.Package(); p != nil {
+				if caps, ok := byBinary[p.Pkg.Path()]; ok {
+					caps[cap] = struct{}{}
+				}
+			}
+		}, &c)
+	var out []BinaryCapabilities
+	for _, path := range slices.Sorted(maps.Keys(byBinary)) {
+		out = append(out, BinaryCapabilities{
+			Package:      path,
+			Capabilities: slices.Sorted(maps.Keys(byBinary[path])),
+		})
+	}
+	return out
+}
+
+// binariesOutput writes the result of GetCapabilitiesByBinary, with a line
+// for each main package followed by an indented line for each of its
+// capabilities.  Since the roots of the analysis are the entrypoints of the
+// main packages, it cannot be combined with config.Roots.
+func binariesOutput(out io.Writer, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) error {
+	if len(config.Roots) > 0 {
+		return fmt.Errorf("-output=binaries cannot be used together with -roots")
+	}
+	binaries := GetCapabilitiesByBinary(pkgs, queriedPackages, config)
+	if len(binaries) == 0 {
+		return fmt.Errorf("none of the queried packages is a main package")
+	}
+	w := bufio.NewWriter(out)
+	for _, b := range binaries {
+		fmt.Fprintln(w, b.Package)
+		for _, c := range b.Capabilities {
+			fmt.Fprintf(w, "\t%s\n", interesting.CapabilityName(c))
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"go/types"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/google/capslock/interesting"
+	cpb "github.com/google/capslock/proto"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+)
+
+// TypeCapabilities holds the capabilities which a user of an exported named
+// type can reach by calling its exported methods.
+type TypeCapabilities struct {
+	// Type is the qualified name of the type, such as "net/http.Client".
+	Type string
+	// Capabilities is the set of capabilities, in enum order.
+	Capabilities []cpb.Capability
+}
+
+// GetCapabilitiesByType returns, for each exported named type in the queried
+// packages which has an exported method with a path to a capability, the
+// capabilities reachable from its exported methods.  Methods with pointer and
+// value receivers are both counted for the named type.  The result is sorted
+// by type name.
+func GetCapabilitiesByType(pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) []TypeCapabilities {
+	c := *config
+	c.ReportExportedEntrypoints = true
+	byType := make(map[string]map[cpb.Capability]struct{})
+	forEachPath(pkgs, queriedPackages,
+		func(cap cpb.Capability, _ bfsStateMap, v *callgraph.Node) {
+			name := methodTypeName(v.Func)
+			if name == "" {
+				return
+			}
+			if byType[name] == nil {
+				byType[name] = make(map[cpb.Capability]struct{})
+			}
+			byType[name][cap] = struct{}{}
+		}, &c)
+	var out []TypeCapabilities
+	for _, name := range slices.Sorted(maps.Keys(byType)) {
+		out = append(out, TypeCapabilities{
+			Type:         name,
+			Capabilities: slices.Sorted(maps.Keys(byType[name])),
+		})
+	}
+	return out
+}
+
+// methodTypeName returns the qualified name of the named type whose method f
+// is, or "" if f is not an exported method of an exported named type.
+func methodTypeName(f *ssa.Function) string {
+	if f.Origin() != nil {
+		f = f.Origin()
+	}
+	if !isExportedFunction(f) {
+		return ""
+	}
+	recv := f.Signature.Recv()
+	if recv == nil {
+		return ""
+	}
+	t := recv.Type()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return ""
+	}
+	return named.Obj().Pkg().Path() + "." + named.Obj().Name()
+}
+
+// byTypeOutput writes the result of GetCapabilitiesByType, with a line for
+// each type followed by an indented line for each of its capabilities.
+func byTypeOutput(out io.Writer, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) error {
+	w := bufio.NewWriter(out)
+	for _, tc := range GetCapabilitiesByType(pkgs, queriedPackages, config) {
+		fmt.Fprintln(w, tc.Type)
+		for _, c := range tc.Capabilities {
+			fmt.Fprintf(w, "\t%s\n", interesting.CapabilityName(c))
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/ssa"
+)
+
+// Cache holds the callgraphs built for a set of packages, and the functions
+// in them which have capabilities, so that several outputs and checks of the
+// same packages, such as RunCapslock followed by CheckForbidden, share one
+// analysis instead of each running their own.  Building a callgraph also
+// rewrites the syntax of the packages, which should only be done once.
+//
+// A Cache must only be used with one set of packages.  It is used by setting
+// Config.Cache; the zero Cache is empty and ready to use.
+type Cache struct {
+	// graphs maps the value of Config.Shallow to the callgraph built with it.
+	graphs map[bool]*cachedGraph
+	// nodes maps the settings which getPackageNodesWithCapability depends on
+	// to its results.
+	nodes map[nodesKey]*cachedNodes
+}
+
+// nodesKey contains the fields of a Config which affect the results of
+// getPackageNodesWithCapability.
+type nodesKey struct {
+	classifier              Classifier
+	disableBuiltin          bool
+	detectPanics            bool
+	detectRecursion         bool
+	collapseSubcapabilities bool
+	shallow                 bool
+	// trustedPackages is Config.TrustedPackages joined with commas, which
+	// affects which cycles DetectRecursion reports.
+	trustedPackages string
+}
+
+// cachedNodes holds the results of getPackageNodesWithCapability.
+type cachedNodes struct {
+	safe                   nodeset
+	nodesByCapability      nodesetPerCapability
+	extraNodesByCapability nodesetPerCapability
+	rewriteFailures        int
+}
+
+// cachedGraph holds the results of buildGraph.
+type cachedGraph struct {
+	graph        *callgraph.Graph
+	ssaProg      *ssa.Program
+	allFunctions map[*ssa.Function]bool
+	failures     []rewriteFailure
+}
+
+// buildGraph returns the results of buildGraph for pkgs, building them only
+// if c has none for the same value of shallow.  If c is nil, the results are
+// always built.
+func (c *Cache) buildGraph(pkgs []*packages.Package, shallow bool) (*callgraph.Graph, *ssa.Program, map[*ssa.Function]bool, []rewriteFailure) {
+	if c == nil {
+		return buildGraph(pkgs, shallow)
+	}
+	g, ok := c.graphs[shallow]
+	if !ok {
+		g = new(cachedGraph)
+		g.graph, g.ssaProg, g.allFunctions, g.failures = buildGraph(pkgs, shallow)
+		if c.graphs == nil {
+			c.graphs = make(map[bool]*cachedGraph)
+		}
+		c.graphs[shallow] = g
+	}
+	return g.graph, g.ssaProg, g.allFunctions, g.failures
+}
+
+// cacheKey returns the key for the results of getPackageNodesWithCapability
+// with config, or false if they cannot be cached because the classifier
+// cannot be compared.
+func cacheKey(config *Config) (nodesKey, bool) {
+	if config.Classifier != nil && !reflect.TypeOf(config.Classifier).Comparable() {
+		return nodesKey{}, false
+	}
+	return nodesKey{
+		classifier:              config.Classifier,
+		disableBuiltin:          config.DisableBuiltin,
+		detectPanics:            config.DetectPanics,
+		detectRecursion:         config.DetectRecursion,
+		collapseSubcapabilities: config.CollapseSubcapabilities,
+		shallow:                 config.Shallow,
+		trustedPackages:         strings.Join(config.TrustedPackages, ","),
+	}, true
+}
+
+// lookupNodes returns the results of getPackageNodesWithCapability stored in
+// c for the settings in config, or nil if there are none.
+func (c *Cache) lookupNodes(config *Config) *cachedNodes {
+	if c == nil {
+		return nil
+	}
+	k, ok := cacheKey(config)
+	if !ok {
+		return nil
+	}
+	return c.nodes[k]
+}
+
+// storeNodes stores n in c as the results of getPackageNodesWithCapability
+// for the settings in config.  It does nothing if c is nil.
+func (c *Cache) storeNodes(config *Config, n *cachedNodes) {
+	if c == nil {
+		return
+	}
+	k, ok := cacheKey(config)
+	if !ok {
+		return
+	}
+	if c.nodes == nil {
+		c.nodes = make(map[nodesKey]*cachedNodes)
+	}
+	c.nodes[k] = n
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package analyzer
+
+import (
+	"bufio"
+	"encoding/json"
+	"go/types"
+	"io"
+	"slices"
+
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/packages"
+)
+
+// callgraphNode is a function in the json form of the callgraph output.
+type callgraphNode struct {
+	Name    string `json:"name"`
+	Package string `json:"package,omitempty"`
+}
+
+// callgraphEdge is a call in the json form of the callgraph output.  Position
+// is the location of the call site, if it is known.
+type callgraphEdge struct {
+	Caller   string `json:"caller"`
+	Callee   string `json:"callee"`
+	Position string `json:"position,omitempty"`
+}
+
+// callgraphOutput writes every edge of the callgraph built for pkgs, rather
+// than only the edges on paths to capabilities, for use by other graph tools.
+// The graph is written in Graphviz DOT format, with one edge per pair of
+// functions, or as a json object listing the functions and each call site if
+// asJSON is true.  If config.CallgraphReachableOnly is set, only functions
+// which can be reached from the queried packages are included.
+func callgraphOutput(out io.Writer, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config, asJSON bool) error {
+	graph, _, _, _ := config.Cache.buildGraph(pkgs, config.Shallow)
+	var nodes []*callgraph.Node
+	for f, v := range graph.Nodes {
+		if f != nil {
+			nodes = append(nodes, v)
+		}
+	}
+	if config.CallgraphReachableOnly {
+		nodes = reachableFromQueried(nodes, queriedPackages)
+	}
+	slices.SortFunc(nodes, nodeCompare)
+	included := make(nodeset)
+	for _, v := range nodes {
+		included[v] = struct{}{}
+	}
+	// edges returns the calls from v to other included functions, ordered by
+	// callee and then by call site.
+	edges := func(v *callgraph.Node) []*callgraph.Edge {
+		var es []*callgraph.Edge
+		for _, e := range v.Out {
+			if _, ok := included[e.Callee]; ok {
+				es = append(es, e)
+			}
+		}
+		slices.SortStableFunc(es, func(a, b *callgraph.Edge) int {
+			if c := nodeCompare(a.Callee, b.Callee); c != 0 {
+				return c
+			}
+			return int(a.Pos() - b.Pos())
+		})
+		return es
+	}
+	w := bufio.NewWriterSize(out, 1<<20)
+	if !asJSON {
+		gb := newGraphBuilder(w, func(v any) string { return v.(*callgraph.Node).Func.String() })
+		for _, v := range nodes {
+			var prev *callgraph.Node
+			for _, e := range edges(v) {
+				if e.Callee != prev {
+					gb.Edge(v, e.Callee)
+				}
+				prev = e.Callee
+			}
+		}
+		gb.Done()
+		return w.Flush()
+	}
+	var result struct {
+		Nodes []callgraphNode `json:"nodes"`
+		Edges []callgraphEdge `json:"edges"`
+	}
+	result.Nodes = []callgraphNode{}
+	result.Edges = []callgraphEdge{}
+	for _, v := range nodes {
+		result.Nodes = append(result.Nodes, callgraphNode{Name: v.Func.String(), Package: packagePath(v.Func)})
+		for _, e := range edges(v) {
+			ce := callgraphEdge{Caller: v.Func.String(), Callee: e.Callee.Func.String()}
+			if pos := callsitePosition(e); pos.IsValid() {
+				ce.Position = pos.String()
+			}
+			result.Edges = append(result.Edges, ce)
+		}
+	}
+	enc := json.NewEncoder(w)
+	if !config.CompactJSON {
+		enc.SetIndent("", "\t")
+	}
+	if err := enc.Encode(result); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// reachableFromQueried returns the nodes which can be reached by following
+// calls from the functions of the queried packages, including those
+// functions.
+func reachableFromQueried(nodes []*callgraph.Node, queriedPackages map[*types.Package]struct{}) []*callgraph.Node {
+	seen := make(nodeset)
+	var queue []*callgraph.Node
+	for _, v := range nodes {
+		if _, ok := queriedPackages[nodeToPackage(v)]; ok {
+			seen[v] = struct{}{}
+			queue = append(queue, v)
+		}
+	}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, e := range v.Out {
+			if _, ok := seen[e.Callee]; !ok && e.Callee.Func != nil {
+				seen[e.Callee] = struct{}{}
+				queue = append(queue, e.Callee)
+			}
+		}
+	}
+	return slices.DeleteFunc(nodes, func(v *callgraph.Node) bool {
+		_, ok := seen[v]
+		return !ok
+	})
+}
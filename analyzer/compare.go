@@ -9,10 +9,12 @@ package analyzer
 import (
 	"fmt"
 	"go/types"
+	"io"
 	"os"
 	"sort"
 	"text/tabwriter"
 
+	"github.com/google/capslock/interesting"
 	cpb "github.com/google/capslock/proto"
 	"golang.org/x/tools/go/packages"
 	"google.golang.org/protobuf/encoding/protojson"
@@ -43,21 +45,32 @@ func GranularityFromString(g string) (Granularity, error) {
 	}
 }
 
-func compare(baselineFilename string, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) (different bool, err error) {
+// compare analyzes pkgs and compares the result with the baselines in
+// baselineFilenames, which should contain the output of -output=json.  If
+// there is more than one baseline, the comparison is made with their union, so
+// a capability is only new if it is absent from every baseline.
+func compare(w io.Writer, baselineFilenames []string, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) (different bool, err error) {
 	if config.Granularity == GranularityUnset {
 		config.Granularity = GranularityPackage
 	}
-	compareData, err := os.ReadFile(baselineFilename)
-	if err != nil {
-		return false, fmt.Errorf("Comparison file should include output from running `%s -output=j`. Error from reading comparison file: %v", programName(), err.Error())
-	}
 	baseline := new(cpb.CapabilityInfoList)
-	err = protojson.Unmarshal(compareData, baseline)
-	if err != nil {
-		return false, fmt.Errorf("Comparison file should include output from running `%s -output=j`. Error from parsing comparison file: %v", programName(), err.Error())
+	for _, baselineFilename := range baselineFilenames {
+		compareData, err := os.ReadFile(baselineFilename)
+		if err != nil {
+			return false, fmt.Errorf("Comparison file should include output from running `%s -output=j`. Error from reading comparison file: %v", programName(), err.Error())
+		}
+		cil := new(cpb.CapabilityInfoList)
+		err = protojson.Unmarshal(compareData, cil)
+		if err != nil {
+			return false, fmt.Errorf("Comparison file should include output from running `%s -output=j`. Error from parsing comparison file: %v", programName(), err.Error())
+		}
+		baseline.CapabilityInfo = append(baseline.CapabilityInfo, cil.CapabilityInfo...)
 	}
 	cil := GetCapabilityInfo(pkgs, queriedPackages, config)
-	return diffCapabilityInfoLists(baseline, cil, config.Granularity), nil
+	if config.Quiet {
+		w = io.Discard
+	}
+	return diffCapabilityInfoLists(w, baseline, cil, config.Granularity, config.CapabilitySet, config.IgnoreRemovals), nil
 }
 
 type mapKey struct {
@@ -68,11 +81,15 @@ type capabilitiesMap map[mapKey]*cpb.CapabilityInfo
 
 // populateMap takes a CapabilityInfoList and returns a map from package
 // or function and capability to a pointer to the corresponding entry in the
-// input.
-func populateMap(cil *cpb.CapabilityInfoList, g Granularity) capabilitiesMap {
+// input.  Entries for capabilities not in cs are omitted.
+func populateMap(cil *cpb.CapabilityInfoList, g Granularity, cs *CapabilitySet) capabilitiesMap {
 	m := make(capabilitiesMap)
 	for _, ci := range cil.GetCapabilityInfo() {
-		mk := mapKey{capability: ci.GetCapability()}
+		c := infoCapability(ci)
+		if !cs.Has(c) {
+			continue
+		}
+		mk := mapKey{capability: c}
 		// The calculation of mk.key depends on the desired granularity.
 		switch g {
 		case GranularityPackage:
@@ -98,9 +115,14 @@ func populateMap(cil *cpb.CapabilityInfoList, g Granularity) capabilitiesMap {
 	return m
 }
 
-func diffCapabilityInfoLists(baseline, current *cpb.CapabilityInfoList, g Granularity) (different bool) {
-	baselineMap := populateMap(baseline, g)
-	currentMap := populateMap(current, g)
+// diffCapabilityInfoLists writes to w a description of the differences
+// between baseline and current, and returns whether there were any.  Only
+// capabilities in cs are compared; a nil cs compares all capabilities.  If
+// ignoreRemovals is true, capabilities which are in baseline but not in
+// current are still described, but do not count as differences.
+func diffCapabilityInfoLists(w io.Writer, baseline, current *cpb.CapabilityInfoList, g Granularity, cs *CapabilitySet, ignoreRemovals bool) (different bool) {
+	baselineMap := populateMap(baseline, g, cs)
+	currentMap := populateMap(current, g, cs)
 	var keys []mapKey
 	for k := range baselineMap {
 		keys = append(keys, k)
@@ -116,44 +138,51 @@ func diffCapabilityInfoLists(baseline, current *cpb.CapabilityInfoList, g Granul
 		}
 		return keys[i].key < keys[j].key
 	})
+	printed := false
 	for _, key := range keys {
 		ciBaseline, inBaseline := baselineMap[key]
 		ciCurrent, inCurrent := currentMap[key]
 		if !inBaseline && inCurrent {
-			if different {
-				fmt.Println()
+			if printed {
+				fmt.Fprintln(w)
 			}
+			printed = true
 			different = true
-			fmt.Printf("Package %s has new capability %s compared to the baseline.\n",
-				key.key, key.capability)
-			printCallPath(ciCurrent.Path)
+			fmt.Fprintf(w, "Package %s has new capability %s compared to the baseline.\n",
+				key.key, interesting.CapabilityName(key.capability))
+			printCallPath(w, ciCurrent.Path)
 		}
 		if inBaseline && !inCurrent {
-			if different {
-				fmt.Println()
+			if printed {
+				fmt.Fprintln(w)
 			}
-			different = true
-			fmt.Printf("Package %s no longer has capability %s which was in the baseline.\n",
-				key.key, key.capability)
-			printCallPath(ciBaseline.Path)
+			printed = true
+			different = different || !ignoreRemovals
+			fmt.Fprintf(w, "Package %s no longer has capability %s which was in the baseline.\n",
+				key.key, interesting.CapabilityName(key.capability))
+			printCallPath(w, ciBaseline.Path)
 		}
 	}
 	return different
 }
 
-func printCallPath(fns []*cpb.Function) {
+func printCallPath(w io.Writer, fns []*cpb.Function) {
 	tw := tabwriter.NewWriter(
-		os.Stdout, // output
-		10,        // minwidth
-		8,         // tabwidth
-		2,         // padding
-		' ',       // padchar
-		0)         // flags
+		w,   // output
+		10,  // minwidth
+		8,   // tabwidth
+		2,   // padding
+		' ', // padchar
+		0)   // flags
 	for _, f := range fns {
 		if f.Site != nil {
 			fmt.Fprint(tw, f.Site.GetFilename(), ":", f.Site.GetLine(), ":", f.Site.GetColumn())
 		}
-		fmt.Fprint(tw, "\t", f.GetName(), "\n")
+		fmt.Fprint(tw, "\t", f.GetName())
+		if f.GetInGoroutine() {
+			fmt.Fprint(tw, " (in goroutine)")
+		}
+		fmt.Fprint(tw, "\n")
 	}
 	tw.Flush()
 }
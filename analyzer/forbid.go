@@ -0,0 +1,284 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package analyzer
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"io"
+	"strings"
+
+	"github.com/google/capslock/interesting"
+	cpb "github.com/google/capslock/proto"
+	"golang.org/x/tools/go/callgraph"
+	"golang.org/x/tools/go/packages"
+)
+
+// ForbiddenFoundError indicates that an analysis ran and found that the
+// queried packages can reach some of the functions the caller forbade.
+type ForbiddenFoundError struct {
+	// Functions contains the names of the forbidden functions that can be
+	// reached.
+	Functions []string
+}
+
+func (f ForbiddenFoundError) Error() string {
+	return fmt.Sprintf("forbidden functions can be reached: %s", strings.Join(f.Functions, ", "))
+}
+
+// ParseForbiddenFunctions parses a comma-separated list of function names,
+// such as "reflect.MakeFunc,(*sync.Once).Do", for CheckForbidden.  Spaces
+// around the names are ignored.  It returns an error if a name is not the
+// name of a function or method in a package.
+func ParseForbiddenFunctions(s string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if _, _, _, ok := parseFunctionName(name); !ok {
+			return nil, fmt.Errorf(`invalid function name %q, want a name such as "reflect.MakeFunc" or "(*sync.Once).Do"`, name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// parseFunctionName splits a function name such as "reflect.MakeFunc" or
+// "(*sync.Once).Do" into the path of its package, the name of its receiver
+// type, if it is a method, and the name of the function or method.
+func parseFunctionName(name string) (pkgPath, recv, fn string, ok bool) {
+	qualified := name
+	if rest, isMethod := strings.CutPrefix(name, "("); isMethod {
+		if qualified, fn, ok = strings.Cut(rest, ")."); !ok {
+			return "", "", "", false
+		}
+		qualified = strings.TrimPrefix(qualified, "*")
+	}
+	// The package path may contain dots, but not after its last slash.
+	i := strings.LastIndex(qualified, ".")
+	if i <= 0 || strings.Contains(qualified[i:], "/") {
+		return "", "", "", false
+	}
+	pkgPath = qualified[:i]
+	if fn == "" {
+		fn = qualified[i+1:]
+	} else {
+		recv = qualified[i+1:]
+		if !token.IsIdentifier(recv) {
+			return "", "", "", false
+		}
+	}
+	if !token.IsIdentifier(fn) {
+		return "", "", "", false
+	}
+	return pkgPath, recv, fn, true
+}
+
+// checkFunctionsExist returns an error if one of names, which are function
+// names accepted by ParseForbiddenFunctions, names a function or method that
+// does not exist in its package, when that package is one of pkgs or their
+// dependencies.  Functions in packages that were not loaded cannot be
+// checked, and cannot be reached either.
+func checkFunctionsExist(pkgs []*packages.Package, names []string) error {
+	loaded := make(map[string]*types.Package)
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		if p.Types != nil {
+			loaded[p.PkgPath] = p.Types
+		}
+	})
+	for _, name := range names {
+		pkgPath, recv, fn, ok := parseFunctionName(name)
+		if !ok {
+			return fmt.Errorf("invalid function name %q", name)
+		}
+		pkg, ok := loaded[pkgPath]
+		if !ok {
+			continue
+		}
+		if recv == "" {
+			if _, ok := pkg.Scope().Lookup(fn).(*types.Func); !ok {
+				return fmt.Errorf("package %s has no function %s", pkgPath, fn)
+			}
+			continue
+		}
+		tn, ok := pkg.Scope().Lookup(recv).(*types.TypeName)
+		if !ok {
+			return fmt.Errorf("package %s has no type %s", pkgPath, recv)
+		}
+		m, _, _ := types.LookupFieldOrMethod(types.NewPointer(tn.Type()), false, pkg, fn)
+		if _, ok := m.(*types.Func); !ok {
+			return fmt.Errorf("type %s.%s has no method %s", pkgPath, recv, fn)
+		}
+	}
+	return nil
+}
+
+// CheckForbidden searches the callgraph for paths from the functions in the
+// queried packages to each of the functions named in forbidden.  Functions
+// are named as in capability maps, for example "reflect.MakeFunc" or
+// "(*sync.Once).Do".  Unlike the search for capabilities, the search follows
+// calls through functions regardless of how the classifier categorizes them.
+// The callgraph is shared with other analyses through config.Cache.
+//
+// For each forbidden function that can be reached, CheckForbidden writes one
+// of the shortest paths to it to w, and it returns a ForbiddenFoundError
+// listing those functions.  If none can be reached, it returns nil.  It
+// returns an error, without searching, if one of the names is not valid, or
+// names a function which does not exist in a package that was loaded.
+func CheckForbidden(w io.Writer, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config, forbidden []string) error {
+	if err := checkFunctionsExist(pkgs, forbidden); err != nil {
+		return err
+	}
+	graph, _, _, _ := config.Cache.buildGraph(pkgs, config.Shallow)
+	targets := make(map[string]nodeset)
+	for _, name := range forbidden {
+		targets[name] = make(nodeset)
+	}
+	for f, v := range graph.Nodes {
+		if f == nil {
+			continue
+		}
+		if ns, ok := targets[f.String()]; ok {
+			ns[v] = struct{}{}
+		}
+	}
+	classifier := config.edgeClassifier()
+	var found []string
+	for _, name := range forbidden {
+		if len(targets[name]) == 0 {
+			continue
+		}
+		nodes := searchBackwardsFromCapabilities(
+			nodesetPerCapability{cpb.Capability_CAPABILITY_UNSPECIFIED: targets[name]},
+			nil, nil, classifier)
+		start, length := shortestPathFromQueried(nodes, queriedPackages)
+		if start == nil {
+			continue
+		}
+		if len(found) > 0 {
+			fmt.Fprintln(w)
+		}
+		found = append(found, name)
+		fmt.Fprintf(w, "%s can reach forbidden function %s:\n", start.Func, name)
+		fns := make([]*cpb.Function, 0, length)
+		var incomingEdge *callgraph.Edge
+		for v := start; v != nil; incomingEdge, v = nodes[v].edge, nodes[v].next() {
+			addFunction(&fns, v, incomingEdge)
+		}
+		printCallPath(w, fns)
+	}
+	if len(found) > 0 {
+		return ForbiddenFoundError{Functions: found}
+	}
+	return nil
+}
+
+// shortestPathFromQueried returns the function in one of the queried packages
+// which has the shortest path in nodes, and the number of functions in that
+// path.  Ties are broken by the name of the function.  It returns nil if
+// nodes contains no function in the queried packages.
+func shortestPathFromQueried(nodes bfsStateMap, queriedPackages map[*types.Package]struct{}) (start *callgraph.Node, length int) {
+	for v := range nodes {
+		if v.Func == nil || v.Func.Package() == nil {
+			continue
+		}
+		if _, ok := queriedPackages[v.Func.Package().Pkg]; !ok {
+			continue
+		}
+		n := 0
+		for u := v; u != nil; u = nodes[u].next() {
+			n++
+		}
+		if start == nil || n < length || (n == length && v.Func.String() < start.Func.String()) {
+			start, length = v, n
+		}
+	}
+	return start, length
+}
+
+// ForbiddenModuleFoundError indicates that an analysis ran and found that
+// some of the modules the caller forbade contribute capabilities to the
+// queried packages.
+type ForbiddenModuleFoundError struct {
+	// Modules contains the forbidden modules, as they were specified, which
+	// contribute capabilities.
+	Modules []string
+}
+
+func (f ForbiddenModuleFoundError) Error() string {
+	return fmt.Sprintf("forbidden modules contribute capabilities: %s", strings.Join(f.Modules, ", "))
+}
+
+// CheckForbiddenModules checks whether any of the modules in forbidden
+// contributes a capability to the queried packages.  Modules are given by
+// path, such as "example.com/mod", or by path and version, such as
+// "example.com/mod@v1.2.3", in which case only that version of the module
+// is forbidden.  A capability is contributed by the module of the function
+// to which it is attributed, as in the matrix output: the last function in
+// its call path which is not in the standard library.
+//
+// The settings of config which only filter or reshape the output, such as
+// MinSeverity, DirectOnly, Roots and IgnorePaths, are ignored, so that they cannot hide a
+// capability from the check.  The analysis itself is shared with other
+// analyses through config.Cache.
+//
+// For each capability that a forbidden module contributes,
+// CheckForbiddenModules writes a call path to it to w, and it returns a
+// ForbiddenModuleFoundError listing those modules.  If there are none, it
+// returns nil.
+func CheckForbiddenModules(w io.Writer, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config, forbidden []string) error {
+	c := *config
+	c.Granularity = GranularityFunction
+	c.OmitPaths = false
+	c.RelativePaths = false
+	c.PerCallSite = false
+	c.ExamplesPerKey = 1
+	c.MinSeverity = interesting.SeverityUnspecified
+	c.DirectOnly = false
+	c.Roots = nil
+	c.ReportExportedEntrypoints = false
+	c.IgnorePaths = nil
+	if found := writeForbiddenModules(w, GetCapabilityInfo(pkgs, queriedPackages, &c), &c, forbidden); len(found) > 0 {
+		return ForbiddenModuleFoundError{Modules: found}
+	}
+	return nil
+}
+
+// writeForbiddenModules writes to w a call path for each capability in cil
+// that a module in forbidden contributes, and returns those modules.
+func writeForbiddenModules(w io.Writer, cil *cpb.CapabilityInfoList, config *Config, forbidden []string) (found []string) {
+	versions := make(map[string]string)
+	for _, m := range cil.GetModuleInfo() {
+		versions[m.GetPath()] = m.GetVersion()
+	}
+	for _, spec := range forbidden {
+		path, version, hasVersion := strings.Cut(spec, "@")
+		if hasVersion && versions[path] != version {
+			continue
+		}
+		// reported contains the capabilities for which a path through the
+		// module has been written, so that only one is written for each.
+		reported := make(map[cpb.Capability]bool)
+		for _, ci := range cil.GetCapabilityInfo() {
+			leaf := attributedFunction(ci, config)
+			if leaf == nil || leaf.GetModule() != path || reported[infoCapability(ci)] {
+				continue
+			}
+			if len(found) > 0 || len(reported) > 0 {
+				fmt.Fprintln(w)
+			}
+			if len(reported) == 0 {
+				found = append(found, spec)
+			}
+			reported[infoCapability(ci)] = true
+			fmt.Fprintf(w, "%s has capability %s from forbidden module %s:\n",
+				ci.GetPath()[0].GetName(), interesting.CapabilityName(infoCapability(ci)), spec)
+			printCallPath(w, ci.GetPath())
+		}
+	}
+	return found
+}
@@ -0,0 +1,97 @@
+// Copyright 2023 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"go/types"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/capslock/interesting"
+	cpb "github.com/google/capslock/proto"
+	"golang.org/x/tools/go/packages"
+)
+
+// genmapOutput writes a capability map, in the format accepted by
+// interesting.LoadClassifier, which declares the capabilities used by the
+// queried packages.
+//
+// By default, the map contains a "package" line for each package.  With
+// GranularityFunction, it contains a "func" line for each function instead.
+func genmapOutput(out io.Writer, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) error {
+	if config.Granularity == GranularityUnset {
+		config.Granularity = GranularityPackage
+	}
+	cil := GetCapabilityInfo(pkgs, queriedPackages, config)
+	w := bufio.NewWriter(out)
+	writeCapabilityMap(w, cil, config.Granularity)
+	return w.Flush()
+}
+
+// writeCapabilityMap writes the capability map for cil to w.  A capability map
+// can only assign one capability to each package or function, so if a key has
+// several capabilities, the first is written as a directive and the rest are
+// written as comments.  Keys that cannot be expressed in a capability map,
+// such as the names of some synthetic functions, are also written as comments.
+func writeCapabilityMap(w io.Writer, cil *cpb.CapabilityInfoList, g Granularity) {
+	keyword := "package"
+	if g == GranularityFunction {
+		keyword = "func"
+	}
+	caps := make(map[string]map[cpb.Capability]struct{})
+	for _, ci := range cil.GetCapabilityInfo() {
+		key := ci.GetPackageDir()
+		if g == GranularityFunction {
+			path := ci.GetPath()
+			if len(path) == 0 {
+				continue
+			}
+			key = path[0].GetName()
+		}
+		if caps[key] == nil {
+			caps[key] = make(map[cpb.Capability]struct{})
+		}
+		caps[key][infoCapability(ci)] = struct{}{}
+	}
+	keys := make([]string, 0, len(caps))
+	for key := range caps {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(w, "# Capability map generated by %s -output=genmap.\n", programName())
+	custom := make(map[string]struct{})
+	for _, cs := range caps {
+		for c := range cs {
+			if name, ok := interesting.CustomCapability(c); ok {
+				custom[name] = struct{}{}
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(custom)) {
+		fmt.Fprintf(w, "define_capability %s\n", name)
+	}
+	for _, key := range keys {
+		var cs []cpb.Capability
+		for c := range caps[key] {
+			cs = append(cs, c)
+		}
+		sort.Slice(cs, func(i, j int) bool { return cs[i] < cs[j] })
+		commented := cs
+		if !strings.ContainsAny(key, "# \t") {
+			fmt.Fprintf(w, "%s %s %s\n", keyword, key, interesting.CapabilityName(cs[0]))
+			commented = cs[1:]
+		}
+		for _, c := range commented {
+			fmt.Fprintf(w, "# %s %s %s\n", keyword, key, interesting.CapabilityName(c))
+		}
+	}
+}
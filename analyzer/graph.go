@@ -11,10 +11,12 @@ import (
 	"fmt"
 	"go/types"
 	"io"
-	"os"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/google/capslock/interesting"
 	cpb "github.com/google/capslock/proto"
 	"golang.org/x/tools/go/callgraph"
 	"golang.org/x/tools/go/packages"
@@ -33,6 +35,14 @@ func (cs *CapabilitySet) Has(c cpb.Capability) bool {
 		return true
 	}
 	_, ok := cs.capabilities[c]
+	if p, isSub := interesting.ParentCapability(c); !ok && isSub {
+		// A subcapability is in the set if its parent is.
+		_, ok = cs.capabilities[p]
+	}
+	if _, isCustom := interesting.CustomCapability(c); !ok && isCustom {
+		// Custom capabilities are in the set if CAPABILITY_CUSTOM is.
+		_, ok = cs.capabilities[cpb.Capability_CAPABILITY_CUSTOM]
+	}
 	return ok != cs.negated
 }
 
@@ -41,7 +51,9 @@ func (cs *CapabilitySet) Has(c cpb.Capability) bool {
 // If cs is empty, a nil *CapabilitySet is returned, which represents the set
 // of all capabilities.  Otherwise, cs should be a comma-separated list of
 // capabilities.  Optionally, all capabilities can be prefixed with '-' to
-// specify the capabilities to exclude from the set.
+// specify the capabilities to exclude from the set.  Subcapabilities can be
+// written like "NETWORK/CLIENT", and a set containing a capability also
+// contains its subcapabilities.
 func NewCapabilitySet(cs string) (*CapabilitySet, error) {
 	if len(cs) == 0 {
 		return nil, nil
@@ -60,21 +72,31 @@ func NewCapabilitySet(cs string) (*CapabilitySet, error) {
 			return nil, fmt.Errorf("mix of negated and unnegated capabilities specified: %q", cs)
 		}
 		negated = neg
-		c, ok := cpb.Capability_value[s]
-		if !ok {
-			c, ok = cpb.Capability_value["CAPABILITY_"+s]
-		}
+		c, ok := interesting.ParseCapability(s)
 		if !ok {
 			return nil, fmt.Errorf("unknown capability %q", s)
 		}
-		out[cpb.Capability(c)] = struct{}{}
+		out[c] = struct{}{}
 	}
 	return &CapabilitySet{out, negated}, nil
 }
 
-func graphOutput(pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) error {
-	w := bufio.NewWriterSize(os.Stdout, 1<<20)
-	gb := newGraphBuilder(w, func(v interface{}) string {
+// graphWriter writes a graph in a particular format, such as DOT or Mermaid.
+type graphWriter interface {
+	// Edge writes an edge between two nodes.
+	Edge(from, to any)
+	// Cluster writes a group of nodes which are drawn together in a box
+	// labeled with label.  Each cluster in the graph needs a different id.
+	Cluster(id int, label string, nodes []any)
+	// Done finishes the graph.
+	Done()
+}
+
+// graphOutput writes the capability graph in Graphviz DOT format, or in
+// Mermaid flowchart format if mermaid is true.
+func graphOutput(out io.Writer, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config, mermaid bool) error {
+	w := bufio.NewWriterSize(out, 1<<20)
+	nodeNamer := func(v interface{}) string {
 		switch v := v.(type) {
 		case *callgraph.Node:
 			if v.Func != nil {
@@ -82,34 +104,70 @@ func graphOutput(pkgs []*packages.Package, queriedPackages map[*types.Package]st
 			}
 			return strconv.Itoa(v.ID)
 		case cpb.Capability:
-			return v.String()
+			return interesting.CapabilityName(v)
 		default:
 			panic("unexpected node type")
 		}
-	})
+	}
+	var gb graphWriter
+	if mermaid {
+		gb = newMermaidGraphBuilder(w, nodeNamer)
+	} else {
+		gb = newGraphBuilder(w, nodeNamer)
+	}
+	// Group the nodes for functions by the module containing them, so that
+	// they can be drawn as clusters showing the boundaries between modules.
+	modules := packageModules(pkgs)
+	moduleNodes := make(map[string]nodeset)
+	addToModule := func(node *callgraph.Node) {
+		pkg := nodeToPackage(node)
+		if pkg == nil {
+			return
+		}
+		m, ok := modules[pkg.Path()]
+		if !ok {
+			// The standard library, or a package with no module information.
+			return
+		}
+		if moduleNodes[m] == nil {
+			moduleNodes[m] = make(nodeset)
+		}
+		moduleNodes[m][node] = struct{}{}
+	}
 	callEdge := func(edge *callgraph.Edge) {
 		gb.Edge(edge.Caller, edge.Callee)
+		addToModule(edge.Caller)
+		addToModule(edge.Callee)
 	}
 	capabilityEdge := func(fn *callgraph.Node, c cpb.Capability) {
 		gb.Edge(fn, c)
+		addToModule(fn)
 	}
 	var filter func(c cpb.Capability) bool
 	if config.CapabilitySet != nil {
 		filter = config.CapabilitySet.Has
 	}
 	CapabilityGraph(pkgs, queriedPackages, config, nil, callEdge, capabilityEdge, filter)
+	for i, m := range slices.Sorted(maps.Keys(moduleNodes)) {
+		var nodes []any
+		for _, node := range slices.SortedFunc(maps.Keys(moduleNodes[m]), nodeCompare) {
+			nodes = append(nodes, node)
+		}
+		gb.Cluster(i, m, nodes)
+	}
 	gb.Done()
 	return w.Flush()
 }
 
+// graphBuilder writes a graph in Graphviz DOT format.
 type graphBuilder struct {
 	io.Writer
 	nodeNamer func(any) string
 	done      bool
 }
 
-func newGraphBuilder(w io.Writer, nodeNamer func(any) string) graphBuilder {
-	gb := graphBuilder{
+func newGraphBuilder(w io.Writer, nodeNamer func(any) string) *graphBuilder {
+	gb := &graphBuilder{
 		Writer:    w,
 		nodeNamer: nodeNamer,
 	}
@@ -129,6 +187,21 @@ func (gb *graphBuilder) Edge(from, to interface{}) {
 	gb.Write([]byte("\"\n"))
 }
 
+// Cluster writes a subgraph containing the given nodes, which Graphviz draws
+// in a box labeled with label.  Each cluster in the graph needs a different
+// id.
+func (gb *graphBuilder) Cluster(id int, label string, nodes []any) {
+	if gb.done {
+		panic("done")
+	}
+	fmt.Fprintf(gb, "\tsubgraph \"cluster_%d\" {\n", id)
+	fmt.Fprintf(gb, "\t\tlabel=\"%s\"\n", strings.ReplaceAll(label, `"`, `\"`))
+	for _, node := range nodes {
+		fmt.Fprintf(gb, "\t\t\"%s\"\n", strings.ReplaceAll(gb.nodeNamer(node), `"`, `\"`))
+	}
+	gb.Write([]byte("\t}\n"))
+}
+
 func (gb *graphBuilder) Done() {
 	if gb.done {
 		panic("done")
@@ -136,3 +209,69 @@ func (gb *graphBuilder) Done() {
 	gb.Write([]byte("}\n"))
 	gb.done = true
 }
+
+// mermaidGraphBuilder writes a graph as a Mermaid flowchart.  Mermaid is
+// restrictive about the characters allowed in node IDs, so each node is given
+// a generated ID, and the node's name is used as its label.
+type mermaidGraphBuilder struct {
+	io.Writer
+	nodeNamer func(any) string
+	ids       map[string]int
+	done      bool
+}
+
+func newMermaidGraphBuilder(w io.Writer, nodeNamer func(any) string) *mermaidGraphBuilder {
+	gb := &mermaidGraphBuilder{
+		Writer:    w,
+		nodeNamer: nodeNamer,
+		ids:       make(map[string]int),
+	}
+	gb.Write([]byte("flowchart LR\n"))
+	return gb
+}
+
+// mermaidEscaper replaces characters which Mermaid would otherwise interpret
+// in a quoted label with entity codes.
+var mermaidEscaper = strings.NewReplacer(
+	`"`, "#quot;",
+	"#", "#35;",
+	"<", "#lt;",
+	">", "#gt;",
+)
+
+// node returns the text used to refer to a node.  The first time a node is
+// written, this includes its label.
+func (gb *mermaidGraphBuilder) node(v any) string {
+	name := gb.nodeNamer(v)
+	if id, ok := gb.ids[name]; ok {
+		return "n" + strconv.Itoa(id)
+	}
+	id := len(gb.ids)
+	gb.ids[name] = id
+	return fmt.Sprintf("n%d[\"%s\"]", id, mermaidEscaper.Replace(name))
+}
+
+func (gb *mermaidGraphBuilder) Edge(from, to any) {
+	if gb.done {
+		panic("done")
+	}
+	fmt.Fprintf(gb, "\t%s --> %s\n", gb.node(from), gb.node(to))
+}
+
+func (gb *mermaidGraphBuilder) Cluster(id int, label string, nodes []any) {
+	if gb.done {
+		panic("done")
+	}
+	fmt.Fprintf(gb, "\tsubgraph cluster_%d [\"%s\"]\n", id, mermaidEscaper.Replace(label))
+	for _, node := range nodes {
+		fmt.Fprintf(gb, "\t\t%s\n", gb.node(node))
+	}
+	gb.Write([]byte("\tend\n"))
+}
+
+func (gb *mermaidGraphBuilder) Done() {
+	if gb.done {
+		panic("done")
+	}
+	gb.done = true
+}
@@ -7,10 +7,12 @@
 package analyzer
 
 import (
+	"fmt"
 	"go/types"
 	"os"
 	"path"
 	"sort"
+	"strings"
 	"sync"
 
 	cpb "github.com/google/capslock/proto"
@@ -21,16 +23,32 @@ import (
 var (
 	standardLibraryPackagesOnce sync.Once
 	standardLibraryPackagesMap  map[string]struct{}
+	standardLibraryPackagesErr  error
 )
 
 // LoadConfig specifies the build tags, GOOS value, and GOARCH value to use
 // when loading packages.  These will be used to determine when a file's build
 // constraint is satisfied.  See
 // https://pkg.go.dev/cmd/go#hdr-Build_constraints for more information.
+//
+// CgoEnabled, if set to "0" or "1", is the CGO_ENABLED value to use.  With
+// "0", files which use cgo, or have a "cgo" build constraint, are excluded, so
+// the pure-Go build of the packages is analyzed.
+//
+// If Vendor is set, dependencies are loaded from the main module's vendor
+// directory, as with "go build -mod=vendor", so no network access is needed.
+//
+// Workspace controls whether a go.work file is used: "on" uses the workspace
+// file found by the go command even if GOWORK=off is set in the environment,
+// "off" ignores any workspace, and "" leaves the choice to the environment.
+// Any other value is the path of the go.work file to use.
 type LoadConfig struct {
-	BuildTags string
-	GOOS      string
-	GOARCH    string
+	BuildTags  string
+	GOOS       string
+	GOARCH     string
+	CgoEnabled string
+	Vendor     bool
+	Workspace  string
 }
 
 // PackagesLoadModeNeeded is a packages.LoadMode that has all the bits set for
@@ -58,12 +76,59 @@ func GetQueriedPackages(pkgs []*packages.Package) map[*types.Package]struct{} {
 	return queriedPackages
 }
 
+// PackagesWithErrors returns the packages in pkgs, and their dependencies,
+// which had errors when they were loaded.  Functions in these packages are
+// not analyzed.
+func PackagesWithErrors(pkgs []*packages.Package) []*packages.Package {
+	var ret []*packages.Package
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		if len(p.Errors) > 0 {
+			ret = append(ret, p)
+		}
+	})
+	sort.Slice(ret, func(i, j int) bool { return ret[i].PkgPath < ret[j].PkgPath })
+	return ret
+}
+
+// PackagesAffectedByErrors returns the packages in pkgs which had errors when
+// they were loaded, or which depend, directly or indirectly, on a package
+// which had.  The SSA form of such a package, and so its callgraph, would be
+// incomplete, so it should be removed from pkgs before the analysis.
+func PackagesAffectedByErrors(pkgs []*packages.Package) []*packages.Package {
+	affected := make(map[*packages.Package]bool)
+	var visit func(p *packages.Package) bool
+	visit = func(p *packages.Package) bool {
+		if a, ok := affected[p]; ok {
+			return a
+		}
+		// affected[p] is set before visiting the imports of p, so that an
+		// import cycle, which is itself an error, cannot make this loop.
+		affected[p] = len(p.Errors) > 0
+		for _, imp := range p.Imports {
+			if visit(imp) {
+				affected[p] = true
+			}
+		}
+		return affected[p]
+	}
+	var ret []*packages.Package
+	for _, p := range pkgs {
+		if visit(p) {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
 func LoadPackages(packageNames []string, lcfg LoadConfig) ([]*packages.Package, error) {
 	cfg := &packages.Config{Mode: PackagesLoadModeNeeded}
 	if lcfg.BuildTags != "" {
-		cfg.BuildFlags = []string{"-tags=" + lcfg.BuildTags}
+		cfg.BuildFlags = append(cfg.BuildFlags, "-tags="+lcfg.BuildTags)
+	}
+	if lcfg.Vendor {
+		cfg.BuildFlags = append(cfg.BuildFlags, "-mod=vendor")
 	}
-	if lcfg.GOOS != "" || lcfg.GOARCH != "" {
+	if lcfg.GOOS != "" || lcfg.GOARCH != "" || lcfg.CgoEnabled != "" || lcfg.Workspace != "" {
 		env := append([]string(nil), os.Environ()...) // go1.21 has slices.Clone for this
 		if lcfg.GOOS != "" {
 			env = append(env, "GOOS="+lcfg.GOOS)
@@ -71,23 +136,43 @@ func LoadPackages(packageNames []string, lcfg LoadConfig) ([]*packages.Package,
 		if lcfg.GOARCH != "" {
 			env = append(env, "GOARCH="+lcfg.GOARCH)
 		}
+		if lcfg.CgoEnabled != "" {
+			env = append(env, "CGO_ENABLED="+lcfg.CgoEnabled)
+		}
+		switch lcfg.Workspace {
+		case "on":
+			if os.Getenv("GOWORK") == "off" {
+				// An empty GOWORK makes the go command search for a go.work
+				// file in the current directory and its parents.
+				env = append(env, "GOWORK=")
+			}
+		case "off":
+			env = append(env, "GOWORK=off")
+		case "":
+			// Leave the choice to the environment.
+		default:
+			env = append(env, "GOWORK="+lcfg.Workspace)
+		}
 		cfg.Env = env
 	}
 	return packages.Load(cfg, packageNames...)
 }
 
-func standardLibraryPackages() map[string]struct{} {
+// standardLibraryPackages returns the set of paths of the packages in the
+// standard library, as listed by the go command.  They are only listed once.
+func standardLibraryPackages() (map[string]struct{}, error) {
 	standardLibraryPackagesOnce.Do(func() {
 		pkgs, err := packages.Load(nil, "std")
 		if err != nil {
-			panic(err.Error())
+			standardLibraryPackagesErr = fmt.Errorf("listing standard library packages: %w", err)
+			return
 		}
 		standardLibraryPackagesMap = make(map[string]struct{})
 		for _, p := range pkgs {
 			standardLibraryPackagesMap[p.PkgPath] = struct{}{}
 		}
 	})
-	return standardLibraryPackagesMap
+	return standardLibraryPackagesMap, standardLibraryPackagesErr
 }
 
 func collectModuleInfo(pkgs []*packages.Package) []*cpb.ModuleInfo {
@@ -121,11 +206,110 @@ func collectModuleInfo(pkgs []*packages.Package) []*cpb.ModuleInfo {
 	return modules
 }
 
+// packageModules returns a map from the paths of pkgs and their
+// dependencies to the paths of the modules containing them.  Packages with
+// no module information, such as those in the standard library, are omitted.
+func packageModules(pkgs []*packages.Package) map[string]string {
+	modules := make(map[string]string)
+	forEachPackageIncludingDependencies(pkgs, func(pkg *packages.Package) {
+		if pkg.Module != nil && pkg.Module.Path != "" {
+			modules[pkg.PkgPath] = pkg.Module.Path
+		}
+	})
+	return modules
+}
+
+// setModules sets the Module fields of each CapabilityInfo in cil, and of
+// each Function in their paths, to the path of the module containing the
+// corresponding package, if that is known.
+func setModules(cil *cpb.CapabilityInfoList, pkgs []*packages.Package) {
+	modules := packageModules(pkgs)
+	for _, ci := range cil.GetCapabilityInfo() {
+		setModule(ci, modules)
+	}
+}
+
+// setModule sets the Module fields of ci and of the Functions in its path,
+// using modules, a map returned by packageModules.
+func setModule(ci *cpb.CapabilityInfo, modules map[string]string) {
+	if m, ok := modules[ci.GetPackageDir()]; ok {
+		ci.Module = proto.String(m)
+	}
+	for _, fn := range ci.GetPath() {
+		if m, ok := modules[fn.GetPackage()]; ok {
+			fn.Module = proto.String(m)
+		}
+	}
+}
+
+// makePathsRelative removes the module path prefix from the import paths of
+// packages in the main module which appear in cil, in package directories,
+// function names and dependency paths.  The package at the root of the
+// module has the directory ".", but function names in it are unchanged, since
+// they would otherwise have no package qualifier.
+func makePathsRelative(cil *cpb.CapabilityInfoList, pkgs []*packages.Package) {
+	relative := pathRelativizer(pkgs)
+	if relative == nil {
+		return
+	}
+	for _, ci := range cil.GetCapabilityInfo() {
+		relative(ci)
+	}
+}
+
+// pathRelativizer returns a function which does the work of
+// makePathsRelative for a single CapabilityInfo, or nil if pkgs are not in a
+// main module.
+func pathRelativizer(pkgs []*packages.Package) func(*cpb.CapabilityInfo) {
+	var prefixes []string
+	roots := make(map[string]struct{})
+	forEachPackageIncludingDependencies(pkgs, func(pkg *packages.Package) {
+		if m := pkg.Module; m != nil && m.Main && m.Path != "" {
+			if _, ok := roots[m.Path]; !ok {
+				roots[m.Path] = struct{}{}
+				prefixes = append(prefixes, m.Path+"/")
+			}
+		}
+	})
+	if len(prefixes) == 0 {
+		return nil
+	}
+	// Replace longer prefixes first, in case one main module is nested in
+	// another's directory.
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+	var oldnew []string
+	for _, p := range prefixes {
+		oldnew = append(oldnew, p, "")
+	}
+	r := strings.NewReplacer(oldnew...)
+	relative := func(s *string) {
+		if s == nil {
+			return
+		}
+		if _, ok := roots[*s]; ok {
+			*s = "."
+			return
+		}
+		*s = r.Replace(*s)
+	}
+	return func(ci *cpb.CapabilityInfo) {
+		relative(ci.PackageDir)
+		if ci.DepPath != nil {
+			*ci.DepPath = r.Replace(*ci.DepPath)
+		}
+		for _, fn := range ci.GetPath() {
+			relative(fn.Package)
+			if fn.Name != nil {
+				*fn.Name = r.Replace(*fn.Name)
+			}
+		}
+	}
+}
+
 func collectPackageInfo(pkgs []*packages.Package) []*cpb.PackageInfo {
 	var out []*cpb.PackageInfo
-	std := standardLibraryPackages()
 	forEachPackageIncludingDependencies(pkgs, func(pkg *packages.Package) {
-		if _, ok := std[pkg.PkgPath]; ok {
+		if isStdLib(pkg.PkgPath) {
 			// Skip this package since it is part of the Go standard library.
 			return
 		}
@@ -141,3 +325,42 @@ func collectPackageInfo(pkgs []*packages.Package) []*cpb.PackageInfo {
 	})
 	return out
 }
+
+// collectBuildTimeExecution returns the "//go:generate" directives in the
+// files of pkgs and their dependencies outside the standard library, ordered
+// by package, file and line.
+func collectBuildTimeExecution(pkgs []*packages.Package) []*cpb.BuildTimeExecution {
+	var out []*cpb.BuildTimeExecution
+	forEachPackageIncludingDependencies(pkgs, func(pkg *packages.Package) {
+		if isStdLib(pkg.PkgPath) {
+			return
+		}
+		for _, file := range pkg.Syntax {
+			for _, cg := range file.Comments {
+				for _, c := range cg.List {
+					command, ok := strings.CutPrefix(c.Text, "//go:generate ")
+					if !ok {
+						continue
+					}
+					position := pkg.Fset.Position(c.Pos())
+					out = append(out, &cpb.BuildTimeExecution{
+						Package:  proto.String(pkg.PkgPath),
+						Filename: proto.String(path.Base(position.Filename)),
+						Line:     proto.Int64(int64(position.Line)),
+						Command:  proto.String(strings.TrimSpace(command)),
+					})
+				}
+			}
+		}
+	})
+	sort.SliceStable(out, func(i, j int) bool {
+		if x, y := out[i].GetPackage(), out[j].GetPackage(); x != y {
+			return x < y
+		}
+		if x, y := out[i].GetFilename(), out[j].GetFilename(); x != y {
+			return x < y
+		}
+		return out[i].GetLine() < out[j].GetLine()
+	})
+	return out
+}
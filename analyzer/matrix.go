@@ -0,0 +1,100 @@
+// Copyright 2023 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package analyzer
+
+import (
+	"encoding/csv"
+	"go/types"
+	"io"
+	"sort"
+
+	"github.com/google/capslock/interesting"
+	cpb "github.com/google/capslock/proto"
+	"golang.org/x/tools/go/packages"
+)
+
+// matrixOutput writes a table in CSV format with a row for each module and a
+// column for each capability, in which a cell contains "x" if the module
+// grants the capability to the queried packages.
+func matrixOutput(out io.Writer, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) error {
+	return writeMatrix(out, GetCapabilityInfo(pkgs, queriedPackages, config), config)
+}
+
+// attributedFunction returns the function in the path of ci to which its
+// capability is attributed: the last function in the path which is not in
+// the standard library, or in config.TrustedPackages, since that is the
+// function in a dependency which uses the capability directly or calls the
+// standard library to do so.  It returns nil if ci has no path.
+func attributedFunction(ci *cpb.CapabilityInfo, config *Config) *cpb.Function {
+	path := ci.GetPath()
+	if len(path) == 0 {
+		return nil
+	}
+	leaf := path[0]
+	for _, fn := range path[1:] {
+		if pkg := fn.GetPackage(); pkg != "" && !config.isStdLib(pkg) {
+			leaf = fn
+		}
+	}
+	return leaf
+}
+
+// writeMatrix writes the matrix for cil to w.
+//
+// Each capability is attributed to the module of the function returned by
+// attributedFunction, or to its package if the module is unknown.  Rows are
+// sorted by module path, and columns, which are only written for
+// capabilities that are present, are in enum order.
+func writeMatrix(w io.Writer, cil *cpb.CapabilityInfoList, config *Config) error {
+	matrix := make(map[string]map[cpb.Capability]bool)
+	present := make(map[cpb.Capability]bool)
+	for _, ci := range cil.GetCapabilityInfo() {
+		leaf := attributedFunction(ci, config)
+		if leaf == nil {
+			continue
+		}
+		row := leaf.GetModule()
+		if row == "" {
+			row = leaf.GetPackage()
+		}
+		if matrix[row] == nil {
+			matrix[row] = make(map[cpb.Capability]bool)
+		}
+		matrix[row][infoCapability(ci)] = true
+		present[infoCapability(ci)] = true
+	}
+	var rows []string
+	for row := range matrix {
+		rows = append(rows, row)
+	}
+	sort.Strings(rows)
+	var columns []cpb.Capability
+	for c := range present {
+		columns = append(columns, c)
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i] < columns[j] })
+
+	cw := csv.NewWriter(w)
+	record := []string{"module"}
+	for _, c := range columns {
+		record = append(record, interesting.CapabilityName(c))
+	}
+	cw.Write(record)
+	for _, row := range rows {
+		record = append(record[:0], row)
+		for _, c := range columns {
+			cell := ""
+			if matrix[row][c] {
+				cell = "x"
+			}
+			record = append(record, cell)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
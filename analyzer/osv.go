@@ -0,0 +1,88 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/google/capslock/interesting"
+	cpb "github.com/google/capslock/proto"
+	"golang.org/x/tools/go/packages"
+)
+
+// osvPackage identifies a package as in the "package" field of an OSV
+// "affected" entry.  See https://ossf.github.io/osv-schema/.
+type osvPackage struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+}
+
+// osvModuleAnnotation lists the capabilities which a module version
+// contributes to the queried packages.
+type osvModuleAnnotation struct {
+	Package      osvPackage `json:"package"`
+	Version      string     `json:"version,omitempty"`
+	Capabilities []string   `json:"capabilities"`
+}
+
+// osvAnnotationsOutput writes the capabilities which each module contributes
+// to the queried packages as a json object, in a form which can be matched
+// against vulnerability data in the OSV format.
+func osvAnnotationsOutput(w io.Writer, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) error {
+	return writeOSVAnnotations(w, GetCapabilityInfo(pkgs, queriedPackages, config), config)
+}
+
+// writeOSVAnnotations writes the OSV annotations for cil to w.  Each
+// capability is attributed to the module of the function returned by
+// attributedFunction; capabilities of functions whose module is unknown,
+// such as those in the standard library, are omitted.  Modules are
+// identified by their path in the "Go" ecosystem and by their version from
+// the ModuleInfo of cil, which is omitted if unknown, as it is for the main
+// module.  Modules are sorted by path, and their capabilities are in enum
+// order.
+func writeOSVAnnotations(w io.Writer, cil *cpb.CapabilityInfoList, config *Config) error {
+	byModule := make(map[string]map[cpb.Capability]struct{})
+	for _, ci := range cil.GetCapabilityInfo() {
+		leaf := attributedFunction(ci, config)
+		if leaf == nil || leaf.GetModule() == "" {
+			continue
+		}
+		m := leaf.GetModule()
+		if byModule[m] == nil {
+			byModule[m] = make(map[cpb.Capability]struct{})
+		}
+		byModule[m][infoCapability(ci)] = struct{}{}
+	}
+	versions := make(map[string]string)
+	for _, m := range cil.GetModuleInfo() {
+		versions[m.GetPath()] = m.GetVersion()
+	}
+	out := struct {
+		Modules []osvModuleAnnotation `json:"modules"`
+	}{Modules: []osvModuleAnnotation{}}
+	for _, m := range slices.Sorted(maps.Keys(byModule)) {
+		a := osvModuleAnnotation{
+			Package: osvPackage{Ecosystem: "Go", Name: m},
+			Version: versions[m],
+		}
+		for _, c := range slices.Sorted(maps.Keys(byModule[m])) {
+			a.Capabilities = append(a.Capabilities, interesting.CapabilityName(c))
+		}
+		out.Modules = append(out.Modules, a)
+	}
+	b, err := json.MarshalIndent(out, "", "\t")
+	if err != nil {
+		return fmt.Errorf("internal error: couldn't marshal OSV annotations: %w", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
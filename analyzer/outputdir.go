@@ -0,0 +1,86 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package analyzer
+
+import (
+	"fmt"
+	"go/types"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	cpb "github.com/google/capslock/proto"
+	"golang.org/x/tools/go/packages"
+	"google.golang.org/protobuf/proto"
+)
+
+// writePackageFiles writes the results in cil to one json file per package
+// in dir, named after the package's import path, such as
+// "dir/example.com/a/b.json".  Each file contains the CapabilityInfos whose
+// PackageDir is that package, with the module versions and go:generate
+// directives which apply to them and the metadata of cil.  A file is written
+// for every queried package, even one with no capabilities, so that the files
+// can be compared against later as per-package baselines.
+func writePackageFiles(dir string, cil *cpb.CapabilityInfoList, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) error {
+	// importPaths maps the PackageDir of the queried packages, which may have
+	// been made relative, to their import paths.
+	importPaths := make(map[string]string)
+	var relative func(*cpb.CapabilityInfo)
+	if config.RelativePaths {
+		relative = pathRelativizer(pkgs)
+	}
+	shards := make(map[string]*cpb.CapabilityInfoList)
+	for p := range queriedPackages {
+		ci := &cpb.CapabilityInfo{PackageDir: proto.String(p.Path())}
+		if relative != nil {
+			relative(ci)
+		}
+		importPaths[ci.GetPackageDir()] = p.Path()
+		shards[p.Path()] = new(cpb.CapabilityInfoList)
+	}
+	for _, ci := range cil.GetCapabilityInfo() {
+		path, ok := importPaths[ci.GetPackageDir()]
+		if !ok {
+			path = ci.GetPackageDir()
+		}
+		if shards[path] == nil {
+			shards[path] = new(cpb.CapabilityInfoList)
+		}
+		shards[path].CapabilityInfo = append(shards[path].CapabilityInfo, ci)
+	}
+	for _, path := range slices.Sorted(maps.Keys(shards)) {
+		shard := shards[path]
+		modules := make(map[string]struct{})
+		for _, ci := range shard.CapabilityInfo {
+			modules[ci.GetModule()] = struct{}{}
+		}
+		for _, m := range cil.GetModuleInfo() {
+			if _, ok := modules[m.GetPath()]; ok {
+				shard.ModuleInfo = append(shard.ModuleInfo, m)
+			}
+		}
+		for _, b := range cil.GetBuildTimeExecution() {
+			if b.GetPackage() == path {
+				shard.BuildTimeExecution = append(shard.BuildTimeExecution, b)
+			}
+		}
+		shard.Metadata = cil.Metadata
+		b, err := marshalJSON(shard, config)
+		if err != nil {
+			return err
+		}
+		filename := filepath.Join(dir, filepath.FromSlash(path)+".json")
+		if err := os.MkdirAll(filepath.Dir(filename), 0o777); err != nil {
+			return fmt.Errorf("could not create output directory: %w", err)
+		}
+		if err := os.WriteFile(filename, append(b, '\n'), 0o666); err != nil {
+			return fmt.Errorf("could not write output file: %w", err)
+		}
+	}
+	return nil
+}
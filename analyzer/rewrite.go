@@ -65,6 +65,17 @@ func zeroLiteral(typeInfo *types.Info) ast.Expr {
 	return expr
 }
 
+// zeroValue creates and returns an expression for the zero value of type t,
+// and adds its type information to typeInfo.  The expression is an
+// identifier referring to the predeclared nil, which is not valid Go for
+// most types, but from which go/ssa builds a zero constant of type t.
+func zeroValue(typeInfo *types.Info, t types.Type) ast.Expr {
+	expr := ast.NewIdent("nil")
+	typeInfo.Uses[expr] = types.Universe.Lookup("nil")
+	typeInfo.Types[expr] = constructTypeAndValue(valueMode, t, nil)
+	return expr
+}
+
 // selectionForMethod finds the Selection object for the given method.
 func selectionForMethod(typ types.Type, name string) *types.Selection {
 	var ms *types.MethodSet = types.NewMethodSet(typ)
@@ -74,6 +85,28 @@ func selectionForMethod(typ types.Type, name string) *types.Selection {
 	return sel
 }
 
+// rewriteFailure describes a call to one of the functions that
+// rewriteCallsToSort, rewriteCallsToOnceDoEtc or rewriteCallsToOnceFuncEtc
+// look for, which could not be rewritten.  The callgraph is less precise around such calls, which can
+// explain unexpected results such as CAPABILITY_UNANALYZED.
+type rewriteFailure struct {
+	pos    token.Position
+	call   string
+	reason string
+}
+
+func newRewriteFailure(p *packages.Package, call *ast.CallExpr, reason string) rewriteFailure {
+	return rewriteFailure{
+		pos:    p.Fset.Position(call.Pos()),
+		call:   types.ExprString(call.Fun),
+		reason: reason,
+	}
+}
+
+func (f rewriteFailure) String() string {
+	return fmt.Sprintf("%v: could not rewrite call to %s: %s", f.pos, f.call, f.reason)
+}
+
 // rewriteCallsToSort iterates through the packages in pkgs, including all
 // transitively-imported packages, and finds calls to sort.Sort, sort.Stable,
 // and sort.IsSorted, which each have a sort.Interface parameter.  We replace
@@ -96,7 +129,9 @@ func selectionForMethod(typ types.Type, name string) *types.Selection {
 // sort.Interface methods for every possible dynamic type for all the values
 // passed to the same sort function anywhere in the program, which can result
 // in a large number of false positives.
-func rewriteCallsToSort(pkgs []*packages.Package) {
+//
+// Calls which cannot be rewritten are returned as rewriteFailures.
+func rewriteCallsToSort(pkgs []*packages.Package) (failures []rewriteFailure) {
 	forEachPackageIncludingDependencies(pkgs, func(p *packages.Package) {
 		for _, file := range p.Syntax {
 			for _, node := range file.Decls {
@@ -106,10 +141,27 @@ func rewriteCallsToSort(pkgs []*packages.Package) {
 					// sort.Stable or sort.IsSorted), replace it with calls to
 					// obj.Less, obj.Swap, and obj.Len, where obj is the argument
 					// that was passed to sort.
+					if call, ok := c.Node().(*ast.CallExpr); ok {
+						// Calls which are statements on their own are handled when
+						// visiting the statement.  Others can't be rewritten.
+						if _, ok := c.Parent().(*ast.ExprStmt); !ok && sortCallArgument(p.TypesInfo, call) != nil {
+							failures = append(failures, newRewriteFailure(p, call, "the call is not a statement on its own"))
+						}
+						return true
+					}
 					if _, ok := c.Node().(ast.Stmt); !ok {
 						// c.Node() is not a statement.
 						return true
 					}
+					obj := isCallToSort(p.TypesInfo, c.Node())
+					if obj == nil {
+						// This was not a call to a sort function.
+						//
+						// We always return true from this function, because the return
+						// value indicates to astutil.Apply whether to keep searching.
+						return true
+					}
+					call := c.Node().(*ast.ExprStmt).X.(*ast.CallExpr)
 					canRewrite := false
 					switch c.Parent().(type) {
 					case *ast.BlockStmt, *ast.CaseClause, *ast.LabeledStmt:
@@ -121,15 +173,7 @@ func rewriteCallsToSort(pkgs []*packages.Package) {
 						// The statement is in a position in the syntax tree where it
 						// can't be replaced with a block or with multiple statements, so
 						// we give up.
-						return true
-					}
-
-					obj := isCallToSort(p.TypesInfo, c.Node())
-					if obj == nil {
-						// This was not a call to a sort function.
-						//
-						// We always return true from this function, because the return
-						// value indicates to astutil.Apply whether to keep searching.
+						failures = append(failures, newRewriteFailure(p, call, "the statement is in a position where it cannot be replaced by a block"))
 						return true
 					}
 					// Less and Swap each take two integer arguments.  The values aren't
@@ -145,6 +189,7 @@ func rewriteCallsToSort(pkgs []*packages.Package) {
 					s3 := statementCallingMethod(p.TypesInfo, obj, "Len", nil)
 					if s1 == nil || s2 == nil || s3 == nil {
 						// We did not succeed in creating these statements.
+						failures = append(failures, newRewriteFailure(p, call, "the argument's type does not have Len, Less and Swap methods"))
 						return true
 					}
 					c.Replace(&ast.BlockStmt{List: []ast.Stmt{s1, s2, s3}})
@@ -154,6 +199,7 @@ func rewriteCallsToSort(pkgs []*packages.Package) {
 			}
 		}
 	})
+	return failures
 }
 
 // rewriteCallsToOnceDoEtc is similar to rewriteCallsToSort.  It finds calls
@@ -170,12 +216,27 @@ func rewriteCallsToSort(pkgs []*packages.Package) {
 //
 //	var myonce *sync.Once = ...
 //	fn()
-func rewriteCallsToOnceDoEtc(pkgs []*packages.Package) {
+//
+// Only calls which are statements on their own can be rewritten, so
+// functions whose results are always used, such as slices.BinarySearchFunc,
+// are not listed in functionsToRewrite.
+//
+// Calls which cannot be rewritten are returned as rewriteFailures.
+func rewriteCallsToOnceDoEtc(pkgs []*packages.Package) (failures []rewriteFailure) {
 	forEachPackageIncludingDependencies(pkgs, func(p *packages.Package) {
 		for _, file := range p.Syntax {
 			for _, node := range file.Decls {
 				var pre astutil.ApplyFunc
 				pre = func(c *astutil.Cursor) bool {
+					if call, ok := c.Node().(*ast.CallExpr); ok {
+						// Calls which are statements on their own are handled when
+						// visiting the statement.  Others, such as calls in go or
+						// defer statements, can't be rewritten.
+						if _, ok := c.Parent().(*ast.ExprStmt); !ok && onceDoEtcArgument(p.TypesInfo, call) != nil {
+							failures = append(failures, newRewriteFailure(p, call, "the call is not a statement on its own"))
+						}
+						return true
+					}
 					obj := isCallToOnceDoEtc(p.TypesInfo, c.Node())
 					if obj == nil {
 						// This was not a call to a relevant function or method.
@@ -184,14 +245,17 @@ func rewriteCallsToOnceDoEtc(pkgs []*packages.Package) {
 					fnType, ok := p.TypesInfo.TypeOf(obj).(*types.Signature)
 					if !ok {
 						// The argument does not appear to be a function.
+						call := c.Node().(*ast.ExprStmt).X.(*ast.CallExpr)
+						failures = append(failures, newRewriteFailure(p, call, "the argument is not a function"))
 						return true
 					}
-					// Create some arguments to pass to the function.  The parameters
-					// must all be integers.
+					// Create some arguments to pass to the function.  These are zero
+					// values of the parameter types, e.g. for the comparison function
+					// passed to slices.SortFunc.
 					params := fnType.Params()
 					args := make([]ast.Expr, params.Len())
 					for i := range args {
-						args[i] = zeroLiteral(p.TypesInfo)
+						args[i] = zeroValue(p.TypesInfo, params.At(i).Type())
 					}
 					c.Replace(
 						statementCallingFunctionObject(p.TypesInfo, obj, args))
@@ -201,6 +265,85 @@ func rewriteCallsToOnceDoEtc(pkgs []*packages.Package) {
 			}
 		}
 	})
+	return failures
+}
+
+// rewriteCallsToOnceFuncEtc finds calls to functions like sync.OnceFunc,
+// which return a function that calls their function argument, and replaces
+// each call with the argument itself.  The returned function has the same
+// signature as the argument, so this does not change the types of any other
+// expressions.
+//
+// e.g. this code:
+//
+//	f := sync.OnceFunc(fn)
+//	f()
+//
+// would be replaced with:
+//
+//	f := fn
+//	f()
+//
+// Without this change, calls to every function returned by sync.OnceFunc
+// would go through the same closure in the sync package, so the callgraph
+// would connect each of them to every argument passed to sync.OnceFunc
+// anywhere in the program.
+//
+// Calls which cannot be rewritten are returned as rewriteFailures.
+func rewriteCallsToOnceFuncEtc(pkgs []*packages.Package) (failures []rewriteFailure) {
+	forEachPackageIncludingDependencies(pkgs, func(p *packages.Package) {
+		// replaced maps each call that was rewritten to its replacement.
+		replaced := make(map[ast.Expr]ast.Expr)
+		var pre astutil.ApplyFunc
+		pre = func(c *astutil.Cursor) bool {
+			call, ok := c.Node().(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			fn := wrappedFunction(p.TypesInfo, call)
+			if fn == nil {
+				// This was not a call to a relevant function.
+				return true
+			}
+			if _, ok := p.TypesInfo.TypeOf(fn).Underlying().(*types.Signature); !ok {
+				// The argument does not appear to be a function; it may be an
+				// untyped nil.
+				failures = append(failures, newRewriteFailure(p, call, "the argument is not a function"))
+				return true
+			}
+			// astutil.Apply does not walk the replacement node, so we rewrite
+			// any calls inside it first.
+			fn = astutil.Apply(fn, pre, nil).(ast.Expr)
+			replaced[call] = fn
+			c.Replace(fn)
+			return true
+		}
+		for _, file := range p.Syntax {
+			for _, node := range file.Decls {
+				astutil.Apply(node, pre, nil)
+			}
+		}
+		// The initializers of package-level variables are also referenced by
+		// InitOrder, which is used to build the package's init function.
+		for _, init := range p.TypesInfo.InitOrder {
+			if fn, ok := replaced[init.Rhs]; ok {
+				init.Rhs = fn
+			}
+		}
+	})
+	return failures
+}
+
+// wrappedFunction checks if call is a call to a function like sync.OnceFunc.
+// If so, it returns the function-typed argument to that function.
+// Otherwise, it returns nil.
+func wrappedFunction(typeInfo *types.Info, call *ast.CallExpr) ast.Expr {
+	for _, m := range functionWrappersToRewrite {
+		if e := m.match(typeInfo, call); e != nil {
+			return e
+		}
+	}
+	return nil
 }
 
 // isCallToSort checks if node is a statement calling sort.Sort, sort.Stable,
@@ -217,6 +360,13 @@ func isCallToSort(typeInfo *types.Info, node ast.Node) ast.Expr {
 		// Not a function call.
 		return nil
 	}
+	return sortCallArgument(typeInfo, call)
+}
+
+// sortCallArgument checks if call is a call to sort.Sort, sort.Stable, or
+// sort.IsSorted.  If so, it returns the argument to that function.  Otherwise,
+// it returns nil.
+func sortCallArgument(typeInfo *types.Info, call *ast.CallExpr) ast.Expr {
 	callee, ok := call.Fun.(*ast.SelectorExpr)
 	if !ok {
 		// The function to be called is not a selection, so it can't be a call to
@@ -267,6 +417,13 @@ func isCallToOnceDoEtc(typeInfo *types.Info, node ast.Node) ast.Expr {
 		// Not a call expression.
 		return nil
 	}
+	return onceDoEtcArgument(typeInfo, call)
+}
+
+// onceDoEtcArgument checks if call is a call to a function or method like
+// (*sync.Once).Do.  If so, it returns the function-typed argument to that
+// function.  Otherwise, it returns nil.
+func onceDoEtcArgument(typeInfo *types.Info, call *ast.CallExpr) ast.Expr {
 	for _, m := range functionsToRewrite {
 		if e := m.match(typeInfo, call); e != nil {
 			return e
@@ -0,0 +1,89 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package analyzer
+
+import (
+	"go/types"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/google/capslock/interesting"
+	cpb "github.com/google/capslock/proto"
+	"golang.org/x/tools/go/ssa"
+)
+
+// heuristicRules describes the builtin analyses which find each capability
+// by examining the bodies of functions.
+var heuristicRules = map[cpb.Capability]string{
+	cpb.Capability_CAPABILITY_ARBITRARY_EXECUTION:              "builtin analysis: function with no Go body, such as an assembly function",
+	cpb.Capability_CAPABILITY_EXEC_SHELL:                       "builtin analysis: command shell executed by name",
+	cpb.Capability_CAPABILITY_FILES_DEVICE:                     "builtin analysis: file under /dev, /proc or /sys opened by name",
+	cpb.Capability_CAPABILITY_NETWORK_INSECURE_TLS:             "builtin analysis: InsecureSkipVerify set to true in a crypto/tls.Config",
+	cpb.Capability_CAPABILITY_PANIC:                            "builtin analysis: call to panic",
+	cpb.Capability_CAPABILITY_RECURSION:                        "builtin analysis: recursive cycle of calls",
+	cpb.Capability_CAPABILITY_REFLECT:                          "builtin analysis: copy of a reflect.Value",
+	cpb.Capability_CAPABILITY_REFLECT_UNEXPORTED_FIELD:         "builtin analysis: reflect used with unsafe.Pointer to access struct fields",
+	cpb.Capability_CAPABILITY_UNSAFE_POINTER:                   "builtin analysis: use of unsafe.Pointer",
+	cpb.Capability_CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP: "builtin analysis: conversion of a pointer to uintptr and back",
+}
+
+// capabilityRule returns a description of the rule which gave fn the
+// capability c: the builtin analysis which found it, if heuristic is true,
+// and otherwise the line of the capability map which classified fn or the
+// interface it implements.  It returns "" if the rule is unknown, for
+// example because classifier is not a RuleClassifier.  A rule assigning a
+// subcapability of c also matches, since c may be a collapsed subcapability.
+func capabilityRule(classifier Classifier, fn *ssa.Function, c cpb.Capability, heuristic bool) string {
+	if heuristic {
+		return heuristicRules[c]
+	}
+	matches := func(cat cpb.Capability) bool {
+		parent, _ := interesting.ParentCapability(cat)
+		return cat == c || parent == c
+	}
+	if fn.Package() == nil && fn.Origin() != nil {
+		// fn is an instantiation of a generic function, which is classified
+		// by the name of the generic function.
+		fn = fn.Origin()
+	}
+	if fn.Package() == nil || fn.Package().Pkg == nil {
+		return ""
+	}
+	if rc, ok := classifier.(RuleClassifier); ok {
+		if cat, rule := rc.FunctionRule(fn.Package().Pkg.Path(), fn.String()); matches(cat) && rule != "" {
+			return rule
+		}
+	}
+	ic, ok := classifier.(InterfaceClassifier)
+	if !ok {
+		return ""
+	}
+	names := ic.InterfaceCategories()
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		i := strings.LastIndex(name, ".")
+		if !matches(names[name]) || i < 0 {
+			continue
+		}
+		p := fn.Prog.ImportedPackage(name[:i])
+		if p == nil {
+			continue
+		}
+		obj, ok := p.Pkg.Scope().Lookup(name[i+1:]).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		iface, ok := obj.Type().Underlying().(*types.Interface)
+		if !ok {
+			continue
+		}
+		if methodInterfaceCategory(fn, []interfaceCategory{{iface, names[name]}}) != cpb.Capability_CAPABILITY_UNSPECIFIED {
+			return "interface " + name + " " + interesting.CapabilityName(names[name])
+		}
+	}
+	return ""
+}
@@ -7,15 +7,21 @@
 package analyzer
 
 import (
+	"cmp"
 	"embed"
 	"fmt"
 	"go/types"
-	"os"
+	"io"
+	"maps"
+	"slices"
 	"sort"
 	"strings"
 	"text/template"
 
 	"github.com/fatih/color"
+	"github.com/google/capslock/interesting"
+	cpb "github.com/google/capslock/proto"
+	"golang.org/x/tools/go/callgraph"
 	"golang.org/x/tools/go/packages"
 	"google.golang.org/protobuf/encoding/protojson"
 )
@@ -31,13 +37,190 @@ func (d DifferenceFoundError) Error() string {
 	return "difference found"
 }
 
-func RunCapslock(args []string, output string, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{},
+// SkippedPackagesError indicates that an analysis ran, but that it skipped
+// some of the requested packages because they had errors, so its results may
+// be incomplete.
+type SkippedPackagesError struct {
+	// Packages contains the paths of the skipped packages.
+	Packages []string
+}
+
+func (s SkippedPackagesError) Error() string {
+	return fmt.Sprintf("skipped packages with errors: %s", strings.Join(s.Packages, ", "))
+}
+
+// UnanalyzedFoundError indicates that an analysis ran and found uses of
+// CAPABILITY_UNANALYZED, when the caller asked for that to be a failure.
+type UnanalyzedFoundError struct{}
+
+func (u UnanalyzedFoundError) Error() string {
+	return "found uses of CAPABILITY_UNANALYZED"
+}
+
+// ExitCode is an exit status of the capslock command.
+type ExitCode int
+
+const (
+	// ExitOK means that the analysis ran and found nothing that should fail.
+	ExitOK ExitCode = 0
+	// ExitDifferenceFound means that -output=compare found a difference from
+	// the baseline.
+	ExitDifferenceFound ExitCode = 1
+	// ExitError means that the tool failed, for example because of an invalid
+	// flag or because the packages could not be loaded.
+	ExitError ExitCode = 2
+	// ExitPackageErrors means that the analysis ran, but skipped some
+	// packages which had errors, because of -skip-errors.
+	ExitPackageErrors ExitCode = 3
+	// ExitUnanalyzedFound means that the analysis found uses of
+	// CAPABILITY_UNANALYZED, and -fail-on-unanalyzed was set.
+	ExitUnanalyzedFound ExitCode = 4
+	// ExitForbiddenFound means that the queried packages can reach a function
+	// passed to -forbid, or that a module passed to -forbid-module contributes
+	// capabilities to them.
+	ExitForbiddenFound ExitCode = 5
+)
+
+// ExitCodeForError returns the exit status of the capslock command when it
+// finishes with the error err, which may be nil.
+func ExitCodeForError(err error) ExitCode {
+	switch err.(type) {
+	case nil:
+		return ExitOK
+	case DifferenceFoundError:
+		return ExitDifferenceFound
+	case SkippedPackagesError:
+		return ExitPackageErrors
+	case UnanalyzedFoundError:
+		return ExitUnanalyzedFound
+	case ForbiddenFoundError, ForbiddenModuleFoundError:
+		return ExitForbiddenFound
+	default:
+		return ExitError
+	}
+}
+
+// SortOrder determines the order in which capabilities are listed.
+type SortOrder int8
+
+const (
+	SortUnset        SortOrder = iota // use default order
+	SortByCapability                  // list capabilities in the usual order
+	SortBySeverity                    // list the most severe capabilities first
+	SortByPackage                     // group capabilities by package
+)
+
+// SortOrderFromString returns the SortOrder named by s, which is one of
+// "capability", "severity" or "package", or SortUnset if s is empty.
+func SortOrderFromString(s string) (SortOrder, error) {
+	switch s {
+	case "":
+		return SortUnset, nil
+	case "capability":
+		return SortByCapability, nil
+	case "severity":
+		return SortBySeverity, nil
+	case "package":
+		return SortByPackage, nil
+	default:
+		return 0, fmt.Errorf("unknown sort order: %q", s)
+	}
+}
+
+// compareSeverity compares capabilities a and b so that sorting by it lists
+// the most severe capabilities first.
+func compareSeverity(config *Config, a, b cpb.Capability) int {
+	return cmp.Compare(
+		interesting.CapabilitySeverity(config.Severities, b),
+		interesting.CapabilitySeverity(config.Severities, a))
+}
+
+// sortCapabilityInfo reorders the entries of cil according to config.Sort.
+// The sort is stable, so entries which compare equal keep the usual order by
+// capability and then function.
+func sortCapabilityInfo(cil *cpb.CapabilityInfoList, config *Config) {
+	switch config.Sort {
+	case SortBySeverity:
+		slices.SortStableFunc(cil.CapabilityInfo, func(a, b *cpb.CapabilityInfo) int {
+			return compareSeverity(config, infoCapability(a), infoCapability(b))
+		})
+	case SortByPackage:
+		slices.SortStableFunc(cil.CapabilityInfo, func(a, b *cpb.CapabilityInfo) int {
+			return cmp.Compare(a.GetPackageDir(), b.GetPackageDir())
+		})
+	}
+}
+
+// FilterQueriedPackages returns the subset of queriedPackages which should
+// be reported on according to config, so that the outputs and the checks
+// such as CheckForbidden agree on it.  Packages made up only of files
+// excluded by config.ExcludeBuildTags are removed, and so, unless
+// config.QueryVendoredAndGenerated is set, are packages in a vendor
+// directory and packages all of whose files are generated.  The removed
+// packages are still analyzed as dependencies of the others.  If removing the
+// vendored and generated packages would leave no queried packages, as when
+// only generated packages were requested, they are kept.
+func FilterQueriedPackages(pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config) map[*types.Package]struct{} {
+	if _, excluded := buildTagFiles(pkgs, config.ExcludeBuildTags); len(excluded) > 0 {
+		queriedPackages = maps.Clone(queriedPackages)
+		for p := range excluded {
+			delete(queriedPackages, p)
+		}
+	}
+	if config.QueryVendoredAndGenerated {
+		return queriedPackages
+	}
+	excluded := vendoredOrGeneratedPackages(pkgs, config.GeneratedFilePatterns)
+	if len(excluded) == 0 {
+		return queriedPackages
+	}
+	remaining := maps.Clone(queriedPackages)
+	for p := range excluded {
+		delete(remaining, p)
+	}
+	if len(remaining) == 0 {
+		return queriedPackages
+	}
+	return remaining
+}
+
+// RunCapslock analyzes pkgs and writes the results to w in the format
+// specified by output.  queriedPackages is used as given; callers which
+// want it filtered according to config should call FilterQueriedPackages.
+//
+// If the output is written successfully and config.CountUnanalyzed is set,
+// RunCapslock also returns the number of functions in queriedPackages which
+// have CAPABILITY_UNANALYZED, found by the same analysis and with the same
+// settings, such as config.Roots and config.MinSeverity, as the output.
+func RunCapslock(w io.Writer, args []string, output string, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{},
+	config *Config) (unanalyzed int, err error) {
+	if unmatched := unmatchedRoots(config.Roots, queriedPackages); len(unmatched) > 0 {
+		return 0, fmt.Errorf("roots %q match no function of the queried packages", unmatched)
+	}
+	if err := writeOutput(w, args, output, pkgs, queriedPackages, config); err != nil {
+		return 0, err
+	}
+	if !config.CountUnanalyzed {
+		return 0, nil
+	}
+	forEachPath(pkgs, queriedPackages,
+		func(cap cpb.Capability, nodes bfsStateMap, v *callgraph.Node) {
+			if cap == cpb.Capability_CAPABILITY_UNANALYZED {
+				unanalyzed++
+			}
+		}, config)
+	return unanalyzed, nil
+}
+
+// writeOutput writes the results of analyzing pkgs to w in the format
+// specified by output, for RunCapslock.
+func writeOutput(w io.Writer, args []string, output string, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{},
 	config *Config) error {
 	if output == "compare" {
-		if len(args) != 1 {
-			return fmt.Errorf("Usage: %s -output=compare <filename>; provided %v args", programName(), len(args))
+		if len(args) == 0 {
+			return fmt.Errorf("Usage: %s -output=compare <filename>...; no comparison file provided", programName())
 		}
-		different, err := compare(args[0], pkgs, queriedPackages, config)
+		different, err := compare(w, args, pkgs, queriedPackages, config)
 		if err != nil {
 			return err
 		}
@@ -48,16 +231,37 @@ func RunCapslock(args []string, output string, pkgs []*packages.Package, queried
 	} else if len(args) >= 1 {
 		return fmt.Errorf("%s: unknown command", args)
 	}
+	if config.OutputDir != "" && output != "json" && output != "j" {
+		return fmt.Errorf("writing to an output directory is only supported with -output=json")
+	}
 	templateFuncMap := template.FuncMap{
-		"format": templateFormat,
+		"format": func(args ...any) string { return templateFormat(config.Severities, args...) },
+		// capabilities returns the names of the capabilities in counts, in
+		// the order given by config.Sort.
+		"capabilities": func(counts map[string]int64) []string {
+			caps := slices.Sorted(maps.Keys(counts))
+			if config.Sort == SortBySeverity {
+				slices.SortStableFunc(caps, func(a, b string) int {
+					x, _ := interesting.ParseCapability(a)
+					y, _ := interesting.ParseCapability(b)
+					return compareSeverity(config, x, y)
+				})
+			}
+			return caps
+		},
 	}
 	if output == "json" || output == "j" {
 		cil := GetCapabilityInfo(pkgs, queriedPackages, config)
-		b, err := protojson.MarshalOptions{Multiline: true, Indent: "\t"}.Marshal(cil)
+		sortCapabilityInfo(cil, config)
+		cil.Metadata = config.Metadata
+		if config.OutputDir != "" {
+			return writePackageFiles(config.OutputDir, cil, pkgs, queriedPackages, config)
+		}
+		b, err := marshalJSON(cil, config)
 		if err != nil {
-			return fmt.Errorf("internal error: couldn't marshal protocol buffer: %s", err.Error())
+			return err
 		}
-		fmt.Println(string(b))
+		fmt.Fprintln(w, string(b))
 		return nil
 	} else if output == "m" || output == "machine" {
 		var cs []string
@@ -67,22 +271,80 @@ func RunCapslock(args []string, output string, pkgs []*packages.Package, queried
 		}
 		sort.Strings(cs)
 		for _, c := range cs {
-			fmt.Println(c)
+			fmt.Fprintln(w, c)
+		}
+		return nil
+	} else if output == "machine-functions" {
+		// Print a line for each function with a capability, containing the
+		// function name and the capability, separated by a tab.
+		cil := GetCapabilityInfo(pkgs, queriedPackages, config)
+		sortCapabilityInfo(cil, config)
+		for _, ci := range cil.GetCapabilityInfo() {
+			name := ci.GetPackageDir()
+			if path := ci.GetPath(); len(path) > 0 {
+				name = path[0].GetName()
+			}
+			fmt.Fprintf(w, "%s\t%s\n", name, interesting.CapabilityName(infoCapability(ci)))
 		}
 		return nil
 	} else if output == "v" || output == "verbose" {
 		cil := GetCapabilityStats(pkgs, queriedPackages, config)
+		if config.Sort == SortBySeverity {
+			slices.SortStableFunc(cil.CapabilityStats, func(a, b *cpb.CapabilityStats) int {
+				x := interesting.FromProto(a.GetCapability(), a.GetCustomCapability())
+				y := interesting.FromProto(b.GetCapability(), b.GetCustomCapability())
+				return compareSeverity(config, x, y)
+			})
+		}
 		ctm := template.Must(template.New("verbose.tmpl").Funcs(templateFuncMap).ParseFS(staticContent, "static/verbose.tmpl"))
-		return ctm.Execute(os.Stdout, cil)
+		return ctm.Execute(w, cil)
 	} else if output == "g" || output == "graph" {
-		return graphOutput(pkgs, queriedPackages, config)
+		return graphOutput(w, pkgs, queriedPackages, config, false)
+	} else if output == "mermaid" {
+		return graphOutput(w, pkgs, queriedPackages, config, true)
+	} else if output == "callgraph" {
+		return callgraphOutput(w, pkgs, queriedPackages, config, false)
+	} else if output == "callgraph-json" {
+		return callgraphOutput(w, pkgs, queriedPackages, config, true)
+	} else if output == "genmap" {
+		return genmapOutput(w, pkgs, queriedPackages, config)
+	} else if output == "matrix" {
+		return matrixOutput(w, pkgs, queriedPackages, config)
+	} else if output == "osv-annotations" {
+		return osvAnnotationsOutput(w, pkgs, queriedPackages, config)
+	} else if output == "summary" {
+		return summaryOutput(w, pkgs, queriedPackages, config, false)
+	} else if output == "badge" {
+		return summaryOutput(w, pkgs, queriedPackages, config, true)
+	} else if output == "by-type" {
+		return byTypeOutput(w, pkgs, queriedPackages, config)
+	} else if output == "binaries" {
+		return binariesOutput(w, pkgs, queriedPackages, config)
 	}
 	cil := GetCapabilityCounts(pkgs, queriedPackages, config)
 	ctm := template.Must(template.New("default.tmpl").Funcs(templateFuncMap).ParseFS(staticContent, "static/default.tmpl"))
-	return ctm.Execute(os.Stdout, cil)
+	return ctm.Execute(w, cil)
+}
+
+// marshalJSON returns cil in the format of the json output mode, which is
+// indented unless config.CompactJSON is set.
+func marshalJSON(cil *cpb.CapabilityInfoList, config *Config) ([]byte, error) {
+	opts := protojson.MarshalOptions{Multiline: true, Indent: "\t"}
+	if config.CompactJSON {
+		opts = protojson.MarshalOptions{}
+	}
+	b, err := opts.Marshal(cil)
+	if err != nil {
+		return nil, fmt.Errorf("internal error: couldn't marshal protocol buffer: %s", err.Error())
+	}
+	return b, nil
 }
 
-func templateFormat(args ...interface{}) string {
+// templateFormat returns the ANSI escape sequence which sets the color for the
+// kind of text named by args[0].  Capabilities, given by name or value in
+// args[1], are colored by their severity according to severities: red for
+// high, yellow for medium and green for low.
+func templateFormat(severities map[cpb.Capability]interesting.Severity, args ...interface{}) string {
 	var format string
 	if len(args) != 0 {
 		format = args[0].(string)
@@ -107,10 +369,11 @@ func templateFormat(args ...interface{}) string {
 		} else {
 			capability, ok = args[1].(string)
 		}
-		switch capability {
-		case "CAPABILITY_SAFE":
+		c, _ := interesting.ParseCapability(capability)
+		switch interesting.CapabilitySeverity(severities, c) {
+		case interesting.SeverityLow:
 			color.New(color.FgHiGreen).SetWriter(&w)
-		case "CAPABILITY_ARBITRARY_EXECUTION", "CAPABILITY_CGO", "CAPABILITY_UNSAFE_POINTER", "CAPABILITY_EXEC":
+		case interesting.SeverityHigh:
 			color.New(color.FgHiRed).SetWriter(&w)
 		default:
 			color.New(color.FgHiYellow).SetWriter(&w)
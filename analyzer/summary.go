@@ -0,0 +1,61 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io"
+
+	"github.com/google/capslock/interesting"
+	"golang.org/x/tools/go/packages"
+)
+
+// summaryOutput writes a single line counting the distinct capabilities of
+// the queried packages, and how many of them have high severity, such as
+//
+//	capslock: 5 capabilities (1 dangerous)
+//
+// If badge is true, it instead writes a shields.io endpoint object with the
+// same message, which can be used to show the summary in a README badge.
+func summaryOutput(w io.Writer, pkgs []*packages.Package, queriedPackages map[*types.Package]struct{}, config *Config, badge bool) error {
+	counts := GetCapabilityCounts(pkgs, queriedPackages, config).GetCapabilityCounts()
+	dangerous := 0
+	for name := range counts {
+		c, _ := interesting.ParseCapability(name)
+		if interesting.CapabilitySeverity(config.Severities, c) == interesting.SeverityHigh {
+			dangerous++
+		}
+	}
+	message := fmt.Sprintf("%d capabilities (%d dangerous)", len(counts), dangerous)
+	if len(counts) == 1 {
+		message = fmt.Sprintf("1 capability (%d dangerous)", dangerous)
+	}
+	if !badge {
+		_, err := fmt.Fprintf(w, "capslock: %s\n", message)
+		return err
+	}
+	color := "brightgreen"
+	if dangerous > 0 {
+		color = "red"
+	} else if len(counts) > 0 {
+		color = "yellow"
+	}
+	// See https://shields.io/badges/endpoint-badge for the format.
+	b, err := json.Marshal(struct {
+		SchemaVersion int    `json:"schemaVersion"`
+		Label         string `json:"label"`
+		Message       string `json:"message"`
+		Color         string `json:"color"`
+	}{1, "capslock", message, color})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	return err
+}
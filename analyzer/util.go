@@ -8,12 +8,18 @@ package analyzer
 
 import (
 	"go/ast"
+	"go/build/constraint"
 	"go/token"
 	"go/types"
+	"maps"
 	"os"
 	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
+	"github.com/google/capslock/interesting"
 	cpb "github.com/google/capslock/proto"
 	"golang.org/x/tools/go/callgraph"
 	"golang.org/x/tools/go/callgraph/vta"
@@ -53,6 +59,24 @@ func (nc nodesetPerCapability) add(cap cpb.Capability, node *callgraph.Node) {
 	m[node] = struct{}{}
 }
 
+// collapseSubcapabilities moves the nodes for each subcapability in nc to the
+// set for its top-level parent capability.
+func (nc nodesetPerCapability) collapseSubcapabilities() {
+	for cap, nodes := range nc {
+		parent, ok := interesting.ParentCapability(cap)
+		if !ok {
+			continue
+		}
+		for p, ok := interesting.ParentCapability(parent); ok; p, ok = interesting.ParentCapability(p) {
+			parent = p
+		}
+		for node := range nodes {
+			nc.add(parent, node)
+		}
+		delete(nc, cap)
+	}
+}
+
 // byFunction is a slice of *callgraph.Node that can be sorted using sort.Sort.
 // The ordering is first by package name, then function name.
 type byFunction []*callgraph.Node
@@ -184,29 +208,499 @@ func callsitePosition(edge *callgraph.Edge) token.Position {
 	}
 }
 
+// isInitFunction returns true if f is a package initializer, which runs the
+// package's init functions and initializes its variables, or one of the init
+// functions, which go/ssa names "init#1", "init#2", and so on.
+func isInitFunction(f *ssa.Function) bool {
+	if f.Signature.Recv() != nil || f.Parent() != nil {
+		return false
+	}
+	return f.Name() == "init" || strings.HasPrefix(f.Name(), "init#")
+}
+
+// callsRecover returns true if f calls the builtin function recover, as a
+// deferred function does to handle a panic.
+func callsRecover(f *ssa.Function) bool {
+	for _, b := range f.Blocks {
+		for _, i := range b.Instrs {
+			if c, ok := i.(*ssa.Call); ok {
+				if b, ok := c.Call.Value.(*ssa.Builtin); ok && b.Name() == "recover" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// unmatchedRoots returns the elements of roots which name no function or
+// method declared in queriedPackages, nor the initializer of one of them.
+func unmatchedRoots(roots []string, queriedPackages map[*types.Package]struct{}) []string {
+	names := make(map[string]struct{})
+	for pkg := range queriedPackages {
+		names[pkg.Path()+".init"] = struct{}{}
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			switch obj := scope.Lookup(name).(type) {
+			case *types.Func:
+				names[obj.FullName()] = struct{}{}
+			case *types.TypeName:
+				named, ok := obj.Type().(*types.Named)
+				if !ok {
+					continue
+				}
+				for i := 0; i < named.NumMethods(); i++ {
+					names[named.Method(i).FullName()] = struct{}{}
+				}
+			}
+		}
+	}
+	var unmatched []string
+	for _, r := range roots {
+		if _, ok := names[r]; !ok {
+			unmatched = append(unmatched, r)
+		}
+	}
+	return unmatched
+}
+
+// isExportedFunction returns true if f is an exported function, or an exported
+// method of an exported type, so that it can be called from other packages.
+// Closures, package initializers, and synthetic functions are not exported.
+func isExportedFunction(f *ssa.Function) bool {
+	if f.Origin() != nil {
+		f = f.Origin()
+	}
+	if f.Synthetic != "" || f.Parent() != nil {
+		return false
+	}
+	obj, ok := f.Object().(*types.Func)
+	if !ok || !obj.Exported() {
+		return false
+	}
+	recv := f.Signature.Recv()
+	if recv == nil {
+		return true
+	}
+	t := recv.Type()
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	switch t := types.Unalias(t).(type) {
+	case *types.Named:
+		return t.Obj().Exported()
+	case *types.Interface:
+		return true
+	}
+	return false
+}
+
+// isStdLib returns true if p is the path of a package in the standard
+// library, or is empty, as it is for functions whose package is unknown.
+//
+// The paths of standard library packages have no dot in their first
+// element, so other paths are rejected without listing the standard library.
+// If the standard library cannot be listed, every remaining path is assumed
+// to be in it.
 func isStdLib(p string) bool {
-	if strings.Contains(p, ".") {
+	if p == "" {
+		return true
+	}
+	if first, _, _ := strings.Cut(p, "/"); strings.Contains(first, ".") {
 		return false
 	}
+	std, err := standardLibraryPackages()
+	if err != nil {
+		return true
+	}
+	_, ok := std[p]
+	return ok
+}
+
+// isStdLib returns true if p is the path of a package in the standard
+// library, or of a package matching one of c.TrustedPackages, which is
+// treated like the standard library.
+func (c *Config) isStdLib(p string) bool {
+	if isStdLib(p) {
+		return true
+	}
+	return slices.ContainsFunc(c.TrustedPackages, func(pattern string) bool {
+		return interesting.MatchPackagePattern(pattern, p)
+	})
+}
+
+// edgeClassifier returns the classifier to use when deciding which calls to
+// follow in the callgraph.  If c.DirectOnly is set, it excludes calls from
+// one package outside the standard library and c.TrustedPackages to another,
+// and if c.IgnoreStringMethods is set, it excludes the calls to String and
+// Error methods made by package fmt or errors when formatting values.
+func (c *Config) edgeClassifier() Classifier {
+	classifier := c.Classifier
+	if c.IgnoreStringMethods {
+		classifier = stringMethodClassifier{classifier}
+	}
+	if c.DirectOnly {
+		classifier = directOnlyClassifier{classifier, c}
+	}
+	return classifier
+}
+
+// examplesPerKey returns the number of example paths to report for each
+// output of GetCapabilityInfo.
+func (c *Config) examplesPerKey() int {
+	if c.ExamplesPerKey < 1 || c.OmitPaths {
+		return 1
+	}
+	return c.ExamplesPerKey
+}
+
+// hasMinSeverity returns true if capability cap has at least the severity
+// c.MinSeverity.
+func (c *Config) hasMinSeverity(cap cpb.Capability) bool {
+	return interesting.CapabilitySeverity(c.Severities, cap) >= c.MinSeverity
+}
+
+// ignoresPath returns true if the path from v recorded in nodes matches one
+// of c.IgnorePaths.
+func (c *Config) ignoresPath(nodes bfsStateMap, v *callgraph.Node) bool {
+	var b strings.Builder
+	for ; v != nil; v = nodes[v].next() {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(v.Func.String())
+	}
+	path := b.String()
+	return slices.ContainsFunc(c.IgnorePaths, func(re *regexp.Regexp) bool { return re.MatchString(path) })
+}
+
+// setCustomCapability replaces a custom capability in ci, which was defined
+// with "define_capability" in a capability map, with CAPABILITY_CUSTOM and
+// the name of the capability.
+func setCustomCapability(ci *cpb.CapabilityInfo) {
+	c, custom := interesting.ToProto(ci.GetCapability())
+	if custom == "" {
+		return
+	}
+	ci.Capability = c.Enum()
+	ci.CustomCapability = proto.String(custom)
+}
+
+// infoCapability returns the capability of ci, undoing the effect of
+// setCustomCapability.
+func infoCapability(ci *cpb.CapabilityInfo) cpb.Capability {
+	return interesting.FromProto(ci.GetCapability(), ci.GetCustomCapability())
+}
+
+// directOnlyClassifier is a Classifier which does not include calls that
+// cross from one package outside the standard library to another.  Packages
+// are outside the standard library as decided by config.isStdLib.
+//
+// The wrapped Classifier is consulted first, even for calls which are then
+// excluded, so that a Classifier tracking which of its "ignore_edge" entries
+// are used sees every call the analysis considers.
+type directOnlyClassifier struct {
+	Classifier
+	config *Config
+}
+
+func (d directOnlyClassifier) IncludeCall(edge *callgraph.Edge) bool {
+	if !d.Classifier.IncludeCall(edge) {
+		return false
+	}
+	if edge.Caller.Func != nil && edge.Callee.Func != nil {
+		caller, callee := packagePath(edge.Caller.Func), packagePath(edge.Callee.Func)
+		if caller != callee && !d.config.isStdLib(caller) && !d.config.isStdLib(callee) {
+			return false
+		}
+	}
 	return true
 }
 
-func buildGraph(pkgs []*packages.Package, populateSyntax bool) (*callgraph.Graph, *ssa.Program, map[*ssa.Function]bool) {
-	rewriteCallsToSort(pkgs)
-	rewriteCallsToOnceDoEtc(pkgs)
+// stringMethodClassifier is a Classifier which does not include the calls
+// for which isFormattingStringMethodCall returns true.  Like
+// directOnlyClassifier, it consults the wrapped Classifier first.
+type stringMethodClassifier struct {
+	Classifier
+}
+
+func (s stringMethodClassifier) IncludeCall(edge *callgraph.Edge) bool {
+	return s.Classifier.IncludeCall(edge) && !isFormattingStringMethodCall(edge)
+}
+
+// isFormattingStringMethodCall returns true if edge is a call to a String or
+// Error method, as found by isStringMethodCall, made by a function in package
+// fmt or errors, such as the call fmt makes to format a value which
+// implements fmt.Stringer.  Since any value can be formatted, the callgraph
+// can connect such a call to the method of every type whose values are
+// formatted anywhere in the program.
+func isFormattingStringMethodCall(edge *callgraph.Edge) bool {
+	if edge == nil || edge.Site == nil || edge.Caller == nil || edge.Caller.Func == nil {
+		return false
+	}
+	if p := packagePath(edge.Caller.Func); p != "fmt" && p != "errors" {
+		return false
+	}
+	return isStringMethodCall(edge.Site.Common())
+}
+
+// isStringMethodCall returns true if call is a dynamic call to a method named
+// String or Error which takes no arguments and returns a string, as in the
+// fmt.Stringer and error interfaces.
+func isStringMethodCall(call *ssa.CallCommon) bool {
+	if !call.IsInvoke() || (call.Method.Name() != "String" && call.Method.Name() != "Error") {
+		return false
+	}
+	sig := call.Signature()
+	if sig.Params().Len() != 0 || sig.Results().Len() != 1 {
+		return false
+	}
+	return types.Identical(sig.Results().At(0).Type(), types.Typ[types.String])
+}
+
+// BuildCallGraph builds the callgraph that Capslock analyzes for pkgs and
+// their dependencies, and returns it with the SSA program it was built from.
+// Before building it, calls to functions such as sort.Sort and
+// (*sync.Once).Do in the syntax of pkgs are rewritten to make the callgraph
+// more precise, so the syntax no longer matches the source files.
+func BuildCallGraph(pkgs []*packages.Package) (*callgraph.Graph, *ssa.Program) {
+	graph, ssaProg, _, _ := buildGraph(pkgs, false)
+	return graph, ssaProg
+}
+
+// buildGraph builds the callgraph for pkgs.  It also returns the calls which
+// it could not rewrite to improve the callgraph's precision.  If shallow is
+// true, the functions in the dependencies of pkgs have no bodies, so the
+// callgraph contains no calls made by them.
+func buildGraph(pkgs []*packages.Package, shallow bool) (*callgraph.Graph, *ssa.Program, map[*ssa.Function]bool, []rewriteFailure) {
+	rewriteFailures := rewriteCallsToSort(pkgs)
+	rewriteFailures = append(rewriteFailures, rewriteCallsToOnceDoEtc(pkgs)...)
+	rewriteFailures = append(rewriteFailures, rewriteCallsToOnceFuncEtc(pkgs)...)
+	// The SSA form is not built in debug mode, which would record the ast
+	// expression for each SSA value but is expensive.  The builtin analyses
+	// of functions which convert unsafe.Pointer objects or use the reflect
+	// package in notable ways only need ssa.Function.Syntax(), which is set
+	// without it.
 	ssaBuilderMode := ssa.InstantiateGenerics
-	if populateSyntax {
-		// Debug mode makes ssa.Function.Syntax() point to the ast Node for the
-		// function.  This will allow us to link nodes in the callgraph with
-		// functions in the syntax tree which convert unsafe.Pointer objects or
-		// use the reflect package in notable ways.
-		ssaBuilderMode |= ssa.GlobalDebug
-	}
-	ssaProg, _ := ssautil.AllPackages(pkgs, ssaBuilderMode)
+	var ssaProg *ssa.Program
+	if shallow {
+		// Only the functions in pkgs have bodies; the dependencies are
+		// created from their type information.
+		ssaProg, _ = ssautil.Packages(pkgs, ssaBuilderMode)
+	} else {
+		ssaProg, _ = ssautil.AllPackages(pkgs, ssaBuilderMode)
+	}
 	ssaProg.Build()
 	allFunctions := ssautil.AllFunctions(ssaProg)
 	graph := vta.CallGraph(allFunctions, nil)
-	return graph, ssaProg, allFunctions
+	addReflectCallEdges(graph, allFunctions)
+	addTypeAssertionEdges(graph, allFunctions)
+	addFileArgumentEdges(graph, allFunctions)
+	return graph, ssaProg, allFunctions, rewriteFailures
+}
+
+// addReflectCallEdges adds edges to graph for calls to (reflect.Value).Call
+// and (reflect.Value).CallSlice where the reflect.Value was created by calling
+// reflect.ValueOf on a statically-known function, such as in
+// reflect.ValueOf(net.Dial).Call(args).  The edges go from the function
+// making the call to the function being called, which the callgraph would
+// otherwise not connect.
+func addReflectCallEdges(graph *callgraph.Graph, allFunctions map[*ssa.Function]bool) {
+	for fn := range allFunctions {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				site, ok := instr.(ssa.CallInstruction)
+				if !ok {
+					continue
+				}
+				common := site.Common()
+				if !isReflectValueCall(common.StaticCallee()) || len(common.Args) == 0 {
+					continue
+				}
+				if target := reflectedFunction(common.Args[0]); target != nil {
+					callgraph.AddEdge(graph.CreateNode(fn), site, graph.CreateNode(target))
+				}
+			}
+		}
+	}
+}
+
+// addTypeAssertionEdges adds edges to graph for method calls on a value which
+// was type-asserted to an interface type literal, such as in
+//
+//	if c, ok := r.(interface{ Chmod(os.FileMode) error }); ok {
+//		c.Chmod(0)
+//	}
+//
+// if the callgraph has no callees for the call, because no value with a
+// suitable dynamic type was found to reach it.  A caller could pass a value of
+// any type which has the method, so the edges go to the method of each type
+// in the program which implements the interface.
+func addTypeAssertionEdges(graph *callgraph.Graph, allFunctions map[*ssa.Function]bool) {
+	// methods maps method names to the methods of concrete types.
+	methods := make(map[string][]*ssa.Function)
+	for fn := range allFunctions {
+		recv := fn.Signature.Recv()
+		if recv == nil || types.IsInterface(recv.Type()) || fn.TypeParams().Len() > 0 {
+			continue
+		}
+		methods[fn.Name()] = append(methods[fn.Name()], fn)
+	}
+	for _, fns := range methods {
+		slices.SortFunc(fns, funcCompare) // make the order of edges deterministic
+	}
+	for fn := range allFunctions {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				site, ok := instr.(ssa.CallInstruction)
+				if !ok {
+					continue
+				}
+				common := site.Common()
+				if !common.IsInvoke() {
+					continue
+				}
+				iface := assertedInterfaceLiteral(common.Value)
+				if iface == nil {
+					continue
+				}
+				caller := graph.CreateNode(fn)
+				if slices.ContainsFunc(caller.Out, func(e *callgraph.Edge) bool { return e.Site == site }) {
+					continue
+				}
+				for _, m := range methods[common.Method.Name()] {
+					if types.Implements(m.Signature.Recv().Type(), iface) {
+						callgraph.AddEdge(caller, site, graph.CreateNode(m))
+					}
+				}
+			}
+		}
+	}
+}
+
+// assertedInterfaceLiteral returns the interface type which v was asserted to
+// have, if v is the result of a type assertion to an interface type literal,
+// and nil otherwise.
+func assertedInterfaceLiteral(v ssa.Value) *types.Interface {
+	if e, ok := v.(*ssa.Extract); ok && e.Index == 0 {
+		v = e.Tuple
+	}
+	ta, ok := v.(*ssa.TypeAssert)
+	if !ok {
+		return nil
+	}
+	iface, _ := types.Unalias(ta.AssertedType).(*types.Interface)
+	return iface
+}
+
+// addFileArgumentEdges adds edges to graph for calls to standard library
+// functions which pass an *os.File as an argument of an interface type with a
+// Read or Write method, such as io.Reader or io.Writer, such as in
+//
+//	fmt.Fprintf(f, "%d\n", n)
+//
+// The edges go from the function making the call to the file's Read or Write
+// method.  The callgraph already connects the callee to the file's methods
+// when the *os.File flows to a call of them, but those edges are not followed
+// when the callee is classified without analyzing its body, as the functions
+// of fmt are, so without these edges the use of the file would be missed.
+// Like the edges the callgraph has for calls outside the standard library,
+// they are added for every file, including os.Stdin, os.Stdout and os.Stderr.
+// Each edge is from the caller, at the call which passes the file, and is
+// added once however many times the call passes the file.
+func addFileArgumentEdges(graph *callgraph.Graph, allFunctions map[*ssa.Function]bool) {
+	for fn := range allFunctions {
+		caller := graph.CreateNode(fn)
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				site, ok := instr.(ssa.CallInstruction)
+				if !ok {
+					continue
+				}
+				if callee := site.Common().StaticCallee(); callee == nil || !isStdLib(packagePath(callee)) {
+					continue
+				}
+				for _, arg := range site.Common().Args {
+					mi, ok := arg.(*ssa.MakeInterface)
+					if !ok || !isOSFilePointer(mi.X.Type()) {
+						continue
+					}
+					iface, ok := mi.Type().Underlying().(*types.Interface)
+					if !ok {
+						continue
+					}
+					for i := 0; i < iface.NumMethods(); i++ {
+						if name := iface.Method(i).Name(); name == "Read" || name == "Write" {
+							target := graph.CreateNode(fn.Prog.LookupMethod(mi.X.Type(), nil, name))
+							if slices.ContainsFunc(caller.Out, func(e *callgraph.Edge) bool { return e.Site == site && e.Callee == target }) {
+								continue
+							}
+							callgraph.AddEdge(caller, site, target)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// isOSFilePointer returns true if t is *os.File.
+func isOSFilePointer(t types.Type) bool {
+	p, ok := types.Unalias(t).(*types.Pointer)
+	if !ok {
+		return false
+	}
+	named, ok := types.Unalias(p.Elem()).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "os" && obj.Name() == "File"
+}
+
+// isReflectValueCall returns true if fn is (reflect.Value).Call or
+// (reflect.Value).CallSlice.
+func isReflectValueCall(fn *ssa.Function) bool {
+	if fn == nil || (fn.Name() != "Call" && fn.Name() != "CallSlice") {
+		return false
+	}
+	recv := fn.Signature.Recv()
+	if recv == nil {
+		return false
+	}
+	named, ok := recv.Type().(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "reflect" && obj.Name() == "Value"
+}
+
+// reflectedFunction returns the function f if v is the result of
+// reflect.ValueOf(f) for a statically-known function or closure f, and nil
+// otherwise.
+func reflectedFunction(v ssa.Value) *ssa.Function {
+	call, ok := v.(*ssa.Call)
+	if !ok {
+		return nil
+	}
+	callee := call.Common().StaticCallee()
+	if callee == nil || callee.String() != "reflect.ValueOf" || len(call.Common().Args) != 1 {
+		return nil
+	}
+	mi, ok := call.Common().Args[0].(*ssa.MakeInterface)
+	if !ok {
+		return nil
+	}
+	switch x := mi.X.(type) {
+	case *ssa.Function:
+		return x
+	case *ssa.MakeClosure:
+		if f, ok := x.Fn.(*ssa.Function); ok {
+			return f
+		}
+	}
+	return nil
 }
 
 // functionsToRewrite lists the functions and methods like (*sync.Once).Do that
@@ -228,6 +722,36 @@ var functionsToRewrite = []matcher{
 		functionName:                "SliceStable",
 		functionTypedParameterIndex: 1,
 	},
+	&packageFunctionMatcher{
+		pkg:                         "slices",
+		functionName:                "SortFunc",
+		functionTypedParameterIndex: 1,
+	},
+	&packageFunctionMatcher{
+		pkg:                         "slices",
+		functionName:                "SortStableFunc",
+		functionTypedParameterIndex: 1,
+	},
+}
+
+// functionWrappersToRewrite lists the functions like sync.OnceFunc that
+// rewriteCallsToOnceFuncEtc will replace with their arguments.
+var functionWrappersToRewrite = []matcher{
+	&packageFunctionMatcher{
+		pkg:                         "sync",
+		functionName:                "OnceFunc",
+		functionTypedParameterIndex: 0,
+	},
+	&packageFunctionMatcher{
+		pkg:                         "sync",
+		functionName:                "OnceValue",
+		functionTypedParameterIndex: 0,
+	},
+	&packageFunctionMatcher{
+		pkg:                         "sync",
+		functionName:                "OnceValues",
+		functionTypedParameterIndex: 0,
+	},
 }
 
 type matcher interface {
@@ -253,7 +777,16 @@ type methodMatcher struct {
 }
 
 func (m *packageFunctionMatcher) match(typeInfo *types.Info, call *ast.CallExpr) ast.Expr {
-	callee, ok := call.Fun.(*ast.SelectorExpr)
+	fun := call.Fun
+	switch f := fun.(type) {
+	case *ast.IndexExpr:
+		// An instantiation of a generic function with explicit type arguments,
+		// e.g. sync.OnceValue[int].
+		fun = f.X
+	case *ast.IndexListExpr:
+		fun = f.X
+	}
+	callee, ok := fun.(*ast.SelectorExpr)
 	if !ok {
 		// The function to be called is not a selection, so it can't be a call to
 		// the relevant package.  (Unless the user has dot-imported the package,
@@ -386,11 +919,19 @@ func (m *methodMatcher) match(typeInfo *types.Info, call *ast.CallExpr) ast.Expr
 }
 
 // visitor is passed to ast.Visit, to find AST nodes where
-// unsafe.Pointer values are converted to pointers.
+// unsafe.Pointer values are converted to pointers, or where pointers and
+// uintptr values are converted to each other, or where the unsafe builtins
+// such as unsafe.Slice and unsafe.Add are called.  It also finds the functions
+// which get struct fields with reflect, and the functions which get unsafe
+// pointers with reflect.
 // It satisfies the ast.Visitor interface.
 type visitor struct {
 	// The sets we are populating.
-	unsafeFunctionNodes map[ast.Node]struct{}
+	unsafeFunctionNodes   map[ast.Node]struct{}
+	pointerToUintptrNodes map[ast.Node]struct{}
+	uintptrToPointerNodes map[ast.Node]struct{}
+	reflectFieldNodes     map[ast.Node]struct{}
+	reflectUnsafeNodes    map[ast.Node]struct{}
 	// Set to true if an unsafe.Pointer conversion is found that is not inside
 	// a function, method, or function literal definition.
 	seenUnsafePointerUseInInitialization *bool
@@ -463,14 +1004,19 @@ func (v *visitor) Visit(node ast.Node) ast.Visitor {
 		// If this node has a single argument which is an unsafe.Pointer (or
 		// is equivalent to an unsafe.Pointer) and the callee is a type which is not
 		// uintptr, we add the current function to v.unsafeFunctionNodes.
+		//
+		// Conversions from unsafe.Pointer to uintptr, and from uintptr to
+		// unsafe.Pointer, are recorded in v.pointerToUintptrNodes and
+		// v.uintptrToPointerNodes, so that functions doing both can be found.
 		funType := v.pkg.TypesInfo.Types[node.Fun]
 		if !funType.IsType() {
 			// The callee is not a type; it's probably a function or method.
-			break
-		}
-		if b, ok := funType.Type.Underlying().(*types.Basic); ok && b.Kind() == types.Uintptr {
-			// The conversion is to a uintptr, not a pointer.  On its own, this is
-			// safe.
+			// Calls to some functions and methods in reflect are recorded in
+			// v.reflectFieldNodes and v.reflectUnsafeNodes.  Calls to the unsafe
+			// builtins which make pointers, slices and strings are recorded in
+			// v.unsafeFunctionNodes.
+			v.visitReflectCall(node)
+			v.visitUnsafeBuiltinCall(node)
 			break
 		}
 		var args []ast.Expr = node.Args
@@ -483,10 +1029,25 @@ func (v *visitor) Visit(node ast.Node) ast.Visitor {
 			// The argument has no type information.
 			break
 		}
-		if b, ok := argType.Underlying().(*types.Basic); !ok || b.Kind() != types.UnsafePointer {
+		if isBasicKind(funType.Type, types.UnsafePointer) && isBasicKind(argType, types.Uintptr) {
+			// The conversion is from a uintptr to an unsafe.Pointer.
+			if v.currentFunction != nil {
+				v.uintptrToPointerNodes[v.currentFunction] = struct{}{}
+			}
+			break
+		}
+		if !isBasicKind(argType, types.UnsafePointer) {
 			// The argument's type is not equivalent to unsafe.Pointer.
 			break
 		}
+		if isBasicKind(funType.Type, types.Uintptr) {
+			// The conversion is to a uintptr, not a pointer.  On its own, this is
+			// safe.
+			if v.currentFunction != nil {
+				v.pointerToUintptrNodes[v.currentFunction] = struct{}{}
+			}
+			break
+		}
 		if v.currentFunction == nil {
 			*v.seenUnsafePointerUseInInitialization = true
 		} else {
@@ -496,6 +1057,159 @@ func (v *visitor) Visit(node ast.Node) ast.Visitor {
 	return v
 }
 
+// visitReflectCall adds the current function to v.reflectFieldNodes if call
+// is a call to a method of reflect.Value which gets a struct field, and to
+// v.reflectUnsafeNodes if call is a call to a function or method in reflect
+// which can be used to access the memory of a value through an unsafe
+// pointer, even if the value was obtained from an unexported field.
+func (v *visitor) visitReflectCall(call *ast.CallExpr) {
+	if v.currentFunction == nil {
+		return
+	}
+	var id *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return
+	}
+	fn, ok := v.pkg.TypesInfo.Uses[id].(*types.Func)
+	if !ok {
+		return
+	}
+	switch fn.FullName() {
+	case "(reflect.Value).Field", "(reflect.Value).FieldByIndex",
+		"(reflect.Value).FieldByIndexErr", "(reflect.Value).FieldByName",
+		"(reflect.Value).FieldByNameFunc":
+		v.reflectFieldNodes[v.currentFunction] = struct{}{}
+	case "reflect.NewAt", "(reflect.Value).UnsafeAddr", "(reflect.Value).UnsafePointer":
+		v.reflectUnsafeNodes[v.currentFunction] = struct{}{}
+	}
+}
+
+// visitUnsafeBuiltinCall adds the current function to v.unsafeFunctionNodes
+// if call is a call to unsafe.Add, unsafe.Slice, unsafe.SliceData,
+// unsafe.String or unsafe.StringData.  Like conversions of unsafe.Pointer
+// values, these can create pointers, slices and strings which alias memory of
+// another type or beyond the bounds of an object.
+func (v *visitor) visitUnsafeBuiltinCall(call *ast.CallExpr) {
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	b, ok := v.pkg.TypesInfo.Uses[sel.Sel].(*types.Builtin)
+	if !ok || b.Pkg() != types.Unsafe {
+		return
+	}
+	switch b.Name() {
+	case "Add", "Slice", "SliceData", "String", "StringData":
+	default:
+		return
+	}
+	if v.currentFunction == nil {
+		*v.seenUnsafePointerUseInInitialization = true
+	} else {
+		v.unsafeFunctionNodes[v.currentFunction] = struct{}{}
+	}
+}
+
+// interfaceCategory is an interface type, and the capability assigned to the
+// methods of types which implement it.
+type interfaceCategory struct {
+	iface      *types.Interface
+	capability cpb.Capability
+}
+
+// findInterfaceCategories returns the interfaces classified by classifier, if
+// it is an InterfaceClassifier, which are declared in packages in the
+// program.  Interfaces in other packages cannot be implemented by any type in
+// the program, so they are ignored.
+func findInterfaceCategories(graph *callgraph.Graph, classifier Classifier) []interfaceCategory {
+	ic, ok := classifier.(InterfaceClassifier)
+	if !ok {
+		return nil
+	}
+	names := ic.InterfaceCategories()
+	if len(names) == 0 {
+		return nil
+	}
+	pkgs := make(map[string]*types.Package)
+	var add func(p *types.Package)
+	add = func(p *types.Package) {
+		if _, ok := pkgs[p.Path()]; ok {
+			return
+		}
+		pkgs[p.Path()] = p
+		for _, q := range p.Imports() {
+			add(q)
+		}
+	}
+	for f := range graph.Nodes {
+		if f != nil && f.Pkg != nil && f.Pkg.Pkg != nil {
+			add(f.Pkg.Pkg)
+		}
+	}
+	var ret []interfaceCategory
+	for _, name := range slices.Sorted(maps.Keys(names)) {
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			continue
+		}
+		p, ok := pkgs[name[:i]]
+		if !ok {
+			continue
+		}
+		obj, ok := p.Scope().Lookup(name[i+1:]).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		if iface, ok := obj.Type().Underlying().(*types.Interface); ok {
+			ret = append(ret, interfaceCategory{iface, names[name]})
+		}
+	}
+	return ret
+}
+
+// methodInterfaceCategory returns the capability of the first interface in
+// interfaces which has a method with the same name as fn and is implemented by
+// fn's receiver type, or a pointer to it.  If fn is not such a method, it
+// returns CAPABILITY_UNSPECIFIED.
+func methodInterfaceCategory(fn *ssa.Function, interfaces []interfaceCategory) cpb.Capability {
+	recv := fn.Signature.Recv()
+	if len(interfaces) == 0 || recv == nil || types.IsInterface(recv.Type()) {
+		return cpb.Capability_CAPABILITY_UNSPECIFIED
+	}
+	t := recv.Type()
+	for _, ic := range interfaces {
+		hasMethod := false
+		for i := 0; i < ic.iface.NumMethods(); i++ {
+			if ic.iface.Method(i).Name() == fn.Name() {
+				hasMethod = true
+				break
+			}
+		}
+		if !hasMethod {
+			continue
+		}
+		if types.Implements(t, ic.iface) {
+			return ic.capability
+		}
+		if _, isPointer := t.(*types.Pointer); !isPointer && types.Implements(types.NewPointer(t), ic.iface) {
+			return ic.capability
+		}
+	}
+	return cpb.Capability_CAPABILITY_UNSPECIFIED
+}
+
+// isBasicKind returns true if the underlying type of t is the basic type
+// with kind k.
+func isBasicKind(t types.Type, k types.BasicKind) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == k
+}
+
 // forEachPackageIncludingDependencies calls fn exactly once for each package
 // that is in pkgs or in the transitive dependencies of pkgs.
 func forEachPackageIncludingDependencies(pkgs []*packages.Package, fn func(*packages.Package)) {
@@ -516,6 +1230,19 @@ func forEachPackageIncludingDependencies(pkgs []*packages.Package, fn func(*pack
 	}
 }
 
+// inPackages returns true if f, or the generic function it is an instance
+// of, is in one of pkgs.
+func inPackages(f *ssa.Function, pkgs map[*types.Package]struct{}) bool {
+	if f.Origin() != nil {
+		f = f.Origin()
+	}
+	if f.Package() == nil {
+		return false
+	}
+	_, ok := pkgs[f.Package().Pkg]
+	return ok
+}
+
 func programName() string {
 	if a := os.Args; len(a) >= 1 {
 		return path.Base(a[0])
@@ -523,6 +1250,104 @@ func programName() string {
 	return "capslock"
 }
 
+// generatedFiles returns the names of the files of pkgs which are generated:
+// those with a "// Code generated ... DO NOT EDIT." comment, and those whose
+// base names match one of patterns.
+func generatedFiles(pkgs []*packages.Package, patterns []string) map[string]struct{} {
+	generated := make(map[string]struct{})
+	for _, p := range pkgs {
+		for _, file := range p.Syntax {
+			name := p.Fset.Position(file.Package).Filename
+			isGenerated := ast.IsGenerated(file)
+			for _, pattern := range patterns {
+				if ok, _ := filepath.Match(pattern, filepath.Base(name)); ok {
+					isGenerated = true
+				}
+			}
+			if isGenerated {
+				generated[name] = struct{}{}
+			}
+		}
+	}
+	return generated
+}
+
+// buildTagFiles returns the names of the files of pkgs which have a "//go:build"
+// constraint that can only be satisfied if one of tags is set, such as
+// "//go:build example" or "//go:build example && linux" for the tag
+// "example", and the packages all of whose files are such files.
+func buildTagFiles(pkgs []*packages.Package, tags []string) (map[string]struct{}, map[*types.Package]struct{}) {
+	files := make(map[string]struct{})
+	pkgSet := make(map[*types.Package]struct{})
+	if len(tags) == 0 {
+		return files, pkgSet
+	}
+	withoutTags := func(tag string) bool { return !slices.Contains(tags, tag) }
+	for _, p := range pkgs {
+		all := len(p.Syntax) > 0
+		for _, file := range p.Syntax {
+			excluded := false
+			for _, cg := range file.Comments {
+				if cg.Pos() > file.Package {
+					break
+				}
+				for _, c := range cg.List {
+					if !constraint.IsGoBuild(c.Text) {
+						continue
+					}
+					if expr, err := constraint.Parse(c.Text); err == nil && !expr.Eval(withoutTags) {
+						excluded = true
+					}
+				}
+			}
+			if excluded {
+				files[p.Fset.Position(file.Package).Filename] = struct{}{}
+			} else {
+				all = false
+			}
+		}
+		if all && p.Types != nil {
+			pkgSet[p.Types] = struct{}{}
+		}
+	}
+	return files, pkgSet
+}
+
+// vendoredOrGeneratedPackages returns the packages of pkgs which are in a
+// vendor directory, or all of whose files are generated, as decided by
+// generatedFiles with patterns.  A package of a module other than the main
+// module is vendored if one of its files is in a directory named "vendor";
+// a package is also vendored if its import path has a "vendor" element, as
+// in GOPATH mode.
+func vendoredOrGeneratedPackages(pkgs []*packages.Package, patterns []string) map[*types.Package]struct{} {
+	generated := generatedFiles(pkgs, patterns)
+	hasVendorElement := func(p string) bool {
+		return slices.Contains(strings.Split(filepath.ToSlash(p), "/"), "vendor")
+	}
+	pkgSet := make(map[*types.Package]struct{})
+	for _, p := range pkgs {
+		if p.Types == nil {
+			continue
+		}
+		vendored := hasVendorElement(p.PkgPath)
+		if p.Module != nil && !p.Module.Main {
+			for _, f := range p.GoFiles {
+				vendored = vendored || hasVendorElement(filepath.Dir(f))
+			}
+		}
+		allGenerated := len(p.Syntax) > 0
+		for _, file := range p.Syntax {
+			if _, ok := generated[p.Fset.Position(file.Package).Filename]; !ok {
+				allGenerated = false
+			}
+		}
+		if vendored || allGenerated {
+			pkgSet[p.Types] = struct{}{}
+		}
+	}
+	return pkgSet
+}
+
 // addFunction adds an entry to *fns for the given node and edge.
 // The edge can be nil.
 func addFunction(fns *[]*cpb.Function, v *callgraph.Node, incomingEdge *callgraph.Edge) {
@@ -537,6 +1362,11 @@ func addFunction(fns *[]*cpb.Function, v *callgraph.Node, incomingEdge *callgrap
 			Column:   proto.Int64(int64(position.Column)),
 		}
 	}
+	if incomingEdge != nil {
+		if _, ok := incomingEdge.Site.(*ssa.Go); ok {
+			fn.InGoroutine = proto.Bool(true)
+		}
+	}
 	*fns = append(*fns, fn)
 }
 
@@ -544,22 +1374,6 @@ func addFunction(fns *[]*cpb.Function, v *callgraph.Node, incomingEdge *callgrap
 // no associated package, e.g. because it is a wrapper function.
 func nodeToPackage(node *callgraph.Node) *types.Package {
 	fn := node.Func
-	// receiverTypePackage returns the package of a method given the type of its
-	// receiver.
-	receiverTypePackage := func(typ types.Type) *types.Package {
-		if typ == nil {
-			return nil
-		}
-		if p, ok := typ.(*types.Pointer); ok {
-			typ = p.Elem()
-		}
-		if n, ok := typ.(*types.Named); ok {
-			if pkg := n.Obj().Pkg(); pkg != nil {
-				return pkg
-			}
-		}
-		return nil
-	}
 	// Ordinary functions and methods.
 	if pkg := fn.Package(); pkg != nil {
 		return pkg.Pkg
@@ -570,16 +1384,13 @@ func nodeToPackage(node *callgraph.Node) *types.Package {
 			return pkg.Pkg
 		}
 	}
-	// Method expressions.
-	if strings.HasSuffix(fn.Name(), "$thunk") {
-		if len(fn.Params) > 0 {
-			return receiverTypePackage(fn.Params[0].Object().Type())
-		}
-	}
-	// Method values.
-	if strings.HasSuffix(fn.Name(), "$bound") {
-		if len(fn.FreeVars) >= 1 {
-			return receiverTypePackage(fn.FreeVars[0].Type())
+	// Wrappers for methods, including methods promoted from embedded fields,
+	// method expressions ("$thunk" functions) and method values ("$bound"
+	// functions), belong to the package which declares the method, as in
+	// packagePath, rather than the package of the type they are called on.
+	if obj := types.Object(fn.Object()); obj != nil {
+		if pkg := obj.Pkg(); pkg != nil {
+			return pkg
 		}
 	}
 	// Other wrappers.
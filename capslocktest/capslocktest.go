@@ -0,0 +1,87 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package capslocktest provides helpers for checking the capabilities of
+// packages from Go tests, so that a change which gives a package a new
+// capability makes a test fail.
+//
+// For example:
+//
+//	func TestCapabilities(t *testing.T) {
+//		capslocktest.AssertCapabilities(t, "example.com/mypackage/...", []cpb.Capability{
+//			cpb.Capability_CAPABILITY_FILES,
+//		})
+//	}
+package capslocktest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/capslock/analyzer"
+	"github.com/google/capslock/interesting"
+	cpb "github.com/google/capslock/proto"
+)
+
+// AssertCapabilities loads and analyzes the packages matching pattern, and
+// reports a test error for each capability they have which is not in
+// allowed, with an example call path for it.  Allowing a capability also
+// allows its subcapabilities.
+//
+// The pattern is interpreted relative to the current directory, which for a
+// test is the directory of the package being tested.
+func AssertCapabilities(t testing.TB, pattern string, allowed []cpb.Capability) {
+	t.Helper()
+	pkgs, err := analyzer.LoadPackages([]string{pattern}, analyzer.LoadConfig{})
+	if err != nil {
+		t.Fatalf("loading packages matching %q: %v", pattern, err)
+	}
+	if len(pkgs) == 0 {
+		t.Fatalf("no packages matching %q", pattern)
+	}
+	if errPkgs := analyzer.PackagesWithErrors(pkgs); len(errPkgs) > 0 {
+		t.Fatalf("loading packages matching %q: package %s has errors: %v", pattern, errPkgs[0].PkgPath, errPkgs[0].Errors)
+	}
+	cil := analyzer.GetCapabilityInfo(pkgs, analyzer.GetQueriedPackages(pkgs), &analyzer.Config{
+		Classifier:  analyzer.GetClassifier(false),
+		Granularity: analyzer.GranularityFunction,
+	})
+	for _, err := range unexpectedCapabilities(cil, allowed) {
+		t.Error(err)
+	}
+}
+
+// unexpectedCapabilities returns an error for each capability in cil that is
+// not in allowed, and is not a subcapability of a capability in allowed.
+func unexpectedCapabilities(cil *cpb.CapabilityInfoList, allowed []cpb.Capability) []error {
+	ok := make(map[cpb.Capability]bool)
+	for _, c := range allowed {
+		ok[c] = true
+	}
+	isAllowed := func(c cpb.Capability) bool {
+		for {
+			if ok[c] {
+				return true
+			}
+			p, isSub := interesting.ParentCapability(c)
+			if !isSub {
+				return false
+			}
+			c = p
+		}
+	}
+	var errs []error
+	reported := make(map[cpb.Capability]bool)
+	for _, ci := range cil.GetCapabilityInfo() {
+		c := ci.GetCapability()
+		if isAllowed(c) || reported[c] {
+			continue
+		}
+		reported[c] = true
+		errs = append(errs, fmt.Errorf("package %s has unexpected capability %s, e.g. via call path: %s", ci.GetPackageDir(), c, ci.GetDepPath()))
+	}
+	return errs
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package capslocktest
+
+import (
+	"strings"
+	"testing"
+
+	cpb "github.com/google/capslock/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAssertCapabilities(t *testing.T) {
+	AssertCapabilities(t, "../testpkgs/callnet", []cpb.Capability{cpb.Capability_CAPABILITY_NETWORK})
+}
+
+func TestUnexpectedCapabilities(t *testing.T) {
+	cil := &cpb.CapabilityInfoList{
+		CapabilityInfo: []*cpb.CapabilityInfo{
+			{
+				PackageDir: proto.String("example.com/p"),
+				Capability: cpb.Capability_CAPABILITY_FILES.Enum(),
+				DepPath:    proto.String("example.com/p.A os.Open"),
+			},
+			{
+				PackageDir: proto.String("example.com/p"),
+				Capability: cpb.Capability_CAPABILITY_NETWORK_CLIENT.Enum(),
+				DepPath:    proto.String("example.com/p.B net.Dial"),
+			},
+			{
+				PackageDir: proto.String("example.com/p"),
+				Capability: cpb.Capability_CAPABILITY_EXEC.Enum(),
+				DepPath:    proto.String("example.com/p.C os/exec.Command"),
+			},
+			{
+				PackageDir: proto.String("example.com/p"),
+				Capability: cpb.Capability_CAPABILITY_EXEC.Enum(),
+				DepPath:    proto.String("example.com/p.D os/exec.Command"),
+			},
+		},
+	}
+	// CAPABILITY_NETWORK_CLIENT is allowed as a subcapability of
+	// CAPABILITY_NETWORK, and CAPABILITY_EXEC is only reported once.
+	errs := unexpectedCapabilities(cil, []cpb.Capability{cpb.Capability_CAPABILITY_NETWORK})
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"package example.com/p has unexpected capability CAPABILITY_FILES, e.g. via call path: example.com/p.A os.Open",
+		"package example.com/p has unexpected capability CAPABILITY_EXEC, e.g. via call path: example.com/p.C os/exec.Command",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpectedCapabilities: got %q, want %q", got, want)
+	}
+}
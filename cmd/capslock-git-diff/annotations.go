@@ -0,0 +1,109 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	cpb "github.com/google/capslock/proto"
+)
+
+// printGitHubAnnotations writes to w a GitHub Actions workflow command for
+// each of cis, which GitHub shows as a warning annotation on the line in the
+// root function of the call path where the call leading to the capability
+// is made.  dirs is the result of packageDirectories.
+func printGitHubAnnotations(w io.Writer, cis []*cpb.CapabilityInfo, dirs map[string]string) error {
+	for _, ci := range cis {
+		if _, err := fmt.Fprintln(w, gitHubAnnotation(ci, dirs)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packageDirectories returns the directories, relative to the root of the
+// git repository containing the current directory, of the packages of the
+// root functions of the call paths in cis, which are where annotations for
+// them are located.  Called in a temporary clone of the repository, it
+// returns the directories at the revision checked out there.
+func packageDirectories(cis []*cpb.CapabilityInfo) (map[string]string, error) {
+	pkgs := make(map[string]bool)
+	for _, ci := range cis {
+		if p := ci.GetPath(); len(p) > 0 && p[0].GetPackage() != "" {
+			pkgs[p[0].GetPackage()] = true
+		}
+	}
+	if len(pkgs) == 0 {
+		return nil, nil
+	}
+	var b bytes.Buffer
+	if err := run(&b, "git", "rev-parse", "--show-toplevel"); err != nil {
+		return nil, err
+	}
+	top := strings.TrimSpace(b.String())
+	b.Reset()
+	args := append([]string{"list", "-e", "-f", "{{.ImportPath}} {{.Dir}}"}, slices.Sorted(maps.Keys(pkgs))...)
+	if err := run(&b, "go", args...); err != nil {
+		return nil, err
+	}
+	dirs := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if pkg, dir, ok := strings.Cut(line, " "); ok && dir != "" {
+			if rel, err := filepath.Rel(top, dir); err == nil {
+				dirs[pkg] = filepath.ToSlash(rel)
+			}
+		}
+	}
+	return dirs, nil
+}
+
+// gitHubAnnotation returns a workflow command which warns about the
+// capability of ci.  dirs maps package paths to their directories relative to
+// the root of the repository.  The call site of the second function in the
+// path is in the root function; if it or the directory of the root function's
+// package is unknown, the annotation has no location.
+func gitHubAnnotation(ci *cpb.CapabilityInfo, dirs map[string]string) string {
+	fns := ci.GetPath()
+	msg := fmt.Sprintf("New %s capability", strings.TrimPrefix(capabilityOf(ci).String(), "CAPABILITY_"))
+	if len(fns) > 0 {
+		msg += " in " + fns[0].GetName()
+	}
+	if len(fns) > 1 {
+		msg += " (via " + fns[len(fns)-1].GetName() + ")"
+	}
+	var props []string
+	if len(fns) > 1 && fns[1].GetSite().GetFilename() != "" {
+		if dir, ok := dirs[fns[0].GetPackage()]; ok {
+			site := fns[1].GetSite()
+			props = append(props,
+				"file="+escapeProperty(path.Join(dir, site.GetFilename())),
+				fmt.Sprintf("line=%d", site.GetLine()),
+				fmt.Sprintf("col=%d", site.GetColumn()))
+		}
+	}
+	if len(props) == 0 {
+		return "::warning::" + escapeData(msg)
+	}
+	return "::warning " + strings.Join(props, ",") + "::" + escapeData(msg)
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes the value of a property of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
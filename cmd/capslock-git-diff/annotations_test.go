@@ -0,0 +1,136 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package main
+
+import (
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	cpb "github.com/google/capslock/proto"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+func TestGitHubAnnotation(t *testing.T) {
+	dirs := map[string]string{
+		"example.com/a":   "a",
+		"example.com/top": ".",
+	}
+	for _, test := range []struct {
+		ci   string
+		want string
+	}{
+		{
+			ci: `capability: CAPABILITY_NETWORK
+				path { name: "example.com/a.Foo" package: "example.com/a" }
+				path { name: "example.com/a.bar" package: "example.com/a" site { filename: "a.go" line: 12 column: 5 } }
+				path { name: "net.Dial" package: "net" site { filename: "other.go" line: 3 column: 2 } }`,
+			want: "::warning file=a/a.go,line=12,col=5::New NETWORK capability in example.com/a.Foo (via net.Dial)",
+		},
+		{
+			ci: `capability: CAPABILITY_FILES
+				path { name: "example.com/top.Foo" package: "example.com/top" }
+				path { name: "os.Open" package: "os" site { filename: "top.go" line: 7 column: 9 } }`,
+			want: "::warning file=top.go,line=7,col=9::New FILES capability in example.com/top.Foo (via os.Open)",
+		},
+		{
+			// The package's directory is unknown.
+			ci: `capability: CAPABILITY_FILES
+				path { name: "example.com/b.Foo" package: "example.com/b" }
+				path { name: "os.Open" package: "os" site { filename: "b.go" line: 7 column: 9 } }`,
+			want: "::warning::New FILES capability in example.com/b.Foo (via os.Open)",
+		},
+		{
+			// Custom capabilities are named.
+			ci: `capability: CAPABILITY_CUSTOM
+				custom_capability: "DATABASE"
+				path { name: "example.com/a.Foo" package: "example.com/a" }
+				path { name: "example.com/db.Query" package: "example.com/db" site { filename: "a.go" line: 4 column: 1 } }`,
+			want: "::warning file=a/a.go,line=4,col=1::New DATABASE capability in example.com/a.Foo (via example.com/db.Query)",
+		},
+		{
+			// The capability is in the root function itself, so there is no
+			// call site.
+			ci: `capability: CAPABILITY_UNSAFE_POINTER
+				path { name: "example.com/a.Foo" package: "example.com/a" }`,
+			want: "::warning::New UNSAFE_POINTER capability in example.com/a.Foo",
+		},
+	} {
+		ci := new(cpb.CapabilityInfo)
+		if err := prototext.Unmarshal([]byte(test.ci), ci); err != nil {
+			t.Fatalf("parsing %s: %v", test.ci, err)
+		}
+		if got := gitHubAnnotation(ci, dirs); got != test.want {
+			t.Errorf("gitHubAnnotation(%s):\ngot  %q\nwant %q", test.ci, got, test.want)
+		}
+	}
+}
+
+func TestEscapeProperty(t *testing.T) {
+	if got, want := escapeProperty("a,b:c%d\n"), "a%2Cb%3Ac%25d%0A"; got != want {
+		t.Errorf("escapeProperty: got %q, want %q", got, want)
+	}
+	if got, want := escapeData("a,b:c%d\n"), "a,b:c%25d%0A"; got != want {
+		t.Errorf("escapeData: got %q, want %q", got, want)
+	}
+}
+
+func TestPackageDirectories(t *testing.T) {
+	// The module is in a subdirectory of the repository, and the current
+	// directory is a package below it, so directories relative to the root of
+	// the repository differ from those relative to the current directory.
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"mod/go.mod": "module example.com/mod\n\ngo 1.21\n",
+		"mod/a/a.go": "package a\n",
+		"mod/b/b.go": "package b\n",
+	} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Skipf("git init: %v: %s", err, out)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Join(dir, "mod", "a")); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("GOFLAGS", "")
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOPROXY", "off")
+
+	var cis []*cpb.CapabilityInfo
+	for _, pkg := range []string{"example.com/mod/a", "example.com/mod/b"} {
+		ci := new(cpb.CapabilityInfo)
+		if err := prototext.Unmarshal([]byte(`path { name: "`+pkg+`.Foo" package: "`+pkg+`" }`), ci); err != nil {
+			t.Fatal(err)
+		}
+		cis = append(cis, ci)
+	}
+	got, err := packageDirectories(cis)
+	if err != nil {
+		t.Fatalf("packageDirectories: %v", err)
+	}
+	want := map[string]string{
+		"example.com/mod/a": "mod/a",
+		"example.com/mod/b": "mod/b",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("packageDirectories: got %v, want %v", got, want)
+	}
+}
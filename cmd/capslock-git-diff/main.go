@@ -23,6 +23,26 @@
 // If only two arguments are supplied, all packages under the current directory
 // are used.
 //
+// With -since, the first revision is the merge base of HEAD and the given
+// revision, and the second is the current state of the repository, so that
+// only the changes made on the current branch are considered:
+//
+//	capslock-git-diff -since=origin/main somepath/...
+//
+// With -github-annotations, a GitHub Actions workflow command is also printed
+// for each call path with a new capability, such as
+//
+//	::warning file=somepath/foo.go,line=12::New NETWORK capability in example.com/somepath.Foo (via net.Dial)
+//
+// which a workflow run on a pull request shows as an annotation on the line
+// of the root function of the path which leads to the capability.  The file
+// is located at the second revision, relative to the root of the repository.
+//
+// With -incremental, only the packages containing files that differ between
+// the two revisions, and the packages matching the pattern that depend on
+// them, are analyzed.  If go.mod, go.sum or go.work changed, all packages are
+// analyzed.
+//
 // If the environment variable CAPSLOCKTOOLSTMPDIR is set and non-empty, it
 // specifies the directory where temporary files are created.  Otherwise the
 // system temporary directory is used.
@@ -30,10 +50,12 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -50,6 +72,9 @@ var (
 	verbose          = flag.Bool("v", false, "enable verbose logging")
 	granularity      = flag.String("granularity", "intermediate", "the granularity to use for comparisons")
 	flagCapabilities = flag.String("capabilities", "-UNANALYZED", "if non-empty, a comma-separated list of capabilities to pass to capslock")
+	incremental      = flag.Bool("incremental", false, "only analyze packages with files that changed between the revisions, and the packages that depend on them")
+	since            = flag.String("since", "", "if non-empty, compare the merge base of HEAD and this revision with the current state of the repository, instead of two revisions given as arguments")
+	githubAnnotate   = flag.Bool("github-annotations", false, "also print a GitHub Actions workflow command for each new capability, which GitHub shows as an annotation on the line of code which leads to it")
 )
 
 func vlog(format string, a ...any) {
@@ -82,39 +107,143 @@ func run(w io.Writer, command string, args ...string) error {
 	return nil
 }
 
-func AnalyzeAtRevision(rev, pkgname string) (cil *cpb.CapabilityInfoList, err error) {
+// changedDirectories returns the directories, relative to the current
+// directory, containing files which differ between the two revisions.  If
+// a file which can affect every package changed, such as go.mod, it returns
+// nil.
+func changedDirectories(revisions [2]string) (map[string]bool, error) {
+	var revs []string
+	for _, rev := range revisions {
+		if rev != "." {
+			// "." refers to the working tree, which git diff uses when given
+			// fewer than two revisions.
+			revs = append(revs, rev)
+		}
+	}
+	if len(revs) == 0 {
+		// Both revisions are the working tree.
+		return map[string]bool{}, nil
+	}
+	// With -z, the names are terminated by NUL bytes and are not quoted, so
+	// names containing spaces or other special characters are read intact.
+	args := append([]string{"diff", "--name-only", "--relative", "-z"}, revs...)
+	var b bytes.Buffer
+	if err := run(&b, "git", append(args, "--")...); err != nil {
+		return nil, err
+	}
+	dirs := make(map[string]bool)
+	for _, name := range strings.Split(b.String(), "\x00") {
+		if name == "" {
+			continue
+		}
+		switch filepath.Base(name) {
+		case "go.mod", "go.sum", "go.work", "go.work.sum":
+			vlog("%s changed; analyzing all packages", name)
+			return nil, nil
+		}
+		dirs[filepath.Dir(filepath.FromSlash(name))] = true
+	}
+	vlog("directories with changes: %v", slices.Sorted(maps.Keys(dirs)))
+	return dirs, nil
+}
+
+// mergeBase returns the best common ancestor of HEAD and rev.
+func mergeBase(rev string) (string, error) {
+	var b bytes.Buffer
+	if err := run(&b, "git", "merge-base", "HEAD", rev); err != nil {
+		return "", err
+	}
+	base := strings.TrimSpace(b.String())
+	vlog("merge base of HEAD and %q: %s", rev, base)
+	return base, nil
+}
+
+// affectedPackages returns the packages matching pkgname which are in one of
+// the directories in dirs, or which depend on a package in one of them.
+func affectedPackages(pkgname string, dirs map[string]bool) ([]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	// The fields are separated by tabs, since the directory can contain spaces.
+	if err := run(&b, "go", "list", "-e", "-f", `{{.ImportPath}}{{"\t"}}{{.Dir}}{{range .Deps}}{{"\t"}}{{.}}{{end}}`, pkgname); err != nil {
+		return nil, err
+	}
+	type pkg struct {
+		path string
+		deps []string
+	}
+	var pkgs []pkg
+	changed := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		pkgs = append(pkgs, pkg{fields[0], fields[2:]})
+		if dir, err := filepath.Rel(wd, fields[1]); err == nil && dirs[dir] {
+			changed[fields[0]] = true
+		}
+	}
+	var affected []string
+	for _, p := range pkgs {
+		if changed[p.path] || slices.ContainsFunc(p.deps, func(d string) bool { return changed[d] }) {
+			affected = append(affected, p.path)
+		}
+	}
+	vlog("affected packages: %q", affected)
+	return affected, nil
+}
+
+// AnalyzeAtRevision analyzes the packages matching pkgname at revision rev.
+// If dirs is non-nil, only the packages affected by changes in those
+// directories are analyzed.
+func AnalyzeAtRevision(rev, pkgname string, dirs map[string]bool) (cil *cpb.CapabilityInfoList, err error) {
+	err = atRevision(rev, func() error {
+		cil, err = callCapslockForChanges(rev, pkgname, dirs)
+		return err
+	})
+	return cil, err
+}
+
+// atRevision calls fn in the directory which corresponds to the current
+// directory in a temporary clone of the repository checked out at revision
+// rev, or in the current directory if rev is ".", which refers to the
+// working tree.
+func atRevision(rev string, fn func() error) (err error) {
 	vlog("analyzing at revision %q", rev)
 	if rev == "." {
-		return callCapslock(rev, pkgname)
+		return fn()
 	}
 	// Make a temporary directory.
 	tmpdir, err := os.MkdirTemp(os.Getenv("CAPSLOCKTOOLSTMPDIR"), "")
 	if err != nil {
-		return nil, fmt.Errorf("creating temporary directory: %w", err)
+		return fmt.Errorf("creating temporary directory: %w", err)
 	}
 	// Get the location of the .git directory, so we can make a temporary clone.
 	var b bytes.Buffer
 	if err = run(&b, "git", "rev-parse", "--git-dir"); err != nil {
-		return nil, err
+		return err
 	}
 	gitdir := strings.TrimSuffix(b.String(), "\n")
 	vlog("git directory: %q", gitdir)
 	b.Reset()
 	// Get the relative directory within the git repository.
 	if err = run(&b, "git", "rev-parse", "--show-prefix"); err != nil {
-		return nil, err
+		return err
 	}
 	prefix := strings.TrimSuffix(b.String(), "\n")
 	vlog("current path in repository: %q", prefix)
 	b.Reset()
 	// Clone the repo.
 	if err = run(nil, "git", "clone", "--shared", "--no-checkout", "--", gitdir, tmpdir); err != nil {
-		return nil, err
+		return err
 	}
 	// Temporarily switch directory.
 	wd, err := os.Getwd()
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer func() {
 		// Switch back to the original directory.
@@ -125,20 +254,37 @@ func AnalyzeAtRevision(rev, pkgname string) (cil *cpb.CapabilityInfoList, err er
 		vlog("returned to working directory %q", wd)
 	}()
 	if err = os.Chdir(tmpdir); err != nil {
-		return nil, fmt.Errorf("switching to temporary directory: %w", err)
+		return fmt.Errorf("switching to temporary directory: %w", err)
 	}
 	vlog("switched to directory %q", tmpdir)
 	// Checkout the revision.
 	if err = run(nil, "git", "checkout", rev, "--"); err != nil {
-		return nil, err
+		return err
 	}
 	// Go to the same directory in the clone.
 	path := filepath.Join(tmpdir, prefix)
 	if err = os.Chdir(path); err != nil {
-		return nil, fmt.Errorf("switching to temporary directory: %w", err)
+		return fmt.Errorf("switching to temporary directory: %w", err)
 	}
 	vlog("switched to directory %q", path)
-	return callCapslock(rev, pkgname)
+	return fn()
+}
+
+// callCapslockForChanges calls capslock on the packages matching pkgname, or
+// if dirs is non-nil, on the subset of them affected by changes in dirs.
+func callCapslockForChanges(rev, pkgname string, dirs map[string]bool) (*cpb.CapabilityInfoList, error) {
+	if dirs == nil {
+		return callCapslock(rev, pkgname)
+	}
+	pkgs, err := affectedPackages(pkgname, dirs)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		vlog("no packages affected at revision %q", rev)
+		return new(cpb.CapabilityInfoList), nil
+	}
+	return callCapslock(rev, strings.Join(pkgs, ","))
 }
 
 func callCapslock(rev, pkgname string) (cil *cpb.CapabilityInfoList, err error) {
@@ -177,6 +323,7 @@ func usage() {
 two revisions of a git repository.
 
 Usage: capslock-git-diff <revision1> <revision2> [<package>]
+       capslock-git-diff -since=<revision> [<package>]
 `)
 	flag.PrintDefaults()
 	os.Exit(2)
@@ -186,6 +333,16 @@ func main() {
 	flag.Usage = usage
 	flag.Parse()
 	a := flag.Args()
+	if *since != "" {
+		// The revisions are determined from -since, so only the package can be
+		// specified as an argument.
+		base, err := mergeBase(*since)
+		if err != nil {
+			log.Print(err)
+			os.Exit(2)
+		}
+		a = append([]string{base, "."}, a...)
+	}
 	var pkgname string
 	if len(a) == 2 {
 		// By default, use the current directory and its subdirectories.
@@ -193,29 +350,85 @@ func main() {
 	} else if len(a) == 3 {
 		pkgname = a[2]
 	} else {
-		fmt.Fprintf(os.Stderr, "wrong number of arguments: %q\n\n", a)
+		fmt.Fprintf(os.Stderr, "wrong number of arguments: %q\n\n", flag.Args())
 		usage()
 	}
 	revisions := [2]string{a[0], a[1]}
-	cil1, err := AnalyzeAtRevision(revisions[0], pkgname)
+	var dirs map[string]bool
+	if *incremental {
+		var err error
+		if dirs, err = changedDirectories(revisions); err != nil {
+			log.Print(err)
+			os.Exit(2)
+		}
+	}
+	cil1, err := AnalyzeAtRevision(revisions[0], pkgname, dirs)
 	if err != nil {
 		log.Print(err)
 		os.Exit(2)
 	}
-	cil2, err := AnalyzeAtRevision(revisions[1], pkgname)
+	// The files in annotations are located at the second revision, so the
+	// directories of its packages are found while it is checked out.
+	var (
+		cil2    *cpb.CapabilityInfoList
+		pkgDirs map[string]string
+	)
+	err = atRevision(revisions[1], func() (err error) {
+		if cil2, err = callCapslockForChanges(revisions[1], pkgname, dirs); err != nil {
+			return err
+		}
+		if *githubAnnotate {
+			pkgDirs, err = packageDirectories(cil2.GetCapabilityInfo())
+		}
+		return err
+	})
 	if err != nil {
 		log.Print(err)
 		os.Exit(2)
 	}
-	different := diffCapabilityInfoLists(cil1, cil2, revisions, pkgname)
-	if different {
+	added := diffCapabilityInfoLists(cil1, cil2, revisions, pkgname)
+	if *githubAnnotate {
+		if err := printGitHubAnnotations(os.Stdout, added, pkgDirs); err != nil {
+			log.Print(err)
+			os.Exit(2)
+		}
+	}
+	if len(added) > 0 {
 		os.Exit(1)
 	}
 }
 
+// capability identifies the capability of a CapabilityInfo.  Custom
+// capabilities are all stored as CAPABILITY_CUSTOM, so they are identified by
+// their names, which are the same in each run of capslock.
+type capability struct {
+	capability cpb.Capability
+	custom     string
+}
+
+func capabilityOf(ci *cpb.CapabilityInfo) capability {
+	return capability{ci.GetCapability(), ci.GetCustomCapability()}
+}
+
+// compare orders capabilities by their values in the Capability enum, and
+// custom capabilities, which share a value, by name.
+func (c capability) compare(d capability) int {
+	if c.capability != d.capability {
+		return cmp.Compare(c.capability, d.capability)
+	}
+	return strings.Compare(c.custom, d.custom)
+}
+
+func (c capability) String() string {
+	if c.custom != "" {
+		return c.custom
+	}
+	return c.capability.String()
+}
+
 type mapKey struct {
 	key        string
-	capability cpb.Capability
+	capability capability
 }
 type capabilitiesMap map[mapKey]*cpb.CapabilityInfo
 
@@ -237,7 +450,7 @@ func populateMap(cil *cpb.CapabilityInfoList, granularity string) capabilitiesMa
 		if key == "" {
 			continue
 		}
-		m[mapKey{capability: ci.GetCapability(), key: key}] = ci
+		m[mapKey{capability: capabilityOf(ci), key: key}] = ci
 	}
 	return m
 }
@@ -263,8 +476,8 @@ func cover(pending map[string]bool, ci *cpb.CapabilityInfo) (covered []string) {
 	return covered
 }
 
-func sortAndPrintCapabilities(cs []cpb.Capability) {
-	slices.Sort(cs)
+func sortAndPrintCapabilities(cs []capability) {
+	slices.SortFunc(cs, capability.compare)
 	tw := tabwriter.NewWriter(
 		os.Stdout, // output
 		10,        // minwidth
@@ -286,16 +499,33 @@ func sortAndPrintCapabilities(cs []cpb.Capability) {
 		12: "Uses unsafe.Pointer",
 		13: "Uses reflect",
 		14: "Execute other programs, usually via os/exec",
+		15: "Install or modify signal handlers, via os/signal",
+		16: "Make outbound network connections",
+		17: "Listen for inbound network connections",
+		18: "Change garbage collector or scheduler settings",
+		19: "Converts a pointer to a uintptr and back to a pointer",
+		20: "Uses reflect and unsafe to access struct fields, including unexported ones",
+		21: "Calls panic",
+		22: "Calls functions flagged for manual review by the capability map",
+		23: "Maps files or shared memory into memory, e.g. via syscall.Mmap",
+		24: "Executes a command shell, e.g. via exec.Command(\"sh\", \"-c\", ...)",
+		25: "Registers something in a global registry, e.g. via sql.Register or http.Handle",
+		26: "Replaces the current process with another program, e.g. via syscall.Exec",
+		27: "Has a custom capability defined in a capability map with define_capability",
+		28: "Is part of a recursive cycle of calls, which can exhaust the stack",
+		29: "Uses the display, clipboard, keyboard or mouse, e.g. via a GUI toolkit",
+		30: "Disables verification of TLS certificates with InsecureSkipVerify",
+		31: "Opens device files or special files, e.g. under /dev, /proc or /sys",
 	}
 	for _, c := range cs {
-		fmt.Fprint(tw, "\t", cpb.Capability_name[int32(c)], ":\t", capabilityDescription[c], "\n")
+		fmt.Fprint(tw, "\t", c, ":\t", capabilityDescription[c.capability], "\n")
 	}
 	tw.Flush()
 }
 
-func summarizeNewCapabilities(keys []mapKey, baselineMap, currentMap capabilitiesMap) (newlyUsedCapabilities, existingCapabilitiesWithNewUses []cpb.Capability) {
-	hasAnyOldUse := make(map[cpb.Capability]bool)
-	newUses := make(map[cpb.Capability]int)
+func summarizeNewCapabilities(keys []mapKey, baselineMap, currentMap capabilitiesMap) (newlyUsedCapabilities, existingCapabilitiesWithNewUses []capability) {
+	hasAnyOldUse := make(map[capability]bool)
+	newUses := make(map[capability]int)
 	for _, key := range keys {
 		_, inBaseline := baselineMap[key]
 		_, inCurrent := currentMap[key]
@@ -344,7 +574,11 @@ func summarizeNewCapabilities(keys []mapKey, baselineMap, currentMap capabilitie
 	return newlyUsedCapabilities, existingCapabilitiesWithNewUses
 }
 
-func diffCapabilityInfoLists(baseline, current *cpb.CapabilityInfoList, revisions [2]string, pkgname string) (different bool) {
+// diffCapabilityInfoLists prints the capabilities of current which are not in
+// baseline, with an example call path for each, and returns the
+// CapabilityInfo of each of those paths.  The result is empty if no
+// capabilities were added.
+func diffCapabilityInfoLists(baseline, current *cpb.CapabilityInfoList, revisions [2]string, pkgname string) (added []*cpb.CapabilityInfo) {
 	fmt.Printf("Comparing capabilities in %q between revisions %q and %q\n\n",
 		pkgname, revisions[0], revisions[1])
 	if revisions[0] != "." && revisions[1] != "." {
@@ -369,15 +603,15 @@ func diffCapabilityInfoLists(baseline, current *cpb.CapabilityInfoList, revision
 		}
 	}
 	sort.Slice(keys, func(i, j int) bool {
-		if a, b := keys[i].capability, keys[j].capability; a != b {
-			return a < b
+		if c := keys[i].capability.compare(keys[j].capability); c != 0 {
+			return c < 0
 		}
 		return keys[i].key < keys[j].key
 	})
 	newlyUsedCapabilities, existingCapabilitiesWithNewUses :=
 		summarizeNewCapabilities(keys, baselineMap, currentMap)
 	// Output changes for each capability, in the order they were printed above.
-	for _, list := range [][]cpb.Capability{newlyUsedCapabilities, existingCapabilitiesWithNewUses} {
+	for _, list := range [][]capability{newlyUsedCapabilities, existingCapabilitiesWithNewUses} {
 		for _, c := range list {
 			switch *granularity {
 			case "package":
@@ -397,7 +631,6 @@ func diffCapabilityInfoLists(baseline, current *cpb.CapabilityInfoList, revision
 				_, inCurrent := currentMap[key]
 				if !inBaseline && inCurrent {
 					pending[key.key] = true
-					different = true
 				}
 			}
 			for _, key := range keys {
@@ -416,10 +649,11 @@ func diffCapabilityInfoLists(baseline, current *cpb.CapabilityInfoList, revision
 					fmt.Printf("\n%s %s has capability %s:\n", granularityDescription, key.key, key.capability)
 				}
 				printCallPath(ci.Path)
+				added = append(added, ci)
 			}
 		}
 	}
-	return different
+	return added
 }
 
 func printCallPath(fns []*cpb.Function) {
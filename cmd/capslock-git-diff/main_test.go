@@ -0,0 +1,116 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package main
+
+import (
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	cpb "github.com/google/capslock/proto"
+	"google.golang.org/protobuf/encoding/prototext"
+)
+
+func TestPopulateMapCustomCapabilities(t *testing.T) {
+	var cil cpb.CapabilityInfoList
+	if err := prototext.Unmarshal([]byte(`
+		capability_info: {
+			capability: CAPABILITY_CUSTOM
+			custom_capability: "DATABASE"
+			path: { name: "example.com/a.Foo" }
+		}
+		capability_info: {
+			capability: CAPABILITY_CUSTOM
+			custom_capability: "QUEUE"
+			path: { name: "example.com/a.Foo" }
+		}
+		capability_info: {
+			capability: CAPABILITY_FILES
+			path: { name: "example.com/a.Foo" }
+		}`), &cil); err != nil {
+		t.Fatalf("parsing CapabilityInfoList: %v", err)
+	}
+	m := populateMap(&cil, "function")
+	// The custom capabilities are distinct from each other.
+	if len(m) != 3 {
+		t.Errorf("populateMap: got %d entries, want 3: %v", len(m), m)
+	}
+	for _, name := range []string{"DATABASE", "QUEUE"} {
+		key := mapKey{key: "example.com/a.Foo", capability: capability{cpb.Capability_CAPABILITY_CUSTOM, name}}
+		if ci, ok := m[key]; !ok || ci.GetCustomCapability() != name {
+			t.Errorf("populateMap: got %v for %v, want the CapabilityInfo for %s", ci, key, name)
+		}
+		if got := key.capability.String(); got != name {
+			t.Errorf("capability.String(): got %q, want %q", got, name)
+		}
+	}
+}
+
+func TestChangedDirectories(t *testing.T) {
+	// The path of the repository and the name of the changed file contain
+	// spaces, which must not split them.
+	dir := filepath.Join(t.TempDir(), "my repo")
+	for name, content := range map[string]string{
+		"go.mod":            "module example.com/mod\n\ngo 1.21\n",
+		"a/a.go":            "package a\n",
+		"a/notes file.txt":  "notes\n",
+		"b/b.go":            "package b\n\nimport _ \"example.com/mod/a\"\n",
+		"c/c.go":            "package c\n",
+		"c/other notes.txt": "notes\n",
+	} {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("git %q: %v: %s", args, err, out)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a", "notes file.txt"), []byte("changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("GOFLAGS", "")
+	t.Setenv("GOWORK", "off")
+	t.Setenv("GOPROXY", "off")
+
+	dirs, err := changedDirectories([2]string{"HEAD", "."})
+	if err != nil {
+		t.Fatalf("changedDirectories: %v", err)
+	}
+	if want := map[string]bool{"a": true}; !maps.Equal(dirs, want) {
+		t.Errorf("changedDirectories: got %v, want %v", dirs, want)
+	}
+	affected, err := affectedPackages("./...", dirs)
+	if err != nil {
+		t.Fatalf("affectedPackages: %v", err)
+	}
+	if want := []string{"example.com/mod/a", "example.com/mod/b"}; !slices.Equal(affected, want) {
+		t.Errorf("affectedPackages: got %q, want %q", affected, want)
+	}
+}
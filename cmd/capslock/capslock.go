@@ -8,60 +8,144 @@
 // and for each function in those packages that has interesting capabilities,
 // outputs a string describing this to stdout.
 //
-// The exit status code is 2 for an error, 1 if a difference is found when a
-// comparison is requested, and 0 otherwise.
+// The exit status code is 0 if the analysis found nothing that should fail,
+// 1 if a difference is found when a comparison is requested, 2 for an error,
+// 3 if -skip-errors skipped packages with errors, 4 if -fail-on-unanalyzed
+// was set and CAPABILITY_UNANALYZED was found, and 5 if -forbid or
+// -forbid-module found a forbidden function or module.  If more than one
+// applies, an error takes precedence, followed by a difference, a forbidden
+// function or module, CAPABILITY_UNANALYZED, and skipped packages.  See
+// analyzer.ExitCode.
 package main
 
 import (
 	"bytes"
+	"debug/buildinfo"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
 	"runtime"
+	"runtime/debug"
 	"runtime/pprof"
+	"slices"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/fatih/color"
 	"github.com/google/capslock/analyzer"
 	"github.com/google/capslock/interesting"
+	cpb "github.com/google/capslock/proto"
 	"golang.org/x/tools/go/packages"
+	"google.golang.org/protobuf/proto"
 )
 
 var (
 	packageList    = flag.String("packages", "", "target patterns to be analysed; allows wildcarding")
-	output         = flag.String("output", "", "output mode to use; non-default options are json, m, v, graph, and compare")
+	output         = flag.String("output", "", "output mode to use; non-default options are json, m, machine-functions, v, graph, mermaid, callgraph, callgraph-json, genmap, matrix, osv-annotations, by-type, binaries, summary, badge, and compare; only json output includes metadata recording how the analysis was run")
+	listPkgs       = flag.Bool("list-packages", false, "load the packages to analyze, print each one with its module and whether it was loaded from the local workspace or a temporary module, and exit without analyzing them")
+	printMap       = flag.Bool("print-capability-map", false, "print the capability map in effect, after merging any custom capability map with the builtin one, and exit")
+	outputFile     = flag.String("o", "", "write the results to the specified file instead of standard output")
+	outputDir      = flag.String("output-dir", "", "with -output=json, write the results for each package to a separate file in the specified directory, named after the package's import path, instead of to standard output")
 	verbose        = flag.Int("v", 0, "verbosity level")
 	noiseFlag      = flag.Bool("noisy", false, "include output on unanalyzed function calls (can be noisy)")
-	customMap      = flag.String("capability_map", "", "use a custom capability map file")
+	customMaps     = stringListFlag("capability_map", "use a custom capability map file; can be repeated, in which case later files take precedence over earlier ones")
 	disableBuiltin = flag.Bool("disable_builtin", false, "when using a custom capability map, disable the builtin capability mappings")
-	capabilities   = flag.String("capabilities", "", "if non-empty, a comma-separated list of capabilities to consider for graph output.  Optionally, all capabilities can be prefixed with '-' to specify capabilities to ignore.")
+	capabilities   = flag.String("capabilities", "", "if non-empty, a comma-separated list of capabilities to consider for graph and compare output.  Optionally, all capabilities can be prefixed with '-' to specify capabilities to ignore.")
 	buildTags      = flag.String("buildtags", "", "command-separated list of build tags to use when loading packages")
+	excludeTags    = flag.String("exclude-build-tag", "", `comma-separated list of build tags, such as "example"; code in files of the queried packages whose build constraints require one of them is not reported`)
+	includeVendGen = flag.Bool("include-vendored-and-generated", false, `report packages in a vendor directory, and packages all of whose files are generated, as queried packages; by default they are only analyzed as dependencies of the other queried packages`)
 	goos           = flag.String("goos", "", "GOOS value to use when loading packages")
 	goarch         = flag.String("goarch", "", "GOARCH value to use when loading packages")
+	cgo            = flag.String("cgo", "", `CGO_ENABLED value to use when loading packages, "0" or "1"; "0" analyzes the build of the packages without cgo`)
+	workspace      = flag.String("workspace", "", `whether to use the go.work workspace containing the current directory when loading packages, "on" or "off"; by default the GOWORK environment variable decides`)
 	cpuprofile     = flag.String("cpuprofile", "", "write cpu profile to specified file")
 	memprofile     = flag.String("memprofile", "", "write memory profile to specified file")
 	granularity    = flag.String("granularity", "",
 		`the granularity to use for comparisons, either "package" or "function".`)
 	forceLocalModule = flag.Bool("force_local_module", false, "if the requested packages cannot be loaded in the current workspace, return an error immediately, instead of trying to load them in a temporary module")
 	omitPaths        = flag.Bool("omit_paths", false, "omit example call paths from output")
+	graphFocus       = flag.String("graph-focus", "", "if non-empty, a package path or pattern, such as example.com/x/... or example.com/*/internal; graph output is limited to call paths through a function in a matching package")
+	perCallSite      = flag.Bool("per-call-site", false, "report a separate example path for each call site through which a function reaches a capability")
+	ignorePaths      = stringListFlag("ignore-path", `a regular expression matched against the call path of each result, written as the names of its functions separated by spaces, such as "example.com/p.Foo net.Dial"; matching results are dropped from the output.  Can be repeated`)
+	roots            = flag.String("roots", "", `a comma-separated list of functions of the queried packages, such as "example.com/cmd/tool.main", named as in capability maps; only the capabilities reachable from them are reported`)
+	exportedOnly     = flag.Bool("exported-only", false, "only report exported functions and methods of the queried packages, which are the entrypoints available to their users")
+	skipErrors       = flag.Bool("skip-errors", false, "if some packages have errors, skip them and the packages which depend on them, and analyze the rest, instead of aborting the analysis")
+	quiet            = flag.Bool("quiet", false, "don't print the differences found by -output=compare; only the exit status reports whether there were any")
+	ignoreRemovals   = flag.Bool("ignore-removals", false, "with -output=compare, still print capabilities which are no longer found, but only exit with a non-zero status for new capabilities")
+	binary           = flag.String("binary", "", "analyze the dependencies of the specified Go executable, at the module versions recorded in its build information, instead of -packages")
+	module           = flag.String("module", "", `analyze all the packages of the specified module version, such as "example.com/mod@v1.2.3", in a temporary module, instead of -packages`)
+	colorMode        = flag.String("color", "auto", `whether to color the output with ANSI escape sequences: "always", "never", or "auto" to color it only when writing to a terminal`)
+	detectPanics     = flag.Bool("detect-panics", false, "report CAPABILITY_PANIC for functions which can call panic; this is off by default since most code can panic")
+	detectRecursion  = flag.Bool("detect-recursion", false, "report CAPABILITY_RECURSION for functions in recursive cycles of calls which include code outside the standard library and -trusted-packages, with the cycle in the json output; with -v=2, each cycle is logged")
+	collapseSubcaps  = flag.Bool("collapse-subcapabilities", false, "report each subcapability, such as CAPABILITY_NETWORK_CLIENT, as its parent capability")
+	severityFlag     = flag.String("severity", "", `a comma-separated list of capabilities with the severity to give them, such as "REFLECT=high,RUNTIME=medium", overriding the defaults and any severity lines in the capability maps; severities decide -min-severity, -sort=severity, the colors of capabilities and the dangerous count in summaries`)
+	minSeverity      = flag.String("min-severity", "", `if set to "low", "medium" or "high", omit capabilities with a lower severity from the output`)
+	sortOrder        = flag.String("sort", "", `the order in which to list capabilities, either "capability", "severity" (most severe first), or "package" (grouped by package, in the json and machine-functions output)`)
+	failOnUnanalyzed = flag.Bool("fail-on-unanalyzed", false, "exit with status 4 if the output reports any uses of CAPABILITY_UNANALYZED, with the same roots and filters such as -min-severity")
+	ignoreStrMethods = flag.Bool("ignore-string-methods", false, "do not follow the calls to String and Error methods which packages fmt and errors make when formatting values, which often connect code that formats values to unrelated types; without it, paths through these calls are reported with lowConfidence set in the JSON output")
+	trustedPkgs      = flag.String("trusted-packages", "", `a comma-separated list of packages, or patterns such as "example.com/internal/..." or "example.com/*/internal", to treat like the standard library when deciding whether a capability is direct, which calls -direct-only follows, and which module a capability is attributed to`)
+	directOnly       = flag.Bool("direct-only", false, "only report capabilities that functions have directly, without following calls into other packages outside the standard library")
+	reachableOnly    = flag.Bool("callgraph-reachable", false, "with -output=callgraph or callgraph-json, only include functions reachable from the queried packages")
+	jsonCompact      = flag.Bool("json-compact", false, "with -output=json or callgraph-json, write the output on a single line instead of indenting it")
+	relativePaths    = flag.Bool("relative-paths", false, "in json output, write the import paths of packages in the main module relative to the module path")
+	shallow          = flag.Bool("shallow", false, "only analyze the code of the queried packages, which is much faster; calls into other packages, including the standard library, are classified by the capability map but not followed")
+	byType           = flag.Bool("by-type", false, "for each exported type in the queried packages, list the capabilities that can be reached by calling its exported methods; the same as -output=by-type")
+	binaries         = flag.Bool("binaries", false, "for each main package in the queried packages, list the capabilities that can be reached from its main function and package initialization, which are what the command can do when run; the same as -output=binaries")
+	forbid           = flag.String("forbid", "", `a comma-separated list of functions, such as "reflect.MakeFunc"; if the queried packages can reach any of them, print a call path to each and exit with status 5`)
+	forbidModules    = stringListFlag("forbid-module", `a module path, such as "example.com/mod", or path and version, such as "example.com/mod@v1.2.3"; if the module contributes any capability to the queried packages, print a call path to each and exit with status 5.  Can be repeated`)
+	reportUnused     = flag.Bool("report-unused-map-entries", false, "after the analysis, print the func, package and ignore_edge entries of the custom capability maps, or of the builtin map if there are none, which did not match any function or call")
+	markGenerated    = flag.Bool("mark-generated", false, `in json output, mark capabilities of functions in generated files, which have a "// Code generated ... DO NOT EDIT." comment or match -generated-files, with "generated": true`)
+	generatedFiles   = flag.String("generated-files", "", `a comma-separated list of patterns, in the syntax of filepath.Match, such as "*.pb.go", matched against the base names of files to treat as generated; implies -mark-generated`)
+	showRules        = flag.Bool("show-rules", false, `in json output, set "rule" for each capability to the capability map line, such as "func os.Getenv CAPABILITY_READ_SYSTEM_STATE", or the builtin analysis which gave the function at the end of the path its capability`)
+	markHeuristic    = flag.Bool("separate-heuristic-findings", false, `in json output, mark capabilities found by analyzing function bodies, such as unsafe pointer conversions and reflect.Value copies, rather than by the capability map, with "heuristic": true`)
+	configFile       = flag.String("config", "", `read default values for other flags from the specified YAML or TOML file, instead of from capslock.yaml, capslock.yml or capslock.toml in the current directory; flags set on the command line take precedence`)
+	examples         = flag.Int("examples", 1, "the maximum number of distinct example call paths to report for each function and capability, or each package and capability with -granularity=package")
 )
 
+// stringList is a flag.Value which collects the values of a repeated flag.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// stringListFlag defines a flag which can be repeated, and returns a pointer
+// to the list of its values.
+func stringListFlag(name, usage string) *[]string {
+	s := new(stringList)
+	flag.Var(s, name, usage)
+	return (*[]string)(s)
+}
+
 func main() {
 	flag.Parse()
 	// The main logic is in 'run' so that deferred functions run before we reach os.Exit.
 	err := run()
-	switch err.(type) {
-	case nil:
-	case analyzer.DifferenceFoundError:
-		os.Exit(1)
+	switch code := analyzer.ExitCodeForError(err); code {
+	case analyzer.ExitOK:
+	case analyzer.ExitDifferenceFound, analyzer.ExitPackageErrors, analyzer.ExitForbiddenFound:
+		// The output, the log messages about skipped packages, and the paths
+		// to forbidden functions already describe the problem.
+		os.Exit(int(code))
 	default:
 		log.Print(err)
-		os.Exit(2)
+		os.Exit(int(code))
 	}
 }
 
-func run() error {
+func run() (err error) {
+	if err := applyConfigFile(); err != nil {
+		return err
+	}
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
 		if err != nil {
@@ -78,38 +162,198 @@ func run() error {
 	if err != nil {
 		return fmt.Errorf("parsing flag -granularity: %w", err)
 	}
-	cs, err := analyzer.NewCapabilitySet(*capabilities)
+	switch *colorMode {
+	case "auto":
+		// The color package has already checked whether standard output is a
+		// terminal.
+		if *outputFile != "" {
+			color.NoColor = true
+		}
+	case "always":
+		color.NoColor = false
+	case "never":
+		color.NoColor = true
+	default:
+		return fmt.Errorf(`parsing flag -color: got %q, want "auto", "always" or "never"`, *colorMode)
+	}
+	var minSev interesting.Severity
+	if *minSeverity != "" {
+		var ok bool
+		if minSev, ok = interesting.ParseSeverity(*minSeverity); !ok {
+			return fmt.Errorf(`parsing flag -min-severity: got %q, want "low", "medium" or "high"`, *minSeverity)
+		}
+	}
+	outputMode := *output
+	if *byType {
+		if outputMode != "" && outputMode != "by-type" {
+			return fmt.Errorf("-by-type cannot be used together with -output=%s", outputMode)
+		}
+		outputMode = "by-type"
+	}
+	if *binaries {
+		if outputMode != "" && outputMode != "binaries" {
+			return fmt.Errorf("-binaries cannot be used together with -output=%s", outputMode)
+		}
+		outputMode = "binaries"
+	}
+	if *outputDir != "" && *outputFile != "" {
+		return fmt.Errorf("-o and -output-dir cannot be used together")
+	}
+	order, err := analyzer.SortOrderFromString(*sortOrder)
 	if err != nil {
-		return fmt.Errorf("parsing flag -capabilities: %w", err)
+		return fmt.Errorf("parsing flag -sort: %w", err)
 	}
-	if *disableBuiltin && *customMap == "" {
+	if *disableBuiltin && len(*customMaps) == 0 {
 		return fmt.Errorf("Error: --disable_builtin only makes sense with a --capability_map file specified")
 	}
 	var classifier *interesting.Classifier
-	if *customMap != "" {
-		f, err := os.Open(*customMap)
-		if err != nil {
-			return err
-		}
-		classifier, err = interesting.LoadClassifier(*customMap, f, *disableBuiltin)
+	if len(*customMaps) > 0 {
+		classifier, err = interesting.LoadClassifierFiles(*customMaps, *disableBuiltin)
 		if err != nil {
 			return err
 		}
 		if *noiseFlag {
 			classifier = interesting.ClassifierExcludingUnanalyzed(classifier)
 		}
-		log.Printf("Using custom capability map %q", *customMap)
+		for _, m := range *customMaps {
+			log.Printf("Using custom capability map %q", m)
+		}
 	} else {
 		classifier = analyzer.GetClassifier(*noiseFlag)
 	}
+	// The capability set is parsed after the capability maps are loaded, so
+	// that it can contain capabilities they define.
+	cs, err := analyzer.NewCapabilitySet(*capabilities)
+	if err != nil {
+		return fmt.Errorf("parsing flag -capabilities: %w", err)
+	}
+	severities := classifier.Severities()
+	if *severityFlag != "" {
+		for _, s := range strings.Split(*severityFlag, ",") {
+			name, level, ok := strings.Cut(s, "=")
+			if !ok {
+				return fmt.Errorf(`parsing flag -severity: got %q, want a capability and a severity such as "REFLECT=high"`, s)
+			}
+			c, ok := interesting.ParseCapability(name)
+			if !ok {
+				return fmt.Errorf("parsing flag -severity: unknown capability %q", name)
+			}
+			sev, ok := interesting.ParseSeverity(level)
+			if !ok {
+				return fmt.Errorf(`parsing flag -severity: got severity %q for %s, want "low", "medium" or "high"`, level, name)
+			}
+			severities[c] = sev
+		}
+	}
+	if *printMap {
+		return classifier.WriteCapabilityMap(os.Stdout)
+	}
+	if *reportUnused {
+		classifier = classifier.WithUsageTracking()
+	}
+	var generatedPatterns []string
+	if *generatedFiles != "" {
+		generatedPatterns = strings.Split(*generatedFiles, ",")
+		for _, p := range generatedPatterns {
+			if _, err := filepath.Match(p, ""); err != nil {
+				return fmt.Errorf("parsing flag -generated-files: pattern %q: %w", p, err)
+			}
+		}
+	}
+
+	var ignorePathPatterns []*regexp.Regexp
+	for _, p := range *ignorePaths {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("parsing flag -ignore-path: %w", err)
+		}
+		ignorePathPatterns = append(ignorePathPatterns, re)
+	}
+
+	var rootFunctions []string
+	if *roots != "" {
+		for _, r := range strings.Split(*roots, ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				rootFunctions = append(rootFunctions, r)
+			}
+		}
+	}
+
+	var trustedPackages []string
+	if *trustedPkgs != "" {
+		trustedPackages = strings.Split(*trustedPkgs, ",")
+	}
+
+	var forbiddenFunctions []string
+	if *forbid != "" {
+		if forbiddenFunctions, err = analyzer.ParseForbiddenFunctions(*forbid); err != nil {
+			return fmt.Errorf("parsing flag -forbid: %w", err)
+		}
+	}
+
+	var excludeBuildTags []string
+	if *excludeTags != "" {
+		excludeBuildTags = strings.Split(*excludeTags, ",")
+	}
 
 	loadConfig := analyzer.LoadConfig{
-		BuildTags: *buildTags,
-		GOOS:      *goos,
-		GOARCH:    *goarch,
+		BuildTags:  *buildTags,
+		GOOS:       *goos,
+		GOARCH:     *goarch,
+		CgoEnabled: *cgo,
+		Vendor:     hasVendorDirectory(),
+		Workspace:  *workspace,
+	}
+	if *cgo != "" && *cgo != "0" && *cgo != "1" {
+		return fmt.Errorf(`parsing flag -cgo: got %q, want "0" or "1"`, *cgo)
+	}
+	if *workspace != "" && *workspace != "on" && *workspace != "off" {
+		return fmt.Errorf(`parsing flag -workspace: got %q, want "on" or "off"`, *workspace)
+	}
+	if loadConfig.Vendor && *verbose > 0 {
+		log.Printf("Loading dependencies from the vendor directory")
+	}
+	var (
+		pkgs          []*packages.Package
+		listFailed    bool
+		failedPackage string
+		// source describes where the packages were loaded from, for
+		// -list-packages.
+		source = "local workspace"
+	)
+	if roots := moduleRoots(packageNames); len(roots) > 1 && *binary == "" && *module == "" && !loadConfig.Vendor &&
+		loadConfig.Workspace != "off" && currentWorkspace() == "" {
+		// The packages are in several modules, and no workspace includes
+		// them all, so make one.
+		if *verbose > 0 {
+			log.Printf("Loading packages from modules %q in a temporary workspace", roots)
+		}
+		var remove func()
+		loadConfig.Workspace, remove, err = makeTemporaryWorkspace(roots)
+		if remove != nil {
+			defer remove()
+		}
+		if err != nil {
+			return err
+		}
+		source = "temporary workspace"
+	}
+	if *binary != "" {
+		if *packageList != "" || *module != "" {
+			return fmt.Errorf("-binary cannot be used together with -packages or -module")
+		}
+		pkgs, packageNames, err = loadBinaryPackages(*binary, loadConfig)
+		source = "temporary module"
+	} else if *module != "" {
+		if *packageList != "" {
+			return fmt.Errorf("-module and -packages cannot be used together")
+		}
+		pkgs, packageNames, err = loadModulePackages(*module, loadConfig)
+		source = "temporary module"
+	} else {
+		pkgs, listFailed, failedPackage, err = loadPackages(packageNames, loadConfig)
 	}
-	pkgs, listFailed, failedPackage, err := loadPackages(packageNames, loadConfig)
-	if (listFailed || len(pkgs) == 0) && !*forceLocalModule {
+	if (listFailed || len(pkgs) == 0) && !*forceLocalModule && !loadConfig.Vendor && *binary == "" && *module == "" {
 		// Either:
 		// - `go list` returned an error for one of the packages, perhaps because
 		//   it is not a dependency of the current workspace; or
@@ -120,7 +364,9 @@ func run() error {
 		// each package.
 		//
 		// -force_local_module disables this behavior, and returns an error
-		// instead.
+		// instead.  So does a vendor directory in the current module, since
+		// vendored modules are usually used where `go get` can't reach the
+		// network.
 		if listFailed {
 			fmt.Fprintf(os.Stderr, "Couldn't load package %q in the current module.", failedPackage)
 		} else {
@@ -145,8 +391,11 @@ func run() error {
 			return err
 		}
 
-		// Try loading the packages again.
+		// Try loading the packages again.  The temporary module is not part
+		// of any workspace.
+		loadConfig.Workspace = "off"
 		pkgs, _, _, err = loadPackages(packageNames, loadConfig)
+		source = "temporary module"
 
 		// Switch back to the original working directory.
 		err1 := os.Chdir(wd)
@@ -161,22 +410,113 @@ func run() error {
 		return fmt.Errorf("No packages matching %v", packageNames)
 	}
 
-	queriedPackages := analyzer.GetQueriedPackages(pkgs)
 	if *verbose > 0 {
 		for _, p := range pkgs {
 			log.Printf("Loaded package %q\n", p.Name)
 		}
 	}
+	if *listPkgs {
+		return listPackages(os.Stdout, pkgs, source)
+	}
+	var skipped []string
 	if packages.PrintErrors(pkgs) > 0 {
-		return fmt.Errorf("Some packages had errors. Aborting analysis.")
+		if !*skipErrors {
+			return fmt.Errorf("Some packages had errors. Aborting analysis.")
+		}
+		for _, p := range analyzer.PackagesWithErrors(pkgs) {
+			log.Printf("Skipping package %q, which had errors", p.PkgPath)
+			skipped = append(skipped, p.PkgPath)
+		}
+		// The packages which depend on ones with errors are skipped too,
+		// since their callgraph would be built from incomplete code.
+		affected := analyzer.PackagesAffectedByErrors(pkgs)
+		for _, p := range affected {
+			if len(p.Errors) == 0 {
+				log.Printf("Skipping package %q, which depends on packages with errors", p.PkgPath)
+				skipped = append(skipped, p.PkgPath)
+			}
+		}
+		pkgs = slices.DeleteFunc(pkgs, func(p *packages.Package) bool { return slices.Contains(affected, p) })
+		if len(pkgs) == 0 {
+			return fmt.Errorf("All packages matching %v had errors, or depend on packages with errors", packageNames)
+		}
+	}
+	queriedPackages := analyzer.GetQueriedPackages(pkgs)
+	var w io.Writer = os.Stdout
+	if *outputFile != "" {
+		f, err := os.Create(*outputFile)
+		if err != nil {
+			return fmt.Errorf("could not create output file: %w", err)
+		}
+		defer func() {
+			if err1 := f.Close(); err == nil && err1 != nil {
+				err = fmt.Errorf("could not close output file: %w", err1)
+			}
+		}()
+		w = f
+	}
+	var logf func(string, ...any)
+	if *verbose >= 2 {
+		logf = log.Printf
+	}
+	config := &analyzer.Config{
+		Classifier:                classifier,
+		DisableBuiltin:            *disableBuiltin,
+		Granularity:               g,
+		CapabilitySet:             cs,
+		OmitPaths:                 *omitPaths,
+		Quiet:                     *quiet,
+		IgnoreRemovals:            *ignoreRemovals,
+		GraphFocus:                *graphFocus,
+		PerCallSite:               *perCallSite,
+		ReportExportedEntrypoints: *exportedOnly,
+		Roots:                     rootFunctions,
+		IgnorePaths:               ignorePathPatterns,
+		Logf:                      logf,
+		CollapseSubcapabilities:   *collapseSubcaps,
+		DetectPanics:              *detectPanics,
+		DetectRecursion:           *detectRecursion,
+		DirectOnly:                *directOnly,
+		IgnoreStringMethods:       *ignoreStrMethods,
+		Severities:                severities,
+		MinSeverity:               minSev,
+		Sort:                      order,
+		ExamplesPerKey:            *examples,
+		OutputDir:                 *outputDir,
+		CompactJSON:               *jsonCompact,
+		CallgraphReachableOnly:    *reachableOnly,
+		Shallow:                   *shallow,
+		RelativePaths:             *relativePaths,
+		Metadata:                  analysisMetadata(packageNames),
+		MarkGenerated:             *markGenerated || len(generatedPatterns) > 0,
+		GeneratedFilePatterns:     generatedPatterns,
+		SeparateHeuristicFindings: *markHeuristic,
+		ReportRules:               *showRules,
+		TrustedPackages:           trustedPackages,
+		ExcludeBuildTags:          excludeBuildTags,
+		QueryVendoredAndGenerated: *includeVendGen,
+		CountUnanalyzed:           *failOnUnanalyzed,
+		Cache:                     new(analyzer.Cache),
+	}
+	queriedPackages = analyzer.FilterQueriedPackages(pkgs, queriedPackages, config)
+	unanalyzed, err := analyzer.RunCapslock(w, flag.Args(), outputMode, pkgs, queriedPackages, config)
+	if err == nil && len(forbiddenFunctions) > 0 {
+		err = analyzer.CheckForbidden(os.Stderr, pkgs, queriedPackages, config, forbiddenFunctions)
+	}
+	if err == nil && len(*forbidModules) > 0 {
+		err = analyzer.CheckForbiddenModules(os.Stderr, pkgs, queriedPackages, config, *forbidModules)
+	}
+	if *reportUnused && analyzer.ExitCodeForError(err) != analyzer.ExitError {
+		if err1 := reportUnusedEntries(classifier); err1 != nil {
+			return err1
+		}
+	}
+	if err == nil && *failOnUnanalyzed && unanalyzed > 0 {
+		err = analyzer.UnanalyzedFoundError{}
+	}
+	if err == nil && len(skipped) > 0 {
+		err = analyzer.SkippedPackagesError{Packages: skipped}
 	}
-	err = analyzer.RunCapslock(flag.Args(), *output, pkgs, queriedPackages, &analyzer.Config{
-		Classifier:     classifier,
-		DisableBuiltin: *disableBuiltin,
-		Granularity:    g,
-		CapabilitySet:  cs,
-		OmitPaths:      *omitPaths,
-	})
 
 	if *memprofile != "" {
 		f, err := os.Create(*memprofile)
@@ -211,6 +551,111 @@ func loadPackages(packageNames []string, loadConfig analyzer.LoadConfig) (pkgs [
 	return pkgs, false, "", err
 }
 
+// listPackages writes a line for each of pkgs, sorted by package path,
+// containing the package path, its module and version, and source, which
+// describes where the packages were loaded from, separated by tabs.  Packages
+// which had errors are followed by the number of errors.
+func listPackages(w io.Writer, pkgs []*packages.Package, source string) error {
+	pkgs = slices.Clone(pkgs)
+	slices.SortFunc(pkgs, func(a, b *packages.Package) int { return strings.Compare(a.PkgPath, b.PkgPath) })
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, p := range pkgs {
+		module := "(standard library)"
+		if m := p.Module; m != nil {
+			module = m.Path
+			if m.Version != "" {
+				module += "@" + m.Version
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s", p.PkgPath, module, source)
+		if len(p.Errors) > 0 {
+			fmt.Fprintf(tw, "\t(%d errors)", len(p.Errors))
+		}
+		fmt.Fprintln(tw)
+	}
+	return tw.Flush()
+}
+
+// hasVendorDirectory returns true if the main module of the current directory
+// has a vendor directory containing a modules.txt file, which means its
+// dependencies can be loaded with -mod=vendor.
+func hasVendorDirectory() bool {
+	out, err := exec.Command("go", "env", "GOMOD").Output()
+	if err != nil {
+		return false
+	}
+	gomod := strings.TrimSpace(string(out))
+	if gomod == "" || gomod == os.DevNull {
+		// Not in a module.
+		return false
+	}
+	_, err = os.Stat(filepath.Join(filepath.Dir(gomod), "vendor", "modules.txt"))
+	return err == nil
+}
+
+// moduleRoots returns the root directories of the modules containing the
+// packages in packageNames which are given as file system paths, such as
+// "./a/..." or "/src/b".  Packages given as import paths are ignored.
+func moduleRoots(packageNames []string) []string {
+	var roots []string
+	for _, p := range packageNames {
+		if !filepath.IsAbs(p) && p != "." && p != ".." &&
+			!strings.HasPrefix(p, "./") && !strings.HasPrefix(p, "../") {
+			continue
+		}
+		dir, err := filepath.Abs(strings.TrimSuffix(p, "/..."))
+		if err != nil {
+			continue
+		}
+		for {
+			if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+				if !slices.Contains(roots, dir) {
+					roots = append(roots, dir)
+				}
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	return roots
+}
+
+// currentWorkspace returns the path of the go.work file used by the go
+// command in the current directory, or "" if there is none.
+func currentWorkspace() string {
+	out, err := exec.Command("go", "env", "GOWORK").Output()
+	if err != nil {
+		return ""
+	}
+	if gowork := strings.TrimSpace(string(out)); gowork != "off" {
+		return gowork
+	}
+	return ""
+}
+
+// makeTemporaryWorkspace creates a go.work file in a new temporary directory
+// which uses the modules in the directories roots, and returns its path, to
+// be used as LoadConfig.Workspace so that packages from all of those modules
+// can be loaded together.
+//
+// The caller can call the returned function, if it is non-nil, to remove the
+// temporary directory containing the workspace when it is no longer needed.
+func makeTemporaryWorkspace(roots []string) (gowork string, remove func(), err error) {
+	tmpdir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return "", nil, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	remove = func() { os.RemoveAll(tmpdir) }
+	if err = runCommand("go", append([]string{"-C", tmpdir, "work", "init"}, roots...)...); err != nil {
+		return "", remove, fmt.Errorf("creating temporary workspace: %w", err)
+	}
+	return filepath.Join(tmpdir, "go.work"), remove, nil
+}
+
 // makeTemporaryModule switches to a new temporary directory, creates a module
 // there, and adds the specified packages to that module with `go get`.
 //
@@ -232,26 +677,207 @@ func makeTemporaryModule(packageNames []string) (remove func(), err error) {
 	if err = os.Chdir(tmpdir); err != nil {
 		return remove, fmt.Errorf("switching to temporary directory: %w", err)
 	}
-	run := func(command string, args ...string) error {
-		if *verbose >= 2 {
-			log.Printf("running %q with args %q", command, args)
-		}
-		cmd := exec.Command(command, args...)
-		var stderr bytes.Buffer
-		cmd.Stderr = &stderr
-		err := cmd.Run()
-		if err != nil || *verbose >= 2 {
-			os.Stderr.Write(stderr.Bytes())
-		}
-		return err
-	}
-	if err = run("go", "mod", "init", "capslockmodule"); err != nil {
+	if err = runCommand("go", "mod", "init", "capslockmodule"); err != nil {
 		return remove, fmt.Errorf("creating temporary module: %w", err)
 	}
 	for _, p := range packageNames {
-		if err := run("go", "get", p); err != nil {
+		if err := runCommand("go", "get", p); err != nil {
 			return remove, fmt.Errorf("calling `go get %q`: %w", p, err)
 		}
 	}
 	return remove, nil
 }
+
+// runCommand runs a command, and prints its standard error if it fails or if
+// the verbosity level is at least 2.
+func runCommand(command string, args ...string) error {
+	if *verbose >= 2 {
+		log.Printf("running %q with args %q", command, args)
+	}
+	cmd := exec.Command(command, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil || *verbose >= 2 {
+		os.Stderr.Write(stderr.Bytes())
+	}
+	return err
+}
+
+// loadBinaryPackages reads the build information embedded in the Go
+// executable at the given path, and loads packages from the module versions
+// it lists, in a temporary module.
+//
+// If the executable's main module has a version, so that it can be fetched,
+// the executable's main package is loaded.  Otherwise, such as when the
+// executable was built from a local checkout, all the packages in each
+// dependency module are loaded instead, since the build information doesn't
+// say which of them were linked into the executable.
+//
+// The returned patterns are the package patterns that were loaded.  Unless
+// they were set explicitly, the GOOS, GOARCH, CGO_ENABLED and build tags in
+// loadConfig are taken from the build information.
+func loadBinaryPackages(name string, loadConfig analyzer.LoadConfig) (pkgs []*packages.Package, patterns []string, err error) {
+	bi, err := buildinfo.ReadFile(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading build information: %w", err)
+	}
+	for _, s := range bi.Settings {
+		switch {
+		case s.Key == "GOOS" && loadConfig.GOOS == "":
+			loadConfig.GOOS = s.Value
+		case s.Key == "GOARCH" && loadConfig.GOARCH == "":
+			loadConfig.GOARCH = s.Value
+		case s.Key == "CGO_ENABLED" && loadConfig.CgoEnabled == "":
+			loadConfig.CgoEnabled = s.Value
+		case s.Key == "-tags" && loadConfig.BuildTags == "":
+			loadConfig.BuildTags = s.Value
+		}
+	}
+	// The temporary module has no vendor directory.
+	loadConfig.Vendor = false
+
+	// getArgs are the arguments to `go get` which add the modules to the
+	// temporary module, and replace are the arguments to `go mod edit` which
+	// add their replacements.
+	var getArgs, replace []string
+	for _, dep := range bi.Deps {
+		if r := dep.Replace; r != nil {
+			if r.Version == "" {
+				log.Printf("Skipping module %q, which was replaced with the local directory %q", dep.Path, r.Path)
+				continue
+			}
+			replace = append(replace, fmt.Sprintf("-replace=%s@%s=%s@%s", dep.Path, dep.Version, r.Path, r.Version))
+		}
+		getArgs = append(getArgs, dep.Path+"@"+dep.Version)
+	}
+	if v := bi.Main.Version; v != "" && v != "(devel)" {
+		getArgs = append(getArgs, bi.Path+"@"+v)
+		patterns = []string{bi.Path}
+	} else {
+		log.Printf("The main module %q of %q has no version; analyzing all packages in its dependencies", bi.Main.Path, name)
+		for _, dep := range bi.Deps {
+			if dep.Replace == nil || dep.Replace.Version != "" {
+				patterns = append(patterns, dep.Path+"/...")
+			}
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil, fmt.Errorf("%q has no dependencies that can be analyzed", name)
+	}
+	if *verbose > 0 {
+		log.Printf("Analyzing %q, built with %s, in a temporary module", name, bi.GoVersion)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, nil, err
+	}
+	remove, err := makeTemporaryModule(nil)
+	if remove != nil {
+		defer remove()
+	}
+	defer func() {
+		if err1 := os.Chdir(wd); err == nil && err1 != nil {
+			err = fmt.Errorf("returning to working directory: %w", err1)
+		}
+	}()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(replace) > 0 {
+		if err := runCommand("go", append([]string{"mod", "edit"}, replace...)...); err != nil {
+			return nil, nil, fmt.Errorf("adding replacements to temporary module: %w", err)
+		}
+	}
+	if err := runCommand("go", append([]string{"get"}, getArgs...)...); err != nil {
+		return nil, nil, fmt.Errorf("adding modules of %q to temporary module: %w", name, err)
+	}
+	loadConfig.Workspace = "off"
+	pkgs, err = analyzer.LoadPackages(patterns, loadConfig)
+	return pkgs, patterns, err
+}
+
+// reportUnusedEntries prints the entries of the custom capability maps, or of
+// the builtin map if there are none, which classifier did not use during the
+// analysis.
+func reportUnusedEntries(classifier *interesting.Classifier) error {
+	declared := classifier
+	if len(*customMaps) > 0 {
+		var err error
+		if declared, err = interesting.LoadClassifierFiles(*customMaps, true); err != nil {
+			return err
+		}
+	}
+	unused := classifier.UnusedEntries(declared)
+	if len(unused) == 0 {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Capability map entries which matched nothing:\n")
+	for _, e := range unused {
+		fmt.Fprintf(os.Stderr, "\t%s\n", e)
+	}
+	return nil
+}
+
+// analysisMetadata returns a description of how this run of capslock is
+// analyzing packageNames, for inclusion in the json output.
+func analysisMetadata(packageNames []string) *cpb.AnalysisMetadata {
+	version := "(devel)"
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		version = bi.Main.Version
+	}
+	md := &cpb.AnalysisMetadata{
+		CapslockVersion:      proto.String(version),
+		GoVersion:            proto.String(runtime.Version()),
+		Timestamp:            proto.String(time.Now().UTC().Format(time.RFC3339)),
+		BuiltinCapabilityMap: proto.Bool(!*disableBuiltin),
+		PackagePatterns:      packageNames,
+	}
+	md.CapabilityMapFiles = *customMaps
+	return md
+}
+
+// loadModulePackages loads all the packages of the module version named by
+// spec, such as "example.com/mod@v1.2.3", in a temporary module.  If spec has
+// no version, the latest version of the module is used.
+//
+// The returned patterns are the package patterns that were loaded.
+func loadModulePackages(spec string, loadConfig analyzer.LoadConfig) (pkgs []*packages.Package, patterns []string, err error) {
+	path, version, _ := strings.Cut(spec, "@")
+	if path == "" {
+		return nil, nil, fmt.Errorf("parsing flag -module: got %q, want a module path and version such as example.com/mod@v1.2.3", spec)
+	}
+	if version == "" {
+		version = "latest"
+	}
+	// The temporary module has no vendor directory.
+	loadConfig.Vendor = false
+	if *verbose > 0 {
+		log.Printf("Analyzing module %s@%s in a temporary module", path, version)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, nil, err
+	}
+	remove, err := makeTemporaryModule(nil)
+	if remove != nil {
+		defer remove()
+	}
+	defer func() {
+		if err1 := os.Chdir(wd); err == nil && err1 != nil {
+			err = fmt.Errorf("returning to working directory: %w", err1)
+		}
+	}()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := runCommand("go", "get", path+"@"+version); err != nil {
+		return nil, nil, fmt.Errorf("adding module %s@%s to temporary module: %w", path, version, err)
+	}
+	loadConfig.Workspace = "off"
+	patterns = []string{path + "/..."}
+	pkgs, err = analyzer.LoadPackages(patterns, loadConfig)
+	return pkgs, patterns, err
+}
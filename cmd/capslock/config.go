@@ -0,0 +1,275 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultConfigFiles are the names of the configuration files which capslock
+// reads from the working directory when -config is not set.  Only the first
+// one which exists is read.
+var defaultConfigFiles = []string{"capslock.yaml", "capslock.yml", "capslock.toml"}
+
+// configSetting is one setting from a configuration file.  A setting with a
+// list of values, such as packages: [a, b], has one element in values for
+// each of them.
+type configSetting struct {
+	name   string
+	values []string
+	line   int
+}
+
+// applyConfigFile reads the configuration file named by -config, or else the
+// first of defaultConfigFiles in the working directory, if any, and sets
+// each flag it names which was not set on the command line.
+func applyConfigFile() error {
+	name := *configFile
+	if name == "" {
+		for _, n := range defaultConfigFiles {
+			if _, err := os.Stat(n); err == nil {
+				name = n
+				break
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("reading configuration file: %w", err)
+			}
+		}
+		if name == "" {
+			return nil
+		}
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("reading configuration file: %w", err)
+	}
+	defer f.Close()
+	var settings []configSetting
+	if filepath.Ext(name) == ".toml" {
+		settings, err = parseTOMLConfig(f)
+	} else {
+		settings, err = parseYAMLConfig(f)
+	}
+	if err != nil {
+		return fmt.Errorf("reading configuration file %s: %w", name, err)
+	}
+	if *verbose > 0 {
+		log.Printf("Using configuration file %q", name)
+	}
+	return applyConfig(flag.CommandLine, settings, name)
+}
+
+// applyConfig sets the flags in flags named by settings, except those which were
+// already set on the command line, so that command-line flags take
+// precedence over the configuration file.  Each value of a setting for a
+// repeatable flag, such as capability_map, is set separately; the values of a
+// setting for any other flag are joined with commas, which suits flags such
+// as packages and capabilities.
+func applyConfig(flags *flag.FlagSet, settings []configSetting, file string) error {
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	seen := make(map[string]bool)
+	for _, s := range settings {
+		f := flags.Lookup(s.name)
+		if f == nil || s.name == "config" {
+			return fmt.Errorf("%s:%d: unknown setting %q", file, s.line, s.name)
+		}
+		if seen[s.name] {
+			return fmt.Errorf("%s:%d: setting %q is repeated", file, s.line, s.name)
+		}
+		seen[s.name] = true
+		if set[s.name] {
+			continue
+		}
+		values := s.values
+		if _, ok := f.Value.(*stringList); !ok {
+			values = []string{strings.Join(values, ",")}
+		}
+		for _, v := range values {
+			if err := flags.Set(s.name, v); err != nil {
+				return fmt.Errorf("%s:%d: setting %q: %w", file, s.line, s.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseYAMLConfig parses the subset of YAML used by capslock configuration
+// files: a mapping from flag names to scalars, to flow sequences such as
+// [a, b], or to block sequences with one "- value" line for each element.
+func parseYAMLConfig(r io.Reader) ([]configSetting, error) {
+	var (
+		settings []configSetting
+		// inBlock is true after a key with no value on its line, which may
+		// be followed by the elements of a block sequence.
+		inBlock bool
+	)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := stripComment(scanner.Text())
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		if elem, ok := strings.CutPrefix(trimmed, "- "); ok && line != trimmed {
+			// An element of a block sequence, which must be indented below
+			// its key.
+			if !inBlock {
+				return nil, fmt.Errorf("line %d: sequence element without a key", n)
+			}
+			v, err := unquote(strings.TrimSpace(elem))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			s := &settings[len(settings)-1]
+			s.values = append(s.values, v)
+			continue
+		}
+		if line != trimmed {
+			return nil, fmt.Errorf("line %d: unexpected indentation", n)
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf(`line %d: got %q, want "key: value"`, n, trimmed)
+		}
+		s := configSetting{name: strings.TrimSpace(key), line: n}
+		inBlock = strings.TrimSpace(value) == ""
+		if !inBlock {
+			values, err := parseValue(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			s.values = values
+		}
+		settings = append(settings, s)
+	}
+	return settings, scanner.Err()
+}
+
+// parseTOMLConfig parses the subset of TOML used by capslock configuration
+// files: key = value lines, where each value is a string, boolean, integer,
+// or an array of strings written on one line.
+func parseTOMLConfig(r io.Reader) ([]configSetting, error) {
+	var settings []configSetting
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf(`line %d: got %q, want "key = value"`, n, line)
+		}
+		key, err := unquote(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		values, err := parseValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		settings = append(settings, configSetting{name: key, values: values, line: n})
+	}
+	return settings, scanner.Err()
+}
+
+// parseValue parses a scalar, or a list of scalars in square brackets
+// separated by commas.
+func parseValue(v string) ([]string, error) {
+	inner, ok := strings.CutPrefix(v, "[")
+	if !ok {
+		s, err := unquote(v)
+		return []string{s}, err
+	}
+	inner, ok = strings.CutSuffix(inner, "]")
+	if !ok {
+		return nil, fmt.Errorf("list %s is missing a closing ']'", v)
+	}
+	values := []string{}
+	for _, elem := range splitList(inner) {
+		if elem = strings.TrimSpace(elem); elem == "" {
+			// Allow a trailing comma.
+			continue
+		}
+		s, err := unquote(elem)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+// splitList splits s at the commas which are not inside quotes.
+func splitList(s string) []string {
+	var (
+		elems []string
+		quote rune
+		start int
+	)
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			elems = append(elems, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(elems, s[start:])
+}
+
+// unquote returns the contents of a string in double or single quotes, or s
+// itself if it is not quoted.
+func unquote(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		u, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+		return u, nil
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return s[1 : len(s)-1], nil
+	case strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'"):
+		return "", fmt.Errorf("unterminated string %s", s)
+	}
+	return s, nil
+}
+
+// stripComment removes a comment starting with '#' from line, unless the '#'
+// is inside a quoted string.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
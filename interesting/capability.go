@@ -0,0 +1,55 @@
+// Copyright 2023 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package interesting
+
+import (
+	"strings"
+
+	cpb "github.com/google/capslock/proto"
+)
+
+// parentCapability maps each subcapability to the capability it refines.
+// A function with a subcapability also has the parent capability, so for
+// example a query for CAPABILITY_NETWORK matches CAPABILITY_NETWORK_CLIENT.
+var parentCapability = map[cpb.Capability]cpb.Capability{
+	cpb.Capability_CAPABILITY_NETWORK_CLIENT:                   cpb.Capability_CAPABILITY_NETWORK,
+	cpb.Capability_CAPABILITY_NETWORK_LISTEN:                   cpb.Capability_CAPABILITY_NETWORK,
+	cpb.Capability_CAPABILITY_RUNTIME_TUNING:                   cpb.Capability_CAPABILITY_RUNTIME,
+	cpb.Capability_CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP: cpb.Capability_CAPABILITY_UNSAFE_POINTER,
+	cpb.Capability_CAPABILITY_REFLECT_UNEXPORTED_FIELD:         cpb.Capability_CAPABILITY_REFLECT,
+	cpb.Capability_CAPABILITY_SYSTEM_CALLS_MMAP:                cpb.Capability_CAPABILITY_SYSTEM_CALLS,
+	cpb.Capability_CAPABILITY_EXEC_SHELL:                       cpb.Capability_CAPABILITY_EXEC,
+	cpb.Capability_CAPABILITY_EXEC_REPLACE:                     cpb.Capability_CAPABILITY_EXEC,
+	cpb.Capability_CAPABILITY_OPERATING_SYSTEM_DISPLAY:         cpb.Capability_CAPABILITY_OPERATING_SYSTEM,
+	cpb.Capability_CAPABILITY_NETWORK_INSECURE_TLS:             cpb.Capability_CAPABILITY_NETWORK,
+	cpb.Capability_CAPABILITY_FILES_DEVICE:                     cpb.Capability_CAPABILITY_FILES,
+}
+
+// ParentCapability returns the capability that c refines, if c is a
+// subcapability.  Otherwise it returns c and false.
+func ParentCapability(c cpb.Capability) (cpb.Capability, bool) {
+	if p, ok := parentCapability[c]; ok {
+		return p, true
+	}
+	return c, false
+}
+
+// ParseCapability returns the capability named by s.  The "CAPABILITY_"
+// prefix of the name is optional, and subcapabilities can be written with a
+// slash separating them from their parent, as in "NETWORK/CLIENT".  Custom
+// capabilities defined with DefineCapability are also accepted.
+func ParseCapability(s string) (cpb.Capability, bool) {
+	if c, ok := lookupCustomCapability(s); ok {
+		return c, true
+	}
+	s = strings.ReplaceAll(s, "/", "_")
+	c, ok := cpb.Capability_value[s]
+	if !ok {
+		c, ok = cpb.Capability_value["CAPABILITY_"+s]
+	}
+	return cpb.Capability(c), ok
+}
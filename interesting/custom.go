@@ -0,0 +1,111 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package interesting
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	cpb "github.com/google/capslock/proto"
+)
+
+// firstCustomCapability is the value given to the first capability defined
+// with a "define_capability" line.  It is well above the values used by the
+// Capability enum, so that new values can be added to the enum without
+// colliding with custom capabilities.
+const firstCustomCapability cpb.Capability = 1 << 16
+
+var customCapabilityName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// customCapabilities records the capabilities defined by capability maps.
+// They are shared by all Classifiers, so that a capability has the same
+// value however many maps define it.
+var customCapabilities = struct {
+	sync.Mutex
+	byName map[string]cpb.Capability
+	names  map[cpb.Capability]string
+}{
+	byName: make(map[string]cpb.Capability),
+	names:  make(map[cpb.Capability]string),
+}
+
+// DefineCapability returns the value of the custom capability with the given
+// name, defining it if necessary.  The name must consist of upper-case
+// letters, digits and underscores, and must not be the name of a capability
+// in the Capability enum, with or without its "CAPABILITY_" prefix.
+func DefineCapability(name string) (cpb.Capability, error) {
+	if !customCapabilityName.MatchString(name) {
+		return 0, fmt.Errorf("invalid capability name %q", name)
+	}
+	if _, ok := cpb.Capability_value[name]; ok {
+		return 0, fmt.Errorf("capability %q is already defined", name)
+	}
+	if _, ok := cpb.Capability_value["CAPABILITY_"+name]; ok {
+		return 0, fmt.Errorf("capability %q is already defined", name)
+	}
+	customCapabilities.Lock()
+	defer customCapabilities.Unlock()
+	if c, ok := customCapabilities.byName[name]; ok {
+		return c, nil
+	}
+	c := firstCustomCapability + cpb.Capability(len(customCapabilities.byName))
+	customCapabilities.byName[name] = c
+	customCapabilities.names[c] = name
+	return c, nil
+}
+
+// CustomCapability returns the name of c, if it is a capability defined
+// with DefineCapability.
+func CustomCapability(c cpb.Capability) (string, bool) {
+	customCapabilities.Lock()
+	defer customCapabilities.Unlock()
+	name, ok := customCapabilities.names[c]
+	return name, ok
+}
+
+// lookupCustomCapability returns the custom capability with the given name,
+// if one has been defined.
+func lookupCustomCapability(name string) (cpb.Capability, bool) {
+	customCapabilities.Lock()
+	defer customCapabilities.Unlock()
+	c, ok := customCapabilities.byName[name]
+	return c, ok
+}
+
+// CapabilityName returns the name of c: the name given to it by
+// DefineCapability for a custom capability, and the name of its Capability
+// enum value otherwise.
+func CapabilityName(c cpb.Capability) string {
+	if name, ok := CustomCapability(c); ok {
+		return name
+	}
+	return c.String()
+}
+
+// ToProto returns the capability to store in a proto for c, and the name to
+// store with it in a custom_capability field, which is empty unless c is a
+// custom capability.  Custom capabilities are stored as CAPABILITY_CUSTOM.
+func ToProto(c cpb.Capability) (cpb.Capability, string) {
+	if name, ok := CustomCapability(c); ok {
+		return cpb.Capability_CAPABILITY_CUSTOM, name
+	}
+	return c, ""
+}
+
+// FromProto returns the capability stored in a proto as c and custom, which
+// are values returned by ToProto.  It returns CAPABILITY_CUSTOM if custom
+// names a capability which has not been defined.
+func FromProto(c cpb.Capability, custom string) cpb.Capability {
+	if c != cpb.Capability_CAPABILITY_CUSTOM {
+		return c
+	}
+	if cc, ok := lookupCustomCapability(custom); ok {
+		return cc
+	}
+	return c
+}
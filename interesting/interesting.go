@@ -14,6 +14,9 @@ import (
 	"fmt"
 	"io"
 	"maps"
+	"os"
+	"path"
+	"path/filepath"
 	"slices"
 	"sort"
 	"strings"
@@ -31,8 +34,24 @@ type Classifier struct {
 	functionCategory   map[string]cpb.Capability
 	unanalyzedCategory map[string]cpb.Capability
 	packageCategory    map[string]cpb.Capability
-	ignoredEdges       map[[2]string]struct{}
-	cgoSuffixes        []string
+	// interfaceCategory maps interface names, such as
+	// "example.com/plugin.Runner", to capabilities.
+	interfaceCategory map[string]cpb.Capability
+	// packagePatterns lists the keys of packageCategory which are patterns
+	// rather than package paths, in sorted order.
+	packagePatterns []string
+	ignoredEdges    map[[2]string]struct{}
+	// ignoredEdgePatterns lists the keys of ignoredEdges which contain a
+	// wildcard, in sorted order.  Other keys are matched exactly.
+	ignoredEdgePatterns [][2]string
+	cgoSuffixes         []string
+	// customCapabilities contains the names of the capabilities declared
+	// with "define_capability" lines.
+	customCapabilities map[string]struct{}
+	// severity overrides the default severities of capabilities.
+	severity map[cpb.Capability]Severity
+	// usage, if non-nil, records the entries used; see WithUsageTracking.
+	usage *usageTracker
 }
 
 var internalMap = parseInternalMapOrDie()
@@ -42,12 +61,136 @@ func newClassifier() *Classifier {
 		functionCategory:   map[string]cpb.Capability{},
 		unanalyzedCategory: map[string]cpb.Capability{},
 		packageCategory:    map[string]cpb.Capability{},
+		interfaceCategory:  map[string]cpb.Capability{},
 		ignoredEdges:       map[[2]string]struct{}{},
+		severity:           map[cpb.Capability]Severity{},
+		customCapabilities: map[string]struct{}{},
 	}
 }
 
+// mergeClassifier copies the classifications in src into dst, overriding any
+// existing classifications in dst for the same keys.
+func mergeClassifier(dst, src *Classifier) {
+	maps.Copy(dst.functionCategory, src.functionCategory)
+	maps.Copy(dst.unanalyzedCategory, src.unanalyzedCategory)
+	maps.Copy(dst.packageCategory, src.packageCategory)
+	maps.Copy(dst.interfaceCategory, src.interfaceCategory)
+	maps.Copy(dst.ignoredEdges, src.ignoredEdges)
+	maps.Copy(dst.severity, src.severity)
+	maps.Copy(dst.customCapabilities, src.customCapabilities)
+	dst.cgoSuffixes = append(dst.cgoSuffixes, src.cgoSuffixes...)
+	dst.updatePackagePatterns()
+	dst.updateIgnoredEdgePatterns()
+}
+
+// isPackagePattern returns true if the key of a "package" line is a pattern
+// which can match multiple packages.
+func isPackagePattern(key string) bool {
+	return strings.HasSuffix(key, "/...") || strings.Contains(key, "*")
+}
+
+// MatchPackagePattern returns true if the package path pkg matches pattern.
+// In a pattern, "*" matches any sequence of characters other than "/", and a
+// "/..." suffix matches the preceding package and every package beneath it.
+// Any other pattern must match pkg exactly.
+func MatchPackagePattern(pattern, pkg string) bool {
+	prefix, subtree := strings.CutSuffix(pattern, "/...")
+	if !subtree {
+		ok, _ := path.Match(pattern, pkg)
+		return ok
+	}
+	n := strings.Count(prefix, "/") + 1
+	elems := strings.SplitN(pkg, "/", n+1)
+	if len(elems) < n {
+		return false
+	}
+	ok, _ := path.Match(prefix, strings.Join(elems[:n], "/"))
+	return ok
+}
+
+// updatePackagePatterns sets c.packagePatterns to the pattern keys of
+// c.packageCategory.
+func (c *Classifier) updatePackagePatterns() {
+	c.packagePatterns = nil
+	for k := range c.packageCategory {
+		if isPackagePattern(k) {
+			c.packagePatterns = append(c.packagePatterns, k)
+		}
+	}
+	sort.Strings(c.packagePatterns)
+}
+
+// isFunctionPattern returns true if the function name in an "ignore_edge"
+// line is a pattern which can match multiple functions.  Function names can
+// contain "*" in pointer receivers, as in "(*sync.Pool).Get", but never end
+// with one, so only a trailing "*" is treated as a wildcard.
+func isFunctionPattern(name string) bool {
+	return strings.HasSuffix(name, "*")
+}
+
+// matchFunctionPattern returns true if the function name fn matches pattern.
+// A trailing "*" in the pattern matches any sequence of characters, so "*"
+// alone matches every function.
+func matchFunctionPattern(pattern, fn string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(fn, prefix)
+	}
+	return pattern == fn
+}
+
+// updateIgnoredEdgePatterns sets c.ignoredEdgePatterns to the keys of
+// c.ignoredEdges which contain a pattern.
+func (c *Classifier) updateIgnoredEdgePatterns() {
+	c.ignoredEdgePatterns = nil
+	for k := range c.ignoredEdges {
+		if isFunctionPattern(k[0]) || isFunctionPattern(k[1]) {
+			c.ignoredEdgePatterns = append(c.ignoredEdgePatterns, k)
+		}
+	}
+	slices.SortFunc(c.ignoredEdgePatterns, func(a, b [2]string) int {
+		return slices.Compare(a[:], b[:])
+	})
+}
+
+// packagePatternCategory returns the category of the most specific package
+// pattern matching pkg, which is the one with the most characters other than
+// wildcards, and the pattern.  Ties are broken in favor of the pattern which
+// sorts first.
+func (c *Classifier) packagePatternCategory(pkg string) (cpb.Capability, string) {
+	best, bestLen := "", -1
+	for _, pattern := range c.packagePatterns {
+		if !MatchPackagePattern(pattern, pkg) {
+			continue
+		}
+		n := len(pattern) - strings.Count(pattern, "*")
+		if strings.HasSuffix(pattern, "/...") {
+			n -= len("/...")
+		}
+		if n > bestLen {
+			best, bestLen = pattern, n
+		}
+	}
+	if bestLen < 0 {
+		return cpb.Capability_CAPABILITY_UNSPECIFIED, ""
+	}
+	c.usage.usePackage(best)
+	return c.packageCategory[best], best
+}
+
 func parseCapabilityMap(source string, r io.Reader) (*Classifier, error) {
+	return parseCapabilityMapIncludedFrom(source, r, nil)
+}
+
+// parseCapabilityMapIncludedFrom parses a capability map, following any
+// include directives it contains.  includedFrom lists the files whose include
+// directives led to this one, outermost first, and is used to detect cycles.
+//
+// Classifications in the map itself take precedence over those in the files
+// it includes, and files included later take precedence over those included
+// earlier.
+func parseCapabilityMapIncludedFrom(source string, r io.Reader, includedFrom []string) (*Classifier, error) {
 	ret := newClassifier()
+	var included []*Classifier
 	scanner := bufio.NewScanner(r)
 	line := 0
 	for scanner.Scan() {
@@ -69,6 +212,14 @@ func parseCapabilityMap(source string, r io.Reader) (*Classifier, error) {
 		case "cgo_suffix":
 			// Format: cgo_suffix suffix.
 			ret.cgoSuffixes = append(ret.cgoSuffixes, args[1])
+		case "define_capability":
+			// Format: define_capability NAME
+			// The capability can be used by later lines, and by any map
+			// loaded after this one.
+			if _, err := DefineCapability(args[1]); err != nil {
+				return nil, fmt.Errorf("%v:%v: %v", source, line, err)
+			}
+			ret.customCapabilities[args[1]] = struct{}{}
 		case "func":
 			// Format: func package/function capability
 			if len(args) < 3 {
@@ -77,7 +228,7 @@ func parseCapabilityMap(source string, r io.Reader) (*Classifier, error) {
 			if _, ok := ret.functionCategory[args[1]]; ok {
 				return nil, fmt.Errorf("%v:%v: duplicate %v key", source, line, args[0])
 			}
-			c, ok := cpb.Capability_value[args[2]]
+			c, ok := ParseCapability(args[2])
 			if !ok {
 				return nil, fmt.Errorf("%v:%v: unsupported capability %q", source, line, args[2])
 			}
@@ -92,6 +243,42 @@ func parseCapabilityMap(source string, r io.Reader) (*Classifier, error) {
 				return nil, fmt.Errorf("%v:%v: duplicate %v key", source, line, args[0])
 			}
 			ret.ignoredEdges[k] = struct{}{}
+		case "include":
+			// Format: include path
+			// Relative paths are resolved relative to the directory of the
+			// including file.
+			p := args[1]
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(filepath.Dir(source), p)
+			}
+			stack := append(slices.Clip(includedFrom), filepath.Clean(source))
+			if slices.Contains(stack, p) {
+				return nil, fmt.Errorf("%v:%v: include cycle: %v", source, line,
+					strings.Join(append(stack, p), " -> "))
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return nil, fmt.Errorf("%v:%v: %v", source, line, err)
+			}
+			c, err := parseCapabilityMapIncludedFrom(p, f, stack)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			included = append(included, c)
+		case "interface":
+			// Format: interface package.Type capability
+			if len(args) < 3 {
+				return nil, fmt.Errorf("%v:%v: invalid %v format", source, line, args[0])
+			}
+			if _, ok := ret.interfaceCategory[args[1]]; ok {
+				return nil, fmt.Errorf("%v:%v: duplicate %v key", source, line, args[0])
+			}
+			c, ok := ParseCapability(args[2])
+			if !ok {
+				return nil, fmt.Errorf("%v:%v: unsupported capability %q", source, line, args[2])
+			}
+			ret.interfaceCategory[args[1]] = cpb.Capability(c)
 		case "package":
 			// Format: package package_name capability
 			if len(args) < 3 {
@@ -100,11 +287,45 @@ func parseCapabilityMap(source string, r io.Reader) (*Classifier, error) {
 			if _, ok := ret.packageCategory[args[1]]; ok {
 				return nil, fmt.Errorf("%v:%v: duplicate %v key", source, line, args[0])
 			}
-			c, ok := cpb.Capability_value[args[2]]
+			c, ok := ParseCapability(args[2])
 			if !ok {
 				return nil, fmt.Errorf("%v:%v: unsupported capability %q", source, line, args[2])
 			}
 			ret.packageCategory[args[1]] = cpb.Capability(c)
+		case "severity":
+			// Format: severity capability low|medium|high
+			if len(args) < 3 {
+				return nil, fmt.Errorf("%v:%v: invalid %v format", source, line, args[0])
+			}
+			c, ok := ParseCapability(args[1])
+			if !ok {
+				return nil, fmt.Errorf("%v:%v: unsupported capability %q", source, line, args[1])
+			}
+			if _, ok := ret.severity[c]; ok {
+				return nil, fmt.Errorf("%v:%v: duplicate %v key", source, line, args[0])
+			}
+			sev, ok := ParseSeverity(args[2])
+			if !ok {
+				return nil, fmt.Errorf("%v:%v: unsupported severity %q", source, line, args[2])
+			}
+			ret.severity[c] = sev
+		case "review":
+			// Format: review function
+			//     or: review package package_name
+			if args[1] == "package" {
+				if len(args) < 3 {
+					return nil, fmt.Errorf("%v:%v: invalid %v format", source, line, args[0])
+				}
+				if _, ok := ret.packageCategory[args[2]]; ok {
+					return nil, fmt.Errorf("%v:%v: duplicate %v key", source, line, args[0])
+				}
+				ret.packageCategory[args[2]] = cpb.Capability_CAPABILITY_REVIEW_REQUIRED
+				break
+			}
+			if _, ok := ret.functionCategory[args[1]]; ok {
+				return nil, fmt.Errorf("%v:%v: duplicate %v key", source, line, args[0])
+			}
+			ret.functionCategory[args[1]] = cpb.Capability_CAPABILITY_REVIEW_REQUIRED
 		case "unanalyzed":
 			// Format: unanalyzed function
 			if _, ok := ret.unanalyzedCategory[args[1]]; ok {
@@ -115,7 +336,19 @@ func parseCapabilityMap(source string, r io.Reader) (*Classifier, error) {
 			return nil, fmt.Errorf("%v:%v: unsupported keyword %q", source, line, args[0])
 		}
 	}
-	return ret, nil
+	if len(included) == 0 {
+		ret.updatePackagePatterns()
+		ret.updateIgnoredEdgePatterns()
+		return ret, nil
+	}
+	merged := newClassifier()
+	for _, c := range included {
+		mergeClassifier(merged, c)
+	}
+	mergeClassifier(merged, ret)
+	sort.Strings(merged.cgoSuffixes)
+	merged.cgoSuffixes = slices.Compact(merged.cgoSuffixes) // remove duplicates
+	return merged, nil
 }
 
 // parseInternalMapOrDie parses the internal embedded capability map data
@@ -152,6 +385,33 @@ func ClassifierExcludingUnanalyzed(classifier *Classifier) *Classifier {
 // Refer to the interesting/interesting.cm file in the source code for an
 // example of the capability map format. Classifications loaded from a
 // caller-specified file always override builtin classifications.
+//
+// A capability map can include other capability maps with a line of the form
+// "include path".  Relative paths are resolved relative to the directory
+// containing source, and classifications in the including map override those
+// in the maps it includes.
+//
+// The package in a "package" line can be a pattern: a "/..." suffix matches a
+// package and all packages beneath it, and "*" matches a single path element
+// or part of one.  See Classifier.FunctionCategory for how these interact with
+// other classifications.
+//
+// Either function in an "ignore_edge" line can end with "*" to match every
+// function whose name begins with the preceding text, so for example
+// "ignore_edge (*sync.Pool).Get *" ignores all calls made by that method.
+//
+// A line of the form "interface package.Type capability" assigns the
+// capability to the methods of every type implementing that interface; see
+// Classifier.InterfaceCategories.
+//
+// A line of the form "review function" flags the function for manual review;
+// it is classified as CAPABILITY_REVIEW_REQUIRED.  A line of the form "review
+// package package_name" does the same for the functions of a package, and is
+// otherwise like a "package" line, so the package can be a pattern.
+//
+// A line of the form "severity capability level", where level is "low",
+// "medium" or "high", overrides the default severity of the capability; see
+// Classifier.Severities.
 func LoadClassifier(source string, r io.Reader, excludeBuiltin bool) (*Classifier, error) {
 	userClassifier, err := parseCapabilityMap(source, r)
 	if err != nil {
@@ -161,30 +421,114 @@ func LoadClassifier(source string, r io.Reader, excludeBuiltin bool) (*Classifie
 		return userClassifier, nil
 	}
 	ret := newClassifier()
-	// Merge.
-	cc := func(dst, src *Classifier) {
-		maps.Copy(dst.functionCategory, src.functionCategory)
-		maps.Copy(dst.unanalyzedCategory, src.unanalyzedCategory)
-		maps.Copy(dst.packageCategory, src.packageCategory)
-		maps.Copy(dst.ignoredEdges, src.ignoredEdges)
-		dst.cgoSuffixes = append(dst.cgoSuffixes, src.cgoSuffixes...)
-	}
-	cc(ret, internalMap)
-	cc(ret, userClassifier)
+	mergeClassifier(ret, internalMap)
+	mergeClassifier(ret, userClassifier)
 	sort.Strings(ret.cgoSuffixes)
 	ret.cgoSuffixes = slices.Compact(ret.cgoSuffixes) // remove duplicates
 	return ret, nil
 }
 
+// LoadClassifierFiles is like LoadClassifier, but reads the capability maps
+// in the named files.  Later files take precedence over earlier ones, and all
+// of them take precedence over the builtin map unless excludeBuiltin is set.
+func LoadClassifierFiles(filenames []string, excludeBuiltin bool) (*Classifier, error) {
+	ret := newClassifier()
+	if !excludeBuiltin {
+		mergeClassifier(ret, internalMap)
+	}
+	for _, filename := range filenames {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		c, err := parseCapabilityMap(filename, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		mergeClassifier(ret, c)
+	}
+	sort.Strings(ret.cgoSuffixes)
+	ret.cgoSuffixes = slices.Compact(ret.cgoSuffixes) // remove duplicates
+	return ret, nil
+}
+
+// WriteCapabilityMap writes the classifications in c to w in the capability
+// map format accepted by LoadClassifier, so that loading the output with
+// excludeBuiltin set produces an equivalent Classifier.  Entries are sorted
+// by keyword and then by key.
+func (c *Classifier) WriteCapabilityMap(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, s := range slices.Sorted(slices.Values(c.cgoSuffixes)) {
+		fmt.Fprintf(bw, "cgo_suffix %s\n", s)
+	}
+	for _, k := range slices.Sorted(maps.Keys(c.customCapabilities)) {
+		fmt.Fprintf(bw, "define_capability %s\n", k)
+	}
+	for _, k := range slices.Sorted(maps.Keys(c.packageCategory)) {
+		fmt.Fprintf(bw, "package %s %s\n", k, CapabilityName(c.packageCategory[k]))
+	}
+	for _, k := range slices.Sorted(maps.Keys(c.functionCategory)) {
+		fmt.Fprintf(bw, "func %s %s\n", k, CapabilityName(c.functionCategory[k]))
+	}
+	for _, k := range slices.Sorted(maps.Keys(c.interfaceCategory)) {
+		fmt.Fprintf(bw, "interface %s %s\n", k, CapabilityName(c.interfaceCategory[k]))
+	}
+	for _, k := range slices.Sorted(maps.Keys(c.unanalyzedCategory)) {
+		fmt.Fprintf(bw, "unanalyzed %s\n", k)
+	}
+	edges := slices.SortedFunc(maps.Keys(c.ignoredEdges), func(a, b [2]string) int {
+		return slices.Compare(a[:], b[:])
+	})
+	for _, e := range edges {
+		fmt.Fprintf(bw, "ignore_edge %s %s\n", e[0], e[1])
+	}
+	for _, k := range slices.Sorted(maps.Keys(c.severity)) {
+		fmt.Fprintf(bw, "severity %s %s\n", CapabilityName(k), c.severity[k])
+	}
+	return bw.Flush()
+}
+
+// Severities returns a new map from capabilities to their severities, which
+// contains the default severities overridden by any "severity" lines in the
+// capability maps c was loaded from.
+func (c *Classifier) Severities() map[cpb.Capability]Severity {
+	s := DefaultSeverities()
+	maps.Copy(s, c.severity)
+	return s
+}
+
+// InterfaceCategories returns a map from the names of interfaces, such as
+// "example.com/plugin.Runner", to capabilities.  The methods of any type
+// implementing one of these interfaces which are in the interface's method
+// set have the corresponding capability, unless FunctionCategory gives them
+// another classification.
+func (c *Classifier) InterfaceCategories() map[string]cpb.Capability {
+	return maps.Clone(c.interfaceCategory)
+}
+
 // IncludeCall returns true if a call from one function to another should be
 // considered when searching for transitive capabilities.  We return false for
 // some internal calls in the standard library where we know a potential
 // transitive capability does not arise in practice.
 func (c *Classifier) IncludeCall(edge *callgraph.Edge) bool {
-	caller := edge.Caller.Func.String()
-	callee := edge.Callee.Func.String()
-	_, ok := internalMap.ignoredEdges[[2]string{caller, callee}]
-	return !ok
+	return !c.ignoresEdge(edge.Caller.Func.String(), edge.Callee.Func.String())
+}
+
+// ignoresEdge returns true if an "ignore_edge" line matches the call from
+// caller to callee.
+func (c *Classifier) ignoresEdge(caller, callee string) bool {
+	if _, ok := c.ignoredEdges[[2]string{caller, callee}]; ok {
+		c.usage.useEdge([2]string{caller, callee})
+		return true
+	}
+	for _, e := range c.ignoredEdgePatterns {
+		if matchFunctionPattern(e[0], caller) && matchFunctionPattern(e[1], callee) {
+			c.usage.useEdge(e)
+			return true
+		}
+	}
+	return false
 }
 
 // FunctionCategory returns a Category for the given function specified by
@@ -194,7 +538,43 @@ func (c *Classifier) IncludeCall(edge *callgraph.Edge) bool {
 // If the return value is Unspecified, then we have not declared it to be
 // either safe or unsafe, so its descendants will have to be considered by the
 // static analysis.
+//
+// Classifications are applied in the following order of precedence: cgo
+// suffixes, "func" lines, "unanalyzed" lines, "package" lines naming the
+// function's package exactly, and then "package" lines with patterns like
+// "example.com/a/..." or "example.com/a/*", of which the most specific
+// matching pattern is used.
 func (c *Classifier) FunctionCategory(pkg, name string) cpb.Capability {
+	cat, _, _ := c.classify(pkg, name)
+	return cat
+}
+
+// FunctionRule is like FunctionCategory, but also returns the line of the
+// capability map which gave the function its category, such as
+// "func os.Getenv CAPABILITY_READ_SYSTEM_STATE", "package example.com/a/...
+// CAPABILITY_NETWORK" or "cgo_suffix _cgo_runtime_cgocall".  The line is ""
+// if no line matched the function.
+func (c *Classifier) FunctionRule(pkg, name string) (cpb.Capability, string) {
+	cat, keyword, key := c.classify(pkg, name)
+	switch keyword {
+	case "":
+		return cat, ""
+	case "func", "package":
+		if cat == cpb.Capability_CAPABILITY_REVIEW_REQUIRED {
+			if keyword == "package" {
+				return cat, "review package " + key
+			}
+			return cat, "review " + key
+		}
+		return cat, fmt.Sprintf("%s %s %s", keyword, key, CapabilityName(cat))
+	}
+	return cat, keyword + " " + key
+}
+
+// classify returns the category of a function, as described for
+// FunctionCategory, and the keyword and key of the capability map line which
+// matched it.
+func (c *Classifier) classify(pkg, name string) (cat cpb.Capability, keyword, key string) {
 	for _, s := range c.cgoSuffixes {
 		// Calls to C functions produce a call to a function
 		// named "_cgo_runtime_cgocall" in the current package.
@@ -202,7 +582,7 @@ func (c *Classifier) FunctionCategory(pkg, name string) cpb.Capability {
 		// "C" pseudo-package (see See https://pkg.go.dev/cmd/cgo)
 		// produce calls to other functions listed in cgoSuffixes.
 		if strings.HasSuffix(name, s) {
-			return cpb.Capability_CAPABILITY_CGO
+			return cpb.Capability_CAPABILITY_CGO, "cgo_suffix", s
 		}
 	}
 	if cat, ok := c.functionCategory[name]; ok {
@@ -210,10 +590,18 @@ func (c *Classifier) FunctionCategory(pkg, name string) cpb.Capability {
 		// package's category.  This includes the possibility that the function
 		// is categorized as "unspecified", which indicates that the analyzer
 		// should analyze the function's code as normal.
-		return cat
+		c.usage.useFunction(name)
+		return cat, "func", name
 	}
 	if cat, ok := c.unanalyzedCategory[name]; ok {
-		return cat
+		return cat, "unanalyzed", name
+	}
+	if cat, ok := c.packageCategory[pkg]; ok {
+		c.usage.usePackage(pkg)
+		return cat, "package", pkg
+	}
+	if cat, pattern := c.packagePatternCategory(pkg); pattern != "" {
+		return cat, "package", pattern
 	}
-	return c.packageCategory[pkg]
+	return cpb.Capability_CAPABILITY_UNSPECIFIED, "", ""
 }
@@ -7,6 +7,10 @@
 package interesting
 
 import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
@@ -176,3 +180,421 @@ func TestUserWithoutBuiltin(t *testing.T) {
 		}
 	}
 }
+
+func TestInclude(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"org.cm": `
+package example.com/org CAPABILITY_NETWORK
+func example.com/org.Foo CAPABILITY_FILES
+`,
+		"team/team.cm": `
+include ../org.cm
+func example.com/org.Foo CAPABILITY_SAFE
+func example.com/team.Bar CAPABILITY_EXEC
+`,
+		"cycle1.cm": "include cycle2.cm\n",
+		"cycle2.cm": "include cycle1.cm\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source := filepath.Join(dir, "team", "team.cm")
+	f, err := os.Open(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	classifier, err := LoadClassifier(source, f, true)
+	if err != nil {
+		t.Fatalf("LoadClassifier failed: %v", err)
+	}
+	for _, c := range []struct {
+		pkg, fn string
+		want    cpb.Capability
+	}{
+		{"example.com/org", "example.com/org.Foo", cpb.Capability_CAPABILITY_SAFE},
+		{"example.com/org", "example.com/org.Baz", cpb.Capability_CAPABILITY_NETWORK},
+		{"example.com/team", "example.com/team.Bar", cpb.Capability_CAPABILITY_EXEC},
+	} {
+		if got := classifier.FunctionCategory(c.pkg, c.fn); got != c.want {
+			t.Errorf("FunctionCategory(%q, %q): got %q, want %q", c.pkg, c.fn, got, c.want)
+		}
+	}
+
+	source = filepath.Join(dir, "cycle1.cm")
+	_, err = LoadClassifier(source, strings.NewReader("include cycle2.cm\n"), true)
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("LoadClassifier(%q): got error %v, want include cycle error", source, err)
+	}
+}
+
+func TestLoadClassifierFiles(t *testing.T) {
+	dir := t.TempDir()
+	org := filepath.Join(dir, "org.cm")
+	project := filepath.Join(dir, "project.cm")
+	for name, content := range map[string]string{
+		org: `
+package example.com/org CAPABILITY_NETWORK
+func example.com/org.Foo CAPABILITY_FILES
+func os.Getenv CAPABILITY_SAFE
+`,
+		project: `
+func example.com/org.Foo CAPABILITY_SAFE
+func example.com/project.Bar CAPABILITY_EXEC
+`,
+	} {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		excludeBuiltin bool
+		pkg, fn        string
+		want           cpb.Capability
+	}{
+		{false, "example.com/org", "example.com/org.Foo", cpb.Capability_CAPABILITY_SAFE},
+		{false, "example.com/org", "example.com/org.Baz", cpb.Capability_CAPABILITY_NETWORK},
+		{false, "example.com/project", "example.com/project.Bar", cpb.Capability_CAPABILITY_EXEC},
+		{false, "os", "os.Getenv", cpb.Capability_CAPABILITY_SAFE},
+		{false, "os", "os.ReadFile", cpb.Capability_CAPABILITY_FILES},
+		{true, "example.com/org", "example.com/org.Foo", cpb.Capability_CAPABILITY_SAFE},
+		{true, "os", "os.ReadFile", cpb.Capability_CAPABILITY_UNSPECIFIED},
+	} {
+		classifier, err := LoadClassifierFiles([]string{org, project}, test.excludeBuiltin)
+		if err != nil {
+			t.Fatalf("LoadClassifierFiles failed: %v", err)
+		}
+		if got := classifier.FunctionCategory(test.pkg, test.fn); got != test.want {
+			t.Errorf("excludeBuiltin=%v: FunctionCategory(%q, %q): got %q, want %q", test.excludeBuiltin, test.pkg, test.fn, got, test.want)
+		}
+	}
+	if _, err := LoadClassifierFiles([]string{org, filepath.Join(dir, "missing.cm")}, false); err == nil {
+		t.Errorf("LoadClassifierFiles with a missing file: got nil error")
+	}
+}
+
+func TestDisplayMap(t *testing.T) {
+	classifier, err := LoadClassifierFiles([]string{"supplemental/display.cm"}, false)
+	if err != nil {
+		t.Fatalf("LoadClassifierFiles failed: %v", err)
+	}
+	for _, test := range []struct {
+		pkg, fn string
+		want    cpb.Capability
+	}{
+		{"github.com/atotto/clipboard", "github.com/atotto/clipboard.WriteAll", cpb.Capability_CAPABILITY_OPERATING_SYSTEM_DISPLAY},
+		{"github.com/jezek/xgb/xproto", "github.com/jezek/xgb/xproto.CreateWindow", cpb.Capability_CAPABILITY_OPERATING_SYSTEM_DISPLAY},
+		{"fyne.io/fyne/v2/app", "fyne.io/fyne/v2/app.New", cpb.Capability_CAPABILITY_OPERATING_SYSTEM_DISPLAY},
+		{"os", "os.ReadFile", cpb.Capability_CAPABILITY_FILES},
+		{"example.com/clipboard", "example.com/clipboard.Foo", cpb.Capability_CAPABILITY_UNSPECIFIED},
+	} {
+		if got := classifier.FunctionCategory(test.pkg, test.fn); got != test.want {
+			t.Errorf("FunctionCategory(%q, %q): got %q, want %q", test.pkg, test.fn, got, test.want)
+		}
+	}
+	if got, _ := ParentCapability(cpb.Capability_CAPABILITY_OPERATING_SYSTEM_DISPLAY); got != cpb.Capability_CAPABILITY_OPERATING_SYSTEM {
+		t.Errorf("ParentCapability(CAPABILITY_OPERATING_SYSTEM_DISPLAY): got %q, want CAPABILITY_OPERATING_SYSTEM", got)
+	}
+}
+
+func TestUnusedEntries(t *testing.T) {
+	const capabilityMap = `
+func example.com/a.Used CAPABILITY_SAFE
+func example.com/a.Unused CAPABILITY_SAFE
+package example.com/a CAPABILITY_FILES
+package example.com/b/... CAPABILITY_NETWORK
+package example.com/c CAPABILITY_NETWORK
+ignore_edge example.com/a.F example.com/a.G
+ignore_edge example.com/b.F *
+`
+	declared, err := LoadClassifier(t.Name(), strings.NewReader(capabilityMap), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier failed: %v", err)
+	}
+	if got := declared.UnusedEntries(declared); got != nil {
+		t.Errorf("UnusedEntries without tracking: got %q, want nil", got)
+	}
+	classifier := declared.WithUsageTracking()
+	classifier.FunctionCategory("example.com/a", "example.com/a.Used")
+	classifier.FunctionCategory("example.com/a", "example.com/a.Other")
+	classifier.FunctionCategory("example.com/b/c", "example.com/b/c.F")
+	classifier.ignoresEdge("example.com/b.F", "example.com/b.G")
+	want := []string{
+		"func example.com/a.Unused CAPABILITY_SAFE",
+		"package example.com/c CAPABILITY_NETWORK",
+		"ignore_edge example.com/a.F example.com/a.G",
+	}
+	if got := classifier.UnusedEntries(classifier); !slices.Equal(got, want) {
+		t.Errorf("UnusedEntries: got %q, want %q", got, want)
+	}
+	// The original classifier is not affected.
+	if got := declared.UnusedEntries(declared); got != nil {
+		t.Errorf("UnusedEntries of original classifier: got %q, want nil", got)
+	}
+}
+
+func TestWriteCapabilityMap(t *testing.T) {
+	for _, classifier := range []*Classifier{
+		DefaultClassifier(),
+		ClassifierExcludingUnanalyzed(DefaultClassifier()),
+	} {
+		var b strings.Builder
+		if err := classifier.WriteCapabilityMap(&b); err != nil {
+			t.Fatalf("WriteCapabilityMap: %v", err)
+		}
+		got, err := LoadClassifier(t.Name(), strings.NewReader(b.String()), true)
+		if err != nil {
+			t.Fatalf("LoadClassifier failed on output of WriteCapabilityMap: %v", err)
+		}
+		if !maps.Equal(got.functionCategory, classifier.functionCategory) ||
+			!maps.Equal(got.packageCategory, classifier.packageCategory) ||
+			!maps.Equal(got.interfaceCategory, classifier.interfaceCategory) ||
+			!maps.Equal(got.unanalyzedCategory, classifier.unanalyzedCategory) ||
+			!maps.Equal(got.ignoredEdges, classifier.ignoredEdges) ||
+			!maps.Equal(got.severity, classifier.severity) ||
+			!slices.Equal(got.cgoSuffixes, slices.Sorted(slices.Values(classifier.cgoSuffixes))) {
+			t.Errorf("WriteCapabilityMap output does not round-trip to the same classifier")
+		}
+	}
+}
+
+func TestFunctionRule(t *testing.T) {
+	const capabilityMap = `
+cgo_suffix _cgo_runtime_cgocall
+package example.com/a/... CAPABILITY_NETWORK
+package example.com/a/b CAPABILITY_FILES
+func example.com/a/b.Foo CAPABILITY_SAFE
+review example.com/a/b.Bar
+unanalyzed example.com/a/b.Baz
+`
+	classifier, err := LoadClassifier(t.Name(), strings.NewReader(capabilityMap), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier failed: %v", err)
+	}
+	for _, test := range []struct {
+		pkg, fn string
+		want    string
+	}{
+		{"example.com/a/c", "example.com/a/c.F", "package example.com/a/... CAPABILITY_NETWORK"},
+		{"example.com/a/b", "example.com/a/b.F", "package example.com/a/b CAPABILITY_FILES"},
+		{"example.com/a/b", "example.com/a/b.Foo", "func example.com/a/b.Foo CAPABILITY_SAFE"},
+		{"example.com/a/b", "example.com/a/b.Bar", "review example.com/a/b.Bar"},
+		{"example.com/a/b", "example.com/a/b.Baz", "unanalyzed example.com/a/b.Baz"},
+		{"example.com/c", "example.com/c._cgo_runtime_cgocall", "cgo_suffix _cgo_runtime_cgocall"},
+		{"example.com/c", "example.com/c.F", ""},
+	} {
+		cat, rule := classifier.FunctionRule(test.pkg, test.fn)
+		if rule != test.want {
+			t.Errorf("FunctionRule(%q, %q): got rule %q, want %q", test.pkg, test.fn, rule, test.want)
+		}
+		if want := classifier.FunctionCategory(test.pkg, test.fn); cat != want {
+			t.Errorf("FunctionRule(%q, %q): got category %v, want %v", test.pkg, test.fn, cat, want)
+		}
+	}
+}
+
+func TestPackagePatterns(t *testing.T) {
+	const capabilityMap = `
+package example.com/a/... CAPABILITY_NETWORK
+package example.com/a/b/... CAPABILITY_FILES
+package example.com/a/b/c CAPABILITY_EXEC
+package example.com/*/internal/* CAPABILITY_OPERATING_SYSTEM
+func example.com/a/b/c.Foo CAPABILITY_SAFE
+`
+	classifier, err := LoadClassifier(t.Name(), strings.NewReader(capabilityMap), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier failed: %v", err)
+	}
+	for _, c := range []struct {
+		pkg, fn string
+		want    cpb.Capability
+	}{
+		{"example.com/a", "example.com/a.Foo", cpb.Capability_CAPABILITY_NETWORK},
+		{"example.com/a/x/y", "example.com/a/x/y.Foo", cpb.Capability_CAPABILITY_NETWORK},
+		{"example.com/ab", "example.com/ab.Foo", cpb.Capability_CAPABILITY_UNSPECIFIED},
+		{"example.com/a/b", "example.com/a/b.Foo", cpb.Capability_CAPABILITY_FILES},
+		{"example.com/a/b/d", "example.com/a/b/d.Foo", cpb.Capability_CAPABILITY_FILES},
+		{"example.com/a/b/c", "example.com/a/b/c.Bar", cpb.Capability_CAPABILITY_EXEC},
+		{"example.com/a/b/c", "example.com/a/b/c.Foo", cpb.Capability_CAPABILITY_SAFE},
+		{"example.com/z/internal/q", "example.com/z/internal/q.Foo", cpb.Capability_CAPABILITY_OPERATING_SYSTEM},
+		{"example.com/z/internal/q/r", "example.com/z/internal/q/r.Foo", cpb.Capability_CAPABILITY_UNSPECIFIED},
+	} {
+		if got := classifier.FunctionCategory(c.pkg, c.fn); got != c.want {
+			t.Errorf("FunctionCategory(%q, %q): got %q, want %q", c.pkg, c.fn, got, c.want)
+		}
+	}
+}
+
+func TestIgnoredEdgePatterns(t *testing.T) {
+	const capabilityMap = `
+ignore_edge (*sync.Pool).Get *
+ignore_edge example.com/a.Foo example.com/b.*
+ignore_edge example.com/a.Bar example.com/b.Baz
+`
+	classifier, err := LoadClassifier(t.Name(), strings.NewReader(capabilityMap), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier failed: %v", err)
+	}
+	for _, c := range []struct {
+		caller, callee string
+		want           bool
+	}{
+		{"(*sync.Pool).Get", "(*sync.Pool).getSlow", true},
+		{"(*sync.Pool).Get", "runtime.procPin", true},
+		{"(*sync.Pool).Put", "runtime.procPin", false},
+		{"example.com/a.Foo", "example.com/b.Baz", true},
+		{"example.com/a.Foo", "(*example.com/b.T).M", false},
+		{"example.com/a.Foo", "example.com/c.Baz", false},
+		{"example.com/a.Bar", "example.com/b.Baz", true},
+		{"example.com/a.Bar", "example.com/b.Qux", false},
+	} {
+		if got := classifier.ignoresEdge(c.caller, c.callee); got != c.want {
+			t.Errorf("ignoresEdge(%q, %q): got %v, want %v", c.caller, c.callee, got, c.want)
+		}
+	}
+}
+
+func TestSeverities(t *testing.T) {
+	const capabilityMap = `
+severity REFLECT high
+severity NETWORK/LISTEN low
+`
+	classifier, err := LoadClassifier(t.Name(), strings.NewReader(capabilityMap), false)
+	if err != nil {
+		t.Fatalf("LoadClassifier failed: %v", err)
+	}
+	severities := classifier.Severities()
+	for _, c := range []struct {
+		capability cpb.Capability
+		want       Severity
+	}{
+		{cpb.Capability_CAPABILITY_REFLECT, SeverityHigh},
+		{cpb.Capability_CAPABILITY_REFLECT_UNEXPORTED_FIELD, SeverityHigh},
+		{cpb.Capability_CAPABILITY_NETWORK, SeverityMedium},
+		{cpb.Capability_CAPABILITY_NETWORK_CLIENT, SeverityMedium},
+		{cpb.Capability_CAPABILITY_NETWORK_LISTEN, SeverityLow},
+		{cpb.Capability_CAPABILITY_EXEC, SeverityHigh},
+		{cpb.Capability_CAPABILITY_READ_SYSTEM_STATE, SeverityLow},
+	} {
+		if got := CapabilitySeverity(severities, c.capability); got != c.want {
+			t.Errorf("CapabilitySeverity(%v): got %v, want %v", c.capability, got, c.want)
+		}
+	}
+	if got := CapabilitySeverity(DefaultSeverities(), cpb.Capability_CAPABILITY_REFLECT); got != SeverityMedium {
+		t.Errorf("default severity of CAPABILITY_REFLECT: got %v, want %v", got, SeverityMedium)
+	}
+	for _, bad := range []string{"severity REFLECT extreme", "severity NOT_A_CAPABILITY high"} {
+		if _, err := LoadClassifier(t.Name(), strings.NewReader(bad), true); err == nil {
+			t.Errorf("LoadClassifier(%q): got nil error", bad)
+		}
+	}
+}
+
+func TestReview(t *testing.T) {
+	const capabilityMap = `
+review example.com/a.Foo
+func example.com/a.Bar CAPABILITY_SAFE
+review package example.com/b
+review package example.com/c/...
+func example.com/b.Safe CAPABILITY_SAFE
+package example.com/c/d CAPABILITY_FILES
+`
+	classifier, err := LoadClassifier(t.Name(), strings.NewReader(capabilityMap), false)
+	if err != nil {
+		t.Fatalf("LoadClassifier failed: %v", err)
+	}
+	for _, c := range []struct {
+		pkg, fn string
+		want    cpb.Capability
+		rule    string
+	}{
+		{"example.com/a", "example.com/a.Foo", cpb.Capability_CAPABILITY_REVIEW_REQUIRED, "review example.com/a.Foo"},
+		{"example.com/a", "example.com/a.Bar", cpb.Capability_CAPABILITY_SAFE, "func example.com/a.Bar CAPABILITY_SAFE"},
+		{"example.com/a", "example.com/a.Baz", cpb.Capability_CAPABILITY_UNSPECIFIED, ""},
+		{"example.com/b", "example.com/b.F", cpb.Capability_CAPABILITY_REVIEW_REQUIRED, "review package example.com/b"},
+		{"example.com/b", "example.com/b.Safe", cpb.Capability_CAPABILITY_SAFE, "func example.com/b.Safe CAPABILITY_SAFE"},
+		{"example.com/c/e", "example.com/c/e.F", cpb.Capability_CAPABILITY_REVIEW_REQUIRED, "review package example.com/c/..."},
+		{"example.com/c/d", "example.com/c/d.F", cpb.Capability_CAPABILITY_FILES, "package example.com/c/d CAPABILITY_FILES"},
+	} {
+		if got := classifier.FunctionCategory(c.pkg, c.fn); got != c.want {
+			t.Errorf("FunctionCategory(%q, %q): got %q, want %q", c.pkg, c.fn, got, c.want)
+		}
+		if _, got := classifier.FunctionRule(c.pkg, c.fn); got != c.rule {
+			t.Errorf("FunctionRule(%q, %q): got rule %q, want %q", c.pkg, c.fn, got, c.rule)
+		}
+	}
+	for _, invalid := range []string{
+		"review example.com/a.Foo\nfunc example.com/a.Foo CAPABILITY_FILES\n",
+		"review package example.com/b\npackage example.com/b CAPABILITY_FILES\n",
+		"review package\n",
+	} {
+		if _, err := LoadClassifier(t.Name(), strings.NewReader(invalid), true); err == nil {
+			t.Errorf("LoadClassifier(%q): got nil error", invalid)
+		}
+	}
+}
+
+func TestDefineCapability(t *testing.T) {
+	const capabilityMap = `
+define_capability DATABASE
+func example.com/db.Query DATABASE
+package example.com/dbdriver DATABASE
+severity DATABASE high
+`
+	classifier, err := LoadClassifier(t.Name(), strings.NewReader(capabilityMap), true)
+	if err != nil {
+		t.Fatalf("LoadClassifier failed: %v", err)
+	}
+	c := classifier.FunctionCategory("example.com/db", "example.com/db.Query")
+	if name, ok := CustomCapability(c); !ok || name != "DATABASE" {
+		t.Errorf("CustomCapability(%v): got %q, %v, want %q, true", c, name, ok, "DATABASE")
+	}
+	if got := classifier.FunctionCategory("example.com/dbdriver", "example.com/dbdriver.Open"); got != c {
+		t.Errorf("FunctionCategory for package entry: got %v, want %v", got, c)
+	}
+	if got := CapabilitySeverity(classifier.Severities(), c); got != SeverityHigh {
+		t.Errorf("CapabilitySeverity(%v): got %v, want %v", c, got, SeverityHigh)
+	}
+	if got, ok := ParseCapability("DATABASE"); !ok || got != c {
+		t.Errorf("ParseCapability(%q): got %v, %v, want %v, true", "DATABASE", got, ok, c)
+	}
+	if got, custom := ToProto(c); got != cpb.Capability_CAPABILITY_CUSTOM || custom != "DATABASE" {
+		t.Errorf("ToProto(%v): got %v, %q", c, got, custom)
+	}
+	if got := FromProto(cpb.Capability_CAPABILITY_CUSTOM, "DATABASE"); got != c {
+		t.Errorf("FromProto(CAPABILITY_CUSTOM, %q): got %v, want %v", "DATABASE", got, c)
+	}
+	// Defining the capability again gives the same value.
+	if again, err := DefineCapability("DATABASE"); err != nil || again != c {
+		t.Errorf("DefineCapability(%q): got %v, %v, want %v, nil", "DATABASE", again, err, c)
+	}
+	var b strings.Builder
+	if err := classifier.WriteCapabilityMap(&b); err != nil {
+		t.Fatalf("WriteCapabilityMap failed: %v", err)
+	}
+	for _, line := range []string{
+		"define_capability DATABASE\n",
+		"func example.com/db.Query DATABASE\n",
+		"severity DATABASE high\n",
+	} {
+		if !strings.Contains(b.String(), line) {
+			t.Errorf("WriteCapabilityMap output %q does not contain %q", b.String(), line)
+		}
+	}
+	for _, invalid := range []string{
+		"define_capability FILES\n",
+		"define_capability CAPABILITY_NETWORK\n",
+		"define_capability lowercase\n",
+		"func example.com/db.Query UNDEFINED\n",
+	} {
+		if _, err := LoadClassifier(t.Name(), strings.NewReader(invalid), true); err == nil {
+			t.Errorf("LoadClassifier(%q): got nil error", invalid)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package interesting
+
+import (
+	"maps"
+	"strings"
+
+	cpb "github.com/google/capslock/proto"
+)
+
+// Severity ranks capabilities by how much attention a reviewer should pay to
+// code that has them.
+type Severity int8
+
+const (
+	SeverityUnspecified Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+)
+
+var severityNames = [...]string{
+	SeverityUnspecified: "unspecified",
+	SeverityLow:         "low",
+	SeverityMedium:      "medium",
+	SeverityHigh:        "high",
+}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return "unknown"
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity returns the severity named by s, which is one of "low",
+// "medium" or "high".
+func ParseSeverity(s string) (Severity, bool) {
+	for i, name := range severityNames {
+		if Severity(i) != SeverityUnspecified && strings.EqualFold(s, name) {
+			return Severity(i), true
+		}
+	}
+	return SeverityUnspecified, false
+}
+
+// defaultSeverity is the severity of each top-level capability.
+// Subcapabilities have the severity of their parent unless listed here.
+var defaultSeverity = map[cpb.Capability]Severity{
+	cpb.Capability_CAPABILITY_SAFE:                SeverityLow,
+	cpb.Capability_CAPABILITY_FILES:               SeverityMedium,
+	cpb.Capability_CAPABILITY_NETWORK:             SeverityMedium,
+	cpb.Capability_CAPABILITY_RUNTIME:             SeverityLow,
+	cpb.Capability_CAPABILITY_READ_SYSTEM_STATE:   SeverityLow,
+	cpb.Capability_CAPABILITY_MODIFY_SYSTEM_STATE: SeverityMedium,
+	cpb.Capability_CAPABILITY_OPERATING_SYSTEM:    SeverityMedium,
+	cpb.Capability_CAPABILITY_SYSTEM_CALLS:        SeverityMedium,
+	cpb.Capability_CAPABILITY_ARBITRARY_EXECUTION: SeverityHigh,
+	cpb.Capability_CAPABILITY_CGO:                 SeverityHigh,
+	cpb.Capability_CAPABILITY_UNANALYZED:          SeverityMedium,
+	cpb.Capability_CAPABILITY_UNSAFE_POINTER:      SeverityHigh,
+	cpb.Capability_CAPABILITY_REFLECT:             SeverityMedium,
+	cpb.Capability_CAPABILITY_EXEC:                SeverityHigh,
+	cpb.Capability_CAPABILITY_SIGNAL:              SeverityMedium,
+	cpb.Capability_CAPABILITY_PANIC:               SeverityLow,
+	cpb.Capability_CAPABILITY_REVIEW_REQUIRED:     SeverityMedium,
+	cpb.Capability_CAPABILITY_GLOBAL_REGISTRATION: SeverityLow,
+	cpb.Capability_CAPABILITY_RECURSION:           SeverityLow,
+
+	// Disabling the verification of TLS certificates is more serious than
+	// other uses of the network.
+	cpb.Capability_CAPABILITY_NETWORK_INSECURE_TLS: SeverityHigh,
+}
+
+// DefaultSeverities returns a new map containing the default severity of
+// each capability.  It can be modified and passed to CapabilitySeverity to
+// override the defaults.
+func DefaultSeverities() map[cpb.Capability]Severity {
+	return maps.Clone(defaultSeverity)
+}
+
+// CapabilitySeverity returns the severity of c according to severities, or
+// according to the default severities if severities is nil.  A
+// subcapability which is not in severities has the severity of its parent,
+// and any other capability which is not in severities has medium severity.
+func CapabilitySeverity(severities map[cpb.Capability]Severity, c cpb.Capability) Severity {
+	if severities == nil {
+		severities = defaultSeverity
+	}
+	if s, ok := severities[c]; ok {
+		return s
+	}
+	if p, ok := ParentCapability(c); ok {
+		if s, ok := severities[p]; ok {
+			return s
+		}
+	}
+	return SeverityMedium
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package interesting
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// usageTracker records which entries of a capability map a Classifier has
+// used to classify a function or a call.
+type usageTracker struct {
+	mu        sync.Mutex
+	functions map[string]struct{}
+	packages  map[string]struct{}
+	edges     map[[2]string]struct{}
+}
+
+func (u *usageTracker) useFunction(name string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	u.functions[name] = struct{}{}
+	u.mu.Unlock()
+}
+
+func (u *usageTracker) usePackage(pkg string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	u.packages[pkg] = struct{}{}
+	u.mu.Unlock()
+}
+
+func (u *usageTracker) useEdge(e [2]string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	u.edges[e] = struct{}{}
+	u.mu.Unlock()
+}
+
+// WithUsageTracking returns a copy of c which records the "func", "package"
+// and "ignore_edge" entries it uses to classify functions and calls, so that
+// UnusedEntries can report the entries which were never used.
+func (c *Classifier) WithUsageTracking() *Classifier {
+	tracked := *c
+	tracked.usage = &usageTracker{
+		functions: make(map[string]struct{}),
+		packages:  make(map[string]struct{}),
+		edges:     make(map[[2]string]struct{}),
+	}
+	return &tracked
+}
+
+// UnusedEntries returns the "func", "package" and "ignore_edge" entries of
+// m, in capability map format, which c has not used to classify any function
+// or call.  c must have been returned by WithUsageTracking.  m is usually c
+// itself, or a Classifier loaded from just the custom capability maps that
+// were merged into c, to check only those maps for stale entries.
+//
+// An entry is unused if no function or call it matches was classified, or if
+// a more specific entry always took precedence over it.
+func (c *Classifier) UnusedEntries(m *Classifier) []string {
+	if c.usage == nil {
+		return nil
+	}
+	c.usage.mu.Lock()
+	defer c.usage.mu.Unlock()
+	var unused []string
+	for _, k := range slices.Sorted(maps.Keys(m.functionCategory)) {
+		if _, ok := c.usage.functions[k]; !ok {
+			unused = append(unused, fmt.Sprintf("func %s %s", k, CapabilityName(m.functionCategory[k])))
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(m.packageCategory)) {
+		if _, ok := c.usage.packages[k]; !ok {
+			unused = append(unused, fmt.Sprintf("package %s %s", k, CapabilityName(m.packageCategory[k])))
+		}
+	}
+	edges := slices.SortedFunc(maps.Keys(m.ignoredEdges), func(a, b [2]string) int {
+		return slices.Compare(a[:], b[:])
+	})
+	for _, e := range edges {
+		if _, ok := c.usage.edges[e]; !ok {
+			unused = append(unused, fmt.Sprintf("ignore_edge %s %s", e[0], e[1]))
+		}
+	}
+	return unused
+}
@@ -26,7 +26,7 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
-// Next_id = 15
+// Next_id = 29
 type Capability int32
 
 const (
@@ -45,6 +45,65 @@ const (
 	Capability_CAPABILITY_UNSAFE_POINTER      Capability = 12
 	Capability_CAPABILITY_REFLECT             Capability = 13
 	Capability_CAPABILITY_EXEC                Capability = 14
+	Capability_CAPABILITY_SIGNAL              Capability = 15
+	// Subcapabilities of CAPABILITY_NETWORK, for making outbound connections
+	// and for accepting inbound connections.
+	Capability_CAPABILITY_NETWORK_CLIENT Capability = 16
+	Capability_CAPABILITY_NETWORK_LISTEN Capability = 17
+	// Subcapability of CAPABILITY_RUNTIME, for changing the settings of the
+	// garbage collector and scheduler.
+	Capability_CAPABILITY_RUNTIME_TUNING Capability = 18
+	// Subcapability of CAPABILITY_UNSAFE_POINTER, for converting a pointer to a
+	// uintptr and back to a pointer, which can be used for type confusion.
+	Capability_CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP Capability = 19
+	// Subcapability of CAPABILITY_REFLECT, for using reflect together with
+	// unsafe pointers to access struct fields, which can bypass the
+	// restrictions on using unexported fields.
+	Capability_CAPABILITY_REFLECT_UNEXPORTED_FIELD Capability = 20
+	// Calling panic.  This is only reported when requested, since it is very
+	// common.
+	Capability_CAPABILITY_PANIC Capability = 21
+	// Functions which a capability map has flagged for manual review with a
+	// "review" line, without deciding what capability they have.
+	Capability_CAPABILITY_REVIEW_REQUIRED Capability = 22
+	// Subcapability of CAPABILITY_SYSTEM_CALLS, for mapping files or shared
+	// memory into the address space of the process, which gives direct access
+	// to memory that the Go runtime does not manage.
+	Capability_CAPABILITY_SYSTEM_CALLS_MMAP Capability = 23
+	// Subcapability of CAPABILITY_EXEC, for executing a command shell, such as
+	// with exec.Command("sh", "-c", ...), which can interpret its arguments as
+	// arbitrary commands.
+	Capability_CAPABILITY_EXEC_SHELL Capability = 24
+	// Registering something in a global registry, such as a database driver
+	// with sql.Register or an HTTP handler with http.Handle.  This is usually
+	// done when a package is initialized, so importing the package changes
+	// the behavior of other packages.
+	Capability_CAPABILITY_GLOBAL_REGISTRATION Capability = 25
+	// Subcapability of CAPABILITY_EXEC, for replacing the current process with
+	// another program, such as with syscall.Exec.  Unlike starting a child
+	// process, this never returns if it succeeds.
+	Capability_CAPABILITY_EXEC_REPLACE Capability = 26
+	// A capability declared in a capability map with "define_capability".  The
+	// name of the capability is given in a separate custom_capability field.
+	Capability_CAPABILITY_CUSTOM Capability = 27
+	// Being part of a recursive cycle of calls, which can exhaust the stack if
+	// the depth of the recursion is controlled by the input.  This is only
+	// reported when requested.
+	Capability_CAPABILITY_RECURSION Capability = 28
+	// Subcapability of CAPABILITY_OPERATING_SYSTEM, for using the display,
+	// clipboard, keyboard or mouse of a desktop session, such as through a GUI
+	// toolkit or X11 bindings.  The builtin capability map does not assign it;
+	// see interesting/supplemental/display.cm.
+	Capability_CAPABILITY_OPERATING_SYSTEM_DISPLAY Capability = 29
+	// Subcapability of CAPABILITY_NETWORK, for disabling the verification of
+	// TLS certificates by setting InsecureSkipVerify in a crypto/tls.Config,
+	// which exposes connections to interception.
+	Capability_CAPABILITY_NETWORK_INSECURE_TLS Capability = 30
+	// Subcapability of CAPABILITY_FILES, for opening device files and the files
+	// of special file systems, such as /dev/urandom, /proc/self/status or
+	// /sys/class/net, which give access to hardware and to the state of the
+	// kernel rather than to ordinary files.
+	Capability_CAPABILITY_FILES_DEVICE Capability = 31
 )
 
 // Enum value maps for Capability.
@@ -65,23 +124,57 @@ var (
 		12: "CAPABILITY_UNSAFE_POINTER",
 		13: "CAPABILITY_REFLECT",
 		14: "CAPABILITY_EXEC",
+		15: "CAPABILITY_SIGNAL",
+		16: "CAPABILITY_NETWORK_CLIENT",
+		17: "CAPABILITY_NETWORK_LISTEN",
+		18: "CAPABILITY_RUNTIME_TUNING",
+		19: "CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP",
+		20: "CAPABILITY_REFLECT_UNEXPORTED_FIELD",
+		21: "CAPABILITY_PANIC",
+		22: "CAPABILITY_REVIEW_REQUIRED",
+		23: "CAPABILITY_SYSTEM_CALLS_MMAP",
+		24: "CAPABILITY_EXEC_SHELL",
+		25: "CAPABILITY_GLOBAL_REGISTRATION",
+		26: "CAPABILITY_EXEC_REPLACE",
+		27: "CAPABILITY_CUSTOM",
+		28: "CAPABILITY_RECURSION",
+		29: "CAPABILITY_OPERATING_SYSTEM_DISPLAY",
+		30: "CAPABILITY_NETWORK_INSECURE_TLS",
+		31: "CAPABILITY_FILES_DEVICE",
 	}
 	Capability_value = map[string]int32{
-		"CAPABILITY_UNSPECIFIED":         0,
-		"CAPABILITY_SAFE":                1,
-		"CAPABILITY_FILES":               2,
-		"CAPABILITY_NETWORK":             3,
-		"CAPABILITY_RUNTIME":             4,
-		"CAPABILITY_READ_SYSTEM_STATE":   5,
-		"CAPABILITY_MODIFY_SYSTEM_STATE": 6,
-		"CAPABILITY_OPERATING_SYSTEM":    7,
-		"CAPABILITY_SYSTEM_CALLS":        8,
-		"CAPABILITY_ARBITRARY_EXECUTION": 9,
-		"CAPABILITY_CGO":                 10,
-		"CAPABILITY_UNANALYZED":          11,
-		"CAPABILITY_UNSAFE_POINTER":      12,
-		"CAPABILITY_REFLECT":             13,
-		"CAPABILITY_EXEC":                14,
+		"CAPABILITY_UNSPECIFIED":                      0,
+		"CAPABILITY_SAFE":                             1,
+		"CAPABILITY_FILES":                            2,
+		"CAPABILITY_NETWORK":                          3,
+		"CAPABILITY_RUNTIME":                          4,
+		"CAPABILITY_READ_SYSTEM_STATE":                5,
+		"CAPABILITY_MODIFY_SYSTEM_STATE":              6,
+		"CAPABILITY_OPERATING_SYSTEM":                 7,
+		"CAPABILITY_SYSTEM_CALLS":                     8,
+		"CAPABILITY_ARBITRARY_EXECUTION":              9,
+		"CAPABILITY_CGO":                              10,
+		"CAPABILITY_UNANALYZED":                       11,
+		"CAPABILITY_UNSAFE_POINTER":                   12,
+		"CAPABILITY_REFLECT":                          13,
+		"CAPABILITY_EXEC":                             14,
+		"CAPABILITY_SIGNAL":                           15,
+		"CAPABILITY_NETWORK_CLIENT":                   16,
+		"CAPABILITY_NETWORK_LISTEN":                   17,
+		"CAPABILITY_RUNTIME_TUNING":                   18,
+		"CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP": 19,
+		"CAPABILITY_REFLECT_UNEXPORTED_FIELD":         20,
+		"CAPABILITY_PANIC":                            21,
+		"CAPABILITY_REVIEW_REQUIRED":                  22,
+		"CAPABILITY_SYSTEM_CALLS_MMAP":                23,
+		"CAPABILITY_EXEC_SHELL":                       24,
+		"CAPABILITY_GLOBAL_REGISTRATION":              25,
+		"CAPABILITY_EXEC_REPLACE":                     26,
+		"CAPABILITY_CUSTOM":                           27,
+		"CAPABILITY_RECURSION":                        28,
+		"CAPABILITY_OPERATING_SYSTEM_DISPLAY":         29,
+		"CAPABILITY_NETWORK_INSECURE_TLS":             30,
+		"CAPABILITY_FILES_DEVICE":                     31,
 	}
 )
 
@@ -200,6 +293,48 @@ type CapabilityInfo struct {
 	PackageDir *string `protobuf:"bytes,4,opt,name=package_dir,json=packageDir" json:"package_dir,omitempty"`
 	// Classification of how the capability was incurred.
 	CapabilityType *CapabilityType `protobuf:"varint,5,opt,name=capability_type,json=capabilityType,enum=capslock.proto.CapabilityType" json:"capability_type,omitempty"`
+	// Whether the function at the start of the path can be reached in the
+	// callgraph from an exported function of one of the queried packages, so
+	// that users of those packages can trigger the capability.
+	ReachableFromExported *bool `protobuf:"varint,7,opt,name=reachable_from_exported,json=reachableFromExported" json:"reachable_from_exported,omitempty"`
+	// The path of the module containing the package, if known.  Its version
+	// is in the module_info field of CapabilityInfoList.
+	Module *string `protobuf:"bytes,8,opt,name=module" json:"module,omitempty"`
+	// Whether the path starts in an init function, or in package variable
+	// initialization, of one of the queried packages, so that the capability
+	// is used when the package is imported even if none of its functions are
+	// called.
+	AtInitTime *bool `protobuf:"varint,9,opt,name=at_init_time,json=atInitTime" json:"at_init_time,omitempty"`
+	// Whether the function at the start of the path is in a generated file,
+	// such as one with a "// Code generated ... DO NOT EDIT." comment.  This
+	// is only set when requested.
+	Generated *bool `protobuf:"varint,10,opt,name=generated" json:"generated,omitempty"`
+	// The name of the capability, if capability is CAPABILITY_CUSTOM.  Custom
+	// capabilities are declared in capability maps with "define_capability".
+	CustomCapability *string `protobuf:"bytes,11,opt,name=custom_capability,json=customCapability" json:"custom_capability,omitempty"`
+	// Whether the capability was found by one of Capslock's heuristic
+	// analyses of function bodies, such as the detection of unsafe pointer
+	// conversions and reflect.Value copies, rather than by the capability map.
+	// This is only set when requested.
+	Heuristic *bool `protobuf:"varint,12,opt,name=heuristic" json:"heuristic,omitempty"`
+	// Whether the path passes through a function of the package it starts in
+	// which calls recover, such as a deferred function which handles a panic,
+	// so that the capability may only be used after a panic.
+	ViaRecover *bool `protobuf:"varint,13,opt,name=via_recover,json=viaRecover" json:"via_recover,omitempty"`
+	// The rule which gave the last function in the path its capability, such
+	// as the capability map line "func os.Getenv CAPABILITY_READ_SYSTEM_STATE",
+	// or a description of the builtin analysis which found it.  This is only
+	// set when requested.
+	Rule *string `protobuf:"bytes,14,opt,name=rule" json:"rule,omitempty"`
+	// Whether the path passes through a call to a String or Error method
+	// made through an interface by the formatting code of package fmt or
+	// errors.  Such calls can reach the method of any type whose values are
+	// formatted anywhere in the program, so the capability is less likely to
+	// be really used.
+	LowConfidence *bool `protobuf:"varint,15,opt,name=low_confidence,json=lowConfidence" json:"low_confidence,omitempty"`
+	// For CAPABILITY_RECURSION, a cycle of calls from the function at the end
+	// of the path back to itself, starting with that function.
+	RecursiveCycle []string `protobuf:"bytes,16,rep,name=recursive_cycle,json=recursiveCycle" json:"recursive_cycle,omitempty"`
 }
 
 func (x *CapabilityInfo) Reset() {
@@ -276,6 +411,76 @@ func (x *CapabilityInfo) GetCapabilityType() CapabilityType {
 	return CapabilityType_CAPABILITY_TYPE_UNSPECIFIED
 }
 
+func (x *CapabilityInfo) GetReachableFromExported() bool {
+	if x != nil && x.ReachableFromExported != nil {
+		return *x.ReachableFromExported
+	}
+	return false
+}
+
+func (x *CapabilityInfo) GetModule() string {
+	if x != nil && x.Module != nil {
+		return *x.Module
+	}
+	return ""
+}
+
+func (x *CapabilityInfo) GetAtInitTime() bool {
+	if x != nil && x.AtInitTime != nil {
+		return *x.AtInitTime
+	}
+	return false
+}
+
+func (x *CapabilityInfo) GetGenerated() bool {
+	if x != nil && x.Generated != nil {
+		return *x.Generated
+	}
+	return false
+}
+
+func (x *CapabilityInfo) GetCustomCapability() string {
+	if x != nil && x.CustomCapability != nil {
+		return *x.CustomCapability
+	}
+	return ""
+}
+
+func (x *CapabilityInfo) GetHeuristic() bool {
+	if x != nil && x.Heuristic != nil {
+		return *x.Heuristic
+	}
+	return false
+}
+
+func (x *CapabilityInfo) GetViaRecover() bool {
+	if x != nil && x.ViaRecover != nil {
+		return *x.ViaRecover
+	}
+	return false
+}
+
+func (x *CapabilityInfo) GetRule() string {
+	if x != nil && x.Rule != nil {
+		return *x.Rule
+	}
+	return ""
+}
+
+func (x *CapabilityInfo) GetLowConfidence() bool {
+	if x != nil && x.LowConfidence != nil {
+		return *x.LowConfidence
+	}
+	return false
+}
+
+func (x *CapabilityInfo) GetRecursiveCycle() []string {
+	if x != nil {
+		return x.RecursiveCycle
+	}
+	return nil
+}
+
 type Function struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -284,6 +489,11 @@ type Function struct {
 	Name    *string        `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
 	Site    *Function_Site `protobuf:"bytes,2,opt,name=site" json:"site,omitempty"`
 	Package *string        `protobuf:"bytes,3,opt,name=package" json:"package,omitempty"`
+	// The path of the module containing the package, if known.
+	Module *string `protobuf:"bytes,4,opt,name=module" json:"module,omitempty"`
+	// Whether the call to this function is a go statement, so that this
+	// function, and the rest of the path, run on a new goroutine.
+	InGoroutine *bool `protobuf:"varint,5,opt,name=in_goroutine,json=inGoroutine" json:"in_goroutine,omitempty"`
 }
 
 func (x *Function) Reset() {
@@ -339,6 +549,20 @@ func (x *Function) GetPackage() string {
 	return ""
 }
 
+func (x *Function) GetModule() string {
+	if x != nil && x.Module != nil {
+		return *x.Module
+	}
+	return ""
+}
+
+func (x *Function) GetInGoroutine() bool {
+	if x != nil && x.InGoroutine != nil {
+		return *x.InGoroutine
+	}
+	return false
+}
+
 type ModuleInfo struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -462,6 +686,14 @@ type CapabilityInfoList struct {
 	CapabilityInfo []*CapabilityInfo `protobuf:"bytes,1,rep,name=capability_info,json=capabilityInfo" json:"capability_info,omitempty"`
 	ModuleInfo     []*ModuleInfo     `protobuf:"bytes,2,rep,name=module_info,json=moduleInfo" json:"module_info,omitempty"`
 	PackageInfo    []*PackageInfo    `protobuf:"bytes,3,rep,name=package_info,json=packageInfo" json:"package_info,omitempty"`
+	// The number of calls to functions like sort.Sort and (*sync.Once).Do which
+	// the analyzer could not rewrite to make the callgraph more precise.  Run
+	// capslock with -v=2 to log them.
+	UnrewrittenCallCount *int64 `protobuf:"varint,4,opt,name=unrewritten_call_count,json=unrewrittenCallCount" json:"unrewritten_call_count,omitempty"`
+	// Information about how the analysis was run, if known.
+	Metadata *AnalysisMetadata `protobuf:"bytes,5,opt,name=metadata" json:"metadata,omitempty"`
+	// The go:generate directives in the analyzed packages.
+	BuildTimeExecution []*BuildTimeExecution `protobuf:"bytes,6,rep,name=build_time_execution,json=buildTimeExecution" json:"build_time_execution,omitempty"`
 }
 
 func (x *CapabilityInfoList) Reset() {
@@ -517,6 +749,200 @@ func (x *CapabilityInfoList) GetPackageInfo() []*PackageInfo {
 	return nil
 }
 
+func (x *CapabilityInfoList) GetUnrewrittenCallCount() int64 {
+	if x != nil && x.UnrewrittenCallCount != nil {
+		return *x.UnrewrittenCallCount
+	}
+	return 0
+}
+
+func (x *CapabilityInfoList) GetMetadata() *AnalysisMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *CapabilityInfoList) GetBuildTimeExecution() []*BuildTimeExecution {
+	if x != nil {
+		return x.BuildTimeExecution
+	}
+	return nil
+}
+
+// BuildTimeExecution is a "//go:generate" directive, which runs a command
+// when "go generate" is run in its package.  The command is not part of the
+// callgraph, but can run arbitrary programs on a developer's machine.
+type BuildTimeExecution struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The path of the package containing the directive.
+	Package *string `protobuf:"bytes,1,opt,name=package" json:"package,omitempty"`
+	// The base name of the file containing the directive.
+	Filename *string `protobuf:"bytes,2,opt,name=filename" json:"filename,omitempty"`
+	Line     *int64  `protobuf:"varint,3,opt,name=line" json:"line,omitempty"`
+	// The command, as written after "//go:generate ".
+	Command *string `protobuf:"bytes,4,opt,name=command" json:"command,omitempty"`
+}
+
+func (x *BuildTimeExecution) Reset() {
+	*x = BuildTimeExecution{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_capability_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BuildTimeExecution) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuildTimeExecution) ProtoMessage() {}
+
+func (x *BuildTimeExecution) ProtoReflect() protoreflect.Message {
+	mi := &file_capability_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuildTimeExecution.ProtoReflect.Descriptor instead.
+func (*BuildTimeExecution) Descriptor() ([]byte, []int) {
+	return file_capability_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BuildTimeExecution) GetPackage() string {
+	if x != nil && x.Package != nil {
+		return *x.Package
+	}
+	return ""
+}
+
+func (x *BuildTimeExecution) GetFilename() string {
+	if x != nil && x.Filename != nil {
+		return *x.Filename
+	}
+	return ""
+}
+
+func (x *BuildTimeExecution) GetLine() int64 {
+	if x != nil && x.Line != nil {
+		return *x.Line
+	}
+	return 0
+}
+
+func (x *BuildTimeExecution) GetCommand() string {
+	if x != nil && x.Command != nil {
+		return *x.Command
+	}
+	return ""
+}
+
+// AnalysisMetadata records how an analysis was run, so that saved output can
+// be interpreted and reproduced later.
+type AnalysisMetadata struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// The version of capslock, or "(devel)" if it was not built from a
+	// versioned module.
+	CapslockVersion *string `protobuf:"bytes,1,opt,name=capslock_version,json=capslockVersion" json:"capslock_version,omitempty"`
+	// The version of Go that capslock was built with.
+	GoVersion *string `protobuf:"bytes,2,opt,name=go_version,json=goVersion" json:"go_version,omitempty"`
+	// The time the analysis was run, in RFC 3339 format.
+	Timestamp *string `protobuf:"bytes,3,opt,name=timestamp" json:"timestamp,omitempty"`
+	// Whether the builtin capability map was used.
+	BuiltinCapabilityMap *bool `protobuf:"varint,4,opt,name=builtin_capability_map,json=builtinCapabilityMap" json:"builtin_capability_map,omitempty"`
+	// The custom capability map files that were used, if any.
+	CapabilityMapFiles []string `protobuf:"bytes,5,rep,name=capability_map_files,json=capabilityMapFiles" json:"capability_map_files,omitempty"`
+	// The package patterns that were analyzed.
+	PackagePatterns []string `protobuf:"bytes,6,rep,name=package_patterns,json=packagePatterns" json:"package_patterns,omitempty"`
+}
+
+func (x *AnalysisMetadata) Reset() {
+	*x = AnalysisMetadata{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_capability_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AnalysisMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalysisMetadata) ProtoMessage() {}
+
+func (x *AnalysisMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_capability_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalysisMetadata.ProtoReflect.Descriptor instead.
+func (*AnalysisMetadata) Descriptor() ([]byte, []int) {
+	return file_capability_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *AnalysisMetadata) GetCapslockVersion() string {
+	if x != nil && x.CapslockVersion != nil {
+		return *x.CapslockVersion
+	}
+	return ""
+}
+
+func (x *AnalysisMetadata) GetGoVersion() string {
+	if x != nil && x.GoVersion != nil {
+		return *x.GoVersion
+	}
+	return ""
+}
+
+func (x *AnalysisMetadata) GetTimestamp() string {
+	if x != nil && x.Timestamp != nil {
+		return *x.Timestamp
+	}
+	return ""
+}
+
+func (x *AnalysisMetadata) GetBuiltinCapabilityMap() bool {
+	if x != nil && x.BuiltinCapabilityMap != nil {
+		return *x.BuiltinCapabilityMap
+	}
+	return false
+}
+
+func (x *AnalysisMetadata) GetCapabilityMapFiles() []string {
+	if x != nil {
+		return x.CapabilityMapFiles
+	}
+	return nil
+}
+
+func (x *AnalysisMetadata) GetPackagePatterns() []string {
+	if x != nil {
+		return x.PackagePatterns
+	}
+	return nil
+}
+
 type CapabilityCountList struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -525,12 +951,14 @@ type CapabilityCountList struct {
 	// A list of capability counts.
 	CapabilityCounts map[string]int64 `protobuf:"bytes,1,rep,name=capability_counts,json=capabilityCounts" json:"capability_counts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
 	ModuleInfo       []*ModuleInfo    `protobuf:"bytes,2,rep,name=module_info,json=moduleInfo" json:"module_info,omitempty"`
+	// The go:generate directives in the analyzed packages.
+	BuildTimeExecution []*BuildTimeExecution `protobuf:"bytes,3,rep,name=build_time_execution,json=buildTimeExecution" json:"build_time_execution,omitempty"`
 }
 
 func (x *CapabilityCountList) Reset() {
 	*x = CapabilityCountList{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_capability_proto_msgTypes[5]
+		mi := &file_capability_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -543,7 +971,7 @@ func (x *CapabilityCountList) String() string {
 func (*CapabilityCountList) ProtoMessage() {}
 
 func (x *CapabilityCountList) ProtoReflect() protoreflect.Message {
-	mi := &file_capability_proto_msgTypes[5]
+	mi := &file_capability_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -556,7 +984,7 @@ func (x *CapabilityCountList) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CapabilityCountList.ProtoReflect.Descriptor instead.
 func (*CapabilityCountList) Descriptor() ([]byte, []int) {
-	return file_capability_proto_rawDescGZIP(), []int{5}
+	return file_capability_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *CapabilityCountList) GetCapabilityCounts() map[string]int64 {
@@ -573,6 +1001,13 @@ func (x *CapabilityCountList) GetModuleInfo() []*ModuleInfo {
 	return nil
 }
 
+func (x *CapabilityCountList) GetBuildTimeExecution() []*BuildTimeExecution {
+	if x != nil {
+		return x.BuildTimeExecution
+	}
+	return nil
+}
+
 type CapabilityStats struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -584,12 +1019,20 @@ type CapabilityStats struct {
 	TransitiveCount *int64      `protobuf:"varint,4,opt,name=transitive_count,json=transitiveCount" json:"transitive_count,omitempty"`
 	ExampleCallpath []*Function `protobuf:"bytes,5,rep,name=example_callpath,json=exampleCallpath" json:"example_callpath,omitempty"`
 	Count           *int64      `protobuf:"varint,6,opt,name=count" json:"count,omitempty"`
+	// Summary of the lengths of the paths counted in count, where the length
+	// of a path is the number of functions in it.  A function that uses the
+	// capability directly has a path of length 1.
+	MinPathLength    *int64 `protobuf:"varint,7,opt,name=min_path_length,json=minPathLength" json:"min_path_length,omitempty"`
+	MedianPathLength *int64 `protobuf:"varint,8,opt,name=median_path_length,json=medianPathLength" json:"median_path_length,omitempty"`
+	MaxPathLength    *int64 `protobuf:"varint,9,opt,name=max_path_length,json=maxPathLength" json:"max_path_length,omitempty"`
+	// The name of the capability, if capability is CAPABILITY_CUSTOM.
+	CustomCapability *string `protobuf:"bytes,10,opt,name=custom_capability,json=customCapability" json:"custom_capability,omitempty"`
 }
 
 func (x *CapabilityStats) Reset() {
 	*x = CapabilityStats{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_capability_proto_msgTypes[6]
+		mi := &file_capability_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -602,7 +1045,7 @@ func (x *CapabilityStats) String() string {
 func (*CapabilityStats) ProtoMessage() {}
 
 func (x *CapabilityStats) ProtoReflect() protoreflect.Message {
-	mi := &file_capability_proto_msgTypes[6]
+	mi := &file_capability_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -615,7 +1058,7 @@ func (x *CapabilityStats) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CapabilityStats.ProtoReflect.Descriptor instead.
 func (*CapabilityStats) Descriptor() ([]byte, []int) {
-	return file_capability_proto_rawDescGZIP(), []int{6}
+	return file_capability_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *CapabilityStats) GetCapability() Capability {
@@ -660,6 +1103,34 @@ func (x *CapabilityStats) GetCount() int64 {
 	return 0
 }
 
+func (x *CapabilityStats) GetMinPathLength() int64 {
+	if x != nil && x.MinPathLength != nil {
+		return *x.MinPathLength
+	}
+	return 0
+}
+
+func (x *CapabilityStats) GetMedianPathLength() int64 {
+	if x != nil && x.MedianPathLength != nil {
+		return *x.MedianPathLength
+	}
+	return 0
+}
+
+func (x *CapabilityStats) GetMaxPathLength() int64 {
+	if x != nil && x.MaxPathLength != nil {
+		return *x.MaxPathLength
+	}
+	return 0
+}
+
+func (x *CapabilityStats) GetCustomCapability() string {
+	if x != nil && x.CustomCapability != nil {
+		return *x.CustomCapability
+	}
+	return ""
+}
+
 type CapabilityStatList struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -667,12 +1138,14 @@ type CapabilityStatList struct {
 
 	CapabilityStats []*CapabilityStats `protobuf:"bytes,1,rep,name=capability_stats,json=capabilityStats" json:"capability_stats,omitempty"`
 	ModuleInfo      []*ModuleInfo      `protobuf:"bytes,2,rep,name=module_info,json=moduleInfo" json:"module_info,omitempty"`
+	// The go:generate directives in the analyzed packages.
+	BuildTimeExecution []*BuildTimeExecution `protobuf:"bytes,3,rep,name=build_time_execution,json=buildTimeExecution" json:"build_time_execution,omitempty"`
 }
 
 func (x *CapabilityStatList) Reset() {
 	*x = CapabilityStatList{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_capability_proto_msgTypes[7]
+		mi := &file_capability_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -685,7 +1158,7 @@ func (x *CapabilityStatList) String() string {
 func (*CapabilityStatList) ProtoMessage() {}
 
 func (x *CapabilityStatList) ProtoReflect() protoreflect.Message {
-	mi := &file_capability_proto_msgTypes[7]
+	mi := &file_capability_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -698,7 +1171,7 @@ func (x *CapabilityStatList) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CapabilityStatList.ProtoReflect.Descriptor instead.
 func (*CapabilityStatList) Descriptor() ([]byte, []int) {
-	return file_capability_proto_rawDescGZIP(), []int{7}
+	return file_capability_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *CapabilityStatList) GetCapabilityStats() []*CapabilityStats {
@@ -715,6 +1188,13 @@ func (x *CapabilityStatList) GetModuleInfo() []*ModuleInfo {
 	return nil
 }
 
+func (x *CapabilityStatList) GetBuildTimeExecution() []*BuildTimeExecution {
+	if x != nil {
+		return x.BuildTimeExecution
+	}
+	return nil
+}
+
 type Function_Site struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -728,7 +1208,7 @@ type Function_Site struct {
 func (x *Function_Site) Reset() {
 	*x = Function_Site{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_capability_proto_msgTypes[8]
+		mi := &file_capability_proto_msgTypes[10]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -741,7 +1221,7 @@ func (x *Function_Site) String() string {
 func (*Function_Site) ProtoMessage() {}
 
 func (x *Function_Site) ProtoReflect() protoreflect.Message {
-	mi := &file_capability_proto_msgTypes[8]
+	mi := &file_capability_proto_msgTypes[10]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -783,7 +1263,7 @@ var File_capability_proto protoreflect.FileDescriptor
 var file_capability_proto_rawDesc = []byte{
 	0x0a, 0x10, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x2e, 0x70, 0x72, 0x6f,
 	0x74, 0x6f, 0x12, 0x0e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x22, 0xa2, 0x02, 0x0a, 0x0e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74,
+	0x74, 0x6f, 0x22, 0x82, 0x05, 0x0a, 0x0e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74,
 	0x79, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x21, 0x0a, 0x0c, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65,
 	0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x70, 0x61, 0x63,
 	0x6b, 0x61, 0x67, 0x65, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x3a, 0x0a, 0x0a, 0x63, 0x61, 0x70, 0x61,
@@ -801,75 +1281,154 @@ var file_capability_proto_rawDesc = []byte{
 	0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1e, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f,
 	0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c,
 	0x69, 0x74, 0x79, 0x54, 0x79, 0x70, 0x65, 0x52, 0x0e, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c,
-	0x69, 0x74, 0x79, 0x54, 0x79, 0x70, 0x65, 0x22, 0xbb, 0x01, 0x0a, 0x08, 0x46, 0x75, 0x6e, 0x63,
+	0x69, 0x74, 0x79, 0x54, 0x79, 0x70, 0x65, 0x12, 0x36, 0x0a, 0x17, 0x72, 0x65, 0x61, 0x63, 0x68,
+	0x61, 0x62, 0x6c, 0x65, 0x5f, 0x66, 0x72, 0x6f, 0x6d, 0x5f, 0x65, 0x78, 0x70, 0x6f, 0x72, 0x74,
+	0x65, 0x64, 0x18, 0x07, 0x20, 0x01, 0x28, 0x08, 0x52, 0x15, 0x72, 0x65, 0x61, 0x63, 0x68, 0x61,
+	0x62, 0x6c, 0x65, 0x46, 0x72, 0x6f, 0x6d, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x12,
+	0x16, 0x0a, 0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x12, 0x20, 0x0a, 0x0c, 0x61, 0x74, 0x5f, 0x69, 0x6e,
+	0x69, 0x74, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x61,
+	0x74, 0x49, 0x6e, 0x69, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x67, 0x65, 0x6e,
+	0x65, 0x72, 0x61, 0x74, 0x65, 0x64, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x67, 0x65,
+	0x6e, 0x65, 0x72, 0x61, 0x74, 0x65, 0x64, 0x12, 0x2b, 0x0a, 0x11, 0x63, 0x75, 0x73, 0x74, 0x6f,
+	0x6d, 0x5f, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x18, 0x0b, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x10, 0x63, 0x75, 0x73, 0x74, 0x6f, 0x6d, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69,
+	0x6c, 0x69, 0x74, 0x79, 0x12, 0x1c, 0x0a, 0x09, 0x68, 0x65, 0x75, 0x72, 0x69, 0x73, 0x74, 0x69,
+	0x63, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x68, 0x65, 0x75, 0x72, 0x69, 0x73, 0x74,
+	0x69, 0x63, 0x12, 0x1f, 0x0a, 0x0b, 0x76, 0x69, 0x61, 0x5f, 0x72, 0x65, 0x63, 0x6f, 0x76, 0x65,
+	0x72, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x76, 0x69, 0x61, 0x52, 0x65, 0x63, 0x6f,
+	0x76, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x75, 0x6c, 0x65, 0x18, 0x0e, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x04, 0x72, 0x75, 0x6c, 0x65, 0x12, 0x25, 0x0a, 0x0e, 0x6c, 0x6f, 0x77, 0x5f, 0x63,
+	0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x0d, 0x6c, 0x6f, 0x77, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x27,
+	0x0a, 0x0f, 0x72, 0x65, 0x63, 0x75, 0x72, 0x73, 0x69, 0x76, 0x65, 0x5f, 0x63, 0x79, 0x63, 0x6c,
+	0x65, 0x18, 0x10, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0e, 0x72, 0x65, 0x63, 0x75, 0x72, 0x73, 0x69,
+	0x76, 0x65, 0x43, 0x79, 0x63, 0x6c, 0x65, 0x22, 0xf6, 0x01, 0x0a, 0x08, 0x46, 0x75, 0x6e, 0x63,
 	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01,
 	0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x31, 0x0a, 0x04, 0x73, 0x69, 0x74, 0x65,
 	0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1d, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63,
 	0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e,
 	0x2e, 0x53, 0x69, 0x74, 0x65, 0x52, 0x04, 0x73, 0x69, 0x74, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x70,
 	0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x70, 0x61,
-	0x63, 0x6b, 0x61, 0x67, 0x65, 0x1a, 0x4e, 0x0a, 0x04, 0x53, 0x69, 0x74, 0x65, 0x12, 0x1a, 0x0a,
-	0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x69, 0x6e,
-	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x16, 0x0a,
-	0x06, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x63,
-	0x6f, 0x6c, 0x75, 0x6d, 0x6e, 0x22, 0x3a, 0x0a, 0x0a, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x49,
-	0x6e, 0x66, 0x6f, 0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69,
-	0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
-	0x6e, 0x22, 0x46, 0x0a, 0x0b, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x49, 0x6e, 0x66, 0x6f,
-	0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
-	0x70, 0x61, 0x74, 0x68, 0x12, 0x23, 0x0a, 0x0d, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x64, 0x5f,
-	0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0c, 0x69, 0x67, 0x6e,
-	0x6f, 0x72, 0x65, 0x64, 0x46, 0x69, 0x6c, 0x65, 0x73, 0x22, 0xda, 0x01, 0x0a, 0x12, 0x43, 0x61,
-	0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x49, 0x6e, 0x66, 0x6f, 0x4c, 0x69, 0x73, 0x74,
-	0x12, 0x47, 0x0a, 0x0f, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x5f, 0x69,
-	0x6e, 0x66, 0x6f, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x63, 0x61, 0x70, 0x73,
-	0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x62,
-	0x69, 0x6c, 0x69, 0x74, 0x79, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0e, 0x63, 0x61, 0x70, 0x61, 0x62,
-	0x69, 0x6c, 0x69, 0x74, 0x79, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x3b, 0x0a, 0x0b, 0x6d, 0x6f, 0x64,
-	0x75, 0x6c, 0x65, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a,
-	0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e,
-	0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75,
-	0x6c, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x3e, 0x0a, 0x0c, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67,
-	0x65, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63,
-	0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x50, 0x61,
-	0x63, 0x6b, 0x61, 0x67, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0b, 0x70, 0x61, 0x63, 0x6b, 0x61,
-	0x67, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x22, 0xff, 0x01, 0x0a, 0x13, 0x43, 0x61, 0x70, 0x61, 0x62,
-	0x69, 0x6c, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x66,
-	0x0a, 0x11, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x5f, 0x63, 0x6f, 0x75,
-	0x6e, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x39, 0x2e, 0x63, 0x61, 0x70, 0x73,
-	0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x62,
-	0x69, 0x6c, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x2e, 0x43,
-	0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x45,
-	0x6e, 0x74, 0x72, 0x79, 0x52, 0x10, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79,
-	0x43, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x12, 0x3b, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65,
-	0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x61,
-	0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4d, 0x6f, 0x64,
-	0x75, 0x6c, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x49,
-	0x6e, 0x66, 0x6f, 0x1a, 0x43, 0x0a, 0x15, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74,
-	0x79, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
-	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
-	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x76,
-	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x98, 0x02, 0x0a, 0x0f, 0x43, 0x61, 0x70,
-	0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x3a, 0x0a, 0x0a,
-	0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e,
-	0x32, 0x1a, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x52, 0x0a, 0x63, 0x61,
-	0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63,
-	0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64,
-	0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x21, 0x0a, 0x0c, 0x64, 0x69,
-	0x72, 0x65, 0x63, 0x74, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03,
-	0x52, 0x0b, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x29, 0x0a,
-	0x10, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x69, 0x74, 0x69, 0x76, 0x65, 0x5f, 0x63, 0x6f, 0x75, 0x6e,
-	0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x69, 0x74,
-	0x69, 0x76, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x43, 0x0a, 0x10, 0x65, 0x78, 0x61, 0x6d,
-	0x70, 0x6c, 0x65, 0x5f, 0x63, 0x61, 0x6c, 0x6c, 0x70, 0x61, 0x74, 0x68, 0x18, 0x05, 0x20, 0x03,
-	0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x2e, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0f, 0x65, 0x78,
-	0x61, 0x6d, 0x70, 0x6c, 0x65, 0x43, 0x61, 0x6c, 0x6c, 0x70, 0x61, 0x74, 0x68, 0x12, 0x14, 0x0a,
-	0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x63, 0x6f,
-	0x75, 0x6e, 0x74, 0x22, 0x9d, 0x01, 0x0a, 0x12, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69,
+	0x63, 0x6b, 0x61, 0x67, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x12, 0x21, 0x0a,
+	0x0c, 0x69, 0x6e, 0x5f, 0x67, 0x6f, 0x72, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x65, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0b, 0x69, 0x6e, 0x47, 0x6f, 0x72, 0x6f, 0x75, 0x74, 0x69, 0x6e, 0x65,
+	0x1a, 0x4e, 0x0a, 0x04, 0x53, 0x69, 0x74, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69, 0x6c, 0x65,
+	0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69, 0x6c, 0x65,
+	0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x63, 0x6f, 0x6c, 0x75,
+	0x6d, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x06, 0x63, 0x6f, 0x6c, 0x75, 0x6d, 0x6e,
+	0x22, 0x3a, 0x0a, 0x0a, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x12,
+	0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61,
+	0x74, 0x68, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x46, 0x0a, 0x0b,
+	0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x12, 0x0a, 0x04, 0x70,
+	0x61, 0x74, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x70, 0x61, 0x74, 0x68, 0x12,
+	0x23, 0x0a, 0x0d, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x64, 0x5f, 0x66, 0x69, 0x6c, 0x65, 0x73,
+	0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0c, 0x69, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x64, 0x46,
+	0x69, 0x6c, 0x65, 0x73, 0x22, 0xa4, 0x03, 0x0a, 0x12, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c,
+	0x69, 0x74, 0x79, 0x49, 0x6e, 0x66, 0x6f, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x47, 0x0a, 0x0f, 0x63,
+	0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79,
+	0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0e, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79,
+	0x49, 0x6e, 0x66, 0x6f, 0x12, 0x3b, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f, 0x69,
+	0x6e, 0x66, 0x6f, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x61, 0x70, 0x73,
+	0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c,
+	0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x49, 0x6e, 0x66,
+	0x6f, 0x12, 0x3e, 0x0a, 0x0c, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x5f, 0x69, 0x6e, 0x66,
+	0x6f, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f,
+	0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x50, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65,
+	0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0b, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x49, 0x6e, 0x66,
+	0x6f, 0x12, 0x34, 0x0a, 0x16, 0x75, 0x6e, 0x72, 0x65, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e,
+	0x5f, 0x63, 0x61, 0x6c, 0x6c, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x14, 0x75, 0x6e, 0x72, 0x65, 0x77, 0x72, 0x69, 0x74, 0x74, 0x65, 0x6e, 0x43, 0x61,
+	0x6c, 0x6c, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x3c, 0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64,
+	0x61, 0x74, 0x61, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x63, 0x61, 0x70, 0x73,
+	0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x41, 0x6e, 0x61, 0x6c, 0x79,
+	0x73, 0x69, 0x73, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x08, 0x6d, 0x65, 0x74,
+	0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x54, 0x0a, 0x14, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x5f, 0x74,
+	0x69, 0x6d, 0x65, 0x5f, 0x65, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x45, 0x78,
+	0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x12, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x54, 0x69,
+	0x6d, 0x65, 0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x78, 0x0a, 0x12, 0x42,
+	0x75, 0x69, 0x6c, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x66,
+	0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66,
+	0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x63,
+	0x6f, 0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x63, 0x6f,
+	0x6d, 0x6d, 0x61, 0x6e, 0x64, 0x22, 0x8d, 0x02, 0x0a, 0x10, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x73,
+	0x69, 0x73, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x29, 0x0a, 0x10, 0x63, 0x61,
+	0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x56, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x67, 0x6f, 0x5f, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x67, 0x6f, 0x56, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d,
+	0x70, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x12, 0x34, 0x0a, 0x16, 0x62, 0x75, 0x69, 0x6c, 0x74, 0x69, 0x6e, 0x5f, 0x63, 0x61,
+	0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x5f, 0x6d, 0x61, 0x70, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x14, 0x62, 0x75, 0x69, 0x6c, 0x74, 0x69, 0x6e, 0x43, 0x61, 0x70, 0x61, 0x62,
+	0x69, 0x6c, 0x69, 0x74, 0x79, 0x4d, 0x61, 0x70, 0x12, 0x30, 0x0a, 0x14, 0x63, 0x61, 0x70, 0x61,
+	0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x5f, 0x6d, 0x61, 0x70, 0x5f, 0x66, 0x69, 0x6c, 0x65, 0x73,
+	0x18, 0x05, 0x20, 0x03, 0x28, 0x09, 0x52, 0x12, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69,
+	0x74, 0x79, 0x4d, 0x61, 0x70, 0x46, 0x69, 0x6c, 0x65, 0x73, 0x12, 0x29, 0x0a, 0x10, 0x70, 0x61,
+	0x63, 0x6b, 0x61, 0x67, 0x65, 0x5f, 0x70, 0x61, 0x74, 0x74, 0x65, 0x72, 0x6e, 0x73, 0x18, 0x06,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x0f, 0x70, 0x61, 0x63, 0x6b, 0x61, 0x67, 0x65, 0x50, 0x61, 0x74,
+	0x74, 0x65, 0x72, 0x6e, 0x73, 0x22, 0xd5, 0x02, 0x0a, 0x13, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69,
+	0x6c, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x66, 0x0a,
+	0x11, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x5f, 0x63, 0x6f, 0x75, 0x6e,
+	0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x39, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c,
+	0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69,
+	0x6c, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x2e, 0x43, 0x61,
+	0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x10, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x43,
+	0x6f, 0x75, 0x6e, 0x74, 0x73, 0x12, 0x3b, 0x0a, 0x0b, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x5f,
+	0x69, 0x6e, 0x66, 0x6f, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x61, 0x70,
+	0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4d, 0x6f, 0x64, 0x75,
+	0x6c, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x49, 0x6e,
+	0x66, 0x6f, 0x12, 0x54, 0x0a, 0x14, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x5f, 0x74, 0x69, 0x6d, 0x65,
+	0x5f, 0x65, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x22, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x2e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x45, 0x78, 0x65, 0x63, 0x75,
+	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x12, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x45,
+	0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x1a, 0x43, 0x0a, 0x15, 0x43, 0x61, 0x70, 0x61,
+	0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x73, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x03, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xc3, 0x03,
+	0x0a, 0x0f, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x53, 0x74, 0x61, 0x74,
+	0x73, 0x12, 0x3a, 0x0a, 0x0a, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1a, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74,
+	0x79, 0x52, 0x0a, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x20, 0x0a,
+	0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x21, 0x0a, 0x0c, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x43, 0x6f, 0x75,
+	0x6e, 0x74, 0x12, 0x29, 0x0a, 0x10, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x69, 0x74, 0x69, 0x76, 0x65,
+	0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x74, 0x72,
+	0x61, 0x6e, 0x73, 0x69, 0x74, 0x69, 0x76, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x43, 0x0a,
+	0x10, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x63, 0x61, 0x6c, 0x6c, 0x70, 0x61, 0x74,
+	0x68, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f,
+	0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x46, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f,
+	0x6e, 0x52, 0x0f, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x43, 0x61, 0x6c, 0x6c, 0x70, 0x61,
+	0x74, 0x68, 0x12, 0x14, 0x0a, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x26, 0x0a, 0x0f, 0x6d, 0x69, 0x6e, 0x5f,
+	0x70, 0x61, 0x74, 0x68, 0x5f, 0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x18, 0x07, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x0d, 0x6d, 0x69, 0x6e, 0x50, 0x61, 0x74, 0x68, 0x4c, 0x65, 0x6e, 0x67, 0x74, 0x68,
+	0x12, 0x2c, 0x0a, 0x12, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x6e, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x5f,
+	0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x10, 0x6d, 0x65,
+	0x64, 0x69, 0x61, 0x6e, 0x50, 0x61, 0x74, 0x68, 0x4c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x12, 0x26,
+	0x0a, 0x0f, 0x6d, 0x61, 0x78, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x5f, 0x6c, 0x65, 0x6e, 0x67, 0x74,
+	0x68, 0x18, 0x09, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x6d, 0x61, 0x78, 0x50, 0x61, 0x74, 0x68,
+	0x4c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x12, 0x2b, 0x0a, 0x11, 0x63, 0x75, 0x73, 0x74, 0x6f, 0x6d,
+	0x5f, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x18, 0x0a, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x10, 0x63, 0x75, 0x73, 0x74, 0x6f, 0x6d, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c,
+	0x69, 0x74, 0x79, 0x22, 0xf3, 0x01, 0x0a, 0x12, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69,
 	0x74, 0x79, 0x53, 0x74, 0x61, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x4a, 0x0a, 0x10, 0x63, 0x61,
 	0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x73, 0x18, 0x01,
 	0x20, 0x03, 0x28, 0x0b, 0x32, 0x1f, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e,
@@ -879,42 +1438,82 @@ var file_capability_proto_rawDesc = []byte{
 	0x5f, 0x69, 0x6e, 0x66, 0x6f, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x63, 0x61,
 	0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4d, 0x6f, 0x64,
 	0x75, 0x6c, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x0a, 0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x49,
-	0x6e, 0x66, 0x6f, 0x2a, 0xa6, 0x03, 0x0a, 0x0a, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69,
-	0x74, 0x79, 0x12, 0x1a, 0x0a, 0x16, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59,
-	0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x13,
-	0x0a, 0x0f, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x53, 0x41, 0x46,
-	0x45, 0x10, 0x01, 0x12, 0x14, 0x0a, 0x10, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54,
-	0x59, 0x5f, 0x46, 0x49, 0x4c, 0x45, 0x53, 0x10, 0x02, 0x12, 0x16, 0x0a, 0x12, 0x43, 0x41, 0x50,
-	0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x4e, 0x45, 0x54, 0x57, 0x4f, 0x52, 0x4b, 0x10,
-	0x03, 0x12, 0x16, 0x0a, 0x12, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f,
-	0x52, 0x55, 0x4e, 0x54, 0x49, 0x4d, 0x45, 0x10, 0x04, 0x12, 0x20, 0x0a, 0x1c, 0x43, 0x41, 0x50,
-	0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x52, 0x45, 0x41, 0x44, 0x5f, 0x53, 0x59, 0x53,
-	0x54, 0x45, 0x4d, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x45, 0x10, 0x05, 0x12, 0x22, 0x0a, 0x1e, 0x43,
-	0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x4d, 0x4f, 0x44, 0x49, 0x46, 0x59,
-	0x5f, 0x53, 0x59, 0x53, 0x54, 0x45, 0x4d, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x45, 0x10, 0x06, 0x12,
-	0x1f, 0x0a, 0x1b, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x4f, 0x50,
-	0x45, 0x52, 0x41, 0x54, 0x49, 0x4e, 0x47, 0x5f, 0x53, 0x59, 0x53, 0x54, 0x45, 0x4d, 0x10, 0x07,
-	0x12, 0x1b, 0x0a, 0x17, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x53,
-	0x59, 0x53, 0x54, 0x45, 0x4d, 0x5f, 0x43, 0x41, 0x4c, 0x4c, 0x53, 0x10, 0x08, 0x12, 0x22, 0x0a,
-	0x1e, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x41, 0x52, 0x42, 0x49,
-	0x54, 0x52, 0x41, 0x52, 0x59, 0x5f, 0x45, 0x58, 0x45, 0x43, 0x55, 0x54, 0x49, 0x4f, 0x4e, 0x10,
-	0x09, 0x12, 0x12, 0x0a, 0x0e, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f,
-	0x43, 0x47, 0x4f, 0x10, 0x0a, 0x12, 0x19, 0x0a, 0x15, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c,
-	0x49, 0x54, 0x59, 0x5f, 0x55, 0x4e, 0x41, 0x4e, 0x41, 0x4c, 0x59, 0x5a, 0x45, 0x44, 0x10, 0x0b,
-	0x12, 0x1d, 0x0a, 0x19, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x55,
-	0x4e, 0x53, 0x41, 0x46, 0x45, 0x5f, 0x50, 0x4f, 0x49, 0x4e, 0x54, 0x45, 0x52, 0x10, 0x0c, 0x12,
-	0x16, 0x0a, 0x12, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x52, 0x45,
-	0x46, 0x4c, 0x45, 0x43, 0x54, 0x10, 0x0d, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x41, 0x50, 0x41, 0x42,
-	0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x45, 0x58, 0x45, 0x43, 0x10, 0x0e, 0x2a, 0x6d, 0x0a, 0x0e,
-	0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1f,
-	0x0a, 0x1b, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x54, 0x59, 0x50,
-	0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12,
-	0x1a, 0x0a, 0x16, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x54, 0x59,
-	0x50, 0x45, 0x5f, 0x44, 0x49, 0x52, 0x45, 0x43, 0x54, 0x10, 0x01, 0x12, 0x1e, 0x0a, 0x1a, 0x43,
-	0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x54,
-	0x52, 0x41, 0x4e, 0x53, 0x49, 0x54, 0x49, 0x56, 0x45, 0x10, 0x02, 0x42, 0x22, 0x5a, 0x20, 0x67,
-	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
-	0x2f, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x6e, 0x66, 0x6f, 0x12, 0x54, 0x0a, 0x14, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x5f, 0x74, 0x69, 0x6d,
+	0x65, 0x5f, 0x65, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x22, 0x2e, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x2e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x45, 0x78, 0x65, 0x63,
+	0x75, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x12, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x54, 0x69, 0x6d, 0x65,
+	0x45, 0x78, 0x65, 0x63, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x2a, 0xc4, 0x07, 0x0a, 0x0a, 0x43, 0x61,
+	0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x12, 0x1a, 0x0a, 0x16, 0x43, 0x41, 0x50, 0x41,
+	0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49,
+	0x45, 0x44, 0x10, 0x00, 0x12, 0x13, 0x0a, 0x0f, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49,
+	0x54, 0x59, 0x5f, 0x53, 0x41, 0x46, 0x45, 0x10, 0x01, 0x12, 0x14, 0x0a, 0x10, 0x43, 0x41, 0x50,
+	0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x46, 0x49, 0x4c, 0x45, 0x53, 0x10, 0x02, 0x12,
+	0x16, 0x0a, 0x12, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x4e, 0x45,
+	0x54, 0x57, 0x4f, 0x52, 0x4b, 0x10, 0x03, 0x12, 0x16, 0x0a, 0x12, 0x43, 0x41, 0x50, 0x41, 0x42,
+	0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x52, 0x55, 0x4e, 0x54, 0x49, 0x4d, 0x45, 0x10, 0x04, 0x12,
+	0x20, 0x0a, 0x1c, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x52, 0x45,
+	0x41, 0x44, 0x5f, 0x53, 0x59, 0x53, 0x54, 0x45, 0x4d, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x45, 0x10,
+	0x05, 0x12, 0x22, 0x0a, 0x1e, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f,
+	0x4d, 0x4f, 0x44, 0x49, 0x46, 0x59, 0x5f, 0x53, 0x59, 0x53, 0x54, 0x45, 0x4d, 0x5f, 0x53, 0x54,
+	0x41, 0x54, 0x45, 0x10, 0x06, 0x12, 0x1f, 0x0a, 0x1b, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c,
+	0x49, 0x54, 0x59, 0x5f, 0x4f, 0x50, 0x45, 0x52, 0x41, 0x54, 0x49, 0x4e, 0x47, 0x5f, 0x53, 0x59,
+	0x53, 0x54, 0x45, 0x4d, 0x10, 0x07, 0x12, 0x1b, 0x0a, 0x17, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49,
+	0x4c, 0x49, 0x54, 0x59, 0x5f, 0x53, 0x59, 0x53, 0x54, 0x45, 0x4d, 0x5f, 0x43, 0x41, 0x4c, 0x4c,
+	0x53, 0x10, 0x08, 0x12, 0x22, 0x0a, 0x1e, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54,
+	0x59, 0x5f, 0x41, 0x52, 0x42, 0x49, 0x54, 0x52, 0x41, 0x52, 0x59, 0x5f, 0x45, 0x58, 0x45, 0x43,
+	0x55, 0x54, 0x49, 0x4f, 0x4e, 0x10, 0x09, 0x12, 0x12, 0x0a, 0x0e, 0x43, 0x41, 0x50, 0x41, 0x42,
+	0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x43, 0x47, 0x4f, 0x10, 0x0a, 0x12, 0x19, 0x0a, 0x15, 0x43,
+	0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x55, 0x4e, 0x41, 0x4e, 0x41, 0x4c,
+	0x59, 0x5a, 0x45, 0x44, 0x10, 0x0b, 0x12, 0x1d, 0x0a, 0x19, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49,
+	0x4c, 0x49, 0x54, 0x59, 0x5f, 0x55, 0x4e, 0x53, 0x41, 0x46, 0x45, 0x5f, 0x50, 0x4f, 0x49, 0x4e,
+	0x54, 0x45, 0x52, 0x10, 0x0c, 0x12, 0x16, 0x0a, 0x12, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c,
+	0x49, 0x54, 0x59, 0x5f, 0x52, 0x45, 0x46, 0x4c, 0x45, 0x43, 0x54, 0x10, 0x0d, 0x12, 0x13, 0x0a,
+	0x0f, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x45, 0x58, 0x45, 0x43,
+	0x10, 0x0e, 0x12, 0x15, 0x0a, 0x11, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59,
+	0x5f, 0x53, 0x49, 0x47, 0x4e, 0x41, 0x4c, 0x10, 0x0f, 0x12, 0x1d, 0x0a, 0x19, 0x43, 0x41, 0x50,
+	0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x4e, 0x45, 0x54, 0x57, 0x4f, 0x52, 0x4b, 0x5f,
+	0x43, 0x4c, 0x49, 0x45, 0x4e, 0x54, 0x10, 0x10, 0x12, 0x1d, 0x0a, 0x19, 0x43, 0x41, 0x50, 0x41,
+	0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x4e, 0x45, 0x54, 0x57, 0x4f, 0x52, 0x4b, 0x5f, 0x4c,
+	0x49, 0x53, 0x54, 0x45, 0x4e, 0x10, 0x11, 0x12, 0x1d, 0x0a, 0x19, 0x43, 0x41, 0x50, 0x41, 0x42,
+	0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x52, 0x55, 0x4e, 0x54, 0x49, 0x4d, 0x45, 0x5f, 0x54, 0x55,
+	0x4e, 0x49, 0x4e, 0x47, 0x10, 0x12, 0x12, 0x2f, 0x0a, 0x2b, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49,
+	0x4c, 0x49, 0x54, 0x59, 0x5f, 0x55, 0x4e, 0x53, 0x41, 0x46, 0x45, 0x5f, 0x50, 0x4f, 0x49, 0x4e,
+	0x54, 0x45, 0x52, 0x5f, 0x55, 0x49, 0x4e, 0x54, 0x50, 0x54, 0x52, 0x5f, 0x52, 0x4f, 0x55, 0x4e,
+	0x44, 0x54, 0x52, 0x49, 0x50, 0x10, 0x13, 0x12, 0x27, 0x0a, 0x23, 0x43, 0x41, 0x50, 0x41, 0x42,
+	0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x52, 0x45, 0x46, 0x4c, 0x45, 0x43, 0x54, 0x5f, 0x55, 0x4e,
+	0x45, 0x58, 0x50, 0x4f, 0x52, 0x54, 0x45, 0x44, 0x5f, 0x46, 0x49, 0x45, 0x4c, 0x44, 0x10, 0x14,
+	0x12, 0x14, 0x0a, 0x10, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x50,
+	0x41, 0x4e, 0x49, 0x43, 0x10, 0x15, 0x12, 0x1e, 0x0a, 0x1a, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49,
+	0x4c, 0x49, 0x54, 0x59, 0x5f, 0x52, 0x45, 0x56, 0x49, 0x45, 0x57, 0x5f, 0x52, 0x45, 0x51, 0x55,
+	0x49, 0x52, 0x45, 0x44, 0x10, 0x16, 0x12, 0x20, 0x0a, 0x1c, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49,
+	0x4c, 0x49, 0x54, 0x59, 0x5f, 0x53, 0x59, 0x53, 0x54, 0x45, 0x4d, 0x5f, 0x43, 0x41, 0x4c, 0x4c,
+	0x53, 0x5f, 0x4d, 0x4d, 0x41, 0x50, 0x10, 0x17, 0x12, 0x19, 0x0a, 0x15, 0x43, 0x41, 0x50, 0x41,
+	0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x45, 0x58, 0x45, 0x43, 0x5f, 0x53, 0x48, 0x45, 0x4c,
+	0x4c, 0x10, 0x18, 0x12, 0x22, 0x0a, 0x1e, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54,
+	0x59, 0x5f, 0x47, 0x4c, 0x4f, 0x42, 0x41, 0x4c, 0x5f, 0x52, 0x45, 0x47, 0x49, 0x53, 0x54, 0x52,
+	0x41, 0x54, 0x49, 0x4f, 0x4e, 0x10, 0x19, 0x12, 0x1b, 0x0a, 0x17, 0x43, 0x41, 0x50, 0x41, 0x42,
+	0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x45, 0x58, 0x45, 0x43, 0x5f, 0x52, 0x45, 0x50, 0x4c, 0x41,
+	0x43, 0x45, 0x10, 0x1a, 0x12, 0x15, 0x0a, 0x11, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49,
+	0x54, 0x59, 0x5f, 0x43, 0x55, 0x53, 0x54, 0x4f, 0x4d, 0x10, 0x1b, 0x12, 0x18, 0x0a, 0x14, 0x43,
+	0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x52, 0x45, 0x43, 0x55, 0x52, 0x53,
+	0x49, 0x4f, 0x4e, 0x10, 0x1c, 0x12, 0x27, 0x0a, 0x23, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c,
+	0x49, 0x54, 0x59, 0x5f, 0x4f, 0x50, 0x45, 0x52, 0x41, 0x54, 0x49, 0x4e, 0x47, 0x5f, 0x53, 0x59,
+	0x53, 0x54, 0x45, 0x4d, 0x5f, 0x44, 0x49, 0x53, 0x50, 0x4c, 0x41, 0x59, 0x10, 0x1d, 0x12, 0x23,
+	0x0a, 0x1f, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x4e, 0x45, 0x54,
+	0x57, 0x4f, 0x52, 0x4b, 0x5f, 0x49, 0x4e, 0x53, 0x45, 0x43, 0x55, 0x52, 0x45, 0x5f, 0x54, 0x4c,
+	0x53, 0x10, 0x1e, 0x12, 0x1b, 0x0a, 0x17, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54,
+	0x59, 0x5f, 0x46, 0x49, 0x4c, 0x45, 0x53, 0x5f, 0x44, 0x45, 0x56, 0x49, 0x43, 0x45, 0x10, 0x1f,
+	0x2a, 0x6d, 0x0a, 0x0e, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x79, 0x54, 0x79,
+	0x70, 0x65, 0x12, 0x1f, 0x0a, 0x1b, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59,
+	0x5f, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43, 0x49, 0x46, 0x49, 0x45,
+	0x44, 0x10, 0x00, 0x12, 0x1a, 0x0a, 0x16, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54,
+	0x59, 0x5f, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x44, 0x49, 0x52, 0x45, 0x43, 0x54, 0x10, 0x01, 0x12,
+	0x1e, 0x0a, 0x1a, 0x43, 0x41, 0x50, 0x41, 0x42, 0x49, 0x4c, 0x49, 0x54, 0x59, 0x5f, 0x54, 0x59,
+	0x50, 0x45, 0x5f, 0x54, 0x52, 0x41, 0x4e, 0x53, 0x49, 0x54, 0x49, 0x56, 0x45, 0x10, 0x02, 0x42,
+	0x22, 0x5a, 0x20, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x63, 0x61, 0x70, 0x73, 0x6c, 0x6f, 0x63, 0x6b, 0x2f, 0x70, 0x72,
+	0x6f, 0x74, 0x6f,
 }
 
 var (
@@ -930,7 +1529,7 @@ func file_capability_proto_rawDescGZIP() []byte {
 }
 
 var file_capability_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_capability_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_capability_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_capability_proto_goTypes = []interface{}{
 	(Capability)(0),             // 0: capslock.proto.Capability
 	(CapabilityType)(0),         // 1: capslock.proto.CapabilityType
@@ -939,31 +1538,37 @@ var file_capability_proto_goTypes = []interface{}{
 	(*ModuleInfo)(nil),          // 4: capslock.proto.ModuleInfo
 	(*PackageInfo)(nil),         // 5: capslock.proto.PackageInfo
 	(*CapabilityInfoList)(nil),  // 6: capslock.proto.CapabilityInfoList
-	(*CapabilityCountList)(nil), // 7: capslock.proto.CapabilityCountList
-	(*CapabilityStats)(nil),     // 8: capslock.proto.CapabilityStats
-	(*CapabilityStatList)(nil),  // 9: capslock.proto.CapabilityStatList
-	(*Function_Site)(nil),       // 10: capslock.proto.Function.Site
-	nil,                         // 11: capslock.proto.CapabilityCountList.CapabilityCountsEntry
+	(*BuildTimeExecution)(nil),  // 7: capslock.proto.BuildTimeExecution
+	(*AnalysisMetadata)(nil),    // 8: capslock.proto.AnalysisMetadata
+	(*CapabilityCountList)(nil), // 9: capslock.proto.CapabilityCountList
+	(*CapabilityStats)(nil),     // 10: capslock.proto.CapabilityStats
+	(*CapabilityStatList)(nil),  // 11: capslock.proto.CapabilityStatList
+	(*Function_Site)(nil),       // 12: capslock.proto.Function.Site
+	nil,                         // 13: capslock.proto.CapabilityCountList.CapabilityCountsEntry
 }
 var file_capability_proto_depIdxs = []int32{
 	0,  // 0: capslock.proto.CapabilityInfo.capability:type_name -> capslock.proto.Capability
 	3,  // 1: capslock.proto.CapabilityInfo.path:type_name -> capslock.proto.Function
 	1,  // 2: capslock.proto.CapabilityInfo.capability_type:type_name -> capslock.proto.CapabilityType
-	10, // 3: capslock.proto.Function.site:type_name -> capslock.proto.Function.Site
+	12, // 3: capslock.proto.Function.site:type_name -> capslock.proto.Function.Site
 	2,  // 4: capslock.proto.CapabilityInfoList.capability_info:type_name -> capslock.proto.CapabilityInfo
 	4,  // 5: capslock.proto.CapabilityInfoList.module_info:type_name -> capslock.proto.ModuleInfo
 	5,  // 6: capslock.proto.CapabilityInfoList.package_info:type_name -> capslock.proto.PackageInfo
-	11, // 7: capslock.proto.CapabilityCountList.capability_counts:type_name -> capslock.proto.CapabilityCountList.CapabilityCountsEntry
-	4,  // 8: capslock.proto.CapabilityCountList.module_info:type_name -> capslock.proto.ModuleInfo
-	0,  // 9: capslock.proto.CapabilityStats.capability:type_name -> capslock.proto.Capability
-	3,  // 10: capslock.proto.CapabilityStats.example_callpath:type_name -> capslock.proto.Function
-	8,  // 11: capslock.proto.CapabilityStatList.capability_stats:type_name -> capslock.proto.CapabilityStats
-	4,  // 12: capslock.proto.CapabilityStatList.module_info:type_name -> capslock.proto.ModuleInfo
-	13, // [13:13] is the sub-list for method output_type
-	13, // [13:13] is the sub-list for method input_type
-	13, // [13:13] is the sub-list for extension type_name
-	13, // [13:13] is the sub-list for extension extendee
-	0,  // [0:13] is the sub-list for field type_name
+	8,  // 7: capslock.proto.CapabilityInfoList.metadata:type_name -> capslock.proto.AnalysisMetadata
+	7,  // 8: capslock.proto.CapabilityInfoList.build_time_execution:type_name -> capslock.proto.BuildTimeExecution
+	13, // 9: capslock.proto.CapabilityCountList.capability_counts:type_name -> capslock.proto.CapabilityCountList.CapabilityCountsEntry
+	4,  // 10: capslock.proto.CapabilityCountList.module_info:type_name -> capslock.proto.ModuleInfo
+	7,  // 11: capslock.proto.CapabilityCountList.build_time_execution:type_name -> capslock.proto.BuildTimeExecution
+	0,  // 12: capslock.proto.CapabilityStats.capability:type_name -> capslock.proto.Capability
+	3,  // 13: capslock.proto.CapabilityStats.example_callpath:type_name -> capslock.proto.Function
+	10, // 14: capslock.proto.CapabilityStatList.capability_stats:type_name -> capslock.proto.CapabilityStats
+	4,  // 15: capslock.proto.CapabilityStatList.module_info:type_name -> capslock.proto.ModuleInfo
+	7,  // 16: capslock.proto.CapabilityStatList.build_time_execution:type_name -> capslock.proto.BuildTimeExecution
+	17, // [17:17] is the sub-list for method output_type
+	17, // [17:17] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
 }
 
 func init() { file_capability_proto_init() }
@@ -1033,7 +1638,7 @@ func file_capability_proto_init() {
 			}
 		}
 		file_capability_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CapabilityCountList); i {
+			switch v := v.(*BuildTimeExecution); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1045,7 +1650,7 @@ func file_capability_proto_init() {
 			}
 		}
 		file_capability_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CapabilityStats); i {
+			switch v := v.(*AnalysisMetadata); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1057,7 +1662,7 @@ func file_capability_proto_init() {
 			}
 		}
 		file_capability_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CapabilityStatList); i {
+			switch v := v.(*CapabilityCountList); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -1069,6 +1674,30 @@ func file_capability_proto_init() {
 			}
 		}
 		file_capability_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CapabilityStats); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_capability_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CapabilityStatList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_capability_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*Function_Site); i {
 			case 0:
 				return &v.state
@@ -1087,7 +1716,7 @@ func file_capability_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_capability_proto_rawDesc,
 			NumEnums:      2,
-			NumMessages:   10,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -7,16 +7,25 @@
 package analyzepackages_test
 
 import (
+	"archive/zip"
 	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"log"
+	"maps"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/capslock/interesting"
 	cpb "github.com/google/capslock/proto"
 	"google.golang.org/protobuf/encoding/protojson"
 )
@@ -120,6 +129,11 @@ func TestExpectedOutput(t *testing.T) {
 		{Fn: []string{"importname.CallTheWrongSort", "os.ReadFile"}},
 		{Fn: []string{`indirectcalls.AccessMethodViaTypeAssertion`, `\(\*os.File\).Chown`}},
 		{Fn: []string{"indirectcalls.CallOs", "os.Getuid"}},
+		// MaybeChmod type-asserts an io.Reader parameter to an interface whose
+		// method set contains Chmod, so that (*os.File).Chmod can be called if
+		// the user passes an argument with dynamic type *os.File.  No code in
+		// our testdata does this, but a user of the package could.
+		{Fn: []string{`indirectcalls.MaybeChmod`, `\(\*os.File\).Chmod`}},
 		{Fn: []string{"indirectcalls.CallOsViaFuncVariable", "os.Getuid"}},
 		{Fn: []string{"indirectcalls.CallOsViaInterfaceMethod", "os.Getuid"}},
 		{Fn: []string{"indirectcalls.CallOsViaStructField", "os.Getuid"}},
@@ -128,7 +142,7 @@ func TestExpectedOutput(t *testing.T) {
 		{Fn: []string{"initfn.init"}, Cap: "CAPABILITY_UNSAFE_POINTER"},
 		{Fn: []string{"initfn.init", "net.LookupIP"}},
 		{Fn: []string{"initfn.init", "os.Getpid"}},
-		{Fn: []string{"initfn.init", "runtime/debug.SetMaxThreads"}},
+		{Fn: []string{"initfn.init", "runtime/debug.SetMaxThreads"}, Cap: "CAPABILITY_RUNTIME_TUNING"},
 		{Fn: []string{"transitive.Asm", "useasm.Foo", "useasm.bar"}},
 		{Fn: []string{`transitive.CallGenericFunction`, `usegenerics.Foo\[.*/transitive.a\]`, `\(.*/transitive.a\).Baz`, `net.Dial`}},
 		{Fn: []string{`transitive.CallGenericFunction`, `usegenerics.Foo\[.*/transitive.a\]`, `os.Rename`}},
@@ -145,6 +159,9 @@ func TestExpectedOutput(t *testing.T) {
 		{Fn: []string{"transitive.InterestingSortSlice"}, Cap: "CAPABILITY_READ_SYSTEM_STATE"},
 		{Fn: []string{"transitive.InterestingSortSliceNested"}, Cap: "CAPABILITY_READ_SYSTEM_STATE"},
 		{Fn: []string{"transitive.InterestingSortSliceStable"}, Cap: "CAPABILITY_READ_SYSTEM_STATE"},
+		{Fn: []string{"transitive.InterestingSortFunc"}, Cap: "CAPABILITY_READ_SYSTEM_STATE"},
+		{Fn: []string{"transitive.InterestingSortFuncNested"}, Cap: "CAPABILITY_READ_SYSTEM_STATE"},
+		{Fn: []string{"transitive.InterestingSortStableFunc"}, Cap: "CAPABILITY_READ_SYSTEM_STATE"},
 		{Fn: []string{"transitive.InterestingSyncPool"}, Cap: "CAPABILITY_UNANALYZED"},
 		{Fn: []string{"transitive.Linkname", "uselinkname.Foo", "uselinkname.runtime_fastrand64"}, Cap: "CAPABILITY_ARBITRARY_EXECUTION"},
 		{Fn: []string{"transitive.MultipleCapabilities", "usecgo._cgo_runtime_cgocall"}},
@@ -158,7 +175,7 @@ func TestExpectedOutput(t *testing.T) {
 		{Fn: []string{"transitive.init", "initfn.init"}, Cap: "CAPABILITY_UNSAFE_POINTER"},
 		{Fn: []string{"transitive.init", "initfn.init", "net.LookupIP"}},
 		{Fn: []string{"transitive.init", "initfn.init", "os.Getpid"}},
-		{Fn: []string{"transitive.init", "initfn.init", "runtime/debug.SetMaxThreads"}},
+		{Fn: []string{"transitive.init", "initfn.init", "runtime/debug.SetMaxThreads"}, Cap: "CAPABILITY_RUNTIME_TUNING"},
 		{Fn: []string{"useasm.Foo", "useasm.bar"}},
 		{Fn: []string{"useasm.bar"}, Cap: "CAPABILITY_ARBITRARY_EXECUTION"},
 		{Fn: []string{"usecgo.CallCBytes", ""}},
@@ -168,13 +185,27 @@ func TestExpectedOutput(t *testing.T) {
 		{Fn: []string{"usecgo.CallGoStringN", ""}},
 		{Fn: []string{"usecgo.Foo", "usecgo._cgo_runtime_cgocall"}},
 		{Fn: []string{"usecgo._Cfunc_acfunction", "usecgo._cgo_runtime_cgocall"}},
+		{Fn: []string{`usedefer.CallDeferInterfaceParameter`, `usedefer.DeferInterfaceParameter`, `usedefer.pidCloser\).Close`, `os.Getpid`}},
+		{Fn: []string{`usedefer.DeferClosure`, `usedefer.DeferClosure\$1`, `os.Getpid`}},
+		{Fn: []string{`usedefer.DeferFuncValue`, `os.Getuid`}},
+		{Fn: []string{`usedefer.DeferInRangeOverFunc`, `usedefer.pidCloser\).Close`, `os.Getpid`}},
+		{Fn: []string{`usedefer.DeferInterfaceCall`, `usedefer.pidCloser\).Close`, `os.Getpid`}},
 		{Fn: []string{`usegenerics.Bar`, `usegenerics.Foo\[.*/usegenerics.a\]`, `\(.*/usegenerics.a\).Baz`, `net.Interfaces`}},
 		{Fn: []string{`usegenerics.Bar`, `usegenerics.Foo\[.*/usegenerics.a\]`, `os.Rename`}},
 		{Fn: []string{`usegenerics.a\).Baz`, `net.Interfaces`}},
 		{Fn: []string{`usegenerics.CallNestedFunction`, `usegenerics.NestedFunction\[.*/usegenerics.a\]\$1`, `\(.*/usegenerics.a\).Baz`, `net.Interfaces`}},
+		{Fn: []string{"usehttp.Client", "net/http.Get"}, Cap: "CAPABILITY_NETWORK_CLIENT"},
+		{Fn: []string{"usehttp.Dial", "net.Dial"}, Cap: "CAPABILITY_NETWORK_CLIENT"},
+		{Fn: []string{"usehttp.Listen", "net.Listen"}, Cap: "CAPABILITY_NETWORK_LISTEN"},
+		{Fn: []string{"usehttp.Server", "net/http.ListenAndServe"}, Cap: "CAPABILITY_NETWORK_LISTEN"},
 		{Fn: []string{"uselinkname.CallExplicitlyCategorizedFunction", "syscall.Getpagesize"}, Cap: "CAPABILITY_SYSTEM_CALLS"},
 		{Fn: []string{"uselinkname.Foo", "uselinkname.runtime_fastrand64"}, Cap: "CAPABILITY_ARBITRARY_EXECUTION"},
 		{Fn: []string{"uselinkname.runtime_fastrand64"}, Cap: "CAPABILITY_ARBITRARY_EXECUTION"},
+		{Fn: []string{"uselog.Default", "log.Printf"}, Cap: "CAPABILITY_OPERATING_SYSTEM"},
+		{Fn: []string{"uselog.RedirectToFile", `\(\*os.File\).Write`}, Cap: "CAPABILITY_FILES"},
+		{Fn: []string{"uselog.ToFile", `\(\*os.File\).Write`}, Cap: "CAPABILITY_FILES"},
+		{Fn: []string{"uselog.ToStderr", `\(\*os.File\).Write`}, Cap: "CAPABILITY_FILES"},
+		{Fn: []string{"usereflect.CallViaReflect", "callnet.Foo", "net.LookupIP"}, Cap: "CAPABILITY_NETWORK"},
 		{Fn: []string{`usereflect.CopyValueConcurrently\$1`}, Cap: `CAPABILITY_REFLECT`},
 		{Fn: []string{`usereflect.CopyValueConcurrently\$2`}, Cap: `CAPABILITY_REFLECT`},
 		{Fn: []string{`usereflect.CopyValueConcurrently`, `usereflect.CopyValueConcurrently\$[12]`}},
@@ -188,6 +219,20 @@ func TestExpectedOutput(t *testing.T) {
 		{Fn: []string{`usereflect.RangeValueTwo\$1`}, Cap: `CAPABILITY_REFLECT`},
 		{Fn: []string{`usereflect.RangeValueTwo\$2`}, Cap: `CAPABILITY_REFLECT`},
 		{Fn: []string{`usereflect.RangeValueTwo`, `usereflect.RangeValueTwo\$[12]`}},
+		{Fn: []string{"useregistration.Handle", "net/http.HandleFunc"}, Cap: "CAPABILITY_GLOBAL_REGISTRATION"},
+		{Fn: []string{"useregistration.init", "encoding/gob.Register"}, Cap: "CAPABILITY_GLOBAL_REGISTRATION"},
+		{Fn: []string{"usereflect.ReadUnexportedField"}, Cap: "CAPABILITY_REFLECT_UNEXPORTED_FIELD"},
+		{Fn: []string{"usedevice.Random$"}, Cap: "CAPABILITY_FILES_DEVICE"},
+		{Fn: []string{"usedevice.Status$"}, Cap: "CAPABILITY_FILES_DEVICE"},
+		{Fn: []string{"usedevice.ReadStatus$"}, Cap: "CAPABILITY_FILES_DEVICE"},
+		{Fn: []string{"usedevice.SetPrintk$"}, Cap: "CAPABILITY_FILES_DEVICE"},
+		{Fn: []string{"usedevice.CreateNull$"}, Cap: "CAPABILITY_FILES_DEVICE"},
+		{Fn: []string{"usedevice.Processes$"}, Cap: "CAPABILITY_FILES_DEVICE"},
+		{Fn: []string{"useinsecuretls.Assignment$"}, Cap: "CAPABILITY_NETWORK_INSECURE_TLS"},
+		{Fn: []string{"useinsecuretls.Literal$"}, Cap: "CAPABILITY_NETWORK_INSECURE_TLS"},
+		{Fn: []string{"usesignal.Foo", "os/signal.Notify"}, Cap: "CAPABILITY_SIGNAL"},
+		{Fn: []string{"usesql.Foo", "database/sql.Open"}, Cap: "CAPABILITY_NETWORK"},
+		{Fn: []string{"useunsafe.Add"}, Cap: "CAPABILITY_UNSAFE_POINTER"},
 		{Fn: []string{"useunsafe.Bar"}, Cap: "CAPABILITY_UNSAFE_POINTER"},
 		{Fn: []string{"useunsafe.Baz"}, Cap: "CAPABILITY_UNSAFE_POINTER"},
 		{Fn: []string{`useunsafe.CallNestedFunctions`, `useunsafe.NestedFunctions\$1\$1\$1`}},
@@ -196,9 +241,23 @@ func TestExpectedOutput(t *testing.T) {
 		{Fn: []string{`useunsafe.Indirect2`, `useunsafe.init\$1`}},
 		{Fn: []string{`useunsafe.NestedFunctions\$1\$1\$1`}, Cap: `CAPABILITY_UNSAFE_POINTER`},
 		{Fn: []string{`useunsafe.ReturnFunction\$1`}, Cap: `CAPABILITY_UNSAFE_POINTER`},
+		{Fn: []string{"useunsafe.Roundtrip"}, Cap: "CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP"},
+		{Fn: []string{"useunsafe.Slice"}, Cap: "CAPABILITY_UNSAFE_POINTER"},
 		{Fn: []string{`useunsafe.T\).M`}, Cap: "CAPABILITY_UNSAFE_POINTER"},
 		{Fn: []string{`useunsafe.init$`}, Cap: `CAPABILITY_UNSAFE_POINTER`},
 		{Fn: []string{`useunsafe.init\$1`}, Cap: `CAPABILITY_UNSAFE_POINTER`},
+		{Fn: []string{"usewriter.FprintfFile", `\(\*os.File\).Write`}, Cap: "CAPABILITY_FILES"},
+		{Fn: []string{"usewriter.FprintfStdout", `\(\*os.File\).Write`}, Cap: "CAPABILITY_FILES"},
+		{Fn: []string{"usewriter.WriteFile", "usewriter.write", `\(\*os.File\).Write`}, Cap: "CAPABILITY_FILES"},
+		{Fn: []string{"usewriter.WriteStdout", "usewriter.write", `\(\*os.File\).Write`}, Cap: "CAPABILITY_FILES"},
+		{Fn: []string{"usecontext.Env$", `usecontext.EnvContext\).Value`, "os.Getenv"}, Cap: "CAPABILITY_READ_SYSTEM_STATE"},
+	}
+	if runtime.GOOS == "linux" {
+		// usemmap and useexecreplace only call syscall.Mmap and syscall.Exec
+		// when built for linux.
+		expectedPaths = append(expectedPaths,
+			expectedPath{Fn: []string{"usemmap.Map", "syscall.Mmap"}, Cap: "CAPABILITY_SYSTEM_CALLS_MMAP"},
+			expectedPath{Fn: []string{"useexecreplace.Replace", "syscall.Exec"}, Cap: "CAPABILITY_EXEC_REPLACE"})
 	}
 	for _, path := range expectedPaths {
 		if matches, err := path.matches(cil); err != nil {
@@ -216,11 +275,19 @@ func TestExpectedOutput(t *testing.T) {
 		{Fn: []string{"uselinkname.CallExplicitlyCategorizedFunction", "syscall.Getpagesize"}, Cap: "CAPABILITY_ARBITRARY_EXECUTION"},
 		{Fn: []string{"useunsafe.Ok"}, Cap: "CAPABILITY_UNSAFE_POINTER"},
 		{Fn: []string{"useunsafe.ReturnFunction$"}, Cap: "CAPABILITY_UNSAFE_POINTER"},
+		{Fn: []string{"useunsafe.Roundtrip"}, Cap: "CAPABILITY_UNSAFE_POINTER"},
+		{Fn: []string{"useunsafe.Ok"}, Cap: "CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP"},
 		{Fn: []string{"usegenerics.AtomicPointer"}},
+		{Fn: []string{"usedevice.Ordinary$"}, Cap: "CAPABILITY_FILES_DEVICE"},
+		{Fn: []string{"usedevice.ReadOrdinary$"}, Cap: "CAPABILITY_FILES_DEVICE"},
+		{Fn: []string{"useinsecuretls.Secure$"}, Cap: "CAPABILITY_NETWORK_INSECURE_TLS"},
+		{Fn: []string{"usecontext.Cancel", ".*"}},
+		{Fn: []string{"usecontext.NewEnv", ".*"}},
 
 		// Currently we don't include functions called by these functions.
 		{Fn: []string{"^sort.Sort", ".*"}}, // need ^ to avoid matching notsort.go
 		{Fn: []string{"sort.Slice", ".*"}},
+		{Fn: []string{"slices.SortFunc", ".*"}},
 		{Fn: []string{`\(\*sync.Once\).Do`, ".*"}},
 		{Fn: []string{`\(\*sync.Pool\).Get`, ".*"}},
 
@@ -234,6 +301,9 @@ func TestExpectedOutput(t *testing.T) {
 		{Fn: []string{"transitive.UninterestingSortSlice", ".*"}},
 		{Fn: []string{"transitive.UninterestingSortSliceNested", ".*"}},
 		{Fn: []string{"transitive.UninterestingSortSliceStable", ".*"}},
+		{Fn: []string{"transitive.UninterestingSortFunc", ".*"}},
+		{Fn: []string{"transitive.UninterestingSortFuncNested", ".*"}},
+		{Fn: []string{"transitive.UninterestingSortStableFunc", ".*"}},
 
 		// These functions copy reflect.Value objects, but the destinations are
 		// only local variables which do not escape, so we do not need to warn
@@ -246,13 +316,7 @@ func TestExpectedOutput(t *testing.T) {
 		{Fn: []string{"usereflect.CopyValueInMultipleAssignment$"}},
 		{Fn: []string{"usereflect.CopyValueInStructField$"}},
 		{Fn: []string{"usereflect.RangeValue$"}},
-
-		// MaybeChmod type-asserts an io.Reader parameter to an interface whose
-		// method set contains Chmod, so that (*os.File).Chmod can be called if
-		// the user passes an argument with dynamic type *os.File.  No code in
-		// our testdata does this, so we do not warn about this code having an
-		// interesting capability, but perhaps it would be good to do so.
-		{Fn: []string{`indirectcalls.MaybeChmod`, `\(*os.File\).Chmod`}},
+		{Fn: []string{"usereflect.ReadExportedField"}, Cap: "CAPABILITY_REFLECT_UNEXPORTED_FIELD"},
 	}
 	for _, path := range unexpectedPaths {
 		if matches, err := path.matches(cil); err != nil {
@@ -268,13 +332,17 @@ func TestExpectedOutput(t *testing.T) {
 
 func TestGraph(t *testing.T) {
 	for _, test := range []struct {
-		args      []string
+		args []string
+		// wantLines maps each expected output line, without leading and
+		// trailing space, to the number of times it should appear, or to 0 if
+		// it should appear once.
 		wantLines map[string]int
 	}{
 		{
 			[]string{"-packages=../testpkgs/useunsafe", "-output=graph"},
 			map[string]int{
 				`digraph {`: 0,
+				`"github.com/google/capslock/testpkgs/useunsafe.Add" -> "CAPABILITY_UNSAFE_POINTER"`:                                                           0,
 				`"github.com/google/capslock/testpkgs/useunsafe.Bar" -> "CAPABILITY_UNSAFE_POINTER"`:                                                           0,
 				`"github.com/google/capslock/testpkgs/useunsafe.Baz" -> "CAPABILITY_UNSAFE_POINTER"`:                                                           0,
 				`"github.com/google/capslock/testpkgs/useunsafe.CallNestedFunctions" -> "github.com/google/capslock/testpkgs/useunsafe.NestedFunctions$1$1$1"`: 0,
@@ -285,8 +353,26 @@ func TestGraph(t *testing.T) {
 				`"github.com/google/capslock/testpkgs/useunsafe.init" -> "CAPABILITY_UNSAFE_POINTER"`:                                                          0,
 				`"github.com/google/capslock/testpkgs/useunsafe.NestedFunctions$1$1$1" -> "CAPABILITY_UNSAFE_POINTER"`:                                         0,
 				`"github.com/google/capslock/testpkgs/useunsafe.ReturnFunction$1" -> "CAPABILITY_UNSAFE_POINTER"`:                                              0,
+				`"github.com/google/capslock/testpkgs/useunsafe.Roundtrip" -> "CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP"`:                                   0,
+				`"github.com/google/capslock/testpkgs/useunsafe.Slice" -> "CAPABILITY_UNSAFE_POINTER"`:                                                         0,
 				`"(github.com/google/capslock/testpkgs/useunsafe.T).M" -> "CAPABILITY_UNSAFE_POINTER"`:                                                         0,
-				`}`: 0,
+				`subgraph "cluster_0" {`:                                                0,
+				`label="github.com/google/capslock"`:                                    0,
+				`"github.com/google/capslock/testpkgs/useunsafe.Add"`:                   0,
+				`"github.com/google/capslock/testpkgs/useunsafe.Bar"`:                   0,
+				`"github.com/google/capslock/testpkgs/useunsafe.Baz"`:                   0,
+				`"github.com/google/capslock/testpkgs/useunsafe.CallNestedFunctions"`:   0,
+				`"github.com/google/capslock/testpkgs/useunsafe.Foo"`:                   0,
+				`"github.com/google/capslock/testpkgs/useunsafe.Indirect"`:              0,
+				`"github.com/google/capslock/testpkgs/useunsafe.Indirect2"`:             0,
+				`"github.com/google/capslock/testpkgs/useunsafe.NestedFunctions$1$1$1"`: 0,
+				`"github.com/google/capslock/testpkgs/useunsafe.ReturnFunction$1"`:      0,
+				`"github.com/google/capslock/testpkgs/useunsafe.Roundtrip"`:             0,
+				`"github.com/google/capslock/testpkgs/useunsafe.Slice"`:                 0,
+				`"(github.com/google/capslock/testpkgs/useunsafe.T).M"`:                 0,
+				`"github.com/google/capslock/testpkgs/useunsafe.init"`:                  0,
+				`"github.com/google/capslock/testpkgs/useunsafe.init$1"`:                0,
+				`}`: 2,
 			},
 		},
 		{
@@ -297,7 +383,11 @@ func TestGraph(t *testing.T) {
 				`"github.com/google/capslock/testpkgs/callos.Foo" -> "os.Getpid"`:       0,
 				`"os/user.Current" -> "CAPABILITY_READ_SYSTEM_STATE"`:                   0,
 				`"os.Getpid" -> "CAPABILITY_READ_SYSTEM_STATE"`:                         0,
-				`}`: 0,
+				`subgraph "cluster_0" {`:                                                0,
+				`label="github.com/google/capslock"`:                                    0,
+				`"github.com/google/capslock/testpkgs/callos.Baz"`:                      0,
+				`"github.com/google/capslock/testpkgs/callos.Foo"`:                      0,
+				`}`: 2,
 			},
 		},
 		{
@@ -308,7 +398,37 @@ func TestGraph(t *testing.T) {
 				`"github.com/google/capslock/testpkgs/callos.Bar" -> "(*os/exec.Cmd).Run"`: 0,
 				`"os/exec.Command" -> "CAPABILITY_EXEC"`:                                   0,
 				`"(*os/exec.Cmd).Run" -> "CAPABILITY_EXEC"`:                                0,
-				`}`: 0,
+				`subgraph "cluster_0" {`:                                                   0,
+				`label="github.com/google/capslock"`:                                       0,
+				`"github.com/google/capslock/testpkgs/callos.Bar"`:                         0,
+				`}`: 2,
+			},
+		},
+		{
+			[]string{"-packages=../testpkgs/callos", "-output=mermaid", "-capabilities=READ_SYSTEM_STATE,NETWORK"},
+			map[string]int{
+				`flowchart LR`: 0,
+				`n0["github.com/google/capslock/testpkgs/callos.Baz"] --> n1["os/user.Current"]`: 0,
+				`n2["github.com/google/capslock/testpkgs/callos.Foo"] --> n3["os.Getpid"]`:       0,
+				`n1 --> n4["CAPABILITY_READ_SYSTEM_STATE"]`:                                      0,
+				`n3 --> n4`: 0,
+				`subgraph cluster_0 ["github.com/google/capslock"]`: 0,
+				`n0`:  0,
+				`n2`:  0,
+				`end`: 0,
+			},
+		},
+		{
+			[]string{"-packages=../testpkgs/initfn", "-output=mermaid", "-capabilities=NETWORK"},
+			map[string]int{
+				`flowchart LR`: 0,
+				`n0["github.com/google/capslock/testpkgs/initfn.init"] --> n1["github.com/google/capslock/testpkgs/initfn.init#35;1"]`: 0,
+				`n1 --> n2["net.LookupIP"]`:                         0,
+				`n2 --> n3["CAPABILITY_NETWORK"]`:                   0,
+				`subgraph cluster_0 ["github.com/google/capslock"]`: 0,
+				`n0`:  0,
+				`n1`:  0,
+				`end`: 0,
 			},
 		},
 		{
@@ -344,9 +464,12 @@ func TestGraph(t *testing.T) {
 			}
 			gotLines[s]++
 		}
-		for s := range test.wantLines {
-			if c := gotLines[s]; c != 1 {
-				t.Errorf("TestGraph(%q): got output line %q %d times, want 1", test.args, s, c)
+		for s, want := range test.wantLines {
+			if want == 0 {
+				want = 1
+			}
+			if c := gotLines[s]; c != want {
+				t.Errorf("TestGraph(%q): got output line %q %d times, want %d", test.args, s, c, want)
 				failed = true
 			}
 		}
@@ -377,6 +500,7 @@ func TestCompare(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	b1 := b
 	f1, err, done := mktemp(b)
 	if err != nil {
 		t.Fatalf("Creating first temporary file: %v", err)
@@ -392,25 +516,90 @@ func TestCompare(t *testing.T) {
 	}
 	defer done()
 
+	// Make two more temporary files which each contain part of the expected
+	// output, so that only their union matches.
+	cil := new(cpb.CapabilityInfoList)
+	if err := protojson.Unmarshal(b1, cil); err != nil {
+		t.Fatalf("Couldn't parse analyzer output: %v", err)
+	}
+	var parts [2]*cpb.CapabilityInfoList
+	for i := range parts {
+		parts[i] = new(cpb.CapabilityInfoList)
+	}
+	for _, ci := range cil.GetCapabilityInfo() {
+		i := 0
+		if strings.Contains(ci.GetPackageDir(), "callruntime") {
+			i = 1
+		}
+		parts[i].CapabilityInfo = append(parts[i].CapabilityInfo, ci)
+	}
+	var partFiles [2]string
+	for i, part := range parts {
+		b, err := protojson.Marshal(part)
+		if err != nil {
+			t.Fatalf("Marshaling partial output: %v", err)
+		}
+		var done func()
+		partFiles[i], err, done = mktemp(b)
+		if err != nil {
+			t.Fatalf("Creating temporary file: %v", err)
+		}
+		defer done()
+	}
+
 	for _, test := range []struct {
-		diffFile         string
+		diffFiles        []string
 		granularity      string
+		quiet            bool
+		ignoreRemovals   bool
+		capabilities     string
 		expectedExitCode int
 		expectedOutput   []string
 	}{
-		{f1, "package", 0, nil},
-		{f1, "function", 0, nil},
-		{f2, "package", 1, []string{
+		{[]string{f1}, "package", false, false, "", 0, nil},
+		{[]string{f1}, "function", false, false, "", 0, nil},
+		{[]string{f2}, "package", false, false, "", 1, []string{
 			"callruntime has new capability CAPABILITY_RUNTIME",
 			"callruntime2 no longer has capability CAPABILITY_RUNTIME",
 		}},
-		{f2, "function", 1, []string{
+		{[]string{f2}, "function", false, false, "", 1, []string{
 			"callruntime.Interesting has new capability CAPABILITY_RUNTIME",
 			"callruntime2.Interesting no longer has capability CAPABILITY_RUNTIME",
 		}},
-		{"../testpkgs/notthere", "package", 2, nil},
+		{[]string{f2}, "package", true, false, "", 1, nil},
+		{partFiles[:], "package", false, false, "", 0, nil},
+		{partFiles[:], "function", false, false, "", 0, nil},
+		{partFiles[:1], "package", false, false, "", 1, []string{
+			"callruntime has new capability CAPABILITY_RUNTIME",
+		}},
+		{[]string{f2}, "package", false, false, "CAPABILITY_NETWORK,CAPABILITY_EXEC", 0, nil},
+		{[]string{f2}, "package", false, false, "-CAPABILITY_RUNTIME", 0, nil},
+		{[]string{f2}, "package", false, false, "CAPABILITY_RUNTIME", 1, []string{
+			"callruntime has new capability CAPABILITY_RUNTIME",
+		}},
+		{[]string{f1, f2}, "package", false, false, "", 1, []string{
+			"callruntime2 no longer has capability CAPABILITY_RUNTIME",
+		}},
+		{[]string{f1, f2}, "package", false, true, "", 0, []string{
+			"callruntime2 no longer has capability CAPABILITY_RUNTIME",
+		}},
+		{[]string{f2}, "package", false, true, "", 1, []string{
+			"callruntime has new capability CAPABILITY_RUNTIME",
+			"callruntime2 no longer has capability CAPABILITY_RUNTIME",
+		}},
+		{[]string{"../testpkgs/notthere"}, "package", false, false, "", 2, nil},
 	} {
-		cmd := exec.Command(bin, "-packages=../testpkgs/...", "-granularity="+test.granularity, "-output=compare", test.diffFile)
+		args := []string{"-packages=../testpkgs/...", "-granularity=" + test.granularity, "-output=compare"}
+		if test.quiet {
+			args = append(args, "-quiet")
+		}
+		if test.ignoreRemovals {
+			args = append(args, "-ignore-removals")
+		}
+		if test.capabilities != "" {
+			args = append(args, "-capabilities="+test.capabilities)
+		}
+		cmd := exec.Command(bin, append(args, test.diffFiles...)...)
 		var output bytes.Buffer
 		cmd.Stdout = &output
 		err := cmd.Run()
@@ -430,6 +619,9 @@ func TestCompare(t *testing.T) {
 		if t.Failed() {
 			continue
 		}
+		if test.quiet && output.Len() != 0 {
+			t.Errorf("%v: got output %q, want none", test, output.String())
+		}
 		// Check that any expected lines are present in the diff output.
 		lines := strings.Split(output.String(), "\n")
 		for _, expected := range test.expectedOutput {
@@ -447,3 +639,1189 @@ func TestCompare(t *testing.T) {
 		}
 	}
 }
+
+func TestGenmap(t *testing.T) {
+	for _, test := range []struct {
+		granularity   string
+		expectedLines []string
+	}{
+		{"package", []string{
+			"^package .*/testpkgs/callnet CAPABILITY_NETWORK$",
+			"^package .*/testpkgs/usesignal CAPABILITY_SIGNAL$",
+		}},
+		{"function", []string{
+			"^func .*/testpkgs/callnet.Foo CAPABILITY_NETWORK$",
+			"^func .*/testpkgs/usesignal.Foo CAPABILITY_SIGNAL$",
+		}},
+	} {
+		cmd := exec.Command(bin, "-packages=../testpkgs/...", "-granularity="+test.granularity, "-output=genmap")
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		if err := cmd.Run(); err != nil {
+			t.Errorf("%v: running capslock: %v", test, err)
+			continue
+		}
+		// The output should be a valid capability map.
+		if _, err := interesting.LoadClassifier("genmap", bytes.NewReader(output.Bytes()), true); err != nil {
+			t.Errorf("%v: loading generated capability map: %v", test, err)
+		}
+		lines := strings.Split(output.String(), "\n")
+		for _, expected := range test.expectedLines {
+			ok := false
+			for _, line := range lines {
+				if matches, err := regexp.MatchString(expected, line); matches {
+					ok = true
+				} else if err != nil {
+					t.Errorf("parsing expression %q: %v", expected, err)
+				}
+			}
+			if !ok {
+				t.Errorf("%v: expected output line %q", test, expected)
+			}
+		}
+	}
+}
+
+func TestSkipErrors(t *testing.T) {
+	for _, test := range []struct {
+		packages         string
+		skipErrors       bool
+		expectedExitCode int
+		expectedOutput   string
+	}{
+		{"./testdata/skiperrors/...", false, 2, ""},
+		// The analysis runs, but the exit status reports the skipped packages.
+		// usesbroken, which depends on the broken package, is skipped too, so
+		// its CAPABILITY_FILES is not reported.
+		{"./testdata/skiperrors/...", true, 3, "CAPABILITY_READ_SYSTEM_STATE\n"},
+		// A package whose dependency is broken cannot be analyzed.
+		{"./testdata/skiperrors/usesbroken", true, 2, ""},
+	} {
+		args := []string{"-packages=" + test.packages, "-output=m"}
+		if test.skipErrors {
+			args = append(args, "-skip-errors")
+		}
+		cmd := exec.Command(bin, args...)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		err := cmd.Run()
+		switch err := err.(type) {
+		case nil:
+			if got, want := 0, test.expectedExitCode; got != want {
+				t.Errorf("%v: got exit code %d, want %d", test, got, want)
+			}
+		case *exec.ExitError:
+			if got, want := err.ExitCode(), test.expectedExitCode; got != want {
+				t.Errorf("%v: got exit code %d, want %d", test, got, want)
+			}
+		default:
+			t.Errorf("%v: running capslock: %v", test, err)
+		}
+		if got, want := output.String(), test.expectedOutput; got != want {
+			t.Errorf("%v: got output %q, want %q", test, got, want)
+		}
+	}
+}
+
+func TestOutputFile(t *testing.T) {
+	want, err := analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "output.json")
+	cmd := exec.Command(bin, "-packages=../testpkgs/...", "-output=json", "-o", name)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	if output.Len() != 0 {
+		t.Errorf("got output %q on stdout, want none", output.String())
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatalf("reading output file: %v", err)
+	}
+	// The runs may have been at different times.
+	timestamp := regexp.MustCompile(`"timestamp":\s*"[^"]*"`)
+	got = timestamp.ReplaceAll(got, nil)
+	want = timestamp.ReplaceAll(want, nil)
+	if !bytes.Equal(got, want) {
+		t.Errorf("output file differs from standard output of -output=json")
+	}
+}
+
+func TestVendor(t *testing.T) {
+	// Create a module whose only dependency is vendored, and check that it can
+	// be analyzed without network access.
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.21\n\nrequire example.com/dep v1.0.0\n",
+		"m.go": `package m
+
+import "example.com/dep"
+
+func Foo() { dep.Foo() }
+`,
+		"vendor/modules.txt": "# example.com/dep v1.0.0\n## explicit\nexample.com/dep\n",
+		"vendor/example.com/dep/dep.go": `package dep
+
+import "os"
+
+func Foo() { os.Getenv("HOME") }
+`,
+	} {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		packages         string
+		expectedExitCode int
+		expectedOutput   string
+	}{
+		{"./...", 0, "CAPABILITY_READ_SYSTEM_STATE\n"},
+		// Packages outside the module are not fetched into a temporary module.
+		{"example.com/other", 2, ""},
+	} {
+		cmd := exec.Command(bin, "-packages="+test.packages, "-output=m")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOFLAGS=", "GOPROXY=off", "GOWORK=off")
+		var output, stderr bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		switch err := err.(type) {
+		case nil:
+			if got, want := 0, test.expectedExitCode; got != want {
+				t.Errorf("%v: got exit code %d, want %d", test, got, want)
+			}
+		case *exec.ExitError:
+			if got, want := err.ExitCode(), test.expectedExitCode; got != want {
+				t.Errorf("%v: got exit code %d, want %d; stderr: %s", test, got, want, stderr.String())
+			}
+		default:
+			t.Errorf("%v: running capslock: %v", test, err)
+		}
+		if got, want := output.String(), test.expectedOutput; got != want {
+			t.Errorf("%v: got output %q, want %q", test, got, want)
+		}
+		if strings.Contains(stderr.String(), "temporary module") {
+			t.Errorf("%v: capslock tried a temporary module: %s", test, stderr.String())
+		}
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	cmd := exec.Command(bin, "-packages=../testpkgs/callnet,../testpkgs/usesignal", "-output=matrix")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	records, err := csv.NewReader(&output).ReadAll()
+	if err != nil {
+		t.Fatalf("parsing output as CSV: %v", err)
+	}
+	want := [][]string{
+		{"module", "CAPABILITY_NETWORK", "CAPABILITY_SIGNAL"},
+		{"github.com/google/capslock", "x", "x"},
+	}
+	if !slices.EqualFunc(records, want, slices.Equal) {
+		t.Errorf("got matrix %q, want %q", records, want)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	for _, test := range []struct {
+		output, packages, want string
+	}{
+		{"summary", "../testpkgs/callnet", "capslock: 1 capability (0 dangerous)\n"},
+		{"summary", "../testpkgs/callnet,../testpkgs/callos", "capslock: 3 capabilities (1 dangerous)\n"},
+		{"badge", "../testpkgs/callnet,../testpkgs/callos",
+			`{"schemaVersion":1,"label":"capslock","message":"3 capabilities (1 dangerous)","color":"red"}` + "\n"},
+	} {
+		out, err := exec.Command(bin, "-packages="+test.packages, "-output="+test.output).Output()
+		if err != nil {
+			t.Fatalf("running capslock: %v", err)
+		}
+		if got := string(out); got != test.want {
+			t.Errorf("capslock -packages=%s -output=%s: got %q, want %q", test.packages, test.output, got, test.want)
+		}
+	}
+}
+
+func TestSeverityFlag(t *testing.T) {
+	const packages = "-packages=../testpkgs/callnet,../testpkgs/callos"
+	out, err := exec.Command(bin, packages, "-output=summary", "-severity=NETWORK=high").Output()
+	if err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	if got, want := string(out), "capslock: 3 capabilities (2 dangerous)\n"; got != want {
+		t.Errorf("capslock -output=summary -severity=NETWORK=high: got %q, want %q", got, want)
+	}
+	out, err = exec.Command(bin, packages, "-color=always", "-severity=NETWORK=high,EXEC=low").Output()
+	if err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	for _, want := range []string{
+		"\x1b[91mCAPABILITY_NETWORK\x1b[0m",
+		"\x1b[92mCAPABILITY_EXEC\x1b[0m",
+		"\x1b[92mCAPABILITY_READ_SYSTEM_STATE\x1b[0m",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("capslock -color=always -severity=NETWORK=high,EXEC=low: output does not contain %q:\n%s", want, out)
+		}
+	}
+	if err := exec.Command(bin, packages, "-severity=NETWORK=severe").Run(); err == nil {
+		t.Errorf("capslock -severity=NETWORK=severe: got no error")
+	}
+}
+
+func TestBuildTimeExecution(t *testing.T) {
+	cmd := exec.Command(bin, "-packages=../testpkgs/usegenerate", "-output=json")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	cil := new(cpb.CapabilityInfoList)
+	if err := protojson.Unmarshal(output.Bytes(), cil); err != nil {
+		t.Fatalf("Couldn't parse analyzer output: %v", err)
+	}
+	var got []string
+	for _, b := range cil.GetBuildTimeExecution() {
+		got = append(got, fmt.Sprintf("%s %s:%d: %s", b.GetPackage(), b.GetFilename(), b.GetLine(), b.GetCommand()))
+	}
+	want := []string{
+		`github.com/google/capslock/testpkgs/usegenerate usegenerate.go:10: echo "generating colors"`,
+		`github.com/google/capslock/testpkgs/usegenerate usegenerate.go:11: go version`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got build-time execution %q, want %q", got, want)
+	}
+	out, err := exec.Command(bin, "-packages=../testpkgs/usegenerate", "-color=never").Output()
+	if err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	if want := "Build-time execution (go:generate):\n  " + want[0] + "\n"; !strings.Contains(string(out), want) {
+		t.Errorf("default output does not contain %q:\n%s", want, out)
+	}
+}
+
+func TestListPackages(t *testing.T) {
+	out, err := exec.Command(bin, "-packages=../testpkgs/callos,../testpkgs/callnet", "-list-packages").Output()
+	if err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	var got [][]string
+	for _, line := range strings.Split(strings.TrimSuffix(string(out), "\n"), "\n") {
+		got = append(got, strings.Fields(line))
+	}
+	want := [][]string{
+		{"github.com/google/capslock/testpkgs/callnet", "github.com/google/capslock", "local", "workspace"},
+		{"github.com/google/capslock/testpkgs/callos", "github.com/google/capslock", "local", "workspace"},
+	}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("capslock -list-packages: got %q, want %q", got, want)
+	}
+}
+
+func TestCallgraphOutput(t *testing.T) {
+	const foo = "github.com/google/capslock/testpkgs/callnet.Foo"
+	out, err := exec.Command(bin, "-packages=../testpkgs/callnet", "-output=callgraph", "-callgraph-reachable").Output()
+	if err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	if want := "\t\"" + foo + "\" -> \"net.LookupIP\"\n"; !strings.HasPrefix(string(out), "digraph {\n") || !strings.Contains(string(out), want) {
+		t.Errorf("-output=callgraph: got output without edge %q", want)
+	}
+	out, err = exec.Command(bin, "-packages=../testpkgs/callnet", "-output=callgraph-json", "-callgraph-reachable").Output()
+	if err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	var graph struct {
+		Nodes []struct{ Name, Package string }
+		Edges []struct{ Caller, Callee, Position string }
+	}
+	if err := json.Unmarshal(out, &graph); err != nil {
+		t.Fatalf("parsing -output=callgraph-json: %v", err)
+	}
+	if !slices.ContainsFunc(graph.Nodes, func(n struct{ Name, Package string }) bool {
+		return n.Name == foo && n.Package == "github.com/google/capslock/testpkgs/callnet"
+	}) {
+		t.Errorf("-output=callgraph-json: got no node for %s", foo)
+	}
+	if !slices.ContainsFunc(graph.Edges, func(e struct{ Caller, Callee, Position string }) bool {
+		return e.Caller == foo && e.Callee == "net.LookupIP" && strings.Contains(e.Position, "callnet.go:")
+	}) {
+		t.Errorf("-output=callgraph-json: got no edge from %s to net.LookupIP with its position", foo)
+	}
+}
+
+func TestModules(t *testing.T) {
+	analyzeOutput, err := analyze()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cil := new(cpb.CapabilityInfoList)
+	if err = protojson.Unmarshal(analyzeOutput, cil); err != nil {
+		t.Fatalf("Couldn't parse analyzer output: %v", err)
+	}
+	const module = "github.com/google/capslock"
+	for _, ci := range cil.GetCapabilityInfo() {
+		if got := ci.GetModule(); got != module {
+			t.Errorf("%s: got module %q, want %q", ci.GetDepPath(), got, module)
+		}
+		for _, fn := range ci.GetPath() {
+			want := ""
+			if strings.HasPrefix(fn.GetPackage(), module+"/") {
+				want = module
+			}
+			if got := fn.GetModule(); got != want {
+				t.Errorf("%s: got module %q for %s, want %q", ci.GetDepPath(), got, fn.GetName(), want)
+			}
+		}
+	}
+}
+
+func TestJSONCompact(t *testing.T) {
+	cmd := exec.Command(bin, "-packages=../testpkgs/callnet", "-output=json", "-json-compact")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	if n := strings.Count(output.String(), "\n"); n != 1 {
+		t.Errorf("got %d lines of output, want 1; output %q", n, output.String())
+	}
+	cil := new(cpb.CapabilityInfoList)
+	if err := protojson.Unmarshal(output.Bytes(), cil); err != nil {
+		t.Fatalf("Couldn't parse analyzer output: %v", err)
+	}
+	if got := len(cil.GetCapabilityInfo()); got != 1 {
+		t.Errorf("got %d CapabilityInfos, want 1", got)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	capabilityMap := filepath.Join(t.TempDir(), "custom.cm")
+	if err := os.WriteFile(capabilityMap, []byte("func net.Dial CAPABILITY_NETWORK\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	capabilityMap2 := filepath.Join(t.TempDir(), "custom2.cm")
+	if err := os.WriteFile(capabilityMap2, []byte("func net.Dial CAPABILITY_NETWORK_CLIENT\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		args        []string
+		wantBuiltin bool
+		wantFiles   []string
+	}{
+		{nil, true, nil},
+		{[]string{"-capability_map=" + capabilityMap}, true, []string{capabilityMap}},
+		{[]string{"-capability_map=" + capabilityMap, "-disable_builtin"}, false, []string{capabilityMap}},
+		{[]string{"-capability_map=" + capabilityMap, "-capability_map=" + capabilityMap2}, true, []string{capabilityMap, capabilityMap2}},
+	} {
+		args := append([]string{"-packages=../testpkgs/callnet", "-output=json"}, test.args...)
+		cmd := exec.Command(bin, args...)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("running capslock %q: %v", args, err)
+		}
+		cil := new(cpb.CapabilityInfoList)
+		if err := protojson.Unmarshal(output.Bytes(), cil); err != nil {
+			t.Fatalf("Couldn't parse analyzer output: %v", err)
+		}
+		md := cil.GetMetadata()
+		if md.GetCapslockVersion() == "" {
+			t.Errorf("capslock %q: got empty capslock version", args)
+		}
+		if got, want := md.GetGoVersion(), runtime.Version(); got != want {
+			t.Errorf("capslock %q: got Go version %q, want %q", args, got, want)
+		}
+		if _, err := time.Parse(time.RFC3339, md.GetTimestamp()); err != nil {
+			t.Errorf("capslock %q: parsing timestamp: %v", args, err)
+		}
+		if got := md.GetBuiltinCapabilityMap(); got != test.wantBuiltin {
+			t.Errorf("capslock %q: got builtin capability map %v, want %v", args, got, test.wantBuiltin)
+		}
+		if got := md.GetCapabilityMapFiles(); !slices.Equal(got, test.wantFiles) {
+			t.Errorf("capslock %q: got capability map files %q, want %q", args, got, test.wantFiles)
+		}
+		if got, want := md.GetPackagePatterns(), []string{"../testpkgs/callnet"}; !slices.Equal(got, want) {
+			t.Errorf("capslock %q: got package patterns %q, want %q", args, got, want)
+		}
+	}
+}
+
+func TestMarkGenerated(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want map[string]bool
+	}{
+		{nil, map[string]bool{"Handwritten": false, "Message": false, "Dial": false}},
+		{[]string{"-mark-generated"}, map[string]bool{"Handwritten": false, "Message": false, "Dial": true}},
+		{[]string{"-generated-files=*.pb.go"}, map[string]bool{"Handwritten": false, "Message": true, "Dial": true}},
+	} {
+		args := append([]string{"-packages=../testpkgs/usegenerated", "-output=json"}, test.args...)
+		cmd := exec.Command(bin, args...)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("running capslock %q: %v", args, err)
+		}
+		cil := new(cpb.CapabilityInfoList)
+		if err := protojson.Unmarshal(output.Bytes(), cil); err != nil {
+			t.Fatalf("Couldn't parse analyzer output: %v", err)
+		}
+		got := make(map[string]bool)
+		for _, ci := range cil.GetCapabilityInfo() {
+			name := ci.GetPath()[0].GetName()
+			got[name[strings.LastIndex(name, ".")+1:]] = ci.GetGenerated()
+		}
+		if !maps.Equal(got, test.want) {
+			t.Errorf("capslock %q: got generated functions %v, want %v", args, got, test.want)
+		}
+	}
+}
+
+func TestSeparateHeuristicFindings(t *testing.T) {
+	for _, test := range []struct {
+		args []string
+		want map[string]bool
+	}{
+		{nil, map[string]bool{
+			"CopyValueViaPointer CAPABILITY_REFLECT": false,
+			"ValueSetInt CAPABILITY_REFLECT":         false,
+			"CallViaReflect CAPABILITY_NETWORK":      false,
+		}},
+		{[]string{"-separate-heuristic-findings"}, map[string]bool{
+			"CopyValueViaPointer CAPABILITY_REFLECT": true,
+			"ValueSetInt CAPABILITY_REFLECT":         false,
+			"CallViaReflect CAPABILITY_NETWORK":      false,
+		}},
+	} {
+		args := append([]string{"-packages=../testpkgs/usereflect", "-output=json"}, test.args...)
+		cmd := exec.Command(bin, args...)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("running capslock %q: %v", args, err)
+		}
+		cil := new(cpb.CapabilityInfoList)
+		if err := protojson.Unmarshal(output.Bytes(), cil); err != nil {
+			t.Fatalf("Couldn't parse analyzer output: %v", err)
+		}
+		got := make(map[string]bool)
+		for _, ci := range cil.GetCapabilityInfo() {
+			name := ci.GetPath()[0].GetName()
+			key := name[strings.LastIndex(name, ".")+1:] + " " + ci.GetCapability().String()
+			if _, ok := test.want[key]; ok {
+				got[key] = ci.GetHeuristic()
+			}
+		}
+		if !maps.Equal(got, test.want) {
+			t.Errorf("capslock %q: got heuristic findings %v, want %v", args, got, test.want)
+		}
+	}
+}
+
+func TestViaRecover(t *testing.T) {
+	cmd := exec.Command(bin, "-packages=../testpkgs/userecover", "-output=json")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	cil := new(cpb.CapabilityInfoList)
+	if err := protojson.Unmarshal(output.Bytes(), cil); err != nil {
+		t.Fatalf("Couldn't parse analyzer output: %v", err)
+	}
+	got := make(map[string]bool)
+	for _, ci := range cil.GetCapabilityInfo() {
+		name := ci.GetPath()[0].GetName()
+		got[name[strings.LastIndex(name, ".")+1:]] = ci.GetViaRecover()
+	}
+	want := map[string]bool{"Cleanup": true, "Cleanup$1": true, "Run": false}
+	if !maps.Equal(got, want) {
+		t.Errorf("got functions reached via recover %v, want %v", got, want)
+	}
+}
+
+func TestInGoroutine(t *testing.T) {
+	cmd := exec.Command(bin, "-packages=../testpkgs/usegoroutine", "-output=json")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	cil := new(cpb.CapabilityInfoList)
+	if err := protojson.Unmarshal(output.Bytes(), cil); err != nil {
+		t.Fatalf("Couldn't parse analyzer output: %v", err)
+	}
+	// got maps the first function of each path to the functions in the path
+	// which are called by a go statement.
+	got := make(map[string][]string)
+	for _, ci := range cil.GetCapabilityInfo() {
+		name := ci.GetPath()[0].GetName()
+		name = name[strings.LastIndex(name, ".")+1:]
+		got[name] = []string{}
+		for _, fn := range ci.GetPath() {
+			if fn.GetInGoroutine() {
+				got[name] = append(got[name], fn.GetName())
+			}
+		}
+	}
+	want := map[string][]string{
+		"Background": {"github.com/google/capslock/testpkgs/usegoroutine.lookup"},
+		"Foreground": {},
+		"lookup":     {},
+	}
+	if !maps.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("got functions called in goroutines %v, want %v", got, want)
+	}
+}
+
+func TestIgnoreStringMethods(t *testing.T) {
+	// The path from Format passes through the call to String made by fmt, so
+	// it has low confidence, and is omitted with -ignore-string-methods.  The
+	// call to String made by Encode is always followed.  FormatAddress and
+	// EncodeAddress also call the LocalAddr method of a connection in package
+	// net directly.
+	for _, test := range []struct {
+		flag string
+		want map[string]bool
+	}{
+		{"-ignore-string-methods=false", map[string]bool{
+			"Encode":        false,
+			"EncodeAddress": false,
+			"Format":        true,
+			"FormatAddress": false,
+		}},
+		{"-ignore-string-methods", map[string]bool{
+			"Encode":        false,
+			"EncodeAddress": false,
+			"FormatAddress": false,
+		}},
+	} {
+		cmd := exec.Command(bin, "-packages=../testpkgs/usestringer", "-output=json", test.flag)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("running capslock %s: %v", test.flag, err)
+		}
+		cil := new(cpb.CapabilityInfoList)
+		if err := protojson.Unmarshal(output.Bytes(), cil); err != nil {
+			t.Fatalf("Couldn't parse analyzer output: %v", err)
+		}
+		got := make(map[string]bool)
+		for _, ci := range cil.GetCapabilityInfo() {
+			if ci.GetCapability() != cpb.Capability_CAPABILITY_NETWORK {
+				continue
+			}
+			name := ci.GetPath()[0].GetName()
+			got[name[strings.LastIndex(name, ".")+1:]] = ci.GetLowConfidence()
+		}
+		if !maps.Equal(got, test.want) {
+			t.Errorf("capslock %s: got functions with CAPABILITY_NETWORK and whether they have low confidence %v, want %v", test.flag, got, test.want)
+		}
+	}
+}
+
+func TestRelativePaths(t *testing.T) {
+	cmd := exec.Command(bin, "-packages=../testpkgs/callnet", "-output=json", "-relative-paths")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	cil := new(cpb.CapabilityInfoList)
+	if err := protojson.Unmarshal(output.Bytes(), cil); err != nil {
+		t.Fatalf("Couldn't parse analyzer output: %v", err)
+	}
+	if len(cil.GetCapabilityInfo()) != 1 {
+		t.Fatalf("got %d CapabilityInfos, want 1", len(cil.GetCapabilityInfo()))
+	}
+	ci := cil.GetCapabilityInfo()[0]
+	if got, want := ci.GetPackageDir(), "testpkgs/callnet"; got != want {
+		t.Errorf("got package directory %q, want %q", got, want)
+	}
+	if got, want := ci.GetDepPath(), "testpkgs/callnet.Foo net.LookupIP"; got != want {
+		t.Errorf("got dependency path %q, want %q", got, want)
+	}
+	if got, want := ci.GetPath()[0].GetPackage(), "testpkgs/callnet"; got != want {
+		t.Errorf("got package %q for first function in path, want %q", got, want)
+	}
+}
+
+func TestColor(t *testing.T) {
+	for _, test := range []struct {
+		mode      string
+		wantColor bool
+	}{
+		// Standard output is not a terminal, so "auto" doesn't color the output.
+		{"auto", false},
+		{"always", true},
+		{"never", false},
+	} {
+		cmd := exec.Command(bin, "-packages=../testpkgs/callnet", "-color="+test.mode)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("running capslock -color=%s: %v", test.mode, err)
+		}
+		if got := strings.Contains(output.String(), "\x1b["); got != test.wantColor {
+			t.Errorf("capslock -color=%s: got escape sequences %v, want %v; output %q", test.mode, got, test.wantColor, output.String())
+		}
+	}
+	if err := exec.Command(bin, "-packages=../testpkgs/callnet", "-color=sometimes").Run(); err == nil {
+		t.Errorf("capslock -color=sometimes: got no error")
+	}
+}
+
+func TestMachineFunctions(t *testing.T) {
+	cmd := exec.Command(bin, "-packages=../testpkgs/callnet", "-output=machine-functions")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	want := "github.com/google/capslock/testpkgs/callnet.Foo\tCAPABILITY_NETWORK\n"
+	if got := output.String(); got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	capabilityMap := filepath.Join(t.TempDir(), "severity.cm")
+	if err := os.WriteFile(capabilityMap, []byte("severity READ_SYSTEM_STATE high\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{
+			"CAPABILITY_EXEC",
+			"CAPABILITY_READ_SYSTEM_STATE",
+			"CAPABILITY_UNSAFE_POINTER",
+			"CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP",
+		}},
+		{[]string{"-sort=severity"}, []string{
+			"CAPABILITY_EXEC",
+			"CAPABILITY_UNSAFE_POINTER",
+			"CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP",
+			"CAPABILITY_READ_SYSTEM_STATE",
+		}},
+		{[]string{"-min-severity=medium"}, []string{
+			"CAPABILITY_EXEC",
+			"CAPABILITY_UNSAFE_POINTER",
+			"CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP",
+		}},
+		{[]string{"-sort=severity", "-output=verbose"}, []string{
+			"CAPABILITY_UNSAFE_POINTER",
+			"CAPABILITY_EXEC",
+			"CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP",
+			"CAPABILITY_READ_SYSTEM_STATE",
+		}},
+		{[]string{"-sort=severity", "-capability_map=" + capabilityMap}, []string{
+			"CAPABILITY_EXEC",
+			"CAPABILITY_READ_SYSTEM_STATE",
+			"CAPABILITY_UNSAFE_POINTER",
+			"CAPABILITY_UNSAFE_POINTER_UINTPTR_ROUNDTRIP",
+		}},
+	} {
+		args := append([]string{"-packages=../testpkgs/useunsafe,../testpkgs/callos", "-color=never"}, test.args...)
+		cmd := exec.Command(bin, args...)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("running capslock %q: %v", args, err)
+		}
+		var got []string
+		for _, line := range strings.Split(output.String(), "\n") {
+			if c, _, ok := strings.Cut(line, ":"); ok && strings.HasPrefix(c, "CAPABILITY_") {
+				got = append(got, c)
+			}
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("capslock %q: got capabilities %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestSortByPackage(t *testing.T) {
+	cmd := exec.Command(bin, "-packages=../testpkgs/useunsafe,../testpkgs/callos", "-output=machine-functions", "-sort=package")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	// Each package's functions should be listed together.
+	var pkgs []string
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		switch {
+		case strings.Contains(line, "testpkgs/callos."):
+			pkgs = append(pkgs, "callos")
+		case strings.Contains(line, "testpkgs/useunsafe."):
+			pkgs = append(pkgs, "useunsafe")
+		default:
+			t.Errorf("unexpected output line %q", line)
+		}
+	}
+	if got := slices.Compact(slices.Clone(pkgs)); !slices.Equal(got, []string{"callos", "useunsafe"}) {
+		t.Errorf("got packages in order %q, want them grouped by package", pkgs)
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	// Module a imports a package from module b, which is only available
+	// through the workspace.
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.work":  "go 1.21\n\nuse (\n\t./a\n\t./b\n)\n",
+		"a/go.mod": "module example.com/a\n\ngo 1.21\n",
+		"a/a.go":   "package a\n\nimport \"example.com/b\"\n\nfunc A() string { return b.B() }\n",
+		"b/go.mod": "module example.com/b\n\ngo 1.21\n",
+		"b/b.go":   "package b\n\nimport \"os\"\n\nfunc B() string { return os.Getenv(\"B\") }\n",
+	} {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, test := range []struct {
+		workspace string
+		wantErr   bool
+	}{
+		{"on", false},
+		{"off", true},
+	} {
+		cmd := exec.Command(bin, "-packages=.", "-output=m", "-force_local_module", "-workspace="+test.workspace)
+		cmd.Dir = filepath.Join(dir, "a")
+		cmd.Env = append(os.Environ(), "GOFLAGS=", "GOWORK=off", "GOPROXY=off")
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		err := cmd.Run()
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("capslock -workspace=%s: got error %v, want error %v", test.workspace, err, test.wantErr)
+			continue
+		}
+		if err == nil && !strings.Contains(output.String(), "CAPABILITY_READ_SYSTEM_STATE") {
+			t.Errorf("capslock -workspace=%s: got output %q, want CAPABILITY_READ_SYSTEM_STATE", test.workspace, output.String())
+		}
+	}
+}
+
+func TestMultipleModules(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a/go.mod": "module example.com/a\n\ngo 1.21\n",
+		"a/a.go":   "package a\n\nimport \"os\"\n\nfunc A() string { return os.Getenv(\"A\") }\n",
+		"b/go.mod": "module example.com/b\n\ngo 1.21\n",
+		"b/b.go":   "package b\n\nimport \"os/exec\"\n\nfunc B() error { return exec.Command(\"b\").Run() }\n",
+	} {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(bin, "-packages=./a/...,./b", "-output=m", "-force_local_module")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOWORK=off", "GOPROXY=off")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	want := "CAPABILITY_EXEC\nCAPABILITY_READ_SYSTEM_STATE\n"
+	if got := output.String(); got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
+
+// testModule and testModuleVersion are the module served by
+// makeModuleProxy.
+const testModule, testModuleVersion = "example.com/capslocktest", "v1.2.3"
+
+// makeModuleProxy writes testModule to a temporary directory in the format of
+// a module proxy, and returns the directory.
+func makeModuleProxy(t *testing.T) string {
+	t.Helper()
+	proxy := t.TempDir()
+	const module, version = testModule, testModuleVersion
+	const goMod = "module " + module + "\n\ngo 1.21\n"
+	dir := filepath.Join(proxy, module, "@v")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var zipFile bytes.Buffer
+	zw := zip.NewWriter(&zipFile)
+	for name, content := range map[string]string{
+		"go.mod":     goMod,
+		"env/env.go": "package env\n\nimport \"os\"\n\nfunc Env() string { return os.Getenv(\"A\") }\n",
+		"net/net.go": "package net\n\nimport \"net\"\n\nfunc Dial() { net.Dial(\"tcp\", \"localhost:80\") }\n",
+	} {
+		w, err := zw.Create(module + "@" + version + "/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string][]byte{
+		"list":            []byte(version + "\n"),
+		version + ".info": []byte(`{"Version":"` + version + `"}`),
+		version + ".mod":  []byte(goMod),
+		version + ".zip":  zipFile.Bytes(),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return proxy
+}
+
+// moduleProxyEnv returns the environment for a go or capslock command which
+// downloads modules from proxy.
+func moduleProxyEnv(t *testing.T, proxy string) []string {
+	return append(os.Environ(),
+		"GOFLAGS=-modcacherw",
+		"GOMODCACHE="+t.TempDir(),
+		"GOPROXY=file://"+filepath.ToSlash(proxy),
+		"GOSUMDB=off")
+}
+
+func TestModule(t *testing.T) {
+	const module, version = testModule, testModuleVersion
+	proxy := makeModuleProxy(t)
+	for _, spec := range []string{module + "@" + version, module} {
+		cmd := exec.Command(bin, "-module="+spec, "-output=m")
+		cmd.Env = moduleProxyEnv(t, proxy)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("running capslock -module=%s: %v", spec, err)
+		}
+		want := "CAPABILITY_NETWORK_CLIENT\nCAPABILITY_READ_SYSTEM_STATE\n"
+		if got := output.String(); got != want {
+			t.Errorf("capslock -module=%s: got output %q, want %q", spec, got, want)
+		}
+	}
+	if err := exec.Command(bin, "-module="+module, "-packages=../testpkgs/callnet").Run(); err == nil {
+		t.Errorf("capslock with -module and -packages: got no error")
+	}
+}
+
+func TestBinary(t *testing.T) {
+	proxy := makeModuleProxy(t)
+	env := moduleProxyEnv(t, proxy)
+	// build compiles the main package in files, and returns the executable.
+	build := func(files map[string]string) string {
+		t.Helper()
+		dir := t.TempDir()
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		exe := filepath.Join(t.TempDir(), "main.exe")
+		cmd := exec.Command("go", "build", "-mod=mod", "-o", exe, ".")
+		cmd.Dir = dir
+		cmd.Env = env
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("building executable: %v\n%s", err, output)
+		}
+		return exe
+	}
+	withDeps := build(map[string]string{
+		"go.mod": "module example.com/main\n\ngo 1.21\n\nrequire " + testModule + " " + testModuleVersion + "\n",
+		"main.go": "package main\n\nimport (\n\t\"" + testModule + "/env\"\n\t\"" + testModule + "/net\"\n)\n\n" +
+			"func main() {\n\tenv.Env()\n\tnet.Dial()\n}\n",
+	})
+	withoutDeps := build(map[string]string{
+		"go.mod":  "module example.com/main\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	})
+
+	for _, test := range []struct {
+		args          []string
+		expectedError string
+	}{
+		{[]string{"-binary=" + withDeps, "-packages=../testpkgs/callnet"}, "-binary cannot be used together with -packages or -module"},
+		{[]string{"-binary=" + withDeps, "-module=" + testModule}, "-binary cannot be used together with -packages or -module"},
+		{[]string{"-binary=analyzepackages_test.go"}, "reading build information"},
+		{[]string{"-binary=" + filepath.Join(t.TempDir(), "missing")}, "reading build information"},
+		{[]string{"-binary=" + withoutDeps}, "has no dependencies that can be analyzed"},
+	} {
+		cmd := exec.Command(bin, append(test.args, "-output=m")...)
+		cmd.Env = env
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err == nil {
+			t.Errorf("capslock %s: got no error", strings.Join(test.args, " "))
+		} else if !strings.Contains(stderr.String(), test.expectedError) {
+			t.Errorf("capslock %s: got error output %q, want it to contain %q", strings.Join(test.args, " "), stderr.String(), test.expectedError)
+		}
+	}
+
+	cmd := exec.Command(bin, "-binary="+withDeps, "-output=m")
+	cmd.Env = env
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running capslock -binary: %v", err)
+	}
+	want := "CAPABILITY_NETWORK_CLIENT\nCAPABILITY_READ_SYSTEM_STATE\n"
+	if got := output.String(); got != want {
+		t.Errorf("capslock -binary: got output %q, want %q", got, want)
+	}
+}
+
+func TestForbid(t *testing.T) {
+	for _, test := range []struct {
+		forbid           string
+		expectedExitCode int
+		expectedOutput   []string
+	}{
+		{"net.Dial", 0, nil},
+		{"os/exec.Command", 5, []string{
+			"callos.Bar can reach forbidden function os/exec.Command:",
+			`callos.go:\d+:\d+ +os/exec.Command`,
+		}},
+		{"net.Dial,os.Getpid,os/exec.Command", 5, []string{
+			"callos.Foo can reach forbidden function os.Getpid:",
+			"callos.Bar can reach forbidden function os/exec.Command:",
+		}},
+		// The search continues through functions the capability map
+		// classifies, such as os/exec.Command.
+		{"(*os/exec.Cmd).Start", 5, []string{
+			`callos.Bar can reach forbidden function \(\*os/exec.Cmd\).Start:`,
+		}},
+		// Spaces around the names are ignored.
+		{" net.Dial, os/exec.Command ", 5, []string{
+			"callos.Bar can reach forbidden function os/exec.Command:",
+		}},
+		// Names which are not function names, or which name functions that
+		// do not exist, are errors rather than being unreachable.
+		{"FILES", 2, []string{`invalid function name "FILES"`}},
+		{"CAPABILITY_FILES", 2, []string{`invalid function name "CAPABILITY_FILES"`}},
+		{"os.NoSuchFunction", 2, []string{"package os has no function NoSuchFunction"}},
+	} {
+		cmd := exec.Command(bin, "-packages=../testpkgs/callos", "-output=m", "-forbid="+test.forbid)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		code := 0
+		if err, ok := err.(*exec.ExitError); ok {
+			code = err.ExitCode()
+		} else if err != nil {
+			t.Fatalf("running capslock: %v", err)
+		}
+		if code != test.expectedExitCode {
+			t.Errorf("capslock -forbid=%s: got exit code %d, want %d; stderr %q", test.forbid, code, test.expectedExitCode, stderr.String())
+		}
+		for _, expected := range test.expectedOutput {
+			if ok, err := regexp.MatchString(expected, stderr.String()); err != nil {
+				t.Errorf("parsing expression %q: %v", expected, err)
+			} else if !ok {
+				t.Errorf("capslock -forbid=%s: expected output matching %q, got %q", test.forbid, expected, stderr.String())
+			}
+		}
+	}
+}
+
+func TestReportUnusedMapEntries(t *testing.T) {
+	capabilityMap := filepath.Join(t.TempDir(), "custom.cm")
+	const content = `func os.Getpid CAPABILITY_SAFE
+func os.NoSuchFunction CAPABILITY_SAFE
+package example.com/nothere CAPABILITY_FILES
+`
+	if err := os.WriteFile(capabilityMap, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(bin, "-packages=../testpkgs/callos", "-output=m", "-capability_map="+capabilityMap, "-report-unused-map-entries")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	var got []string
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		if e, ok := strings.CutPrefix(line, "\t"); ok {
+			got = append(got, e)
+		}
+	}
+	want := []string{
+		"func os.NoSuchFunction CAPABILITY_SAFE",
+		"package example.com/nothere CAPABILITY_FILES",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got unused entries %q, want %q", got, want)
+	}
+}
+
+func TestDefineCapability(t *testing.T) {
+	capabilityMap := filepath.Join(t.TempDir(), "custom.cm")
+	const content = `define_capability USER_LOOKUP
+func os/user.Current USER_LOOKUP
+`
+	if err := os.WriteFile(capabilityMap, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(bin, "-packages=../testpkgs/callos", "-output=json", "-capability_map="+capabilityMap).Output()
+	if err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	cil := new(cpb.CapabilityInfoList)
+	if err := protojson.Unmarshal(out, cil); err != nil {
+		t.Fatalf("parsing output: %v", err)
+	}
+	var got []string
+	for _, ci := range cil.GetCapabilityInfo() {
+		if ci.GetCapability() == cpb.Capability_CAPABILITY_CUSTOM {
+			got = append(got, ci.GetPath()[0].GetName()+" "+ci.GetCustomCapability())
+		}
+	}
+	want := []string{"github.com/google/capslock/testpkgs/callos.Baz USER_LOOKUP"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got custom capabilities %q, want %q", got, want)
+	}
+	out, err = exec.Command(bin, "-packages=../testpkgs/callos", "-output=v", "-color=never", "-capabilities=USER_LOOKUP", "-capability_map="+capabilityMap).Output()
+	if err != nil {
+		t.Fatalf("running capslock: %v", err)
+	}
+	if !bytes.Contains(out, []byte("\nUSER_LOOKUP: 1 references")) {
+		t.Errorf("verbose output does not report USER_LOOKUP:\n%s", out)
+	}
+}
+
+func TestCgoFlag(t *testing.T) {
+	for _, test := range []struct {
+		cgo  string
+		want string
+	}{
+		{"0", "CAPABILITY_READ_SYSTEM_STATE\n"},
+		{"1", "CAPABILITY_NETWORK\n"},
+	} {
+		out, err := exec.Command(bin, "-packages=../testpkgs/cgoswitch", "-output=m", "-cgo="+test.cgo).Output()
+		if err != nil {
+			t.Fatalf("running capslock -cgo=%s: %v", test.cgo, err)
+		}
+		if got := string(out); got != test.want {
+			t.Errorf("capslock -cgo=%s: got output %q, want %q", test.cgo, got, test.want)
+		}
+	}
+}
+
+func TestExcludeBuildTag(t *testing.T) {
+	const (
+		demoExec = "github.com/google/capslock/testpkgs/usedemo.Demo\tCAPABILITY_EXEC\n"
+		demoRead = "github.com/google/capslock/testpkgs/usedemo.Demo\tCAPABILITY_READ_SYSTEM_STATE\n"
+		hostname = "github.com/google/capslock/testpkgs/usedemo.Hostname\tCAPABILITY_READ_SYSTEM_STATE\n"
+	)
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{nil, hostname},
+		{[]string{"-buildtags=example"}, demoRead + hostname + demoExec},
+		{[]string{"-buildtags=example", "-exclude-build-tag=example"}, hostname},
+	} {
+		args := append([]string{"-packages=../testpkgs/usedemo", "-output=machine-functions"}, test.args...)
+		out, err := exec.Command(bin, args...).Output()
+		if err != nil {
+			t.Fatalf("running capslock %q: %v", args, err)
+		}
+		if got := string(out); got != test.want {
+			t.Errorf("capslock %q: got %q, want %q", args, got, test.want)
+		}
+	}
+}
+
+func TestFailOnUnanalyzed(t *testing.T) {
+	for _, test := range []struct {
+		pkg              string
+		expectedExitCode int
+	}{
+		{"../testpkgs/transitive", 4},
+		{"../testpkgs/callnet", 0},
+	} {
+		cmd := exec.Command(bin, "-packages="+test.pkg, "-output=m", "-fail-on-unanalyzed")
+		err := cmd.Run()
+		code := 0
+		if err, ok := err.(*exec.ExitError); ok {
+			code = err.ExitCode()
+		} else if err != nil {
+			t.Fatalf("running capslock: %v", err)
+		}
+		if code != test.expectedExitCode {
+			t.Errorf("capslock -packages=%s -fail-on-unanalyzed: got exit code %d, want %d", test.pkg, code, test.expectedExitCode)
+		}
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		"custom.cm": "func example.com/a.F CAPABILITY_NETWORK\n",
+		"capslock.yaml": "# Settings for capslock.\n" +
+			"capability_map:\n" +
+			"  - custom.cm\n" +
+			"disable_builtin: true\n" +
+			"print-capability-map: true\n",
+		"other.toml": `capability_map = ["custom.cm"]` + "\n" +
+			"disable_builtin = true\n" +
+			"print-capability-map = true\n",
+		"bad.yaml": "no-such-flag: true\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	custom := "func example.com/a.F CAPABILITY_NETWORK\n"
+	for _, test := range []struct {
+		args []string
+		want func(string) bool
+	}{
+		{nil, func(out string) bool { return out == custom }},
+		{[]string{"-config=other.toml"}, func(out string) bool { return out == custom }},
+		// Flags set on the command line override the configuration file.
+		{[]string{"-disable_builtin=false"}, func(out string) bool {
+			return strings.Contains(out, custom) && strings.Contains(out, "func os.Getenv ")
+		}},
+	} {
+		cmd := exec.Command(bin, test.args...)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("running capslock %q: %v", test.args, err)
+		}
+		if !test.want(string(out)) {
+			t.Errorf("capslock %q: got unexpected output %q", test.args, out)
+		}
+	}
+	cmd := exec.Command(bin, "-config=bad.yaml")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), `unknown setting "no-such-flag"`) {
+		t.Errorf("capslock -config=bad.yaml: got error %v and output %q, want an unknown setting error", err, out)
+	}
+}
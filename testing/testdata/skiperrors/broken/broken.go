@@ -0,0 +1,16 @@
+// Copyright 2023 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package broken is used for testing, and intentionally fails to type-check.
+package broken
+
+import "net"
+
+// Foo is a test function.
+func Foo() int {
+	ips, _ := net.LookupIP("localhost")
+	return ips
+}
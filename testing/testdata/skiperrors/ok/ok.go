@@ -0,0 +1,15 @@
+// Copyright 2023 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package ok is used for testing.
+package ok
+
+import "os"
+
+// Foo is a test function.
+func Foo() int {
+	return os.Getpid()
+}
@@ -0,0 +1,21 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usesbroken is used for testing.  It type-checks, but depends on a
+// package which does not.
+package usesbroken
+
+import (
+	"os"
+
+	"github.com/google/capslock/testing/testdata/skiperrors/broken"
+)
+
+// Foo is a test function.
+func Foo() ([]byte, error) {
+	broken.Foo()
+	return os.ReadFile("/tmp/x")
+}
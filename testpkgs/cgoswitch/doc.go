@@ -0,0 +1,9 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package cgoswitch is used for testing.  It has different implementations
+// of Lookup depending on whether cgo is enabled.
+package cgoswitch
@@ -0,0 +1,17 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+//go:build cgo
+
+package cgoswitch
+
+import "net"
+
+// Lookup is a test function which calls net.LookupHost.
+func Lookup(host string) int {
+	addrs, _ := net.LookupHost(host)
+	return len(addrs)
+}
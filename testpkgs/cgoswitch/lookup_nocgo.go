@@ -0,0 +1,16 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+//go:build !cgo
+
+package cgoswitch
+
+import "os"
+
+// Lookup is a test function which calls os.Getenv.
+func Lookup(host string) int {
+	return len(os.Getenv(host))
+}
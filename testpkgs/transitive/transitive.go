@@ -13,6 +13,7 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -227,6 +228,66 @@ func UninterestingSortSliceStable() int {
 	return f[0]
 }
 
+// InterestingSortFunc calls slices.SortFunc with an argument that has an
+// interesting capability.
+func InterestingSortFunc() int {
+	f := bar{1}
+	slices.SortFunc(f, func(a, b int) int { os.Getenv("foo"); return 0 })
+	return f[0]
+}
+
+// UninterestingSortFunc calls slices.SortFunc with an argument that has no
+// interesting capabilities.
+func UninterestingSortFunc() int {
+	f := bar{1}
+	slices.SortFunc(f, func(a, b int) int { return 0 })
+	return f[0]
+}
+
+// InterestingSortFuncNested calls slices.SortFunc with an argument that
+// itself calls slices.SortFunc.  The inner sort's function argument has an
+// interesting capability.
+func InterestingSortFuncNested() int {
+	f := []bar{bar{1, 2}, bar{3, 4}}
+	slices.SortFunc(f, func(a, b bar) int {
+		for _, x := range [2]bar{a, b} {
+			slices.SortFunc(x, func(a, b int) int { os.Getenv("foo"); return a - b })
+		}
+		return a[0] - b[0]
+	})
+	return f[0][0]
+}
+
+// UninterestingSortFuncNested calls slices.SortFunc with an argument that
+// itself calls slices.SortFunc.  The inner sort's function argument has no
+// interesting capabilities.
+func UninterestingSortFuncNested() int {
+	f := []bar{bar{1, 2}, bar{3, 4}}
+	slices.SortFunc(f, func(a, b bar) int {
+		for _, x := range [2]bar{a, b} {
+			slices.SortFunc(x, func(a, b int) int { return a - b })
+		}
+		return a[0] - b[0]
+	})
+	return f[0][0]
+}
+
+// InterestingSortStableFunc calls slices.SortStableFunc with an argument that
+// has an interesting capability.
+func InterestingSortStableFunc() int {
+	f := bar{1}
+	slices.SortStableFunc(f, func(a, b int) int { os.Getenv("foo"); return 0 })
+	return f[0]
+}
+
+// UninterestingSortStableFunc calls slices.SortStableFunc with an argument
+// that has no interesting capabilities.
+func UninterestingSortStableFunc() int {
+	f := bar{1}
+	slices.SortStableFunc(f, func(a, b int) int { return 0 })
+	return f[0]
+}
+
 // InterestingSyncPool calls Get on a Pool whose New function has an
 // interesting capability.
 func InterestingSyncPool() int {
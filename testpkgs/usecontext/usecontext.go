@@ -0,0 +1,50 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usecontext is used for testing.
+package usecontext
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+type key struct{}
+
+// EnvContext is a context whose values come from the environment.
+type EnvContext struct {
+	context.Context
+}
+
+// Value is a test function.
+func (EnvContext) Value(k any) any {
+	return os.Getenv("USECONTEXT")
+}
+
+// NewEnv is a test function.
+func NewEnv() (context.Context, context.CancelFunc) {
+	return context.WithCancel(EnvContext{context.Background()})
+}
+
+// Env is a test function.
+func Env(ctx EnvContext) any {
+	return ctx.Value(key{})
+}
+
+// Cancel is a test function.  Since EnvContext is passed to
+// context.WithCancel elsewhere, the callgraph connects the contexts used here
+// to EnvContext.Value, but they can never be an EnvContext.
+func Cancel() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctx, cancel2 := context.WithTimeout(ctx, time.Second)
+	defer cancel2()
+	ctx = context.WithValue(ctx, key{}, "value")
+	_ = ctx.Value(key{})
+	<-ctx.Done()
+	return ctx.Err()
+}
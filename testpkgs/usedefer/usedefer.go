@@ -0,0 +1,61 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usedefer is used for testing.
+package usedefer
+
+import (
+	"io"
+	"os"
+)
+
+type pidCloser struct{}
+
+func (pidCloser) Close() error {
+	os.Getpid()
+	return nil
+}
+
+// DeferInterfaceCall is a test function.
+func DeferInterfaceCall() {
+	var c io.Closer = pidCloser{}
+	defer c.Close()
+}
+
+// DeferInterfaceParameter is a test function.
+func DeferInterfaceParameter(c io.Closer) {
+	defer c.Close()
+}
+
+// CallDeferInterfaceParameter is a test function.
+func CallDeferInterfaceParameter() {
+	DeferInterfaceParameter(pidCloser{})
+}
+
+// DeferFuncValue is a test function.
+func DeferFuncValue() {
+	f := os.Getuid
+	defer f()
+}
+
+// DeferClosure is a test function.
+func DeferClosure() {
+	defer func() {
+		os.Getpid()
+	}()
+}
+
+func seq(yield func(int) bool) {
+	yield(0)
+}
+
+// DeferInRangeOverFunc is a test function.
+func DeferInRangeOverFunc() {
+	for range seq {
+		var c io.Closer = pidCloser{}
+		defer c.Close()
+	}
+}
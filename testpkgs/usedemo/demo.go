@@ -0,0 +1,16 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+//go:build example
+
+package usedemo
+
+import "os/exec"
+
+// Demo runs a command to demonstrate the package.
+func Demo() error {
+	return exec.Command("echo", Hostname()).Run()
+}
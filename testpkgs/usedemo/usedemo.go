@@ -0,0 +1,17 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usedemo is used for testing.  It contains demo code which is only
+// built with the "example" build tag.
+package usedemo
+
+import "os"
+
+// Hostname returns the host name.
+func Hostname() string {
+	h, _ := os.Hostname()
+	return h
+}
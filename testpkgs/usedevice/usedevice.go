@@ -0,0 +1,64 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usedevice is used for testing.
+package usedevice
+
+import (
+	"io"
+	"os"
+)
+
+// Status is a test function.
+func Status() ([]byte, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// Random is a test function.
+func Random(b []byte) error {
+	f, err := os.OpenFile("/dev/urandom", os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.ReadFull(f, b)
+	return err
+}
+
+// ReadStatus is a test function.
+func ReadStatus() ([]byte, error) {
+	return os.ReadFile("/proc/self/status")
+}
+
+// SetPrintk is a test function.
+func SetPrintk() error {
+	return os.WriteFile("/proc/sys/kernel/printk", []byte("4\n"), 0)
+}
+
+// CreateNull is a test function.
+func CreateNull() (*os.File, error) {
+	return os.Create("/dev/null")
+}
+
+// Processes is a test function.
+func Processes() ([]os.DirEntry, error) {
+	return os.ReadDir("/proc")
+}
+
+// Ordinary is a test function.
+func Ordinary() (*os.File, error) {
+	return os.Open("/devices.txt")
+}
+
+// ReadOrdinary is a test function.
+func ReadOrdinary() ([]byte, error) {
+	return os.ReadFile("/devices.txt")
+}
@@ -0,0 +1,19 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+//go:build linux
+
+package useexecreplace
+
+import (
+	"os"
+	"syscall"
+)
+
+// Replace is a test function.
+func Replace(path string, args []string) error {
+	return syscall.Exec(path, args, os.Environ())
+}
@@ -0,0 +1,17 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usegenerate is used for testing.
+package usegenerate
+
+//go:generate echo "generating colors"
+//go:generate go version
+
+// Color is a test type.
+type Color int
+
+// Red is a test constant.
+const Red Color = 1
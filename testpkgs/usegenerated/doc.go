@@ -0,0 +1,15 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usegenerated is used for testing.
+package usegenerated
+
+import "os"
+
+// Handwritten is a test function.
+func Handwritten() string {
+	return os.Getenv("HANDWRITTEN")
+}
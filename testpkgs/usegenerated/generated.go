@@ -0,0 +1,10 @@
+// Code generated by hand for testing. DO NOT EDIT.
+
+package usegenerated
+
+import "net"
+
+// Dial is a test function.
+func Dial() (net.Conn, error) {
+	return net.Dial("tcp", "localhost:80")
+}
@@ -0,0 +1,14 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+package usegenerated
+
+import "os"
+
+// Message is a test function.
+func Message() string {
+	return os.Getenv("MESSAGE")
+}
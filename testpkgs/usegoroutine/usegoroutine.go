@@ -0,0 +1,24 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usegoroutine is used for testing.
+package usegoroutine
+
+import "os"
+
+// Background looks up an environment variable on a new goroutine.
+func Background() {
+	go lookup()
+}
+
+// Foreground looks up an environment variable.
+func Foreground() string {
+	return lookup()
+}
+
+func lookup() string {
+	return os.Getenv("HOME")
+}
@@ -0,0 +1,45 @@
+// Copyright 2023 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usehttp is used for testing.
+package usehttp
+
+import (
+	"net"
+	"net/http"
+)
+
+// Client is a test function which makes an outbound request.
+func Client() error {
+	resp, err := http.Get("http://localhost/")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// Server is a test function which listens for inbound connections.
+func Server() error {
+	return http.ListenAndServe("localhost:0", nil)
+}
+
+// Dial is a test function which makes an outbound connection.
+func Dial() error {
+	c, err := net.Dial("tcp", "localhost:0")
+	if err != nil {
+		return err
+	}
+	return c.Close()
+}
+
+// Listen is a test function which listens for inbound connections.
+func Listen() error {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
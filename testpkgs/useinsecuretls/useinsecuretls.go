@@ -0,0 +1,33 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package useinsecuretls is used for testing.
+package useinsecuretls
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// Literal is a test function.
+func Literal() *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+}
+
+// Assignment is a test function.
+func Assignment(t *http.Transport) {
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = new(tls.Config)
+	}
+	t.TLSClientConfig.InsecureSkipVerify = true
+}
+
+// Secure is a test function.
+func Secure() *tls.Config {
+	return &tls.Config{InsecureSkipVerify: false, MinVersion: tls.VersionTLS12}
+}
@@ -0,0 +1,35 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package uselog is used for testing the classification of the log package.
+package uselog
+
+import (
+	"log"
+	"os"
+)
+
+// Default logs a message with the standard logger, which writes to standard
+// error.
+func Default() {
+	log.Printf("hello %d", 1)
+}
+
+// ToStderr creates a logger which writes to standard error.  Like any other
+// *os.File passed as an io.Writer, os.Stderr is treated as a file.
+func ToStderr() *log.Logger {
+	return log.New(os.Stderr, "uselog: ", 0)
+}
+
+// ToFile creates a logger which writes to a file.
+func ToFile(f *os.File) *log.Logger {
+	return log.New(f, "uselog: ", 0)
+}
+
+// RedirectToFile makes the standard logger write to a file.
+func RedirectToFile(f *os.File) {
+	log.SetOutput(f)
+}
@@ -0,0 +1,19 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+//go:build linux
+
+package usemmap
+
+import (
+	"os"
+	"syscall"
+)
+
+// Map is a test function.
+func Map(f *os.File) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, 4096, syscall.PROT_READ, syscall.MAP_SHARED)
+}
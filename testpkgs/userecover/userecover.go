@@ -0,0 +1,25 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package userecover is used for testing.
+package userecover
+
+import "os/exec"
+
+// Cleanup calls f, and runs a cleanup command if f panics.
+func Cleanup(f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			exec.Command("cleanup").Run()
+		}
+	}()
+	f()
+}
+
+// Run runs a command.
+func Run() error {
+	return exec.Command("run").Run()
+}
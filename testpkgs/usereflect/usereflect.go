@@ -410,3 +410,29 @@ func RangeValueTwo() {
 	}()
 	_ = x
 }
+
+// CallViaReflect calls a function with capabilities using reflect.Value.Call.
+func CallViaReflect() int {
+	results := reflect.ValueOf(callnet.Foo).Call(nil)
+	return int(results[0].Int())
+}
+
+type hidden struct {
+	Exported   int
+	unexported int
+}
+
+// ReadUnexportedField reads an unexported field of a struct, using
+// reflect.NewAt and an unsafe pointer to get around the restrictions reflect
+// places on accessing unexported fields.
+func ReadUnexportedField() int {
+	h := hidden{1, 2}
+	f := reflect.ValueOf(&h).Elem().FieldByName("unexported")
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem().Interface().(int)
+}
+
+// ReadExportedField reads an exported field of a struct with reflect.
+func ReadExportedField() int {
+	h := hidden{1, 2}
+	return int(reflect.ValueOf(h).Field(0).Int())
+}
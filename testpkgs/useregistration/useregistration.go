@@ -0,0 +1,25 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package useregistration is used for testing.
+package useregistration
+
+import (
+	"encoding/gob"
+	"net/http"
+)
+
+// T is a test type.
+type T struct{ X int }
+
+func init() {
+	gob.Register(T{})
+}
+
+// Handle is a test function.
+func Handle() {
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+}
@@ -0,0 +1,20 @@
+// Copyright 2023 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usesignal is used for testing.
+package usesignal
+
+import (
+	"os"
+	"os/signal"
+)
+
+// Foo is a test function.
+func Foo() chan os.Signal {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	return c
+}
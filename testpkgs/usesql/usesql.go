@@ -0,0 +1,40 @@
+// Copyright 2023 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usesql is used for testing.
+package usesql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+)
+
+// stubDriver is a database driver which does nothing.  Like a real driver, it
+// is registered in an init function, so the callgraph does not connect calls
+// to database/sql with the driver.
+type stubDriver struct{}
+
+func (stubDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("not implemented")
+}
+
+func init() {
+	sql.Register("stub", stubDriver{})
+}
+
+// Foo is a test function.
+func Foo() error {
+	db, err := sql.Open("stub", "")
+	if err != nil {
+		return err
+	}
+	rows, err := db.Query("SELECT 1")
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
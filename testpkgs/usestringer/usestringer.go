@@ -0,0 +1,47 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usestringer is used for testing the analysis of calls to String
+// and Error methods through the fmt.Stringer and error interfaces.
+package usestringer
+
+import (
+	"fmt"
+	"net"
+)
+
+// Format formats a value with package fmt, in the way an encoder for a format
+// such as TOML might do.  It has no capabilities of its own, but the call to
+// String made by fmt can reach the String method of any type whose values
+// are formatted anywhere in the program, including net.pipeAddr.
+func Format(v any) string {
+	return fmt.Sprint(v)
+}
+
+// Encode calls the String or Error method of a value itself.  Unlike the
+// calls made by package fmt, these calls are followed even with
+// -ignore-string-methods.
+func Encode(v any) string {
+	switch v := v.(type) {
+	case fmt.Stringer:
+		return v.String()
+	case error:
+		return v.Error()
+	}
+	return ""
+}
+
+// FormatAddress formats the local address of an in-memory connection.
+func FormatAddress() string {
+	c, _ := net.Pipe()
+	return Format(c.LocalAddr())
+}
+
+// EncodeAddress encodes the local address of an in-memory connection.
+func EncodeAddress() string {
+	c, _ := net.Pipe()
+	return Encode(c.LocalAddr())
+}
@@ -79,6 +79,16 @@ func CallNestedFunctions() float32 {
 	return NestedFunctions()()()()
 }
 
+// Slice makes a slice of the n ints starting at p using unsafe.Slice.
+func Slice(p *int, n int) []int {
+	return unsafe.Slice(p, n)
+}
+
+// Add returns the pointer n bytes after p using unsafe.Add.
+func Add(p unsafe.Pointer, n int) unsafe.Pointer {
+	return unsafe.Add(p, n)
+}
+
 // Ok converts an unsafe.Pointer to a uintptr.
 func Ok() uintptr {
 	var p unsafe.Pointer
@@ -92,3 +102,8 @@ type T struct{}
 func (t T) M() int {
 	return *(*int)(U)
 }
+
+// Roundtrip converts a pointer to a uintptr and back to a pointer.
+func Roundtrip(x *[2]int) *int {
+	return (*int)(unsafe.Pointer(uintptr(unsafe.Pointer(&x[0])) + unsafe.Sizeof(x[0])))
+}
@@ -0,0 +1,39 @@
+// Copyright 2024 Google LLC
+//
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file or at
+// https://developers.google.com/open-source/licenses/bsd
+
+// Package usewriter is used for testing.
+package usewriter
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// write writes a message to w, which can be any kind of writer.
+func write(w io.Writer) {
+	w.Write([]byte("hello\n"))
+}
+
+// WriteFile writes a message to the file f.
+func WriteFile(f *os.File) {
+	write(f)
+}
+
+// WriteStdout writes a message to standard output.
+func WriteStdout() {
+	write(os.Stdout)
+}
+
+// FprintfFile writes a message to the file f with fmt.Fprintf.
+func FprintfFile(f *os.File) {
+	fmt.Fprintf(f, "hello\n")
+}
+
+// FprintfStdout writes a message to standard output with fmt.Fprintf.
+func FprintfStdout() {
+	fmt.Fprintf(os.Stdout, "hello\n")
+}